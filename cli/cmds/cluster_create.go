@@ -47,6 +47,7 @@ type CreateConfig struct {
 	mirrorHostNodes      bool
 	customCertsPath      string
 	timeout              time.Duration
+	ttl                  time.Duration
 }
 
 func NewClusterCreateCmd(appCtx *AppContext) *cobra.Command {
@@ -203,6 +204,10 @@ func newCluster(name, namespace string, config *CreateConfig) *v1beta1.Cluster {
 		cluster.Spec.Persistence.StorageClassName = nil
 	}
 
+	if config.ttl > 0 {
+		cluster.Spec.TTL = &metav1.Duration{Duration: config.ttl}
+	}
+
 	if config.token != "" {
 		cluster.Spec.TokenSecretRef = &v1.SecretReference{
 			Name:      k3kcluster.TokenSecretName(name),
@@ -30,6 +30,7 @@ func createFlags(cmd *cobra.Command, cfg *CreateConfig) {
 	cmd.Flags().StringVar(&cfg.policy, "policy", "", "The policy to create the cluster in")
 	cmd.Flags().StringVar(&cfg.customCertsPath, "custom-certs", "", "The path for custom certificate directory")
 	cmd.Flags().DurationVar(&cfg.timeout, "timeout", 3*time.Minute, "The timeout for waiting for the cluster to become ready (e.g., 10s, 5m, 1h).")
+	cmd.Flags().DurationVar(&cfg.ttl, "ttl", 0, "The lifetime of the cluster, after which it is deleted (e.g., 30m, 2h). Disabled if 0.")
 }
 
 func validateCreateConfig(cfg *CreateConfig) error {
@@ -6,18 +6,19 @@ import (
 
 // config has all virtual-kubelet startup options
 type config struct {
-	ClusterName      string `mapstructure:"clusterName"`
-	ClusterNamespace string `mapstructure:"clusterNamespace"`
-	ServiceName      string `mapstructure:"serviceName"`
-	Token            string `mapstructure:"token"`
-	AgentHostname    string `mapstructure:"agentHostname"`
-	HostKubeconfig   string `mapstructure:"hostKubeconfig"`
-	VirtKubeconfig   string `mapstructure:"virtKubeconfig"`
-	KubeletPort      int    `mapstructure:"kubeletPort"`
-	WebhookPort      int    `mapstructure:"webhookPort"`
-	ServerIP         string `mapstructure:"serverIP"`
-	Version          string `mapstructure:"version"`
-	MirrorHostNodes  bool   `mapstructure:"mirrorHostNodes"`
+	ClusterName      string   `mapstructure:"clusterName"`
+	ClusterNamespace string   `mapstructure:"clusterNamespace"`
+	ServiceName      string   `mapstructure:"serviceName"`
+	Token            string   `mapstructure:"token"`
+	AgentHostname    string   `mapstructure:"agentHostname"`
+	HostKubeconfig   string   `mapstructure:"hostKubeconfig"`
+	VirtKubeconfig   string   `mapstructure:"virtKubeconfig"`
+	KubeletPort      int      `mapstructure:"kubeletPort"`
+	WebhookPort      int      `mapstructure:"webhookPort"`
+	ServerIP         string   `mapstructure:"serverIP"`
+	Version          string   `mapstructure:"version"`
+	MirrorHostNodes  bool     `mapstructure:"mirrorHostNodes"`
+	HostNamespaces   []string `mapstructure:"hostNamespaces"`
 }
 
 func (c *config) validate() error {
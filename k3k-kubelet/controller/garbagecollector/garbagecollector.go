@@ -0,0 +1,285 @@
+package garbagecollector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rancher/k3k/k3k-kubelet/provider"
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller"
+)
+
+const (
+	// collectInterval is the interval between the garbage collections.
+	collectInterval = 5 * time.Minute
+
+	// minOrphanAge is the minimum age of an orphaned host object. Younger objects are skipped, since their virtual
+	// object could be not visible yet.
+	minOrphanAge = time.Minute
+
+	// OrphanedHostObjectReason is the reason of the events recorded on the cluster for the orphaned host objects
+	// of the opt-in kinds not deleted.
+	OrphanedHostObjectReason = "OrphanedHostObject"
+)
+
+// DefaultKinds are the kinds of the host objects always synced from the virtual cluster and collected when orphaned.
+// The VolumeSnapshots are never collected, since deleting them can delete the data of the virtual cluster, e.g. if its
+// datastore is restored from an outdated backup.
+var DefaultKinds = []schema.GroupVersionKind{
+	corev1.SchemeGroupVersion.WithKind("Pod"),
+	corev1.SchemeGroupVersion.WithKind("Secret"),
+	corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+	corev1.SchemeGroupVersion.WithKind("Service"),
+	networkingv1.SchemeGroupVersion.WithKind("Ingress"),
+}
+
+// OptInKinds are the kinds of the host objects always reported when orphaned, but collected only if listed in the
+// additionalKinds of the garbage collection config, since deleting them can delete the data of the virtual cluster.
+var OptInKinds = []schema.GroupVersionKind{
+	corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"),
+}
+
+// Orphan is a host object whose virtual object doesn't exist anymore.
+type Orphan struct {
+	GroupVersionKind schema.GroupVersionKind
+	Name             string
+	Namespace        string
+	// VirtualObject is the virtual object the host object was synced from.
+	VirtualObject types.NamespacedName
+}
+
+// GarbageCollector deletes periodically the host objects of a virtual cluster whose virtual objects don't exist anymore,
+// i.e. deleted while the k3k-kubelet was down or after a restore of the virtual cluster datastore.
+type GarbageCollector struct {
+	ClusterName      string
+	ClusterNamespace string
+	// Kinds are the kinds of the collected host objects.
+	Kinds      []schema.GroupVersionKind
+	HostClient client.Client
+	// HostReader and VirtualReader are used to read the objects from the API servers, bypassing the caches.
+	HostReader    client.Reader
+	VirtualReader client.Reader
+	// EventRecorder records the orphaned host objects of the opt-in kinds not deleted on the cluster.
+	EventRecorder record.EventRecorder
+	Logger        logr.Logger
+}
+
+// AddGarbageCollector adds the garbage collector of the orphaned host objects of the given kinds to the host manager.
+func AddGarbageCollector(virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string, kinds []schema.GroupVersionKind) error {
+	gc := &GarbageCollector{
+		ClusterName:      clusterName,
+		ClusterNamespace: clusterNamespace,
+		Kinds:            kinds,
+		HostClient:       hostMgr.GetClient(),
+		HostReader:       hostMgr.GetAPIReader(),
+		VirtualReader:    virtMgr.GetAPIReader(),
+		EventRecorder:    hostMgr.GetEventRecorderFor("garbage-collector"),
+		Logger:           ctrl.Log.WithName("garbage-collector").WithValues("cluster", clusterName, "clusterNamespace", clusterNamespace),
+	}
+
+	return hostMgr.Add(gc)
+}
+
+// Start runs the garbage collection periodically, until the context is done.
+func (g *GarbageCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(collectInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := g.Collect(ctx); err != nil {
+				g.Logger.Error(err, "failed to collect orphaned host objects")
+			}
+		}
+	}
+}
+
+// Collect finds the orphaned host objects and deletes them, according to the garbage collection config of the cluster.
+// In dry-run mode the orphaned objects are only reported. The orphaned objects of the opt-in kinds are also reported
+// with an event on the cluster when they are not deleted. The orphaned objects are returned.
+func (g *GarbageCollector) Collect(ctx context.Context) ([]Orphan, error) {
+	var cluster v1beta1.Cluster
+	if err := g.HostReader.Get(ctx, types.NamespacedName{Name: g.ClusterName, Namespace: g.ClusterNamespace}, &cluster); err != nil {
+		return nil, err
+	}
+
+	var config v1beta1.GarbageCollectionConfig
+	if syncConfig := controller.SyncConfig(&cluster); syncConfig != nil {
+		config = syncConfig.GarbageCollection
+	}
+
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	// the host objects are in the dedicated host namespaces of the cluster with the PerNamespace host namespace mode
+	orphans, err := g.orphans(ctx, translate.NewHostTranslator(&cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+
+	for _, orphan := range orphans {
+		log := g.Logger.WithValues("kind", orphan.GroupVersionKind.Kind, "name", orphan.Name, "namespace", orphan.Namespace, "virtualObject", orphan.VirtualObject.String())
+
+		keepReason, err := g.keepReason(ctx, config, orphan)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if keepReason != "" {
+			log.Info("found orphaned host object, not deleted: " + keepReason)
+
+			if slices.Contains(OptInKinds, orphan.GroupVersionKind) {
+				g.EventRecorder.Eventf(&cluster, corev1.EventTypeWarning, OrphanedHostObjectReason,
+					"Orphaned host %s %s/%s of the deleted virtual object %s not deleted: %s",
+					orphan.GroupVersionKind.Kind, orphan.Namespace, orphan.Name, orphan.VirtualObject.String(), keepReason)
+			}
+
+			continue
+		}
+
+		log.Info("deleting orphaned host object")
+
+		obj := &metav1.PartialObjectMetadata{}
+		obj.SetGroupVersionKind(orphan.GroupVersionKind)
+		obj.SetName(orphan.Name)
+		obj.SetNamespace(orphan.Namespace)
+
+		if err := g.HostClient.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return orphans, errors.Join(errs...)
+}
+
+// keepReason returns why an orphaned host object is not deleted, or an empty string if it can be deleted.
+// The orphaned objects of the opt-in kinds are deleted only if listed in the additional kinds of the config, and the
+// PersistentVolumeClaims only if their PersistentVolume has the Retain reclaim policy, keeping the data.
+func (g *GarbageCollector) keepReason(ctx context.Context, config v1beta1.GarbageCollectionConfig, orphan Orphan) (string, error) {
+	if config.DryRun {
+		return "dry-run mode", nil
+	}
+
+	if !slices.Contains(OptInKinds, orphan.GroupVersionKind) {
+		return "", nil
+	}
+
+	if !slices.Contains(config.AdditionalKinds, v1beta1.GarbageCollectedKind(orphan.GroupVersionKind.Kind)) {
+		return "kind not listed in the additionalKinds of the garbage collection", nil
+	}
+
+	if orphan.GroupVersionKind.Kind != "PersistentVolumeClaim" {
+		return "", nil
+	}
+
+	var pvc corev1.PersistentVolumeClaim
+	if err := g.HostReader.Get(ctx, types.NamespacedName{Name: orphan.Name, Namespace: orphan.Namespace}, &pvc); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+
+	if pvc.Spec.VolumeName == "" {
+		return "", nil
+	}
+
+	var pv corev1.PersistentVolume
+	if err := g.HostReader.Get(ctx, types.NamespacedName{Name: pvc.Spec.VolumeName}, &pv); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("failed to get PersistentVolume %s: %w", pvc.Spec.VolumeName, err)
+	}
+
+	if pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+		return fmt.Sprintf("PersistentVolume %s has the %s reclaim policy", pv.Name, pv.Spec.PersistentVolumeReclaimPolicy), nil
+	}
+
+	return "", nil
+}
+
+// orphans returns the host objects of the virtual cluster whose virtual objects don't exist, of the collected
+// and of the opt-in kinds.
+func (g *GarbageCollector) orphans(ctx context.Context, translator *translate.ToHostTranslator) ([]Orphan, error) {
+	var orphans []Orphan
+
+	for _, gvk := range append(slices.Clone(g.Kinds), OptInKinds...) {
+		hostObjects := &metav1.PartialObjectMetadataList{}
+		hostObjects.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+
+		if err := g.HostReader.List(ctx, hostObjects, translator.ListOptions(nil)...); err != nil {
+			return nil, err
+		}
+
+		for _, hostObject := range hostObjects.Items {
+			if time.Since(hostObject.CreationTimestamp.Time) < minOrphanAge || !hostObject.DeletionTimestamp.IsZero() {
+				continue
+			}
+
+			virtualGVK, virtualKey, found := virtualObject(gvk, &hostObject)
+			if !found {
+				continue
+			}
+
+			virtualObject := &metav1.PartialObjectMetadata{}
+			virtualObject.SetGroupVersionKind(virtualGVK)
+
+			err := g.VirtualReader.Get(ctx, virtualKey, virtualObject)
+			if err == nil {
+				continue
+			}
+
+			if !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+
+			orphans = append(orphans, Orphan{
+				GroupVersionKind: gvk,
+				Name:             hostObject.Name,
+				Namespace:        hostObject.Namespace,
+				VirtualObject:    virtualKey,
+			})
+		}
+	}
+
+	return orphans, nil
+}
+
+// virtualObject returns the kind and the key of the virtual object of a host object, from its annotations.
+// The Secrets with the service account tokens of the pods belong to the virtual pods.
+func virtualObject(gvk schema.GroupVersionKind, hostObject *metav1.PartialObjectMetadata) (schema.GroupVersionKind, types.NamespacedName, bool) {
+	key := types.NamespacedName{
+		Name:      hostObject.Annotations[translate.ResourceNameAnnotation],
+		Namespace: hostObject.Annotations[translate.ResourceNamespaceAnnotation],
+	}
+
+	if gvk.Kind == "Secret" && hostObject.Labels[provider.TokenSecretLabel] == "true" {
+		gvk = corev1.SchemeGroupVersion.WithKind("Pod")
+		key.Name = hostObject.Annotations[provider.TokenSecretPodAnnotation]
+	}
+
+	return gvk, key, key.Name != "" && key.Namespace != ""
+}
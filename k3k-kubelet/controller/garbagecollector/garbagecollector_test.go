@@ -0,0 +1,221 @@
+package garbagecollector
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/rancher/k3k/k3k-kubelet/provider"
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+// hostPVC returns an orphaned host PersistentVolumeClaim bound to a PersistentVolume with the given reclaim policy.
+func hostPVC(name string, reclaimPolicy corev1.PersistentVolumeReclaimPolicy) (*corev1.PersistentVolumeClaim, *corev1.PersistentVolume) {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "k3k-mycluster",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			Labels:            map[string]string{translate.ClusterNameLabel: "mycluster"},
+			Annotations: map[string]string{
+				translate.ResourceNameAnnotation:      "deleted",
+				translate.ResourceNamespaceAnnotation: "default",
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{VolumeName: "pv-" + name},
+	}
+
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-" + name},
+		Spec:       corev1.PersistentVolumeSpec{PersistentVolumeReclaimPolicy: reclaimPolicy},
+	}
+
+	return pvc, pv
+}
+
+// hostConfigMap returns a host ConfigMap synced from a virtual ConfigMap.
+func hostConfigMap(name, virtualName string, age time.Duration) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "k3k-mycluster",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			Labels:            map[string]string{translate.ClusterNameLabel: "mycluster"},
+			Annotations: map[string]string{
+				translate.ResourceNameAnnotation:      virtualName,
+				translate.ResourceNamespaceAnnotation: "default",
+			},
+		},
+	}
+}
+
+func Test_Collect(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "token-secret",
+			Namespace:         "k3k-mycluster",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			Labels: map[string]string{
+				translate.ClusterNameLabel: "mycluster",
+				provider.TokenSecretLabel:  "true",
+			},
+			Annotations: map[string]string{
+				translate.ResourceNamespaceAnnotation: "default",
+				provider.TokenSecretPodAnnotation:     "deleted-pod",
+			},
+		},
+	}
+
+	// orphaned host PersistentVolumeClaims, reported and collected only if opted in and their data is retained
+	orphanPVC, deletedPV := hostPVC("orphan-pvc", corev1.PersistentVolumeReclaimDelete)
+	retainedPVC, retainedPV := hostPVC("retained-pvc", corev1.PersistentVolumeReclaimRetain)
+
+	// a host ConfigMap of another cluster in the same namespace
+	otherClusterConfigMap := hostConfigMap("other-cluster", "deleted", time.Hour)
+	otherClusterConfigMap.Labels[translate.ClusterNameLabel] = "othercluster"
+
+	tests := []struct {
+		name        string
+		config      v1beta1.GarbageCollectionConfig
+		wantOrphans []string
+		wantDeleted []string
+		wantEvents  int
+	}{
+		{
+			name:        "disabled",
+			config:      v1beta1.GarbageCollectionConfig{Enabled: false},
+			wantOrphans: nil,
+			wantDeleted: nil,
+		},
+		{
+			name:        "enabled",
+			config:      v1beta1.GarbageCollectionConfig{Enabled: true},
+			wantOrphans: []string{"token-secret", "orphan", "orphan-pvc", "retained-pvc"},
+			wantDeleted: []string{"orphan", "token-secret"},
+			wantEvents:  2,
+		},
+		{
+			name:        "dry run",
+			config:      v1beta1.GarbageCollectionConfig{Enabled: true, DryRun: true, AdditionalKinds: []v1beta1.GarbageCollectedKind{v1beta1.PersistentVolumeClaimGarbageCollectedKind}},
+			wantOrphans: []string{"token-secret", "orphan", "orphan-pvc", "retained-pvc"},
+			wantDeleted: nil,
+			wantEvents:  2,
+		},
+		{
+			name:        "persistent volume claims",
+			config:      v1beta1.GarbageCollectionConfig{Enabled: true, AdditionalKinds: []v1beta1.GarbageCollectedKind{v1beta1.PersistentVolumeClaimGarbageCollectedKind}},
+			wantOrphans: []string{"token-secret", "orphan", "orphan-pvc", "retained-pvc"},
+			wantDeleted: []string{"orphan", "token-secret", "retained-pvc"},
+			wantEvents:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			cluster := &v1beta1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: "k3k-mycluster"},
+				Spec: v1beta1.ClusterSpec{
+					Sync: &v1beta1.SyncConfig{GarbageCollection: tt.config},
+				},
+			}
+
+			hostClient := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(
+					cluster,
+					hostConfigMap("synced", "existing", time.Hour),
+					hostConfigMap("orphan", "deleted", time.Hour),
+					hostConfigMap("recent", "not-visible-yet", time.Second),
+					otherClusterConfigMap,
+					tokenSecret,
+					orphanPVC,
+					deletedPV,
+					retainedPVC,
+					retainedPV,
+				).
+				Build()
+
+			virtualClient := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"}}).
+				Build()
+
+			recorder := record.NewFakeRecorder(10)
+
+			gc := &GarbageCollector{
+				ClusterName:      "mycluster",
+				ClusterNamespace: "k3k-mycluster",
+				Kinds:            DefaultKinds,
+				HostClient:       hostClient,
+				HostReader:       hostClient,
+				VirtualReader:    virtualClient,
+				EventRecorder:    recorder,
+				Logger:           logr.Discard(),
+			}
+
+			orphans, err := gc.Collect(ctx)
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+
+			var orphanNames []string
+			for _, orphan := range orphans {
+				orphanNames = append(orphanNames, orphan.Name)
+			}
+
+			if !slices.Equal(orphanNames, tt.wantOrphans) {
+				t.Errorf("Collect() orphans = %v, want %v", orphanNames, tt.wantOrphans)
+			}
+
+			var deleted []string
+
+			for _, obj := range []client.Object{
+				&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "synced"}},
+				&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "orphan"}},
+				&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "recent"}},
+				&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other-cluster"}},
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "token-secret"}},
+				&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "orphan-pvc"}},
+				&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "retained-pvc"}},
+			} {
+				key := client.ObjectKey{Name: obj.GetName(), Namespace: "k3k-mycluster"}
+				if err := hostClient.Get(ctx, key, obj); apierrors.IsNotFound(err) {
+					deleted = append(deleted, obj.GetName())
+				}
+			}
+
+			if !slices.Equal(deleted, tt.wantDeleted) {
+				t.Errorf("Collect() deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+
+			// the orphaned PersistentVolumeClaims not deleted are reported on the cluster
+			if len(recorder.Events) != tt.wantEvents {
+				t.Errorf("Collect() events = %d, want %d", len(recorder.Events), tt.wantEvents)
+			}
+		})
+	}
+}
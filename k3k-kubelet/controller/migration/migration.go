@@ -0,0 +1,581 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gwapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	"github.com/rancher/k3k/k3k-kubelet/provider"
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+)
+
+const (
+	// ReclaimPolicyAnnotation is the annotation with the original reclaim policy of a host PersistentVolume,
+	// set to Retain while its claim is renamed.
+	ReclaimPolicyAnnotation = "migration.k3k.io/reclaim-policy"
+
+	defaultTimeout = 5 * time.Minute
+)
+
+// ErrMigrationInProgress is returned by Migrate while the legacy objects are being deleted or the renamed claims
+// are being bound. Migrate has to be called again later to complete the migration.
+var ErrMigrationInProgress = errors.New("migration in progress")
+
+// copiedKinds are the kinds of the host objects renamed by creating a copy with the new name before deleting
+// the legacy object. They are recreated by the syncers anyway, the copy avoids an interruption.
+var copiedKinds = []schema.GroupVersionKind{
+	v1.SchemeGroupVersion.WithKind("ConfigMap"),
+	v1.SchemeGroupVersion.WithKind("Secret"),
+	networkingv1.SchemeGroupVersion.WithKind("Ingress"),
+	gwapiv1.SchemeGroupVersion.WithKind("HTTPRoute"),
+	gwapiv1.SchemeGroupVersion.WithKind("GRPCRoute"),
+	gwapiv1alpha2.SchemeGroupVersion.WithKind("TLSRoute"),
+	schedulingv1.SchemeGroupVersion.WithKind("PriorityClass"),
+}
+
+// migratedKinds are the kinds of all the host objects renamed by the migration.
+var migratedKinds = append([]schema.GroupVersionKind{
+	v1.SchemeGroupVersion.WithKind("Pod"),
+	v1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"),
+	snapshotv1.SchemeGroupVersion.WithKind("VolumeSnapshot"),
+	v1.SchemeGroupVersion.WithKind("Service"),
+}, copiedKinds...)
+
+// Migrator renames the host objects of a virtual cluster named with the legacy name translation
+// to the names returned by TranslateName.
+//
+// The objects are renamed as follows:
+//   - the Pods can't be renamed, so they are deleted and recreated by the virtual kubelet with the new name.
+//     All the workloads of the virtual cluster are restarted once.
+//   - the bound PersistentVolumeClaims are recreated with the new name and bound to the same PersistentVolume.
+//     The reclaim policy of the volume is set to Retain while the claim is renamed, so that the data is kept.
+//   - the ready VolumeSnapshots are recreated with the new name as pre-provisioned snapshots of the same snapshot handle.
+//   - the Services are deleted before creating them with the new name, since their node ports can't be shared.
+//   - the other objects are copied with the new name before deleting the legacy objects.
+//
+// The migration is run by the k3k controller while the virtual kubelets are stopped, since it needs the access to the
+// PersistentVolumes and the VolumeSnapshotContents, not granted to them. It doesn't wait for the deletions and the
+// bindings: it returns ErrMigrationInProgress, and it's run again until completed.
+type Migrator struct {
+	Client     client.Client
+	Translator translate.ToHostTranslator
+	Logger     logr.Logger
+	// Timeout is the maximum time waiting for the binding of the renamed claims, from their creation.
+	Timeout time.Duration
+}
+
+// NewMigrator returns a Migrator of the host objects of the virtual cluster.
+func NewMigrator(hostClient client.Client, clusterName, clusterNamespace string, logger logr.Logger) *Migrator {
+	return &Migrator{
+		Client: hostClient,
+		Translator: translate.ToHostTranslator{
+			ClusterName:      clusterName,
+			ClusterNamespace: clusterNamespace,
+		},
+		Logger:  logger.WithName("migration"),
+		Timeout: defaultTimeout,
+	}
+}
+
+// Migrate renames the host objects with legacy names. It returns ErrMigrationInProgress while the legacy Pods and
+// PersistentVolumeClaims are being deleted, or the renamed claims are being bound.
+func (m *Migrator) Migrate(ctx context.Context) error {
+	// the legacy claims are deleted once the pods using them are deleted
+	if err := m.migratePods(ctx); err != nil {
+		return err
+	}
+
+	// the other objects are renamed while the claims are being renamed
+	claimsErr := m.migratePersistentVolumeClaims(ctx)
+	if claimsErr != nil && !errors.Is(claimsErr, ErrMigrationInProgress) {
+		return claimsErr
+	}
+
+	if err := m.migrateVolumeSnapshots(ctx); err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, obj := range m.legacyObjects(ctx, v1.SchemeGroupVersion.WithKind("Service"), &errs) {
+		errs = append(errs, m.recreate(ctx, obj))
+	}
+
+	for _, gvk := range copiedKinds {
+		for _, obj := range m.legacyObjects(ctx, gvk, &errs) {
+			// the Secrets with the tokens of the pods are deleted with their legacy pods
+			if obj.GetLabels()[provider.TokenSecretLabel] == "true" {
+				continue
+			}
+
+			errs = append(errs, m.copy(ctx, obj))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	return claimsErr
+}
+
+// Pending returns true if some host objects have a legacy name, or some renamed claims are being bound.
+func (m *Migrator) Pending(ctx context.Context) (bool, error) {
+	var errs []error
+
+	for _, gvk := range migratedKinds {
+		if len(m.legacyObjects(ctx, gvk, &errs)) > 0 {
+			return true, nil
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return false, err
+	}
+
+	pvcs, err := m.boundClaims(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range pvcs {
+		var pv v1.PersistentVolume
+		if err := m.Client.Get(ctx, types.NamespacedName{Name: pvcs[i].Spec.VolumeName}, &pv); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return false, err
+			}
+
+			continue
+		}
+
+		if _, found := pv.Annotations[ReclaimPolicyAnnotation]; found && m.binding(&pvcs[i]) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// boundClaims returns the host PersistentVolumeClaims of the cluster with a volume.
+func (m *Migrator) boundClaims(ctx context.Context) ([]v1.PersistentVolumeClaim, error) {
+	var pvcs v1.PersistentVolumeClaimList
+	if err := m.Client.List(ctx, &pvcs, client.InNamespace(m.Translator.ClusterNamespace), client.MatchingLabels{translate.ClusterNameLabel: m.Translator.ClusterName}); err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(pvcs.Items, func(pvc v1.PersistentVolumeClaim) bool {
+		return pvc.Spec.VolumeName == ""
+	}), nil
+}
+
+// binding returns true if the binding of a renamed claim is still waited for.
+func (m *Migrator) binding(pvc *v1.PersistentVolumeClaim) bool {
+	return time.Since(pvc.CreationTimestamp.Time) < m.Timeout
+}
+
+// legacyObjects returns the host objects of the kind with a legacy name. The kinds not served by the host cluster are skipped.
+// The errors are appended to errs.
+func (m *Migrator) legacyObjects(ctx context.Context, gvk schema.GroupVersionKind, errs *[]error) []*unstructured.Unstructured {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+
+	opts := []client.ListOption{client.MatchingLabels{translate.ClusterNameLabel: m.Translator.ClusterName}}
+
+	// the PriorityClasses are not namespaced
+	if gvk.Kind != "PriorityClass" {
+		opts = append(opts, client.InNamespace(m.Translator.ClusterNamespace))
+	}
+
+	if err := m.Client.List(ctx, list, opts...); err != nil {
+		if !meta.IsNoMatchError(err) {
+			*errs = append(*errs, err)
+		}
+
+		return nil
+	}
+
+	var objects []*unstructured.Unstructured
+
+	for i := range list.Items {
+		if _, legacy := m.newName(&list.Items[i]); legacy {
+			objects = append(objects, &list.Items[i])
+		}
+	}
+
+	return objects
+}
+
+// newName returns the name translated from the annotations of a host object, and true if the host object has a legacy name.
+func (m *Migrator) newName(obj client.Object) (string, bool) {
+	key, err := translate.VirtualName(obj)
+	if err != nil {
+		return "", false
+	}
+
+	newName := m.Translator.TranslateName(key.Namespace, key.Name)
+	legacyName := m.Translator.LegacyTranslateName(key.Namespace, key.Name)
+
+	return newName, obj.GetName() == legacyName && obj.GetName() != newName
+}
+
+// copy creates the copy of a legacy object with the new name, and deletes the legacy object.
+func (m *Migrator) copy(ctx context.Context, legacy *unstructured.Unstructured) error {
+	newName, _ := m.newName(legacy)
+
+	m.Logger.Info("renaming host object", "kind", legacy.GetKind(), "name", legacy.GetName(), "newName", newName)
+
+	if err := m.Client.Create(ctx, renamedCopy(legacy, newName)); client.IgnoreAlreadyExists(err) != nil {
+		return err
+	}
+
+	return client.IgnoreNotFound(m.Client.Delete(ctx, legacy))
+}
+
+// recreate deletes a legacy object and creates it with the new name.
+func (m *Migrator) recreate(ctx context.Context, legacy *unstructured.Unstructured) error {
+	newName, _ := m.newName(legacy)
+
+	m.Logger.Info("recreating host object", "kind", legacy.GetKind(), "name", legacy.GetName(), "newName", newName)
+
+	obj := renamedCopy(legacy, newName)
+
+	// the cluster IPs are allocated again, except for the headless Services
+	if clusterIP, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); clusterIP != v1.ClusterIPNone {
+		unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+		unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+	}
+
+	if err := m.Client.Delete(ctx, legacy); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	return client.IgnoreAlreadyExists(m.Client.Create(ctx, obj))
+}
+
+// renamedCopy returns a copy of the object with the new name, without the fields set by the API server.
+func renamedCopy(obj *unstructured.Unstructured, name string) *unstructured.Unstructured {
+	renamed := obj.DeepCopy()
+	renamed.SetName(name)
+	renamed.SetResourceVersion("")
+	renamed.SetUID("")
+	renamed.SetGeneration(0)
+	renamed.SetCreationTimestamp(metav1.Time{})
+	renamed.SetManagedFields(nil)
+	renamed.SetFinalizers(nil)
+	unstructured.RemoveNestedField(renamed.Object, "status")
+
+	return renamed
+}
+
+// migratePods deletes the host Pods with a legacy name, and returns ErrMigrationInProgress until they are deleted.
+// The virtual kubelet creates them again with the new names.
+func (m *Migrator) migratePods(ctx context.Context) error {
+	var errs []error
+
+	pods := m.legacyObjects(ctx, v1.SchemeGroupVersion.WithKind("Pod"), &errs)
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if len(pods) == 0 {
+		return nil
+	}
+
+	for _, pod := range pods {
+		if !pod.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+
+		m.Logger.Info("deleting host pod with a legacy name", "name", pod.GetName())
+
+		if err := m.Client.Delete(ctx, pod); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	return ErrMigrationInProgress
+}
+
+// migratePersistentVolumeClaims recreates the host PersistentVolumeClaims with a legacy name, and once the legacy
+// claims are deleted binds the new claims to their volumes. The claims renamed by a previous migration are bound too.
+// It returns ErrMigrationInProgress until the legacy claims are deleted and the renamed claims are bound.
+func (m *Migrator) migratePersistentVolumeClaims(ctx context.Context) error {
+	var errs []error
+
+	legacyClaims := m.legacyObjects(ctx, v1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"), &errs)
+
+	for _, obj := range legacyClaims {
+		// the legacy claims being deleted are already renamed
+		if !obj.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+
+		var legacy v1.PersistentVolumeClaim
+		if err := m.Client.Get(ctx, client.ObjectKeyFromObject(obj), &legacy); err != nil {
+			errs = append(errs, client.IgnoreNotFound(err))
+			continue
+		}
+
+		errs = append(errs, m.migratePersistentVolumeClaim(ctx, &legacy))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	// the volumes are bound to the renamed claims once the legacy claims are deleted
+	if len(legacyClaims) > 0 {
+		return ErrMigrationInProgress
+	}
+
+	pvcs, err := m.boundClaims(ctx)
+	if err != nil {
+		return err
+	}
+
+	var inProgress bool
+
+	for i := range pvcs {
+		err := m.bindVolume(ctx, &pvcs[i])
+		if errors.Is(err, ErrMigrationInProgress) {
+			inProgress = true
+			continue
+		}
+
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil || !inProgress {
+		return err
+	}
+
+	return ErrMigrationInProgress
+}
+
+// migratePersistentVolumeClaim recreates a legacy claim with the new name.
+func (m *Migrator) migratePersistentVolumeClaim(ctx context.Context, legacy *v1.PersistentVolumeClaim) error {
+	newName, _ := m.newName(legacy)
+
+	log := m.Logger.WithValues("name", legacy.Name, "newName", newName)
+
+	// the unbound claims are created again by the syncer
+	if legacy.Spec.VolumeName == "" {
+		log.Info("deleting unbound host persistent volume claim with a legacy name")
+		return client.IgnoreNotFound(m.Client.Delete(ctx, legacy))
+	}
+
+	var pv v1.PersistentVolume
+	if err := m.Client.Get(ctx, types.NamespacedName{Name: legacy.Spec.VolumeName}, &pv); err != nil {
+		return err
+	}
+
+	// the volume must be retained when the legacy claim is deleted
+	if pv.Spec.PersistentVolumeReclaimPolicy != v1.PersistentVolumeReclaimRetain {
+		patch := client.MergeFrom(pv.DeepCopy())
+
+		if pv.Annotations == nil {
+			pv.Annotations = map[string]string{}
+		}
+
+		pv.Annotations[ReclaimPolicyAnnotation] = string(pv.Spec.PersistentVolumeReclaimPolicy)
+		pv.Spec.PersistentVolumeReclaimPolicy = v1.PersistentVolumeReclaimRetain
+
+		if err := m.Client.Patch(ctx, &pv, patch); err != nil {
+			return err
+		}
+	}
+
+	log.Info("renaming host persistent volume claim", "volume", pv.Name)
+
+	pvc := legacy.DeepCopy()
+	pvc.ObjectMeta = metav1.ObjectMeta{
+		Name:            newName,
+		Namespace:       legacy.Namespace,
+		Labels:          legacy.Labels,
+		Annotations:     legacy.Annotations,
+		OwnerReferences: legacy.OwnerReferences,
+	}
+	pvc.Status = v1.PersistentVolumeClaimStatus{}
+
+	// the new claim is bound by the migration, not by the binder of the legacy claim
+	delete(pvc.Annotations, "pv.kubernetes.io/bind-completed")
+	delete(pvc.Annotations, "pv.kubernetes.io/bound-by-controller")
+
+	if err := m.Client.Create(ctx, pvc); client.IgnoreAlreadyExists(err) != nil {
+		return err
+	}
+
+	return client.IgnoreNotFound(m.Client.Delete(ctx, legacy))
+}
+
+// bindVolume binds the volume of a renamed claim to it, and restores the reclaim policy of the volume once bound.
+// It returns ErrMigrationInProgress until the volume is bound, for at most the Timeout from the creation of the claim.
+// The volumes not renamed by the migration are skipped.
+func (m *Migrator) bindVolume(ctx context.Context, pvc *v1.PersistentVolumeClaim) error {
+	var pv v1.PersistentVolume
+	if err := m.Client.Get(ctx, types.NamespacedName{Name: pvc.Spec.VolumeName}, &pv); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	reclaimPolicy, found := pv.Annotations[ReclaimPolicyAnnotation]
+	if !found {
+		return nil
+	}
+
+	log := m.Logger.WithValues("name", pvc.Name, "volume", pv.Name)
+
+	if pv.Spec.ClaimRef == nil || pv.Spec.ClaimRef.Name != pvc.Name || pv.Spec.ClaimRef.UID != pvc.UID {
+		log.Info("binding the volume to the renamed host persistent volume claim")
+
+		patch := client.MergeFrom(pv.DeepCopy())
+		pv.Spec.ClaimRef = &v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "PersistentVolumeClaim",
+			Namespace:  pvc.Namespace,
+			Name:       pvc.Name,
+			UID:        pvc.UID,
+		}
+
+		if err := m.Client.Patch(ctx, &pv, patch); err != nil {
+			return err
+		}
+	}
+
+	// the volume is retained until bound to the renamed claim
+	if pv.Status.Phase != v1.VolumeBound {
+		if m.binding(pvc) {
+			return ErrMigrationInProgress
+		}
+
+		log.Info("volume not bound to the renamed host persistent volume claim, the reclaim policy is not restored")
+
+		return nil
+	}
+
+	log.Info("restoring the reclaim policy of the volume", "reclaimPolicy", reclaimPolicy)
+
+	patch := client.MergeFrom(pv.DeepCopy())
+	pv.Spec.PersistentVolumeReclaimPolicy = v1.PersistentVolumeReclaimPolicy(reclaimPolicy)
+	delete(pv.Annotations, ReclaimPolicyAnnotation)
+
+	return m.Client.Patch(ctx, &pv, patch)
+}
+
+// migrateVolumeSnapshots recreates the host VolumeSnapshots with a legacy name as pre-provisioned snapshots
+// of the snapshot handles of their VolumeSnapshotContents. The snapshots not ready yet are created again by the syncer.
+func (m *Migrator) migrateVolumeSnapshots(ctx context.Context) error {
+	var errs []error
+
+	for _, obj := range m.legacyObjects(ctx, snapshotv1.SchemeGroupVersion.WithKind("VolumeSnapshot"), &errs) {
+		var legacy snapshotv1.VolumeSnapshot
+		if err := m.Client.Get(ctx, client.ObjectKeyFromObject(obj), &legacy); err != nil {
+			errs = append(errs, client.IgnoreNotFound(err))
+			continue
+		}
+
+		errs = append(errs, m.migrateVolumeSnapshot(ctx, &legacy))
+	}
+
+	return errors.Join(errs...)
+}
+
+// migrateVolumeSnapshot recreates a legacy VolumeSnapshot with the new name.
+func (m *Migrator) migrateVolumeSnapshot(ctx context.Context, legacy *snapshotv1.VolumeSnapshot) error {
+	newName, _ := m.newName(legacy)
+
+	log := m.Logger.WithValues("name", legacy.Name, "newName", newName)
+
+	var legacyContent snapshotv1.VolumeSnapshotContent
+
+	if legacy.Status != nil && legacy.Status.BoundVolumeSnapshotContentName != nil {
+		key := types.NamespacedName{Name: *legacy.Status.BoundVolumeSnapshotContentName}
+		if err := m.Client.Get(ctx, key, &legacyContent); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	if legacyContent.Status == nil || legacyContent.Status.SnapshotHandle == nil {
+		log.Info("deleting host volume snapshot not ready with a legacy name")
+		return client.IgnoreNotFound(m.Client.Delete(ctx, legacy))
+	}
+
+	log.Info("renaming host volume snapshot", "volumeSnapshotContent", legacyContent.Name)
+
+	content := &snapshotv1.VolumeSnapshotContent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: newName,
+		},
+		Spec: snapshotv1.VolumeSnapshotContentSpec{
+			Driver:                  legacyContent.Spec.Driver,
+			DeletionPolicy:          legacyContent.Spec.DeletionPolicy,
+			VolumeSnapshotClassName: legacyContent.Spec.VolumeSnapshotClassName,
+			SourceVolumeMode:        legacyContent.Spec.SourceVolumeMode,
+			Source: snapshotv1.VolumeSnapshotContentSource{
+				SnapshotHandle: legacyContent.Status.SnapshotHandle,
+			},
+			VolumeSnapshotRef: v1.ObjectReference{
+				APIVersion: snapshotv1.SchemeGroupVersion.String(),
+				Kind:       "VolumeSnapshot",
+				Namespace:  legacy.Namespace,
+				Name:       newName,
+			},
+		},
+	}
+
+	if err := m.Client.Create(ctx, content); client.IgnoreAlreadyExists(err) != nil {
+		return err
+	}
+
+	snapshot := &snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            newName,
+			Namespace:       legacy.Namespace,
+			Labels:          legacy.Labels,
+			Annotations:     legacy.Annotations,
+			OwnerReferences: legacy.OwnerReferences,
+		},
+		Spec: snapshotv1.VolumeSnapshotSpec{
+			Source: snapshotv1.VolumeSnapshotSource{
+				VolumeSnapshotContentName: &content.Name,
+			},
+			VolumeSnapshotClassName: legacy.Spec.VolumeSnapshotClassName,
+		},
+	}
+
+	if err := m.Client.Create(ctx, snapshot); client.IgnoreAlreadyExists(err) != nil {
+		return err
+	}
+
+	// the snapshot data is now referenced by the new VolumeSnapshotContent, and must be retained
+	// when the legacy one is deleted
+	if legacyContent.Spec.DeletionPolicy != snapshotv1.VolumeSnapshotContentRetain {
+		patch := client.MergeFrom(legacyContent.DeepCopy())
+		legacyContent.Spec.DeletionPolicy = snapshotv1.VolumeSnapshotContentRetain
+
+		if err := m.Client.Patch(ctx, &legacyContent, patch); err != nil {
+			return err
+		}
+	}
+
+	if err := m.Client.Delete(ctx, legacy); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	return client.IgnoreNotFound(m.Client.Delete(ctx, &legacyContent))
+}
@@ -0,0 +1,271 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gwapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+)
+
+// legacyObjectMeta returns the metadata of a host object synced from a virtual object with the legacy name translation.
+func legacyObjectMeta(translator translate.ToHostTranslator, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      translator.LegacyTranslateName("default", name),
+		Namespace: translator.ClusterNamespace,
+		Labels:    map[string]string{translate.ClusterNameLabel: translator.ClusterName},
+		Annotations: map[string]string{
+			translate.ResourceNameAnnotation:      name,
+			translate.ResourceNamespaceAnnotation: "default",
+		},
+	}
+}
+
+// migrate runs the migration until completed.
+func migrate(t *testing.T, migrator *Migrator) {
+	t.Helper()
+
+	for range 5 {
+		err := migrator.Migrate(context.Background())
+		if err == nil {
+			return
+		}
+
+		if !errors.Is(err, ErrMigrationInProgress) {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+	}
+
+	t.Fatalf("Migrate() not completed")
+}
+
+func Test_Migrate(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme, snapshotv1.AddToScheme, gwapiv1.Install, gwapiv1alpha2.Install,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	translator := translate.ToHostTranslator{ClusterName: "mycluster", ClusterNamespace: "k3k-mycluster"}
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: legacyObjectMeta(translator, "config"),
+		Data:       map[string]string{"key": "value"},
+	}
+
+	service := &v1.Service{
+		ObjectMeta: legacyObjectMeta(translator, "nginx"),
+		Spec:       v1.ServiceSpec{ClusterIP: "10.43.0.10", ClusterIPs: []string{"10.43.0.10"}},
+	}
+
+	pod := &v1.Pod{ObjectMeta: legacyObjectMeta(translator, "nginx")}
+
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-1234"},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimDelete,
+			ClaimRef: &v1.ObjectReference{
+				Kind:      "PersistentVolumeClaim",
+				Namespace: translator.ClusterNamespace,
+				Name:      translator.LegacyTranslateName("default", "data"),
+				UID:       "legacy-uid",
+			},
+		},
+	}
+
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: legacyObjectMeta(translator, "data"),
+		Spec:       v1.PersistentVolumeClaimSpec{VolumeName: pv.Name},
+	}
+	pvc.Annotations["pv.kubernetes.io/bind-completed"] = "yes"
+
+	// an object already renamed
+	secret := &v1.Secret{ObjectMeta: legacyObjectMeta(translator, "credentials")}
+	secret.Name = translator.TranslateName("default", "credentials")
+
+	hostClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(configMap, service, pod, pv, pvc, secret).
+		Build()
+
+	migrator := &Migrator{
+		Client:     hostClient,
+		Translator: translator,
+		Logger:     logr.Discard(),
+		Timeout:    100 * time.Millisecond,
+	}
+
+	if pending, err := migrator.Pending(ctx); err != nil || !pending {
+		t.Fatalf("Pending() = %t, %v, want true", pending, err)
+	}
+
+	// the legacy pods are deleted first, then the objects are renamed on the next run
+	if err := migrator.Migrate(ctx); !errors.Is(err, ErrMigrationInProgress) {
+		t.Fatalf("Migrate() error = %v, want %v", err, ErrMigrationInProgress)
+	}
+
+	migrate(t, migrator)
+
+	if pending, err := migrator.Pending(ctx); err != nil || pending {
+		t.Errorf("Pending() after Migrate() = %t, %v, want false", pending, err)
+	}
+
+	// the legacy objects are deleted
+	for _, obj := range []client.Object{configMap, service, pod, pvc} {
+		if err := hostClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); !apierrors.IsNotFound(err) {
+			t.Errorf("legacy %s not deleted: %v", obj.GetName(), err)
+		}
+	}
+
+	var newConfigMap v1.ConfigMap
+	if err := hostClient.Get(ctx, client.ObjectKey{Name: translator.TranslateName("default", "config"), Namespace: translator.ClusterNamespace}, &newConfigMap); err != nil {
+		t.Errorf("renamed configmap not found: %v", err)
+	} else if newConfigMap.Data["key"] != "value" {
+		t.Errorf("unexpected data of the renamed configmap %v", newConfigMap.Data)
+	}
+
+	var newService v1.Service
+	if err := hostClient.Get(ctx, client.ObjectKey{Name: translator.TranslateName("default", "nginx"), Namespace: translator.ClusterNamespace}, &newService); err != nil {
+		t.Errorf("renamed service not found: %v", err)
+	} else if newService.Spec.ClusterIP == "10.43.0.10" {
+		t.Errorf("the cluster IP of the renamed service is not allocated again")
+	}
+
+	// the pods are recreated by the virtual kubelet
+	var newPod v1.Pod
+	if err := hostClient.Get(ctx, client.ObjectKey{Name: translator.TranslateName("default", "nginx"), Namespace: translator.ClusterNamespace}, &newPod); !apierrors.IsNotFound(err) {
+		t.Errorf("unexpected renamed pod: %v", err)
+	}
+
+	var newPVC v1.PersistentVolumeClaim
+	if err := hostClient.Get(ctx, client.ObjectKey{Name: translator.TranslateName("default", "data"), Namespace: translator.ClusterNamespace}, &newPVC); err != nil {
+		t.Fatalf("renamed pvc not found: %v", err)
+	}
+
+	if newPVC.Spec.VolumeName != pv.Name || newPVC.Annotations["pv.kubernetes.io/bind-completed"] != "" {
+		t.Errorf("unexpected renamed pvc %v", newPVC)
+	}
+
+	// the volume is bound to the new claim, and retained until bound
+	if err := hostClient.Get(ctx, client.ObjectKeyFromObject(pv), pv); err != nil {
+		t.Fatal(err)
+	}
+
+	if pv.Spec.ClaimRef.Name != newPVC.Name || pv.Spec.ClaimRef.UID != newPVC.UID {
+		t.Errorf("volume not bound to the renamed pvc: %v", pv.Spec.ClaimRef)
+	}
+
+	if pv.Spec.PersistentVolumeReclaimPolicy != v1.PersistentVolumeReclaimRetain || pv.Annotations[ReclaimPolicyAnnotation] != "Delete" {
+		t.Errorf("volume not retained: %s", pv.Spec.PersistentVolumeReclaimPolicy)
+	}
+
+	// the already renamed objects are kept
+	if err := hostClient.Get(ctx, client.ObjectKeyFromObject(secret), secret); err != nil {
+		t.Errorf("renamed secret deleted: %v", err)
+	}
+}
+
+func Test_Migrate_binding(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	translator := translate.ToHostTranslator{ClusterName: "mycluster", ClusterNamespace: "k3k-mycluster"}
+
+	// a claim renamed by a previous run, whose volume is not bound yet
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: legacyObjectMeta(translator, "data"),
+		Spec:       v1.PersistentVolumeClaimSpec{VolumeName: "pvc-1234"},
+	}
+	pvc.Name = translator.TranslateName("default", "data")
+	pvc.CreationTimestamp = metav1.Now()
+	pvc.UID = "new-uid"
+
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pvc-1234",
+			Annotations: map[string]string{ReclaimPolicyAnnotation: "Delete"},
+		},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimRetain,
+			ClaimRef: &v1.ObjectReference{
+				Kind:      "PersistentVolumeClaim",
+				Namespace: translator.ClusterNamespace,
+				Name:      pvc.Name,
+				UID:       pvc.UID,
+			},
+		},
+		Status: v1.PersistentVolumeStatus{Phase: v1.VolumeAvailable},
+	}
+
+	hostClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(pv, pvc).
+		WithStatusSubresource(pv).
+		Build()
+
+	migrator := &Migrator{
+		Client:     hostClient,
+		Translator: translator,
+		Logger:     logr.Discard(),
+		Timeout:    time.Hour,
+	}
+
+	// the migration doesn't wait for the binding, and is pending until the volume is bound
+	if err := migrator.Migrate(ctx); !errors.Is(err, ErrMigrationInProgress) {
+		t.Fatalf("Migrate() error = %v, want %v", err, ErrMigrationInProgress)
+	}
+
+	if pending, err := migrator.Pending(ctx); err != nil || !pending {
+		t.Fatalf("Pending() = %t, %v, want true", pending, err)
+	}
+
+	if err := hostClient.Get(ctx, client.ObjectKeyFromObject(pv), pv); err != nil {
+		t.Fatal(err)
+	}
+
+	pv.Status.Phase = v1.VolumeBound
+	if err := hostClient.Status().Update(ctx, pv); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrator.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	if pending, err := migrator.Pending(ctx); err != nil || pending {
+		t.Errorf("Pending() after Migrate() = %t, %v, want false", pending, err)
+	}
+
+	// the reclaim policy of the bound volume is restored
+	if err := hostClient.Get(ctx, client.ObjectKeyFromObject(pv), pv); err != nil {
+		t.Fatal(err)
+	}
+
+	if pv.Spec.PersistentVolumeReclaimPolicy != v1.PersistentVolumeReclaimDelete || pv.Annotations[ReclaimPolicyAnnotation] != "" {
+		t.Errorf("reclaim policy not restored: %s", pv.Spec.PersistentVolumeReclaimPolicy)
+	}
+}
@@ -15,8 +15,8 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 
-	"github.com/rancher/k3k/k3k-kubelet/translate"
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller"
 )
 
 const (
@@ -35,17 +35,13 @@ func (c *ConfigMapSyncer) Name() string {
 
 // AddConfigMapSyncer adds configmap syncer controller to the manager of the virtual cluster
 func AddConfigMapSyncer(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) error {
+	syncerContext, err := newSyncerContext(ctx, virtMgr, hostMgr, clusterName, clusterNamespace)
+	if err != nil {
+		return err
+	}
+
 	reconciler := ConfigMapSyncer{
-		SyncerContext: &SyncerContext{
-			VirtualClient: virtMgr.GetClient(),
-			HostClient:    hostMgr.GetClient(),
-			Translator: translate.ToHostTranslator{
-				ClusterName:      clusterName,
-				ClusterNamespace: clusterNamespace,
-			},
-			ClusterName:      clusterName,
-			ClusterNamespace: clusterNamespace,
-		},
+		SyncerContext: syncerContext,
 	}
 
 	name := reconciler.Translator.TranslateName(clusterNamespace, configMapControllerName)
@@ -66,7 +62,7 @@ func (c *ConfigMapSyncer) filterResources(object client.Object) bool {
 	}
 
 	// check for configMap Sync Config
-	syncConfig := cluster.Spec.Sync.ConfigMaps
+	syncConfig := controller.SyncConfig(&cluster).ConfigMaps
 
 	// If syncing is disabled, only process deletions to allow for cleanup.
 	if !syncConfig.Enabled {
@@ -0,0 +1,502 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gwapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller"
+)
+
+const (
+	// GatewayRouteNotSyncedReason is the reason of the events recorded when a Gateway API route cannot be synced to the host cluster.
+	GatewayRouteNotSyncedReason = "GatewayRouteNotSynced"
+
+	httpRouteControllerName   = "httproute-syncer-controller"
+	grpcRouteControllerName   = "grpcroute-syncer-controller"
+	tlsRouteControllerName    = "tlsroute-syncer-controller"
+	gatewayRouteFinalizerName = "gatewayroute.k3k.io/finalizer"
+)
+
+// GatewayRouteReconciler syncs the Gateway API routes of a kind to the host cluster, attaching them to the host Gateways
+// configured in the cluster.
+type GatewayRouteReconciler[T ctrlruntimeclient.Object] struct {
+	*SyncerContext
+	record.EventRecorder
+
+	// newRoute returns an empty route.
+	newRoute func() T
+	// parentRefs returns the parentRefs of the route.
+	parentRefs func(route T) *[]gwapiv1.ParentReference
+	// backendRefs returns the references to the backends of the route, that are translated in place.
+	backendRefs func(route T) ([]*gwapiv1.BackendObjectReference, error)
+	// routeStatus returns the status of the route.
+	routeStatus func(route T) *gwapiv1.RouteStatus
+}
+
+// AddHTTPRouteSyncer adds the HTTPRoute syncer controller to the manager of the virtual cluster
+func AddHTTPRouteSyncer(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) error {
+	reconciler := &GatewayRouteReconciler[*gwapiv1.HTTPRoute]{
+		newRoute:    func() *gwapiv1.HTTPRoute { return &gwapiv1.HTTPRoute{} },
+		parentRefs:  func(route *gwapiv1.HTTPRoute) *[]gwapiv1.ParentReference { return &route.Spec.ParentRefs },
+		backendRefs: httpRouteBackendRefs,
+		routeStatus: func(route *gwapiv1.HTTPRoute) *gwapiv1.RouteStatus { return &route.Status.RouteStatus },
+	}
+
+	return addGatewayRouteSyncer(ctx, reconciler, virtMgr, hostMgr, clusterName, clusterNamespace, httpRouteControllerName)
+}
+
+// AddGRPCRouteSyncer adds the GRPCRoute syncer controller to the manager of the virtual cluster
+func AddGRPCRouteSyncer(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) error {
+	reconciler := &GatewayRouteReconciler[*gwapiv1.GRPCRoute]{
+		newRoute:    func() *gwapiv1.GRPCRoute { return &gwapiv1.GRPCRoute{} },
+		parentRefs:  func(route *gwapiv1.GRPCRoute) *[]gwapiv1.ParentReference { return &route.Spec.ParentRefs },
+		backendRefs: grpcRouteBackendRefs,
+		routeStatus: func(route *gwapiv1.GRPCRoute) *gwapiv1.RouteStatus { return &route.Status.RouteStatus },
+	}
+
+	return addGatewayRouteSyncer(ctx, reconciler, virtMgr, hostMgr, clusterName, clusterNamespace, grpcRouteControllerName)
+}
+
+// AddTLSRouteSyncer adds the TLSRoute syncer controller to the manager of the virtual cluster
+func AddTLSRouteSyncer(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) error {
+	reconciler := &GatewayRouteReconciler[*gwapiv1alpha2.TLSRoute]{
+		newRoute:    func() *gwapiv1alpha2.TLSRoute { return &gwapiv1alpha2.TLSRoute{} },
+		parentRefs:  func(route *gwapiv1alpha2.TLSRoute) *[]gwapiv1.ParentReference { return &route.Spec.ParentRefs },
+		backendRefs: tlsRouteBackendRefs,
+		routeStatus: func(route *gwapiv1alpha2.TLSRoute) *gwapiv1.RouteStatus { return &route.Status.RouteStatus },
+	}
+
+	return addGatewayRouteSyncer(ctx, reconciler, virtMgr, hostMgr, clusterName, clusterNamespace, tlsRouteControllerName)
+}
+
+func addGatewayRouteSyncer[T ctrlruntimeclient.Object](ctx context.Context, reconciler *GatewayRouteReconciler[T], virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace, controllerName string) error {
+	syncerContext, err := newSyncerContext(ctx, virtMgr, hostMgr, clusterName, clusterNamespace)
+	if err != nil {
+		return err
+	}
+
+	reconciler.SyncerContext = syncerContext
+
+	name := reconciler.Translator.TranslateName(clusterNamespace, controllerName)
+
+	reconciler.EventRecorder = virtMgr.GetEventRecorderFor(name)
+
+	// the host routes are watched to reflect their status back to the virtual routes
+	hostRouteSource := source.Kind(hostMgr.GetCache(), reconciler.newRoute(),
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.virtualRouteRequest),
+		predicate.TypedFuncs[T]{
+			CreateFunc:  func(event.TypedCreateEvent[T]) bool { return false },
+			DeleteFunc:  func(event.TypedDeleteEvent[T]) bool { return false },
+			GenericFunc: func(event.TypedGenericEvent[T]) bool { return false },
+			UpdateFunc: func(e event.TypedUpdateEvent[T]) bool {
+				return !equality.Semantic.DeepEqual(reconciler.routeStatus(e.ObjectOld), reconciler.routeStatus(e.ObjectNew))
+			},
+		},
+	)
+
+	return ctrl.NewControllerManagedBy(virtMgr).
+		Named(name).
+		For(reconciler.newRoute()).
+		WithEventFilter(predicate.NewPredicateFuncs(reconciler.filterResources)).
+		WatchesRawSource(hostRouteSource).
+		Complete(reconciler)
+}
+
+// virtualRouteRequest maps a synced host route to the request for its virtual route.
+func (r *GatewayRouteReconciler[T]) virtualRouteRequest(_ context.Context, hostRoute T) []reconcile.Request {
+	if !r.Translator.IsHostObject(hostRoute) {
+		return nil
+	}
+
+	name := hostRoute.GetAnnotations()[translate.ResourceNameAnnotation]
+	namespace := hostRoute.GetAnnotations()[translate.ResourceNamespaceAnnotation]
+
+	if name == "" || namespace == "" {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}}
+}
+
+func (r *GatewayRouteReconciler[T]) filterResources(object ctrlruntimeclient.Object) bool {
+	var cluster v1beta1.Cluster
+
+	ctx := context.Background()
+
+	if err := r.HostClient.Get(ctx, types.NamespacedName{Name: r.ClusterName, Namespace: r.ClusterNamespace}, &cluster); err != nil {
+		return false
+	}
+
+	syncConfig := controller.SyncConfig(&cluster).GatewayRoutes
+
+	// If syncing is disabled, only process deletions to allow for cleanup.
+	if !syncConfig.Enabled {
+		return object.GetDeletionTimestamp() != nil
+	}
+
+	labelSelector := labels.SelectorFromSet(syncConfig.Selector)
+	if labelSelector.Empty() {
+		return true
+	}
+
+	return labelSelector.Matches(labels.Set(object.GetLabels()))
+}
+
+func (r *GatewayRouteReconciler[T]) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithValues("cluster", r.ClusterName, "clusterNamespace", r.ClusterNamespace)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	log.Info("reconciling route object")
+
+	var cluster v1beta1.Cluster
+
+	if err := r.HostClient.Get(ctx, types.NamespacedName{Name: r.ClusterName, Namespace: r.ClusterNamespace}, &cluster); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	virtRoute := r.newRoute()
+	if err := r.VirtualClient.Get(ctx, req.NamespacedName, virtRoute); err != nil {
+		return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
+	}
+
+	// the host routes events are not filtered by the sync config
+	if !r.filterResources(virtRoute) {
+		return reconcile.Result{}, nil
+	}
+
+	syncedRoute, syncErr := r.route(virtRoute, controller.SyncConfig(&cluster).GatewayRoutes)
+	if err := translate.SetClusterOwner(&cluster, syncedRoute, r.HostClient.Scheme()); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	hostRoute := r.newRoute()
+	hostRouteExists := true
+
+	if err := r.HostClient.Get(ctx, ctrlruntimeclient.ObjectKeyFromObject(syncedRoute), hostRoute); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		hostRouteExists = false
+	}
+
+	// a route with the same name not synced from this virtual route must not be overwritten, or deleted
+	isSynced := hostRouteExists && r.isSyncedFrom(hostRoute, virtRoute)
+
+	// handle deletion
+	if !virtRoute.GetDeletionTimestamp().IsZero() {
+		if isSynced {
+			if err := r.HostClient.Delete(ctx, syncedRoute); err != nil {
+				return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
+			}
+		}
+
+		// remove the finalizer after cleaning up the synced route
+		if controllerutil.RemoveFinalizer(virtRoute, gatewayRouteFinalizerName) {
+			if err := r.VirtualClient.Update(ctx, virtRoute); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+
+		return reconcile.Result{}, nil
+	}
+
+	if hostRouteExists && !isSynced {
+		syncErr = fmt.Errorf("route %s/%s already exists", hostRoute.GetNamespace(), hostRoute.GetName())
+	}
+
+	// the routes that cannot be synced are removed from the host, if they were synced before
+	if syncErr != nil {
+		log.Info("route cannot be synced to the host cluster", "reason", syncErr.Error())
+		r.Eventf(virtRoute, corev1.EventTypeWarning, GatewayRouteNotSyncedReason, "Route not synced to the host cluster: %s", syncErr.Error())
+
+		if isSynced {
+			if err := r.HostClient.Delete(ctx, syncedRoute); err != nil {
+				return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
+			}
+		}
+
+		return reconcile.Result{}, nil
+	}
+
+	// Add finalizer if it does not exist
+
+	if controllerutil.AddFinalizer(virtRoute, gatewayRouteFinalizerName) {
+		if err := r.VirtualClient.Update(ctx, virtRoute); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	// create or update the route on host
+	if !hostRouteExists {
+		log.Info("creating the route for the first time on the host cluster")
+		return reconcile.Result{}, r.HostClient.Create(ctx, syncedRoute)
+	}
+
+	log.Info("updating route on the host cluster")
+
+	syncedRoute.SetResourceVersion(hostRoute.GetResourceVersion())
+
+	if err := r.HostClient.Update(ctx, syncedRoute); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// reflect the status of the host route to the virtual one
+	parents := r.virtualRouteParents(virtRoute, hostRoute)
+
+	if !equality.Semantic.DeepEqual(r.routeStatus(virtRoute).Parents, parents) {
+		log.Info("updating route status on the virtual cluster")
+
+		r.routeStatus(virtRoute).Parents = parents
+
+		return reconcile.Result{}, r.VirtualClient.Status().Update(ctx, virtRoute)
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// isSyncedFrom returns true if the host route was synced from the given virtual route.
+func (r *GatewayRouteReconciler[T]) isSyncedFrom(hostRoute, virtRoute T) bool {
+	return hostRoute.GetLabels()[translate.ClusterNameLabel] == r.ClusterName &&
+		hostRoute.GetAnnotations()[translate.ResourceNameAnnotation] == virtRoute.GetName() &&
+		hostRoute.GetAnnotations()[translate.ResourceNamespaceAnnotation] == virtRoute.GetNamespace()
+}
+
+// route translates the virtual route to the host one. The parentRefs are replaced with the allowed host Gateways,
+// and the backends are translated to the synced services. An error is returned if the route cannot be synced.
+func (r *GatewayRouteReconciler[T]) route(virtRoute T, syncConfig v1beta1.GatewayRouteSyncConfig) (T, error) {
+	hostRoute := virtRoute.DeepCopyObject().(T)
+	r.Translator.TranslateTo(hostRoute)
+
+	// the status is reflected from the host to the virtual cluster
+	*r.routeStatus(hostRoute) = gwapiv1.RouteStatus{}
+
+	parentRefs, err := hostParentRefs(*r.parentRefs(virtRoute), syncConfig.Gateways)
+	if err != nil {
+		return hostRoute, err
+	}
+
+	*r.parentRefs(hostRoute) = parentRefs
+
+	backendRefs, err := r.backendRefs(hostRoute)
+	if err != nil {
+		return hostRoute, err
+	}
+
+	for _, backendRef := range backendRefs {
+		if err := r.translateBackendRef(virtRoute.GetNamespace(), backendRef); err != nil {
+			return hostRoute, err
+		}
+	}
+
+	return hostRoute, nil
+}
+
+// translateBackendRef translates the reference to a Service of the virtual cluster to the synced host Service.
+// The references to the Services of other namespaces are not supported, since the ReferenceGrants of the virtual
+// cluster allowing them are not synced.
+func (r *GatewayRouteReconciler[T]) translateBackendRef(namespace string, backendRef *gwapiv1.BackendObjectReference) error {
+	if ptr.Deref(backendRef.Group, "") != "" || ptr.Deref(backendRef.Kind, "Service") != "Service" {
+		return fmt.Errorf("backendRef %q is not a Service", backendRef.Name)
+	}
+
+	if backendRef.Namespace != nil && string(*backendRef.Namespace) != namespace {
+		return fmt.Errorf("backendRef %q references the namespace %q: cross-namespace backends are not supported", backendRef.Name, *backendRef.Namespace)
+	}
+
+	// all the synced services are in the namespace of the cluster
+	backendRef.Name = gwapiv1.ObjectName(r.Translator.TranslateName(namespace, string(backendRef.Name)))
+	backendRef.Namespace = nil
+
+	return nil
+}
+
+// virtualRouteParents returns the status of the parents of the host route, with the references to the host Gateways
+// replaced by the corresponding parentRefs of the virtual route.
+func (r *GatewayRouteReconciler[T]) virtualRouteParents(virtRoute, hostRoute T) []gwapiv1.RouteParentStatus {
+	virtParentRefs := *r.parentRefs(virtRoute)
+	hostParentRefs := *r.parentRefs(hostRoute)
+
+	var parents []gwapiv1.RouteParentStatus
+
+	for _, parent := range r.routeStatus(hostRoute).Parents {
+		parent := *parent.DeepCopy()
+
+		i := slices.IndexFunc(hostParentRefs, func(parentRef gwapiv1.ParentReference) bool {
+			return sameParentRef(parentRef, parent.ParentRef)
+		})
+
+		// the routes without parentRefs are attached to the default Gateway, that is reported as is
+		if i >= 0 && i < len(virtParentRefs) {
+			parent.ParentRef = virtParentRefs[i]
+		}
+
+		parents = append(parents, parent)
+	}
+
+	return parents
+}
+
+// hostParentRefs returns the parentRefs of the host route, referencing the allowed Gateways with the same name.
+// The routes without parentRefs are attached to the first Gateway.
+func hostParentRefs(parentRefs []gwapiv1.ParentReference, gateways []v1beta1.GatewayReference) ([]gwapiv1.ParentReference, error) {
+	if len(gateways) == 0 {
+		return nil, errors.New("no Gateways are allowed for the virtual cluster")
+	}
+
+	if len(parentRefs) == 0 {
+		return []gwapiv1.ParentReference{gatewayParentRef(gateways[0], gwapiv1.ParentReference{})}, nil
+	}
+
+	hostRefs := make([]gwapiv1.ParentReference, 0, len(parentRefs))
+
+	for _, parentRef := range parentRefs {
+		if ptr.Deref(parentRef.Group, gwapiv1.GroupName) != gwapiv1.GroupName || ptr.Deref(parentRef.Kind, "Gateway") != "Gateway" {
+			return nil, fmt.Errorf("parentRef %q is not a Gateway", parentRef.Name)
+		}
+
+		i := slices.IndexFunc(gateways, func(gateway v1beta1.GatewayReference) bool {
+			return gateway.Name == string(parentRef.Name)
+		})
+
+		if i < 0 {
+			return nil, fmt.Errorf("gateway %q is not allowed", parentRef.Name)
+		}
+
+		hostRefs = append(hostRefs, gatewayParentRef(gateways[i], parentRef))
+	}
+
+	return hostRefs, nil
+}
+
+// gatewayParentRef returns the reference to the host Gateway. The sectionName of the Gateway takes precedence over
+// the one requested by the route.
+func gatewayParentRef(gateway v1beta1.GatewayReference, parentRef gwapiv1.ParentReference) gwapiv1.ParentReference {
+	sectionName := parentRef.SectionName
+	if gateway.SectionName != "" {
+		sectionName = ptr.To(gwapiv1.SectionName(gateway.SectionName))
+	}
+
+	return gwapiv1.ParentReference{
+		Group:       ptr.To(gwapiv1.Group(gwapiv1.GroupName)),
+		Kind:        ptr.To(gwapiv1.Kind("Gateway")),
+		Namespace:   ptr.To(gwapiv1.Namespace(gateway.Namespace)),
+		Name:        gwapiv1.ObjectName(gateway.Name),
+		SectionName: sectionName,
+		Port:        parentRef.Port,
+	}
+}
+
+func sameParentRef(a, b gwapiv1.ParentReference) bool {
+	return a.Name == b.Name &&
+		ptr.Deref(a.Namespace, "") == ptr.Deref(b.Namespace, "") &&
+		ptr.Deref(a.SectionName, "") == ptr.Deref(b.SectionName, "")
+}
+
+func httpRouteBackendRefs(route *gwapiv1.HTTPRoute) ([]*gwapiv1.BackendObjectReference, error) {
+	var backendRefs []*gwapiv1.BackendObjectReference
+
+	filtersBackendRefs := func(filters []gwapiv1.HTTPRouteFilter) error {
+		for i := range filters {
+			if filters[i].ExtensionRef != nil {
+				return fmt.Errorf("extensionRef %q is not supported", filters[i].ExtensionRef.Name)
+			}
+
+			if filters[i].RequestMirror != nil {
+				backendRefs = append(backendRefs, &filters[i].RequestMirror.BackendRef)
+			}
+		}
+
+		return nil
+	}
+
+	for i := range route.Spec.Rules {
+		rule := &route.Spec.Rules[i]
+
+		if err := filtersBackendRefs(rule.Filters); err != nil {
+			return nil, err
+		}
+
+		for j := range rule.BackendRefs {
+			backendRefs = append(backendRefs, &rule.BackendRefs[j].BackendObjectReference)
+
+			if err := filtersBackendRefs(rule.BackendRefs[j].Filters); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return backendRefs, nil
+}
+
+func grpcRouteBackendRefs(route *gwapiv1.GRPCRoute) ([]*gwapiv1.BackendObjectReference, error) {
+	var backendRefs []*gwapiv1.BackendObjectReference
+
+	filtersBackendRefs := func(filters []gwapiv1.GRPCRouteFilter) error {
+		for i := range filters {
+			if filters[i].ExtensionRef != nil {
+				return fmt.Errorf("extensionRef %q is not supported", filters[i].ExtensionRef.Name)
+			}
+
+			if filters[i].RequestMirror != nil {
+				backendRefs = append(backendRefs, &filters[i].RequestMirror.BackendRef)
+			}
+		}
+
+		return nil
+	}
+
+	for i := range route.Spec.Rules {
+		rule := &route.Spec.Rules[i]
+
+		if err := filtersBackendRefs(rule.Filters); err != nil {
+			return nil, err
+		}
+
+		for j := range rule.BackendRefs {
+			backendRefs = append(backendRefs, &rule.BackendRefs[j].BackendObjectReference)
+
+			if err := filtersBackendRefs(rule.BackendRefs[j].Filters); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return backendRefs, nil
+}
+
+func tlsRouteBackendRefs(route *gwapiv1alpha2.TLSRoute) ([]*gwapiv1.BackendObjectReference, error) {
+	var backendRefs []*gwapiv1.BackendObjectReference
+
+	for i := range route.Spec.Rules {
+		for j := range route.Spec.Rules[i].BackendRefs {
+			backendRefs = append(backendRefs, &route.Spec.Rules[i].BackendRefs[j].BackendObjectReference)
+		}
+	}
+
+	return backendRefs, nil
+}
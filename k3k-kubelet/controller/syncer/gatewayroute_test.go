@@ -0,0 +1,275 @@
+package syncer_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/rancher/k3k/k3k-kubelet/controller/syncer"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var HTTPRouteTests = func() {
+	var (
+		namespace string
+		cluster   v1beta1.Cluster
+	)
+
+	BeforeEach(func() {
+		ctx := context.Background()
+
+		ns := v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "ns-"},
+		}
+		err := hostTestEnv.k8sClient.Create(ctx, &ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = ns.Name
+
+		cluster = v1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "cluster-",
+				Namespace:    namespace,
+			},
+			Spec: v1beta1.ClusterSpec{
+				Sync: &v1beta1.SyncConfig{
+					GatewayRoutes: v1beta1.GatewayRouteSyncConfig{
+						Enabled: true,
+						Gateways: []v1beta1.GatewayReference{
+							{Name: "gateway", Namespace: "gateway-system"},
+						},
+					},
+				},
+			},
+		}
+		err = hostTestEnv.k8sClient.Create(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = syncer.AddHTTPRouteSyncer(ctx, virtManager, hostManager, cluster.Name, cluster.Namespace)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ns := v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		err := hostTestEnv.k8sClient.Delete(context.Background(), &ns)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("creates a HTTPRoute attached to the host Gateway", func() {
+		ctx := context.Background()
+
+		route := &gwapiv1.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "route-",
+				Namespace:    "default",
+			},
+			Spec: gwapiv1.HTTPRouteSpec{
+				CommonRouteSpec: gwapiv1.CommonRouteSpec{
+					ParentRefs: []gwapiv1.ParentReference{{Name: "gateway"}},
+				},
+				Hostnames: []gwapiv1.Hostname{"test.com"},
+				Rules: []gwapiv1.HTTPRouteRule{
+					{
+						BackendRefs: []gwapiv1.HTTPBackendRef{
+							{
+								BackendRef: gwapiv1.BackendRef{
+									BackendObjectReference: gwapiv1.BackendObjectReference{
+										Name: "test-service",
+										Port: ptr.To[gwapiv1.PortNumber](8888),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, route)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created HTTPRoute %s in virtual cluster", route.Name))
+
+		var hostRoute gwapiv1.HTTPRoute
+		hostRouteName := translateName(cluster, route.Namespace, route.Name)
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: hostRouteName, Namespace: namespace}
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostRoute)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		By(fmt.Sprintf("Created HTTPRoute %s in host cluster", hostRouteName))
+
+		Expect(hostRoute.Spec.Hostnames).To(ConsistOf(gwapiv1.Hostname("test.com")))
+
+		Expect(hostRoute.Spec.ParentRefs).To(HaveLen(1))
+		Expect(hostRoute.Spec.ParentRefs[0].Name).To(Equal(gwapiv1.ObjectName("gateway")))
+		Expect(hostRoute.Spec.ParentRefs[0].Namespace).To(Equal(ptr.To(gwapiv1.Namespace("gateway-system"))))
+
+		backendRef := hostRoute.Spec.Rules[0].BackendRefs[0]
+		Expect(backendRef.Name).To(Equal(gwapiv1.ObjectName(translateName(cluster, route.Namespace, "test-service"))))
+		Expect(backendRef.Namespace).To(BeNil())
+	})
+
+	It("will not create a HTTPRoute attached to a Gateway not allowed", func() {
+		ctx := context.Background()
+
+		route := &gwapiv1.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "route-",
+				Namespace:    "default",
+			},
+			Spec: gwapiv1.HTTPRouteSpec{
+				CommonRouteSpec: gwapiv1.CommonRouteSpec{
+					ParentRefs: []gwapiv1.ParentReference{{Name: "other-gateway"}},
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, route)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created HTTPRoute %s in virtual cluster", route.Name))
+
+		var hostRoute gwapiv1.HTTPRoute
+		hostRouteName := translateName(cluster, route.Namespace, route.Name)
+
+		Consistently(func() bool {
+			key := client.ObjectKey{Name: hostRouteName, Namespace: namespace}
+			err := hostTestEnv.k8sClient.Get(ctx, key, &hostRoute)
+			return apierrors.IsNotFound(err)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 3).
+			Should(BeTrue())
+	})
+
+	It("will not create a HTTPRoute with a backend of another namespace", func() {
+		ctx := context.Background()
+
+		route := &gwapiv1.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "route-",
+				Namespace:    "default",
+			},
+			Spec: gwapiv1.HTTPRouteSpec{
+				CommonRouteSpec: gwapiv1.CommonRouteSpec{
+					ParentRefs: []gwapiv1.ParentReference{{Name: "gateway"}},
+				},
+				Rules: []gwapiv1.HTTPRouteRule{
+					{
+						BackendRefs: []gwapiv1.HTTPBackendRef{
+							{
+								BackendRef: gwapiv1.BackendRef{
+									BackendObjectReference: gwapiv1.BackendObjectReference{
+										Name:      "test-service",
+										Namespace: ptr.To(gwapiv1.Namespace("kube-system")),
+										Port:      ptr.To[gwapiv1.PortNumber](8888),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, route)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created HTTPRoute %s in virtual cluster", route.Name))
+
+		var hostRoute gwapiv1.HTTPRoute
+		hostRouteName := translateName(cluster, route.Namespace, route.Name)
+
+		Consistently(func() bool {
+			key := client.ObjectKey{Name: hostRouteName, Namespace: namespace}
+			err := hostTestEnv.k8sClient.Get(ctx, key, &hostRoute)
+			return apierrors.IsNotFound(err)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 3).
+			Should(BeTrue())
+	})
+
+	It("reflects the status of the host HTTPRoute", func() {
+		ctx := context.Background()
+
+		route := &gwapiv1.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "route-",
+				Namespace:    "default",
+			},
+			Spec: gwapiv1.HTTPRouteSpec{
+				CommonRouteSpec: gwapiv1.CommonRouteSpec{
+					ParentRefs: []gwapiv1.ParentReference{{Name: "gateway"}},
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, route)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created HTTPRoute %s in virtual cluster", route.Name))
+
+		var hostRoute gwapiv1.HTTPRoute
+		hostRouteName := translateName(cluster, route.Namespace, route.Name)
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: hostRouteName, Namespace: namespace}
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostRoute)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		hostRoute.Status.Parents = []gwapiv1.RouteParentStatus{
+			{
+				ParentRef:      hostRoute.Spec.ParentRefs[0],
+				ControllerName: "example.com/gateway-controller",
+				Conditions: []metav1.Condition{
+					{
+						Type:               string(gwapiv1.RouteConditionAccepted),
+						Status:             metav1.ConditionTrue,
+						Reason:             string(gwapiv1.RouteReasonAccepted),
+						LastTransitionTime: metav1.Now(),
+					},
+				},
+			},
+		}
+
+		err = hostTestEnv.k8sClient.Status().Update(ctx, &hostRoute)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Updated the status of the host HTTPRoute")
+
+		Eventually(func() []gwapiv1.RouteParentStatus {
+			key := client.ObjectKeyFromObject(route)
+			err := virtTestEnv.k8sClient.Get(ctx, key, route)
+			Expect(err).NotTo(HaveOccurred())
+
+			return route.Status.Parents
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(HaveLen(1))
+
+		// the host Gateway is reported with the parentRef of the virtual route
+		Expect(route.Status.Parents[0].ParentRef).To(Equal(route.Spec.ParentRefs[0]))
+		Expect(route.Status.Parents[0].ControllerName).To(Equal(gwapiv1.GatewayController("example.com/gateway-controller")))
+	})
+}
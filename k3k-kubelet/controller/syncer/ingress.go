@@ -2,14 +2,21 @@ package syncer
 
 import (
 	"context"
+	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -17,41 +24,77 @@ import (
 
 	"github.com/rancher/k3k/k3k-kubelet/translate"
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller"
 )
 
 const (
+	// IngressNotSyncedReason is the reason of the events recorded when an Ingress cannot be synced to the host cluster.
+	IngressNotSyncedReason = "IngressNotSynced"
+
+	// legacyIngressClassAnnotation is the deprecated annotation used to specify the class of an Ingress.
+	legacyIngressClassAnnotation = "kubernetes.io/ingress.class"
+
 	ingressControllerName = "ingress-syncer-controller"
 	ingressFinalizerName  = "ingress.k3k.io/finalizer"
 )
 
 type IngressReconciler struct {
 	*SyncerContext
+	record.EventRecorder
 }
 
 // AddIngressSyncer adds ingress syncer controller to the manager of the virtual cluster
 func AddIngressSyncer(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) error {
+	syncerContext, err := newSyncerContext(ctx, virtMgr, hostMgr, clusterName, clusterNamespace)
+	if err != nil {
+		return err
+	}
+
 	reconciler := IngressReconciler{
-		SyncerContext: &SyncerContext{
-			ClusterName:      clusterName,
-			ClusterNamespace: clusterNamespace,
-			VirtualClient:    virtMgr.GetClient(),
-			HostClient:       hostMgr.GetClient(),
-			Translator: translate.ToHostTranslator{
-				ClusterName:      clusterName,
-				ClusterNamespace: clusterNamespace,
-			},
-		},
+		SyncerContext: syncerContext,
 	}
 
 	name := reconciler.Translator.TranslateName(clusterNamespace, ingressControllerName)
 
+	reconciler.EventRecorder = virtMgr.GetEventRecorderFor(name)
+
+	// the host Ingresses are watched to reflect their status back to the virtual Ingresses
+	hostIngressSource := source.Kind(hostMgr.GetCache(), &networkingv1.Ingress{},
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.virtualIngressRequest),
+		predicate.TypedFuncs[*networkingv1.Ingress]{
+			CreateFunc:  func(event.TypedCreateEvent[*networkingv1.Ingress]) bool { return false },
+			DeleteFunc:  func(event.TypedDeleteEvent[*networkingv1.Ingress]) bool { return false },
+			GenericFunc: func(event.TypedGenericEvent[*networkingv1.Ingress]) bool { return false },
+			UpdateFunc: func(e event.TypedUpdateEvent[*networkingv1.Ingress]) bool {
+				return !equality.Semantic.DeepEqual(e.ObjectOld.Status, e.ObjectNew.Status)
+			},
+		},
+	)
+
 	return ctrl.NewControllerManagedBy(virtMgr).
 		Named(name).
 		For(&networkingv1.Ingress{}).
 		WithEventFilter(predicate.NewPredicateFuncs(reconciler.filterResources)).
+		WatchesRawSource(hostIngressSource).
 		Complete(&reconciler)
 }
 
+// virtualIngressRequest maps a synced host Ingress to the request for its virtual Ingress.
+func (r *IngressReconciler) virtualIngressRequest(_ context.Context, hostIngress *networkingv1.Ingress) []reconcile.Request {
+	if !r.Translator.IsHostObject(hostIngress) {
+		return nil
+	}
+
+	name := hostIngress.Annotations[translate.ResourceNameAnnotation]
+	namespace := hostIngress.Annotations[translate.ResourceNamespaceAnnotation]
+
+	if name == "" || namespace == "" {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}}
+}
+
 func (r *IngressReconciler) filterResources(object ctrlruntimeclient.Object) bool {
 	var cluster v1beta1.Cluster
 
@@ -62,7 +105,7 @@ func (r *IngressReconciler) filterResources(object ctrlruntimeclient.Object) boo
 	}
 
 	// check for ingressConfig
-	syncConfig := cluster.Spec.Sync.Ingresses
+	syncConfig := controller.SyncConfig(&cluster).Ingresses
 
 	// If syncing is disabled, only process deletions to allow for cleanup.
 	if !syncConfig.Enabled {
@@ -96,16 +139,38 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req reconcile.Request
 		return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
 	}
 
-	syncedIngress := r.ingress(&virtIngress)
-	if err := controllerutil.SetControllerReference(&cluster, syncedIngress, r.HostClient.Scheme()); err != nil {
+	// the host Ingresses events are not filtered by the sync config
+	if !r.filterResources(&virtIngress) {
+		return reconcile.Result{}, nil
+	}
+
+	syncedIngress, syncErr := r.ingress(&virtIngress, controller.SyncConfig(&cluster).Ingresses)
+	if err := translate.SetClusterOwner(&cluster, syncedIngress, r.HostClient.Scheme()); err != nil {
 		return reconcile.Result{}, err
 	}
 
+	var hostIngress networkingv1.Ingress
+
+	hostIngressExists := true
+
+	if err := r.HostClient.Get(ctx, ctrlruntimeclient.ObjectKeyFromObject(syncedIngress), &hostIngress); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		hostIngressExists = false
+	}
+
+	// an Ingress with the same name not synced from this virtual Ingress must not be overwritten, or deleted
+	isSynced := hostIngressExists && r.isSyncedFrom(&hostIngress, &virtIngress)
+
 	// handle deletion
 	if !virtIngress.DeletionTimestamp.IsZero() {
 		// deleting the synced service if exists
-		if err := r.HostClient.Delete(ctx, syncedIngress); err != nil {
-			return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
+		if isSynced {
+			if err := r.HostClient.Delete(ctx, syncedIngress); err != nil {
+				return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
+			}
 		}
 
 		// remove the finalizer after cleaning up the synced service
@@ -118,6 +183,38 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req reconcile.Request
 		return reconcile.Result{}, nil
 	}
 
+	if hostIngressExists && !isSynced {
+		syncErr = fmt.Errorf("ingress %s/%s already exists", hostIngress.Namespace, hostIngress.Name)
+	}
+
+	if syncErr == nil {
+		usedHosts, err := r.usedHosts(ctx)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+
+		for _, rule := range syncedIngress.Spec.Rules {
+			if ingressName, found := usedHosts[rule.Host]; found && rule.Host != "" {
+				syncErr = fmt.Errorf("host %q is already used by the ingress %s", rule.Host, ingressName)
+				break
+			}
+		}
+	}
+
+	// the Ingresses that cannot be synced are removed from the host, if they were synced before
+	if syncErr != nil {
+		log.Info("ingress cannot be synced to the host cluster", "reason", syncErr.Error())
+		r.Eventf(&virtIngress, corev1.EventTypeWarning, IngressNotSyncedReason, "Ingress not synced to the host cluster: %s", syncErr.Error())
+
+		if isSynced {
+			if err := r.HostClient.Delete(ctx, syncedIngress); err != nil {
+				return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
+			}
+		}
+
+		return reconcile.Result{}, nil
+	}
+
 	// Add finalizer if it does not exist
 
 	if controllerutil.AddFinalizer(&virtIngress, ingressFinalizerName) {
@@ -127,27 +224,72 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req reconcile.Request
 	}
 
 	// create or update the ingress on host
-	var hostIngress networkingv1.Ingress
-	if err := r.HostClient.Get(ctx, types.NamespacedName{Name: syncedIngress.Name, Namespace: r.ClusterNamespace}, &hostIngress); err != nil {
-		if apierrors.IsNotFound(err) {
-			log.Info("creating the ingress for the first time on the host cluster")
-			return reconcile.Result{}, r.HostClient.Create(ctx, syncedIngress)
-		}
+	if !hostIngressExists {
+		log.Info("creating the ingress for the first time on the host cluster")
+		return reconcile.Result{}, r.HostClient.Create(ctx, syncedIngress)
+	}
 
+	log.Info("updating ingress on the host cluster")
+
+	if err := r.HostClient.Update(ctx, syncedIngress); err != nil {
 		return reconcile.Result{}, err
 	}
 
-	log.Info("updating ingress on the host cluster")
+	// reflect the load balancer status of the host Ingress to the virtual one
+	if !equality.Semantic.DeepEqual(virtIngress.Status.LoadBalancer, hostIngress.Status.LoadBalancer) {
+		log.Info("updating ingress status on the virtual cluster")
 
-	return reconcile.Result{}, r.HostClient.Update(ctx, syncedIngress)
+		virtIngress.Status.LoadBalancer = *hostIngress.Status.LoadBalancer.DeepCopy()
+
+		return reconcile.Result{}, r.VirtualClient.Status().Update(ctx, &virtIngress)
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// isSyncedFrom returns true if the host Ingress was synced from the given virtual Ingress.
+func (r *IngressReconciler) isSyncedFrom(hostIngress, virtIngress *networkingv1.Ingress) bool {
+	return hostIngress.Labels[translate.ClusterNameLabel] == r.ClusterName &&
+		hostIngress.Annotations[translate.ResourceNameAnnotation] == virtIngress.Name &&
+		hostIngress.Annotations[translate.ResourceNamespaceAnnotation] == virtIngress.Namespace
 }
 
-func (s *IngressReconciler) ingress(obj *networkingv1.Ingress) *networkingv1.Ingress {
+// usedHosts returns the hosts used by the host Ingresses visible to the virtual kubelet that don't belong to the virtual
+// cluster, with the namespaced name of the Ingress using them. The Ingresses of the other host namespaces are checked
+// by the k3k controller, that deletes the synced Ingresses using their hosts.
+func (r *IngressReconciler) usedHosts(ctx context.Context) (map[string]string, error) {
+	var hostIngresses networkingv1.IngressList
+	if err := r.HostClient.List(ctx, &hostIngresses); err != nil {
+		return nil, err
+	}
+
+	usedHosts := make(map[string]string)
+
+	for _, hostIngress := range hostIngresses.Items {
+		if hostIngress.Labels[translate.ClusterNameLabel] == r.ClusterName {
+			continue
+		}
+
+		for _, rule := range hostIngress.Spec.Rules {
+			usedHosts[rule.Host] = hostIngress.Namespace + "/" + hostIngress.Name
+		}
+	}
+
+	return usedHosts, nil
+}
+
+// ingress translates the virtual Ingress to the host one. The services and secrets references are translated to the
+// synced resources, and the class is mapped to the host IngressClass. An error is returned if the class is not mapped.
+func (s *IngressReconciler) ingress(obj *networkingv1.Ingress, syncConfig v1beta1.IngressSyncConfig) (*networkingv1.Ingress, error) {
 	hostIngress := obj.DeepCopy()
 	s.Translator.TranslateTo(hostIngress)
 
+	// modify the default backend and the services in rules to point to the synced services
+	if hostIngress.Spec.DefaultBackend != nil && hostIngress.Spec.DefaultBackend.Service != nil {
+		hostIngress.Spec.DefaultBackend.Service.Name = s.Translator.TranslateName(obj.GetNamespace(), hostIngress.Spec.DefaultBackend.Service.Name)
+	}
+
 	for _, rule := range hostIngress.Spec.Rules {
-		// modify services in rules to point to the synced services
 		if rule.HTTP != nil {
 			for _, path := range rule.HTTP.Paths {
 				if path.Backend.Service != nil {
@@ -156,6 +298,34 @@ func (s *IngressReconciler) ingress(obj *networkingv1.Ingress) *networkingv1.Ing
 			}
 		}
 	}
-	// don't sync finalizers to the host
-	return hostIngress
+
+	// modify the TLS secrets to point to the synced secrets
+	for i, tls := range hostIngress.Spec.TLS {
+		if tls.SecretName != "" {
+			hostIngress.Spec.TLS[i].SecretName = s.Translator.TranslateName(obj.GetNamespace(), tls.SecretName)
+		}
+	}
+
+	// the status is reflected from the host to the virtual cluster
+	hostIngress.Status = networkingv1.IngressStatus{}
+
+	if len(syncConfig.IngressClassMapping) == 0 {
+		return hostIngress, nil
+	}
+
+	ingressClassName := obj.Annotations[legacyIngressClassAnnotation]
+	if obj.Spec.IngressClassName != nil {
+		ingressClassName = *obj.Spec.IngressClassName
+	}
+
+	hostIngressClassName, found := syncConfig.IngressClassMapping[ingressClassName]
+	if !found {
+		return hostIngress, fmt.Errorf("ingressClassName %q is not allowed", ingressClassName)
+	}
+
+	delete(hostIngress.Annotations, legacyIngressClassAnnotation)
+
+	hostIngress.Spec.IngressClassName = &hostIngressClassName
+
+	return hostIngress, nil
 }
@@ -346,4 +346,160 @@ var IngressTests = func() {
 			WithTimeout(time.Second * 10).
 			Should(BeTrue())
 	})
+
+	It("translates the TLS secrets and the default backend of an Ingress", func() {
+		ctx := context.Background()
+
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "ingress-",
+				Namespace:    "default",
+			},
+			Spec: networkingv1.IngressSpec{
+				DefaultBackend: &networkingv1.IngressBackend{
+					Service: &networkingv1.IngressServiceBackend{
+						Name: "default-service",
+						Port: networkingv1.ServiceBackendPort{Number: 80},
+					},
+				},
+				TLS: []networkingv1.IngressTLS{
+					{
+						Hosts:      []string{"test.com"},
+						SecretName: "test-tls",
+					},
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, ingress)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created Ingress %s in virtual cluster", ingress.Name))
+
+		var hostIngress networkingv1.Ingress
+		hostIngressName := translateName(cluster, ingress.Namespace, ingress.Name)
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: hostIngressName, Namespace: namespace}
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostIngress)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		By(fmt.Sprintf("Created Ingress %s in host cluster", hostIngressName))
+
+		Expect(hostIngress.Spec.DefaultBackend.Service.Name).To(Equal(translateName(cluster, ingress.Namespace, "default-service")))
+		Expect(hostIngress.Spec.TLS).To(HaveLen(1))
+		Expect(hostIngress.Spec.TLS[0].SecretName).To(Equal(translateName(cluster, ingress.Namespace, "test-tls")))
+	})
+
+	It("maps the ingressClassName to the host IngressClass", func() {
+		ctx := context.Background()
+
+		cluster.Spec.Sync.Ingresses.IngressClassMapping = map[string]string{
+			"nginx": "tenant-nginx",
+		}
+		err := hostTestEnv.k8sClient.Update(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "ingress-",
+				Namespace:    "default",
+			},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: ptr.To("nginx"),
+				DefaultBackend: &networkingv1.IngressBackend{
+					Service: &networkingv1.IngressServiceBackend{
+						Name: "test-service",
+						Port: networkingv1.ServiceBackendPort{Number: 80},
+					},
+				},
+			},
+		}
+
+		notAllowedIngress := ingress.DeepCopy()
+		notAllowedIngress.Spec.IngressClassName = ptr.To("traefik")
+
+		err = virtTestEnv.k8sClient.Create(ctx, ingress)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = virtTestEnv.k8sClient.Create(ctx, notAllowedIngress)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created Ingresses %s and %s in virtual cluster", ingress.Name, notAllowedIngress.Name))
+
+		var hostIngress networkingv1.Ingress
+		hostIngressName := translateName(cluster, ingress.Namespace, ingress.Name)
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: hostIngressName, Namespace: namespace}
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostIngress)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		Expect(hostIngress.Spec.IngressClassName).To(Equal(ptr.To("tenant-nginx")))
+
+		Consistently(func() bool {
+			key := client.ObjectKey{Name: translateName(cluster, notAllowedIngress.Namespace, notAllowedIngress.Name), Namespace: namespace}
+			err := hostTestEnv.k8sClient.Get(ctx, key, &hostIngress)
+			return apierrors.IsNotFound(err)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 3).
+			Should(BeTrue())
+	})
+
+	It("reflects the status of the host Ingress", func() {
+		ctx := context.Background()
+
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "ingress-",
+				Namespace:    "default",
+			},
+			Spec: networkingv1.IngressSpec{
+				DefaultBackend: &networkingv1.IngressBackend{
+					Service: &networkingv1.IngressServiceBackend{
+						Name: "test-service",
+						Port: networkingv1.ServiceBackendPort{Number: 80},
+					},
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, ingress)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created Ingress %s in virtual cluster", ingress.Name))
+
+		var hostIngress networkingv1.Ingress
+		hostIngressName := translateName(cluster, ingress.Namespace, ingress.Name)
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: hostIngressName, Namespace: namespace}
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostIngress)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		hostIngress.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}}
+		err = hostTestEnv.k8sClient.Status().Update(ctx, &hostIngress)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() []networkingv1.IngressLoadBalancerIngress {
+			key := client.ObjectKeyFromObject(ingress)
+			err := virtTestEnv.k8sClient.Get(ctx, key, ingress)
+			Expect(err).NotTo(HaveOccurred())
+			return ingress.Status.LoadBalancer.Ingress
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(Equal([]networkingv1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}}))
+	})
+
 }
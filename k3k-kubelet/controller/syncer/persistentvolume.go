@@ -0,0 +1,365 @@
+package syncer
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/component-helpers/storage/volume"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// hostPVAnnotation is the annotation with the name of the host PersistentVolume reflected in the virtual cluster
+	hostPVAnnotation = "pv.k3k.io/host-pv"
+
+	// placeholderPVDriver is the FlexVolume driver of the PersistentVolumes of the virtual cluster.
+	// The volumes are mounted by the host kubelet, so the virtual ones don't need an actual volume source.
+	placeholderPVDriver = "k3k.io/placeholder"
+
+	// virtualPVProvisioner is the provisioner of the PersistentVolumes created by k3k-kubelet in the virtual cluster
+	virtualPVProvisioner = "k3k-kubelet"
+
+	pvControllerName = "pv-controller"
+)
+
+type PVReconciler struct {
+	*SyncerContext
+}
+
+// AddPVController adds the controller of the PersistentVolumes bound to the virtual PVCs to k3k-kubelet.
+// The PersistentVolumes are deleted when their PVC is deleted, and the legacy ones are replaced with placeholder volumes.
+func AddPVController(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) error {
+	syncerContext, err := newSyncerContext(ctx, virtMgr, hostMgr, clusterName, clusterNamespace)
+	if err != nil {
+		return err
+	}
+
+	reconciler := PVReconciler{
+		SyncerContext: syncerContext,
+	}
+
+	name := reconciler.Translator.TranslateName(clusterNamespace, pvControllerName)
+
+	// the PVCs are deleted without the finalizer if they were not synced, i.e. if they don't match the sync selector
+	pvcDeletions := predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		UpdateFunc:  func(event.UpdateEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+
+	return ctrl.NewControllerManagedBy(virtMgr).
+		Named(name).
+		For(&v1.PersistentVolume{}, builder.WithPredicates(predicate.NewPredicateFuncs(isVirtualPV))).
+		Watches(&v1.PersistentVolumeClaim{}, handler.EnqueueRequestsFromMapFunc(virtualPVRequest), builder.WithPredicates(pvcDeletions)).
+		Complete(&reconciler)
+}
+
+// virtualPVRequest maps a virtual PVC to the request for its PersistentVolume.
+func virtualPVRequest(_ context.Context, obj ctrlruntimeclient.Object) []reconcile.Request {
+	pvc, ok := obj.(*v1.PersistentVolumeClaim)
+	if !ok {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: virtualPVName(pvc)}}}
+}
+
+func (r *PVReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithValues("cluster", r.ClusterName, "clusterNamespace", r.ClusterNamespace)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	var pv v1.PersistentVolume
+	if err := r.VirtualClient.Get(ctx, req.NamespacedName, &pv); err != nil {
+		return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
+	}
+
+	if !isVirtualPV(&pv) {
+		return reconcile.Result{}, nil
+	}
+
+	var pvc v1.PersistentVolumeClaim
+
+	claimRef := pv.Spec.ClaimRef
+	if claimRef != nil {
+		if err := r.VirtualClient.Get(ctx, types.NamespacedName{Name: claimRef.Name, Namespace: claimRef.Namespace}, &pvc); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return reconcile.Result{}, err
+			}
+		}
+	}
+
+	// the PVC bound to the volume was deleted, or it was recreated with the same name
+	if claimRef == nil || pvc.UID == "" || pvc.UID != claimRef.UID {
+		log.Info("deleting the persistent volume of a deleted persistent volume claim", "PersistentVolume", pv.Name)
+
+		return reconcile.Result{}, deleteVirtualPV(ctx, r.VirtualClient, pv.Name)
+	}
+
+	if isPlaceholderPV(&pv) || !pv.DeletionTimestamp.IsZero() || !pvc.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	log.Info("replacing the legacy persistent volume with a placeholder volume", "PersistentVolume", pv.Name)
+
+	// the legacy placeholder volumes were named after the PVC, and they are kept if the PVC is bound to them
+	if pv.Name != virtualPVName(&pvc) {
+		if err := deleteVirtualPV(ctx, r.VirtualClient, pv.Name); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	hostPVC, hostPV, err := r.hostPV(ctx, &pvc)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	return reconcile.Result{}, ensureVirtualPV(ctx, r.VirtualClient, &pvc, hostPVC, hostPV)
+}
+
+// virtualPVName returns the name of the PersistentVolume bound to the virtual PVC.
+// The PVCs already bound keep their volume, since the volume name of a PVC cannot be changed.
+func virtualPVName(pvc *v1.PersistentVolumeClaim) string {
+	if pvc.Spec.VolumeName != "" {
+		return pvc.Spec.VolumeName
+	}
+
+	return "pvc-" + string(pvc.UID)
+}
+
+// isVirtualPV returns true if the PersistentVolume was created by k3k-kubelet in the virtual cluster.
+func isVirtualPV(obj ctrlruntimeclient.Object) bool {
+	return obj.GetAnnotations()[volume.AnnDynamicallyProvisioned] == virtualPVProvisioner
+}
+
+// isPlaceholderPV returns true if the PersistentVolume has the placeholder volume source.
+// The PersistentVolumes created by previous releases have the legacy placeholder source, or the source of the host volume.
+func isPlaceholderPV(pv *v1.PersistentVolume) bool {
+	return pv.Spec.FlexVolume != nil && pv.Spec.FlexVolume.Driver == placeholderPVDriver
+}
+
+// virtualPV returns the PersistentVolume bound to the virtual PVC in the virtual cluster.
+// The volume source is a placeholder, since the volume is mounted by the host kubelet, and the host volume source
+// can reference objects and credentials of the host cluster. If the host PVC is bound, the capacity and the access modes
+// of the host PersistentVolume are reflected. Otherwise the volume is used to schedule the pods with a WaitForFirstConsumer
+// PVC not bound yet.
+func virtualPV(pvc *v1.PersistentVolumeClaim, hostPV *v1.PersistentVolume) *v1.PersistentVolume {
+	pv := &v1.PersistentVolume{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PersistentVolume",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: virtualPVName(pvc),
+			Annotations: map[string]string{
+				volume.AnnBoundByController:      "true",
+				volume.AnnDynamicallyProvisioned: virtualPVProvisioner,
+			},
+		},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				FlexVolume: &v1.FlexPersistentVolumeSource{Driver: placeholderPVDriver},
+			},
+			StorageClassName:              ptr.Deref(pvc.Spec.StorageClassName, ""),
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimDelete,
+			ClaimRef: &v1.ObjectReference{
+				APIVersion: "v1",
+				Kind:       "PersistentVolumeClaim",
+				UID:        pvc.UID,
+				Namespace:  pvc.Namespace,
+				Name:       pvc.Name,
+			},
+		},
+	}
+
+	if hostPV == nil {
+		pv.Spec.VolumeMode = pvc.Spec.VolumeMode
+		pv.Spec.AccessModes = pvc.Spec.AccessModes
+		pv.Spec.Capacity = pvc.Spec.Resources.Requests
+
+		return pv
+	}
+
+	pv.Annotations[hostPVAnnotation] = hostPV.Name
+	pv.Spec.VolumeMode = hostPV.Spec.VolumeMode
+	pv.Spec.AccessModes = hostPV.Spec.AccessModes
+	pv.Spec.Capacity = hostPV.Spec.Capacity
+
+	return pv
+}
+
+// ensureVirtualPV creates or updates the PersistentVolume bound to the virtual PVC, and binds the PVC to it.
+// The volume source of an existing PersistentVolume is immutable, so only its capacity and annotations are updated,
+// and the legacy volumes are deleted and created again. The PersistentVolumes not created by k3k-kubelet are left untouched.
+// If the host PVC is bound, its status is reflected to the virtual PVC.
+func ensureVirtualPV(ctx context.Context, virtClient ctrlruntimeclient.Client, pvc, hostPVC *v1.PersistentVolumeClaim, hostPV *v1.PersistentVolume) error {
+	pv := virtualPV(pvc, hostPV)
+
+	var currentPV v1.PersistentVolume
+
+	err := virtClient.Get(ctx, ctrlruntimeclient.ObjectKeyFromObject(pv), &currentPV)
+	if ctrlruntimeclient.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	found := err == nil
+
+	if found && !isVirtualPV(&currentPV) {
+		return nil
+	}
+
+	if found && !isPlaceholderPV(&currentPV) {
+		if err := deleteVirtualPV(ctx, virtClient, currentPV.Name); err != nil {
+			return err
+		}
+
+		found = false
+	}
+
+	if !found {
+		if err := virtClient.Create(ctx, pv); err != nil {
+			return err
+		}
+
+		currentPV = *pv
+	} else if !equality.Semantic.DeepEqual(currentPV.Spec.Capacity, pv.Spec.Capacity) || currentPV.Annotations[hostPVAnnotation] != pv.Annotations[hostPVAnnotation] {
+		if currentPV.Annotations == nil {
+			currentPV.Annotations = make(map[string]string)
+		}
+
+		currentPV.Annotations[hostPVAnnotation] = pv.Annotations[hostPVAnnotation]
+		currentPV.Spec.Capacity = pv.Spec.Capacity
+
+		if err := virtClient.Update(ctx, &currentPV); err != nil {
+			return err
+		}
+	}
+
+	if currentPV.Status.Phase != v1.VolumeBound {
+		orig := currentPV.DeepCopy()
+		currentPV.Status = v1.PersistentVolumeStatus{
+			Phase: v1.VolumeBound,
+		}
+
+		if err := virtClient.Status().Patch(ctx, &currentPV, ctrlruntimeclient.MergeFrom(orig)); err != nil {
+			return err
+		}
+	}
+
+	return bindVirtualPVC(ctx, virtClient, pvc, hostPVC, &currentPV)
+}
+
+// deleteVirtualPV deletes a PersistentVolume created by k3k-kubelet in the virtual cluster. Its finalizers are removed,
+// since the pv-protection finalizer is not removed while the volume is bound, and there is no actual volume to release.
+func deleteVirtualPV(ctx context.Context, virtClient ctrlruntimeclient.Client, name string) error {
+	var pv v1.PersistentVolume
+	if err := virtClient.Get(ctx, types.NamespacedName{Name: name}, &pv); err != nil {
+		return ctrlruntimeclient.IgnoreNotFound(err)
+	}
+
+	if !isVirtualPV(&pv) {
+		return nil
+	}
+
+	if pv.DeletionTimestamp.IsZero() {
+		if err := virtClient.Delete(ctx, &pv); err != nil {
+			return ctrlruntimeclient.IgnoreNotFound(err)
+		}
+	}
+
+	if len(pv.Finalizers) == 0 {
+		return nil
+	}
+
+	pv.Finalizers = nil
+
+	return ctrlruntimeclient.IgnoreNotFound(virtClient.Update(ctx, &pv))
+}
+
+// bindVirtualPVC binds the virtual PVC to the PersistentVolume, and updates its status with the capacity of the volume.
+// The capacity, conditions and allocated resources of the bound host PVC are reflected, to report the resize status.
+func bindVirtualPVC(ctx context.Context, virtClient ctrlruntimeclient.Client, pvc, hostPVC *v1.PersistentVolumeClaim, pv *v1.PersistentVolume) error {
+	if pvc.Spec.VolumeName == "" {
+		pvc.Spec.VolumeName = pv.Name
+
+		if err := virtClient.Update(ctx, pvc); err != nil {
+			return err
+		}
+	}
+
+	pvcStatus := pvc.DeepCopy()
+	if pvcStatus.Annotations == nil {
+		pvcStatus.Annotations = make(map[string]string)
+	}
+
+	pvcStatus.Annotations[volume.AnnBoundByController] = "yes"
+	pvcStatus.Annotations[volume.AnnBindCompleted] = "yes"
+	pvcStatus.Status.Phase = v1.ClaimBound
+	pvcStatus.Status.AccessModes = pv.Spec.AccessModes
+	pvcStatus.Status.Capacity = pv.Spec.Capacity
+
+	if hostPVC != nil && hostPVC.Status.Phase == v1.ClaimBound {
+		pvcStatus.Status.Capacity = hostPVC.Status.Capacity
+		pvcStatus.Status.Conditions = hostPVC.Status.Conditions
+		pvcStatus.Status.AllocatedResources = hostPVC.Status.AllocatedResources
+		pvcStatus.Status.AllocatedResourceStatuses = hostPVC.Status.AllocatedResourceStatuses
+	}
+
+	if equality.Semantic.DeepEqual(pvc.Annotations, pvcStatus.Annotations) && equality.Semantic.DeepEqual(pvc.Status, pvcStatus.Status) {
+		return nil
+	}
+
+	if err := virtClient.Status().Update(ctx, pvcStatus); err != nil {
+		return err
+	}
+
+	*pvc = *pvcStatus
+
+	return nil
+}
+
+// hostPV returns the PVC synced from the virtual PVC and its bound host PersistentVolume, or nil if it's not bound yet.
+func (c *SyncerContext) hostPV(ctx context.Context, virtPVC *v1.PersistentVolumeClaim) (*v1.PersistentVolumeClaim, *v1.PersistentVolume, error) {
+	var hostPVC v1.PersistentVolumeClaim
+
+	if err := c.HostClient.Get(ctx, c.Translator.NamespacedName(virtPVC), &hostPVC); err != nil {
+		return nil, nil, ctrlruntimeclient.IgnoreNotFound(err)
+	}
+
+	hostPV, err := c.boundPV(ctx, &hostPVC)
+	if err != nil || hostPV == nil {
+		return nil, nil, err
+	}
+
+	return &hostPVC, hostPV, nil
+}
+
+// boundPV returns the host PersistentVolume bound to the host PVC, or nil if it's not bound yet.
+func (c *SyncerContext) boundPV(ctx context.Context, hostPVC *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+	if hostPVC.Status.Phase != v1.ClaimBound || hostPVC.Spec.VolumeName == "" {
+		return nil, nil
+	}
+
+	var hostPV v1.PersistentVolume
+	if err := c.HostClient.Get(ctx, types.NamespacedName{Name: hostPVC.Spec.VolumeName}, &hostPV); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &hostPV, nil
+}
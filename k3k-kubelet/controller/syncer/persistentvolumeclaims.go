@@ -2,14 +2,24 @@ package syncer
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"strings"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -17,41 +27,75 @@ import (
 
 	"github.com/rancher/k3k/k3k-kubelet/translate"
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller"
 )
 
 const (
+	// PVCNotSyncedReason is the reason of the events recorded when a PVC cannot be synced to the host cluster.
+	PVCNotSyncedReason = "PersistentVolumeClaimNotSynced"
+
 	pvcControllerName = "pvc-syncer-controller"
 	pvcFinalizerName  = "pvc.k3k.io/finalizer"
 )
 
 type PVCReconciler struct {
 	*SyncerContext
+	record.EventRecorder
 }
 
 // AddPVCSyncer adds persistentvolumeclaims syncer controller to k3k-kubelet
 func AddPVCSyncer(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) error {
+	syncerContext, err := newSyncerContext(ctx, virtMgr, hostMgr, clusterName, clusterNamespace)
+	if err != nil {
+		return err
+	}
+
 	reconciler := PVCReconciler{
-		SyncerContext: &SyncerContext{
-			ClusterName:      clusterName,
-			ClusterNamespace: clusterNamespace,
-			VirtualClient:    virtMgr.GetClient(),
-			HostClient:       hostMgr.GetClient(),
-			Translator: translate.ToHostTranslator{
-				ClusterName:      clusterName,
-				ClusterNamespace: clusterNamespace,
-			},
-		},
+		SyncerContext: syncerContext,
 	}
 
 	name := reconciler.Translator.TranslateName(clusterNamespace, pvcControllerName)
 
+	reconciler.EventRecorder = virtMgr.GetEventRecorderFor(name)
+
+	// the host PVCs are watched to reflect their bound PersistentVolume to the virtual cluster
+	hostPVCSource := source.Kind(hostMgr.GetCache(), &v1.PersistentVolumeClaim{},
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.virtualPVCRequest),
+		predicate.TypedFuncs[*v1.PersistentVolumeClaim]{
+			CreateFunc:  func(event.TypedCreateEvent[*v1.PersistentVolumeClaim]) bool { return false },
+			DeleteFunc:  func(event.TypedDeleteEvent[*v1.PersistentVolumeClaim]) bool { return false },
+			GenericFunc: func(event.TypedGenericEvent[*v1.PersistentVolumeClaim]) bool { return false },
+			UpdateFunc: func(e event.TypedUpdateEvent[*v1.PersistentVolumeClaim]) bool {
+				return e.ObjectOld.Spec.VolumeName != e.ObjectNew.Spec.VolumeName ||
+					!equality.Semantic.DeepEqual(e.ObjectOld.Status, e.ObjectNew.Status)
+			},
+		},
+	)
+
 	return ctrl.NewControllerManagedBy(virtMgr).
 		Named(name).
 		For(&v1.PersistentVolumeClaim{}).
 		WithEventFilter(predicate.NewPredicateFuncs(reconciler.filterResources)).
+		WatchesRawSource(hostPVCSource).
 		Complete(&reconciler)
 }
 
+// virtualPVCRequest maps a synced host PVC to the request for its virtual PVC.
+func (r *PVCReconciler) virtualPVCRequest(_ context.Context, hostPVC *v1.PersistentVolumeClaim) []reconcile.Request {
+	if !r.Translator.IsHostObject(hostPVC) {
+		return nil
+	}
+
+	name := hostPVC.Annotations[translate.ResourceNameAnnotation]
+	namespace := hostPVC.Annotations[translate.ResourceNamespaceAnnotation]
+
+	if name == "" || namespace == "" {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}}
+}
+
 func (r *PVCReconciler) filterResources(object ctrlruntimeclient.Object) bool {
 	var cluster v1beta1.Cluster
 
@@ -62,7 +106,7 @@ func (r *PVCReconciler) filterResources(object ctrlruntimeclient.Object) bool {
 	}
 
 	// check for pvc config
-	syncConfig := cluster.Spec.Sync.PersistentVolumeClaims
+	syncConfig := controller.SyncConfig(&cluster).PersistentVolumeClaims
 
 	// If syncing is disabled, only process deletions to allow for cleanup.
 	if !syncConfig.Enabled {
@@ -94,8 +138,13 @@ func (r *PVCReconciler) Reconcile(ctx context.Context, req reconcile.Request) (r
 		return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
 	}
 
-	syncedPVC := r.pvc(&virtPVC)
-	if err := controllerutil.SetControllerReference(&cluster, syncedPVC, r.HostClient.Scheme()); err != nil {
+	// the host PVCs events are not filtered by the sync config
+	if !r.filterResources(&virtPVC) {
+		return reconcile.Result{}, nil
+	}
+
+	syncedPVC, syncErr := r.pvc(&virtPVC, controller.SyncConfig(&cluster).PersistentVolumeClaims)
+	if err := translate.SetClusterOwner(&cluster, syncedPVC, r.HostClient.Scheme()); err != nil {
 		return reconcile.Result{}, err
 	}
 
@@ -105,6 +154,12 @@ func (r *PVCReconciler) Reconcile(ctx context.Context, req reconcile.Request) (r
 		if err := r.HostClient.Delete(ctx, syncedPVC); err != nil && !apierrors.IsNotFound(err) {
 			return reconcile.Result{}, err
 		}
+
+		// deleting the PersistentVolume bound to the virtual pvc
+		if err := deleteVirtualPV(ctx, r.VirtualClient, virtualPVName(&virtPVC)); err != nil {
+			return reconcile.Result{}, err
+		}
+
 		// remove the finalizer after cleaning up the synced pvc
 		if controllerutil.RemoveFinalizer(&virtPVC, pvcFinalizerName) {
 			if err := r.VirtualClient.Update(ctx, &virtPVC); err != nil {
@@ -122,17 +177,165 @@ func (r *PVCReconciler) Reconcile(ctx context.Context, req reconcile.Request) (r
 		}
 	}
 
-	// create the pvc on host
-	log.Info("creating the persistent volume claim for the first time on the host cluster")
+	var hostPVC v1.PersistentVolumeClaim
+	if err := r.HostClient.Get(ctx, ctrlruntimeclient.ObjectKeyFromObject(syncedPVC), &hostPVC); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		// the PVCs already synced are kept if the StorageClass mapping changes, to preserve their data
+		if syncErr != nil {
+			log.Info("persistent volume claim cannot be synced to the host cluster", "reason", syncErr.Error())
+			r.Eventf(&virtPVC, v1.EventTypeWarning, PVCNotSyncedReason, "PersistentVolumeClaim not synced to the host cluster: %s", syncErr.Error())
+
+			return reconcile.Result{}, nil
+		}
+
+		// create the pvc on host
+		log.Info("creating the persistent volume claim for the first time on the host cluster")
+
+		return reconcile.Result{}, ctrlruntimeclient.IgnoreAlreadyExists(r.HostClient.Create(ctx, syncedPVC))
+	}
+
+	// propagate the labels, the annotations and the storage requests to expand the volume
+	if updatedPVC := updatedPVC(&hostPVC, syncedPVC); !equality.Semantic.DeepEqual(&hostPVC, updatedPVC) {
+		log.Info("updating the persistent volume claim on the host cluster")
+
+		if err := r.HostClient.Update(ctx, updatedPVC); err != nil {
+			if !apierrors.IsInvalid(err) && !apierrors.IsForbidden(err) {
+				return reconcile.Result{}, err
+			}
+
+			// the update was rejected by the host cluster, i.e. the StorageClass doesn't allow the volume expansion
+			r.Eventf(&virtPVC, v1.EventTypeWarning, PVCNotSyncedReason, "PersistentVolumeClaim not updated in the host cluster: %s", err.Error())
+		} else {
+			hostPVC = *updatedPVC
+		}
+	}
+
+	hostPV, err := r.boundPV(ctx, &hostPVC)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// the host PVC is not bound yet, i.e. with a WaitForFirstConsumer StorageClass
+	if hostPV == nil {
+		return reconcile.Result{}, nil
+	}
+
+	log.Info("reflecting the bound persistent volume of the host cluster", "PersistentVolume", hostPV.Name)
 
-	// note that we dont need to update the PVC on the host cluster, only syncing the PVC to allow being
-	// handled by the host cluster.
-	return reconcile.Result{}, ctrlruntimeclient.IgnoreAlreadyExists(r.HostClient.Create(ctx, syncedPVC))
+	return reconcile.Result{}, ensureVirtualPV(ctx, r.VirtualClient, &virtPVC, &hostPVC, hostPV)
 }
 
-func (r *PVCReconciler) pvc(obj *v1.PersistentVolumeClaim) *v1.PersistentVolumeClaim {
+// pvc translates the virtual PVC to the host one. The data sources are translated to the synced resources, and the
+// StorageClass is mapped to the host StorageClass. An error is returned if the StorageClass is not mapped.
+func (r *PVCReconciler) pvc(obj *v1.PersistentVolumeClaim, syncConfig v1beta1.PersistentVolumeClaimSyncConfig) (*v1.PersistentVolumeClaim, error) {
 	hostPVC := obj.DeepCopy()
 	r.Translator.TranslateTo(hostPVC)
 
-	return hostPVC
+	// the binding of the PVCs is managed separately in each cluster
+	for key := range hostPVC.Annotations {
+		if isVolumeBindingAnnotation(key) {
+			delete(hostPVC.Annotations, key)
+		}
+	}
+
+	hostPVC.Spec.VolumeName = ""
+	hostPVC.Status = v1.PersistentVolumeClaimStatus{}
+
+	// the PVCs and the VolumeSnapshots used as data sources are translated to the synced ones
+	if dataSource := hostPVC.Spec.DataSource; dataSource != nil && isSyncedDataSource(dataSource.APIGroup, dataSource.Kind) {
+		dataSource.Name = r.Translator.TranslateName(obj.Namespace, dataSource.Name)
+	}
+
+	if dataSourceRef := hostPVC.Spec.DataSourceRef; dataSourceRef != nil && isSyncedDataSource(dataSourceRef.APIGroup, dataSourceRef.Kind) {
+		namespace := obj.Namespace
+		if dataSourceRef.Namespace != nil {
+			namespace = *dataSourceRef.Namespace
+		}
+
+		dataSourceRef.Name = r.Translator.TranslateName(namespace, dataSourceRef.Name)
+		dataSourceRef.Namespace = nil
+	}
+
+	if len(syncConfig.StorageClassMapping) == 0 {
+		return hostPVC, nil
+	}
+
+	storageClassName := ptr.Deref(obj.Spec.StorageClassName, syncConfig.DefaultStorageClassName)
+
+	hostStorageClassName, found := syncConfig.StorageClassMapping[storageClassName]
+	if !found {
+		return hostPVC, fmt.Errorf("storageClassName %q is not allowed", storageClassName)
+	}
+
+	hostPVC.Spec.StorageClassName = &hostStorageClassName
+
+	return hostPVC, nil
+}
+
+// updatedPVC returns the host PVC updated with the labels, the annotations and the storage requests of the synced PVC.
+// The labels and annotations removed from the virtual PVC are removed from the host PVC, except the tracking keys of
+// k3k and the annotations of the host controllers binding the volume. The storage requests can be changed only for
+// the bound PVCs.
+func updatedPVC(hostPVC, syncedPVC *v1.PersistentVolumeClaim) *v1.PersistentVolumeClaim {
+	updated := hostPVC.DeepCopy()
+
+	updated.Labels = maps.Clone(syncedPVC.Labels)
+	if updated.Labels == nil {
+		updated.Labels = make(map[string]string)
+	}
+
+	updated.Annotations = maps.Clone(syncedPVC.Annotations)
+	if updated.Annotations == nil {
+		updated.Annotations = make(map[string]string)
+	}
+
+	for key, value := range hostPVC.Labels {
+		if _, found := updated.Labels[key]; !found && isTrackingKey(key) {
+			updated.Labels[key] = value
+		}
+	}
+
+	for key, value := range hostPVC.Annotations {
+		if _, found := updated.Annotations[key]; !found && (isTrackingKey(key) || isVolumeBindingAnnotation(key)) {
+			updated.Annotations[key] = value
+		}
+	}
+
+	if updated.Status.Phase == v1.ClaimBound {
+		updated.Spec.Resources.Requests = syncedPVC.Spec.Resources.Requests
+	}
+
+	return updated
+}
+
+// isTrackingKey returns true if the label or annotation is set by k3k to track the virtual object of a host object.
+func isTrackingKey(key string) bool {
+	switch key {
+	case translate.ClusterNameLabel, translate.ClusterNamespaceLabel, translate.ResourceNameAnnotation, translate.ResourceNamespaceAnnotation:
+		return true
+	default:
+		return false
+	}
+}
+
+// isVolumeBindingAnnotation returns true if the annotation is set by the controllers binding and provisioning the volumes.
+func isVolumeBindingAnnotation(key string) bool {
+	return strings.HasPrefix(key, "pv.kubernetes.io/") ||
+		strings.HasPrefix(key, "volume.kubernetes.io/") ||
+		strings.HasPrefix(key, "volume.beta.kubernetes.io/")
+}
+
+// isSyncedDataSource returns true if the data source of a PVC is a resource synced to the host cluster.
+func isSyncedDataSource(apiGroup *string, kind string) bool {
+	switch ptr.Deref(apiGroup, "") {
+	case "":
+		return kind == "PersistentVolumeClaim"
+	case snapshotv1.GroupName:
+		return kind == "VolumeSnapshot"
+	default:
+		return false
+	}
 }
@@ -10,9 +10,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/rancher/k3k/k3k-kubelet/controller/syncer"
+	"github.com/rancher/k3k/k3k-kubelet/translate"
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -47,6 +50,9 @@ var PVCTests = func() {
 
 		err = syncer.AddPVCSyncer(ctx, virtManager, hostManager, cluster.Name, cluster.Namespace)
 		Expect(err).NotTo(HaveOccurred())
+
+		err = syncer.AddPVController(ctx, virtManager, hostManager, cluster.Name, cluster.Namespace)
+		Expect(err).NotTo(HaveOccurred())
 	})
 
 	AfterEach(func() {
@@ -101,4 +107,539 @@ var PVCTests = func() {
 
 		GinkgoWriter.Printf("labels: %v\n", hostPVC.Labels)
 	})
+
+	It("removes the labels and annotations deleted from the pvc", func() {
+		ctx := context.Background()
+
+		pvc := &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "pvc-",
+				Namespace:    "default",
+				Labels: map[string]string{
+					"foo": "bar",
+					"app": "web",
+				},
+				Annotations: map[string]string{
+					"description": "data",
+				},
+			},
+			Spec: v1.PersistentVolumeClaimSpec{
+				StorageClassName: ptr.To("test-sc"),
+				AccessModes: []v1.PersistentVolumeAccessMode{
+					v1.ReadWriteOnce,
+				},
+				Resources: v1.VolumeResourceRequirements{
+					Requests: v1.ResourceList{
+						"storage": resource.MustParse("1G"),
+					},
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, pvc)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created PVC %s in virtual cluster", pvc.Name))
+
+		var hostPVC v1.PersistentVolumeClaim
+		hostPVCName := translateName(cluster, pvc.Namespace, pvc.Name)
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: hostPVCName, Namespace: namespace}
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostPVC)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		// the annotations of the host controllers binding the volume are kept
+		hostPVC.Annotations["volume.kubernetes.io/selected-node"] = "node-1"
+		err = hostTestEnv.k8sClient.Update(ctx, &hostPVC)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = virtTestEnv.k8sClient.Get(ctx, client.ObjectKeyFromObject(pvc), pvc)
+		Expect(err).NotTo(HaveOccurred())
+
+		delete(pvc.Labels, "app")
+		delete(pvc.Annotations, "description")
+		err = virtTestEnv.k8sClient.Update(ctx, pvc)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Removed the label and the annotation of PVC %s in virtual cluster", pvc.Name))
+
+		Eventually(func(g Gomega) {
+			key := client.ObjectKey{Name: hostPVCName, Namespace: namespace}
+			err := hostTestEnv.k8sClient.Get(ctx, key, &hostPVC)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			g.Expect(hostPVC.Labels).To(HaveKeyWithValue("foo", "bar"))
+			g.Expect(hostPVC.Labels).NotTo(HaveKey("app"))
+			g.Expect(hostPVC.Annotations).NotTo(HaveKey("description"))
+			g.Expect(hostPVC.Annotations).To(HaveKeyWithValue("volume.kubernetes.io/selected-node", "node-1"))
+			g.Expect(hostPVC.Annotations).To(HaveKeyWithValue(translate.ResourceNameAnnotation, pvc.Name))
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(Succeed())
+	})
+
+	It("reflects the bound PersistentVolume of the host cluster", func() {
+		ctx := context.Background()
+
+		pvc := &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "pvc-",
+				Namespace:    "default",
+			},
+			Spec: v1.PersistentVolumeClaimSpec{
+				StorageClassName: ptr.To("test-sc"),
+				AccessModes: []v1.PersistentVolumeAccessMode{
+					v1.ReadWriteOnce,
+				},
+				Resources: v1.VolumeResourceRequirements{
+					Requests: v1.ResourceList{
+						"storage": resource.MustParse("1G"),
+					},
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, pvc)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created PVC %s in virtual cluster", pvc.Name))
+
+		var hostPVC v1.PersistentVolumeClaim
+		hostPVCName := translateName(cluster, pvc.Namespace, pvc.Name)
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: hostPVCName, Namespace: namespace}
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostPVC)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		// bind the host PVC to a PersistentVolume, as done by the host provisioner
+		hostPV := &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "pv-"},
+			Spec: v1.PersistentVolumeSpec{
+				StorageClassName: "test-sc",
+				AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+				Capacity: v1.ResourceList{
+					"storage": resource.MustParse("2G"),
+				},
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{
+						Driver:           "test.csi.k8s.io",
+						VolumeHandle:     "volume-1",
+						VolumeAttributes: map[string]string{"foo": "bar"},
+					},
+				},
+				ClaimRef: &v1.ObjectReference{
+					Namespace: hostPVC.Namespace,
+					Name:      hostPVC.Name,
+					UID:       hostPVC.UID,
+				},
+			},
+		}
+
+		err = hostTestEnv.k8sClient.Create(ctx, hostPV)
+		Expect(err).NotTo(HaveOccurred())
+
+		DeferCleanup(func() {
+			Expect(hostTestEnv.k8sClient.Delete(context.Background(), hostPV)).To(Succeed())
+		})
+
+		hostPVC.Spec.VolumeName = hostPV.Name
+		err = hostTestEnv.k8sClient.Update(ctx, &hostPVC)
+		Expect(err).NotTo(HaveOccurred())
+
+		hostPVC.Status.Phase = v1.ClaimBound
+		hostPVC.Status.AccessModes = hostPV.Spec.AccessModes
+		hostPVC.Status.Capacity = hostPV.Spec.Capacity
+		err = hostTestEnv.k8sClient.Status().Update(ctx, &hostPVC)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Bound PVC %s to PersistentVolume %s in host cluster", hostPVCName, hostPV.Name))
+
+		Eventually(func() v1.PersistentVolumeClaimPhase {
+			key := client.ObjectKeyFromObject(pvc)
+			err := virtTestEnv.k8sClient.Get(ctx, key, pvc)
+			Expect(err).NotTo(HaveOccurred())
+
+			return pvc.Status.Phase
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(Equal(v1.ClaimBound))
+
+		Expect(pvc.Spec.VolumeName).NotTo(BeEmpty())
+		Expect(pvc.Status.Capacity.Storage().String()).To(Equal("2G"))
+
+		var virtPV v1.PersistentVolume
+		err = virtTestEnv.k8sClient.Get(ctx, client.ObjectKey{Name: pvc.Spec.VolumeName}, &virtPV)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(virtPV.Spec.StorageClassName).To(Equal("test-sc"))
+		Expect(virtPV.Annotations).To(HaveKeyWithValue("pv.k3k.io/host-pv", hostPV.Name))
+		Expect(virtPV.Spec.Capacity.Storage().String()).To(Equal("2G"))
+		Expect(virtPV.Spec.ClaimRef.UID).To(Equal(pvc.UID))
+
+		// the host volume source is not reflected in the virtual cluster
+		Expect(virtPV.Spec.CSI).To(BeNil())
+		Expect(virtPV.Spec.FlexVolume).NotTo(BeNil())
+		Expect(virtPV.Spec.FlexVolume.Driver).To(Equal("k3k.io/placeholder"))
+	})
+
+	It("propagates the expansion of a bound pvc to the host cluster", func() {
+		ctx := context.Background()
+
+		// the expansion is allowed by the StorageClass in both clusters
+		for _, k8sClient := range []client.Client{virtTestEnv.k8sClient, hostTestEnv.k8sClient} {
+			storageClass := &storagev1.StorageClass{
+				ObjectMeta:           metav1.ObjectMeta{Name: "expandable-sc"},
+				Provisioner:          "test.csi.k8s.io",
+				AllowVolumeExpansion: ptr.To(true),
+			}
+
+			err := k8sClient.Create(ctx, storageClass)
+			Expect(client.IgnoreAlreadyExists(err)).NotTo(HaveOccurred())
+		}
+
+		pvc := &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "pvc-",
+				Namespace:    "default",
+			},
+			Spec: v1.PersistentVolumeClaimSpec{
+				StorageClassName: ptr.To("expandable-sc"),
+				AccessModes: []v1.PersistentVolumeAccessMode{
+					v1.ReadWriteOnce,
+				},
+				Resources: v1.VolumeResourceRequirements{
+					Requests: v1.ResourceList{
+						"storage": resource.MustParse("1G"),
+					},
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, pvc)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created PVC %s in virtual cluster", pvc.Name))
+
+		var hostPVC v1.PersistentVolumeClaim
+		hostPVCName := translateName(cluster, pvc.Namespace, pvc.Name)
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: hostPVCName, Namespace: namespace}
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostPVC)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		hostPV := &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "pv-"},
+			Spec: v1.PersistentVolumeSpec{
+				StorageClassName: "expandable-sc",
+				AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+				Capacity: v1.ResourceList{
+					"storage": resource.MustParse("1G"),
+				},
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{
+						Driver:       "test.csi.k8s.io",
+						VolumeHandle: "volume-2",
+					},
+				},
+				ClaimRef: &v1.ObjectReference{
+					Namespace: hostPVC.Namespace,
+					Name:      hostPVC.Name,
+					UID:       hostPVC.UID,
+				},
+			},
+		}
+
+		err = hostTestEnv.k8sClient.Create(ctx, hostPV)
+		Expect(err).NotTo(HaveOccurred())
+
+		DeferCleanup(func() {
+			Expect(hostTestEnv.k8sClient.Delete(context.Background(), hostPV)).To(Succeed())
+		})
+
+		hostPVC.Spec.VolumeName = hostPV.Name
+		err = hostTestEnv.k8sClient.Update(ctx, &hostPVC)
+		Expect(err).NotTo(HaveOccurred())
+
+		hostPVC.Status.Phase = v1.ClaimBound
+		hostPVC.Status.AccessModes = hostPV.Spec.AccessModes
+		hostPVC.Status.Capacity = hostPV.Spec.Capacity
+		err = hostTestEnv.k8sClient.Status().Update(ctx, &hostPVC)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() v1.PersistentVolumeClaimPhase {
+			key := client.ObjectKeyFromObject(pvc)
+			err := virtTestEnv.k8sClient.Get(ctx, key, pvc)
+			Expect(err).NotTo(HaveOccurred())
+
+			return pvc.Status.Phase
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(Equal(v1.ClaimBound))
+
+		pvc.Spec.Resources.Requests["storage"] = resource.MustParse("3G")
+		err = virtTestEnv.k8sClient.Update(ctx, pvc)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Expanded PVC %s in virtual cluster", pvc.Name))
+
+		Eventually(func() string {
+			key := client.ObjectKey{Name: hostPVCName, Namespace: namespace}
+			err := hostTestEnv.k8sClient.Get(ctx, key, &hostPVC)
+			Expect(err).NotTo(HaveOccurred())
+
+			return hostPVC.Spec.Resources.Requests.Storage().String()
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(Equal("3G"))
+	})
+
+	It("maps the StorageClass of the pvc to the host StorageClass", func() {
+		ctx := context.Background()
+
+		err := hostTestEnv.k8sClient.Get(ctx, client.ObjectKeyFromObject(&cluster), &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		cluster.Spec.Sync = &v1beta1.SyncConfig{
+			PersistentVolumeClaims: v1beta1.PersistentVolumeClaimSyncConfig{
+				Enabled: true,
+				StorageClassMapping: map[string]string{
+					"fast": "host-fast",
+				},
+				DefaultStorageClassName: "fast",
+			},
+		}
+		err = hostTestEnv.k8sClient.Update(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, storageClassName := range []*string{ptr.To("fast"), nil} {
+			pvc := &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "pvc-",
+					Namespace:    "default",
+				},
+				Spec: v1.PersistentVolumeClaimSpec{
+					StorageClassName: storageClassName,
+					AccessModes: []v1.PersistentVolumeAccessMode{
+						v1.ReadWriteOnce,
+					},
+					Resources: v1.VolumeResourceRequirements{
+						Requests: v1.ResourceList{
+							"storage": resource.MustParse("1G"),
+						},
+					},
+				},
+			}
+
+			err := virtTestEnv.k8sClient.Create(ctx, pvc)
+			Expect(err).NotTo(HaveOccurred())
+
+			By(fmt.Sprintf("Created PVC %s in virtual cluster", pvc.Name))
+
+			var hostPVC v1.PersistentVolumeClaim
+			hostPVCName := translateName(cluster, pvc.Namespace, pvc.Name)
+
+			Eventually(func() error {
+				key := client.ObjectKey{Name: hostPVCName, Namespace: namespace}
+				return hostTestEnv.k8sClient.Get(ctx, key, &hostPVC)
+			}).
+				WithPolling(time.Millisecond * 300).
+				WithTimeout(time.Second * 10).
+				Should(BeNil())
+
+			Expect(hostPVC.Spec.StorageClassName).To(Equal(ptr.To("host-fast")))
+		}
+	})
+
+	It("will not create a pvc with a StorageClass not mapped", func() {
+		ctx := context.Background()
+
+		err := hostTestEnv.k8sClient.Get(ctx, client.ObjectKeyFromObject(&cluster), &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		cluster.Spec.Sync = &v1beta1.SyncConfig{
+			PersistentVolumeClaims: v1beta1.PersistentVolumeClaimSyncConfig{
+				Enabled: true,
+				StorageClassMapping: map[string]string{
+					"fast": "host-fast",
+				},
+			},
+		}
+		err = hostTestEnv.k8sClient.Update(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		pvc := &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "pvc-",
+				Namespace:    "default",
+			},
+			Spec: v1.PersistentVolumeClaimSpec{
+				StorageClassName: ptr.To("expensive"),
+				AccessModes: []v1.PersistentVolumeAccessMode{
+					v1.ReadWriteOnce,
+				},
+				Resources: v1.VolumeResourceRequirements{
+					Requests: v1.ResourceList{
+						"storage": resource.MustParse("1G"),
+					},
+				},
+			},
+		}
+
+		err = virtTestEnv.k8sClient.Create(ctx, pvc)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created PVC %s in virtual cluster", pvc.Name))
+
+		var hostPVC v1.PersistentVolumeClaim
+		hostPVCName := translateName(cluster, pvc.Namespace, pvc.Name)
+
+		Consistently(func() bool {
+			key := client.ObjectKey{Name: hostPVCName, Namespace: namespace}
+			err := hostTestEnv.k8sClient.Get(ctx, key, &hostPVC)
+			return apierrors.IsNotFound(err)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 3).
+			Should(BeTrue())
+	})
+
+	It("deletes the PersistentVolume of a deleted pvc", func() {
+		ctx := context.Background()
+
+		pv := &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "pvc-",
+				Annotations: map[string]string{
+					"pv.kubernetes.io/provisioned-by": "k3k-kubelet",
+				},
+			},
+			Spec: v1.PersistentVolumeSpec{
+				AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+				Capacity: v1.ResourceList{
+					"storage": resource.MustParse("1G"),
+				},
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					FlexVolume: &v1.FlexPersistentVolumeSource{Driver: "k3k.io/placeholder"},
+				},
+				ClaimRef: &v1.ObjectReference{
+					Namespace: "default",
+					Name:      "deleted-pvc",
+					UID:       "deleted-pvc-uid",
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, pv)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created PersistentVolume %s of a deleted PVC in virtual cluster", pv.Name))
+
+		Eventually(func() bool {
+			err := virtTestEnv.k8sClient.Get(ctx, client.ObjectKeyFromObject(pv), pv)
+			return apierrors.IsNotFound(err)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeTrue())
+	})
+
+	It("replaces the legacy pseudo PersistentVolume with a placeholder volume", func() {
+		ctx := context.Background()
+
+		pvc := &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "pvc-",
+				Namespace:    "default",
+			},
+			Spec: v1.PersistentVolumeClaimSpec{
+				StorageClassName: ptr.To("test-sc"),
+				AccessModes: []v1.PersistentVolumeAccessMode{
+					v1.ReadWriteOnce,
+				},
+				Resources: v1.VolumeResourceRequirements{
+					Requests: v1.ResourceList{
+						"storage": resource.MustParse("1G"),
+					},
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, pvc)
+		Expect(err).NotTo(HaveOccurred())
+
+		// the pseudo PersistentVolumes were named after the PVC by previous releases
+		legacyPV := &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: pvc.Name,
+				Labels: map[string]string{
+					"pod.k3k.io/pseudoPV": "true",
+				},
+				Annotations: map[string]string{
+					"pv.kubernetes.io/provisioned-by": "k3k-kubelet",
+				},
+			},
+			Spec: v1.PersistentVolumeSpec{
+				StorageClassName: "test-sc",
+				AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+				Capacity: v1.ResourceList{
+					"storage": resource.MustParse("1G"),
+				},
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					FlexVolume: &v1.FlexPersistentVolumeSource{Driver: "pseudopv"},
+				},
+				ClaimRef: &v1.ObjectReference{
+					Namespace: pvc.Namespace,
+					Name:      pvc.Name,
+					UID:       pvc.UID,
+				},
+			},
+		}
+
+		err = virtTestEnv.k8sClient.Create(ctx, legacyPV)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created legacy PersistentVolume %s in virtual cluster", legacyPV.Name))
+
+		Eventually(func() bool {
+			err := virtTestEnv.k8sClient.Get(ctx, client.ObjectKeyFromObject(legacyPV), legacyPV)
+			return apierrors.IsNotFound(err)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeTrue())
+
+		Eventually(func() string {
+			err := virtTestEnv.k8sClient.Get(ctx, client.ObjectKeyFromObject(pvc), pvc)
+			Expect(err).NotTo(HaveOccurred())
+
+			return pvc.Spec.VolumeName
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(Equal("pvc-" + string(pvc.UID)))
+
+		var virtPV v1.PersistentVolume
+		err = virtTestEnv.k8sClient.Get(ctx, client.ObjectKey{Name: pvc.Spec.VolumeName}, &virtPV)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(virtPV.Spec.FlexVolume).NotTo(BeNil())
+		Expect(virtPV.Spec.FlexVolume.Driver).To(Equal("k3k.io/placeholder"))
+		Expect(virtPV.Spec.ClaimRef.UID).To(Equal(pvc.UID))
+	})
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	v1 "k8s.io/api/core/v1"
@@ -253,4 +254,136 @@ var PriorityClassTests = func() {
 			WithTimeout(time.Second * 10).
 			Should(BeTrue())
 	})
+
+	It("will not create a priorityClass on the host cluster if the value is not allowed by the policy", func() {
+		ctx := context.Background()
+
+		policy := &v1beta1.VirtualClusterPolicy{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "policy-"},
+			Spec: v1beta1.VirtualClusterPolicySpec{
+				PriorityClasses: &v1beta1.PriorityClassPolicy{
+					MaxValue: ptr.To[int32](1000),
+				},
+			},
+		}
+
+		err := hostTestEnv.k8sClient.Create(ctx, policy)
+		Expect(err).NotTo(HaveOccurred())
+
+		DeferCleanup(func() {
+			Expect(hostTestEnv.k8sClient.Delete(context.Background(), policy)).To(Succeed())
+		})
+
+		cluster.Status.PolicyName = policy.Name
+		err = hostTestEnv.k8sClient.Status().Update(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		allowedPriorityClass := &schedulingv1.PriorityClass{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "pc-"},
+			Value:      1000,
+		}
+
+		err = virtTestEnv.k8sClient.Create(ctx, allowedPriorityClass)
+		Expect(err).NotTo(HaveOccurred())
+
+		priorityClass := &schedulingv1.PriorityClass{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "pc-"},
+			Value:      1001,
+		}
+
+		err = virtTestEnv.k8sClient.Create(ctx, priorityClass)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created priorityClasses %s and %s in virtual cluster", allowedPriorityClass.Name, priorityClass.Name))
+
+		var hostPriorityClass schedulingv1.PriorityClass
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: translateName(cluster, "", allowedPriorityClass.Name)}
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostPriorityClass)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		Consistently(func() bool {
+			key := client.ObjectKey{Name: translateName(cluster, "", priorityClass.Name)}
+			err = hostTestEnv.k8sClient.Get(ctx, key, &hostPriorityClass)
+			return apierrors.IsNotFound(err)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 3).
+			Should(BeTrue())
+	})
+
+	It("will sync the priorityClasses again when the policy changes", func() {
+		ctx := context.Background()
+
+		policy := &v1beta1.VirtualClusterPolicy{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "policy-"},
+			Spec: v1beta1.VirtualClusterPolicySpec{
+				PriorityClasses: &v1beta1.PriorityClassPolicy{
+					MaxValue: ptr.To[int32](2000),
+				},
+			},
+		}
+
+		err := hostTestEnv.k8sClient.Create(ctx, policy)
+		Expect(err).NotTo(HaveOccurred())
+
+		DeferCleanup(func() {
+			Expect(hostTestEnv.k8sClient.Delete(context.Background(), policy)).To(Succeed())
+		})
+
+		cluster.Status.PolicyName = policy.Name
+		err = hostTestEnv.k8sClient.Status().Update(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		priorityClass := &schedulingv1.PriorityClass{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "pc-"},
+			Value:      1001,
+		}
+
+		err = virtTestEnv.k8sClient.Create(ctx, priorityClass)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created priorityClass %s in virtual cluster", priorityClass.Name))
+
+		var hostPriorityClass schedulingv1.PriorityClass
+		key := client.ObjectKey{Name: translateName(cluster, "", priorityClass.Name)}
+
+		Eventually(func() error {
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostPriorityClass)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		By("Lowering the maximum value allowed by the policy")
+
+		policy.Spec.PriorityClasses.MaxValue = ptr.To[int32](1000)
+		err = hostTestEnv.k8sClient.Update(ctx, policy)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() bool {
+			err = hostTestEnv.k8sClient.Get(ctx, key, &hostPriorityClass)
+			return apierrors.IsNotFound(err)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeTrue())
+
+		By("Unbinding the policy from the cluster")
+
+		cluster.Status.PolicyName = ""
+		err = hostTestEnv.k8sClient.Status().Update(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() error {
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostPriorityClass)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+	})
 }
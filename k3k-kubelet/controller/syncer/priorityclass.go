@@ -2,61 +2,124 @@ package syncer
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	corev1 "k8s.io/api/core/v1"
 	schedulingv1 "k8s.io/api/scheduling/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
-	"github.com/rancher/k3k/k3k-kubelet/translate"
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller"
 )
 
 const (
 	PriorityClassGlobalDefaultAnnotation = "priorityclass.k3k.io/globalDefault"
 
+	// PriorityClassNotAllowedReason is the reason of the events recorded when a PriorityClass is not allowed by the policy.
+	PriorityClassNotAllowedReason = "PriorityClassNotAllowed"
+
 	priorityClassControllerName = "priorityclass-syncer-controller"
 	priorityClassFinalizerName  = "priorityclass.k3k.io/finalizer"
 )
 
 type PriorityClassSyncer struct {
 	*SyncerContext
+	record.EventRecorder
 }
 
 // AddPriorityClassSyncer adds a PriorityClass reconciler to k3k-kubelet
 func AddPriorityClassSyncer(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) error {
+	syncerContext, err := newSyncerContext(ctx, virtMgr, hostMgr, clusterName, clusterNamespace)
+	if err != nil {
+		return err
+	}
+
 	// initialize a new Reconciler
 	reconciler := PriorityClassSyncer{
-		SyncerContext: &SyncerContext{
-			ClusterName:      clusterName,
-			ClusterNamespace: clusterNamespace,
-			VirtualClient:    virtMgr.GetClient(),
-			HostClient:       hostMgr.GetClient(),
-			Translator: translate.ToHostTranslator{
-				ClusterName:      clusterName,
-				ClusterNamespace: clusterNamespace,
-			},
-		},
+		SyncerContext: syncerContext,
 	}
 
 	name := reconciler.Translator.TranslateName(clusterNamespace, priorityClassControllerName)
 
+	reconciler.EventRecorder = virtMgr.GetEventRecorderFor(name)
+
+	// the sync config is read from the Cluster, and the allowed values from its VirtualClusterPolicy
+	clusterSource := source.Kind(hostMgr.GetCache(), &v1beta1.Cluster{},
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.clusterPriorityClassRequests),
+	)
+
+	policySource := source.Kind(hostMgr.GetCache(), &v1beta1.VirtualClusterPolicy{},
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.policyPriorityClassRequests),
+	)
+
 	return ctrl.NewControllerManagedBy(virtMgr).
 		Named(name).
 		For(&schedulingv1.PriorityClass{}).WithEventFilter(ignoreSystemPrefixPredicate).
 		WithEventFilter(predicate.NewPredicateFuncs(reconciler.filterResources)).
+		WatchesRawSource(clusterSource).
+		WatchesRawSource(policySource).
 		Complete(&reconciler)
 }
 
+// clusterPriorityClassRequests maps the Cluster to the requests for the virtual PriorityClasses to sync.
+func (r *PriorityClassSyncer) clusterPriorityClassRequests(ctx context.Context, cluster *v1beta1.Cluster) []reconcile.Request {
+	if cluster.Name != r.ClusterName || cluster.Namespace != r.ClusterNamespace {
+		return nil
+	}
+
+	return r.priorityClassRequests(ctx)
+}
+
+// policyPriorityClassRequests maps the VirtualClusterPolicy bound to the Cluster to the requests for the virtual
+// PriorityClasses to sync.
+func (r *PriorityClassSyncer) policyPriorityClassRequests(ctx context.Context, policy *v1beta1.VirtualClusterPolicy) []reconcile.Request {
+	var cluster v1beta1.Cluster
+	if err := r.HostClient.Get(ctx, types.NamespacedName{Name: r.ClusterName, Namespace: r.ClusterNamespace}, &cluster); err != nil {
+		return nil
+	}
+
+	if cluster.Status.PolicyName != policy.Name {
+		return nil
+	}
+
+	return r.priorityClassRequests(ctx)
+}
+
+// priorityClassRequests returns the requests for the virtual PriorityClasses passing the same filters of their events.
+func (r *PriorityClassSyncer) priorityClassRequests(ctx context.Context) []reconcile.Request {
+	var priorityClasses schedulingv1.PriorityClassList
+	if err := r.VirtualClient.List(ctx, &priorityClasses); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "failed to list the priority classes")
+		return nil
+	}
+
+	var requests []reconcile.Request
+
+	for _, priorityClass := range priorityClasses.Items {
+		if strings.HasPrefix(priorityClass.Name, "system-") || !r.filterResources(&priorityClass) {
+			continue
+		}
+
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: priorityClass.Name}})
+	}
+
+	return requests
+}
+
 // IgnoreSystemPrefixPredicate filters out resources whose names start with "system-".
 var ignoreSystemPrefixPredicate = predicate.Funcs{
 	UpdateFunc: func(e event.UpdateEvent) bool {
@@ -83,7 +146,7 @@ func (r *PriorityClassSyncer) filterResources(object ctrlruntimeclient.Object) b
 	}
 
 	// check for priorityClassConfig
-	syncConfig := cluster.Spec.Sync.PriorityClasses
+	syncConfig := controller.SyncConfig(&cluster).PriorityClasses
 
 	// If syncing is disabled, only process deletions to allow for cleanup.
 	if !syncConfig.Enabled {
@@ -135,6 +198,23 @@ func (r *PriorityClassSyncer) Reconcile(ctx context.Context, req reconcile.Reque
 		return reconcile.Result{}, nil
 	}
 
+	// the PriorityClasses not allowed by the policy are not synced, and removed from the host if they were before
+	priorityClassPolicy, err := r.priorityClassPolicy(ctx, &cluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if err := ValidatePriorityClassValue(priorityClass.Value, priorityClassPolicy); err != nil {
+		log.Info("priorityClass not allowed by the policy", "priorityClass", priorityClass.Name, "reason", err.Error())
+		r.Eventf(&priorityClass, corev1.EventTypeWarning, PriorityClassNotAllowedReason, "PriorityClass not synced to the host cluster: %s", err.Error())
+
+		if err := r.HostClient.Delete(ctx, hostPriorityClass); err != nil && !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		return reconcile.Result{}, nil
+	}
+
 	// Add finalizer if it does not exist
 	if controllerutil.AddFinalizer(&priorityClass, priorityClassFinalizerName) {
 		if err := r.VirtualClient.Update(ctx, &priorityClass); err != nil {
@@ -145,8 +225,7 @@ func (r *PriorityClassSyncer) Reconcile(ctx context.Context, req reconcile.Reque
 	// create the priorityClass on the host
 	log.Info("creating the priorityClass for the first time on the host cluster")
 
-	err := r.HostClient.Create(ctx, hostPriorityClass)
-	if err != nil {
+	if err := r.HostClient.Create(ctx, hostPriorityClass); err != nil {
 		if !apierrors.IsAlreadyExists(err) {
 			return reconcile.Result{}, err
 		}
@@ -172,3 +251,34 @@ func (r *PriorityClassSyncer) translatePriorityClass(priorityClass schedulingv1.
 
 	return hostPriorityClass
 }
+
+// priorityClassPolicy returns the PriorityClasses constraints of the VirtualClusterPolicy bound to the cluster, if any.
+func (r *PriorityClassSyncer) priorityClassPolicy(ctx context.Context, cluster *v1beta1.Cluster) (*v1beta1.PriorityClassPolicy, error) {
+	if cluster.Status.PolicyName == "" {
+		return nil, nil
+	}
+
+	var policy v1beta1.VirtualClusterPolicy
+	if err := r.HostClient.Get(ctx, types.NamespacedName{Name: cluster.Status.PolicyName}, &policy); err != nil {
+		return nil, ctrlruntimeclient.IgnoreNotFound(err)
+	}
+
+	return policy.Spec.PriorityClasses, nil
+}
+
+// ValidatePriorityClassValue returns an error if the value of a PriorityClass is out of the range allowed by the policy.
+func ValidatePriorityClassValue(value int32, policy *v1beta1.PriorityClassPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	if policy.MinValue != nil && value < *policy.MinValue {
+		return fmt.Errorf("value %d is lower than the minimum allowed value %d", value, *policy.MinValue)
+	}
+
+	if policy.MaxValue != nil && value > *policy.MaxValue {
+		return fmt.Errorf("value %d is higher than the maximum allowed value %d", value, *policy.MaxValue)
+	}
+
+	return nil
+}
@@ -0,0 +1,452 @@
+package syncer
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	quota "k8s.io/apiserver/pkg/quota/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller/policy"
+)
+
+const (
+	// HostQuotaName is the name of the ResourceQuotas mirroring in the virtual cluster the quotas of the host cluster.
+	HostQuotaName = "k3k-host-quota"
+	// ResourceQuotaMirroredLabel is the label of the ResourceQuotas mirroring the quotas of the host cluster.
+	ResourceQuotaMirroredLabel = "resourcequota.k3k.io/mirrored"
+
+	resourceQuotaControllerName = "resourcequota-syncer-controller"
+)
+
+type ResourceQuotaSyncer struct {
+	*SyncerContext
+}
+
+// AddResourceQuotaSyncer adds the controller mirroring in the virtual cluster the quotas limiting its pods in the host cluster:
+// the ResourceQuotas of the VirtualClusterPolicy in the host namespace, and the workloads quota of the Cluster.
+// The quotas are mirrored in the "kube-system" namespace, or in every virtual namespace with the PerNamespace host namespace mode.
+// The hard limits of the mirrored ResourceQuotas are the amounts still available in the host cluster, added to the usage of
+// the virtual namespace, so that the quota controller of the virtual cluster computes their status without contradicting
+// the host. The mirrored ResourceQuotas are read-only: the changes made in the virtual cluster are reverted, and the deleted
+// ones are recreated.
+func AddResourceQuotaSyncer(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) error {
+	syncerContext, err := newSyncerContext(ctx, virtMgr, hostMgr, clusterName, clusterNamespace)
+	if err != nil {
+		return err
+	}
+
+	reconciler := ResourceQuotaSyncer{
+		SyncerContext: syncerContext,
+	}
+
+	name := reconciler.Translator.TranslateName(clusterNamespace, resourceQuotaControllerName)
+
+	// the usage of the pods changes only when they are created, deleted or terminated
+	podUsageChanged := predicate.TypedFuncs[*corev1.Pod]{
+		CreateFunc: func(event.TypedCreateEvent[*corev1.Pod]) bool { return true },
+		DeleteFunc: func(event.TypedDeleteEvent[*corev1.Pod]) bool { return true },
+		UpdateFunc: func(e event.TypedUpdateEvent[*corev1.Pod]) bool {
+			return isTerminatedPod(e.ObjectOld) != isTerminatedPod(e.ObjectNew)
+		},
+		GenericFunc: func(event.TypedGenericEvent[*corev1.Pod]) bool { return false },
+	}
+
+	clusterSource := source.Kind(hostMgr.GetCache(), &v1beta1.Cluster{},
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.clusterQuotaRequests),
+	)
+
+	hostQuotaSource := source.Kind(hostMgr.GetCache(), &corev1.ResourceQuota{},
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.hostQuotaRequests),
+	)
+
+	hostLimitRangeSource := source.Kind(hostMgr.GetCache(), &corev1.LimitRange{},
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.hostLimitRangeRequests),
+	)
+
+	// with the PerNamespace host namespace mode the usage of the pods of the cluster is counted by the syncer
+	hostPodSource := source.Kind(hostMgr.GetCache(), &corev1.Pod{},
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.hostPodRequests),
+		podUsageChanged,
+	)
+
+	// the usage of the virtual pods is added to the hard limits of the quota mirrored in their namespace
+	virtPodSource := source.Kind(virtMgr.GetCache(), &corev1.Pod{},
+		handler.TypedEnqueueRequestsFromMapFunc(func(_ context.Context, pod *corev1.Pod) []reconcile.Request {
+			return []reconcile.Request{mirroredQuotaRequest(pod.Namespace)}
+		}),
+		podUsageChanged,
+	)
+
+	// the quotas are mirrored in the new virtual namespaces
+	namespaceCreated := predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return reconciler.Translator.NamespacePerVirtualNamespace },
+		UpdateFunc:  func(event.UpdateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+
+	return ctrl.NewControllerManagedBy(virtMgr).
+		Named(name).
+		For(&corev1.ResourceQuota{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(object ctrlruntimeclient.Object) bool {
+			return object.GetName() == HostQuotaName
+		}))).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(func(_ context.Context, object ctrlruntimeclient.Object) []reconcile.Request {
+			return []reconcile.Request{mirroredQuotaRequest(object.GetName())}
+		}), builder.WithPredicates(namespaceCreated)).
+		WatchesRawSource(clusterSource).
+		WatchesRawSource(hostQuotaSource).
+		WatchesRawSource(hostLimitRangeSource).
+		WatchesRawSource(hostPodSource).
+		WatchesRawSource(virtPodSource).
+		Complete(&reconciler)
+}
+
+func mirroredQuotaRequest(namespace string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Name: HostQuotaName, Namespace: namespace}}
+}
+
+// mirroredQuotaNamespaces returns the virtual namespaces of the mirrored quotas.
+func (r *ResourceQuotaSyncer) mirroredQuotaNamespaces(ctx context.Context) []string {
+	if !r.Translator.NamespacePerVirtualNamespace {
+		return []string{metav1.NamespaceSystem}
+	}
+
+	var namespaces corev1.NamespaceList
+	if err := r.VirtualClient.List(ctx, &namespaces); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "failed to list the virtual namespaces")
+		return nil
+	}
+
+	names := make([]string, 0, len(namespaces.Items))
+	for _, namespace := range namespaces.Items {
+		names = append(names, namespace.Name)
+	}
+
+	return names
+}
+
+// clusterQuotaRequests maps the Cluster to the requests for all the mirrored quotas, since its workloads quota applies to all of them.
+func (r *ResourceQuotaSyncer) clusterQuotaRequests(ctx context.Context, cluster *v1beta1.Cluster) []reconcile.Request {
+	if cluster.Name != r.ClusterName || cluster.Namespace != r.ClusterNamespace {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, namespace := range r.mirroredQuotaNamespaces(ctx) {
+		requests = append(requests, mirroredQuotaRequest(namespace))
+	}
+
+	return requests
+}
+
+// hostQuotaRequests maps a ResourceQuota of the VirtualClusterPolicy to the requests for the quotas mirroring its host namespace.
+func (r *ResourceQuotaSyncer) hostQuotaRequests(ctx context.Context, hostQuota *corev1.ResourceQuota) []reconcile.Request {
+	if hostQuota.Labels[policy.ManagedByLabelKey] != policy.VirtualPolicyControllerName {
+		return nil
+	}
+
+	var requests []reconcile.Request
+
+	for _, namespace := range r.mirroredQuotaNamespaces(ctx) {
+		if r.quotaNamespace(namespace) == hostQuota.Namespace {
+			requests = append(requests, mirroredQuotaRequest(namespace))
+		}
+	}
+
+	return requests
+}
+
+// hostLimitRangeRequests maps a LimitRange of the VirtualClusterPolicy to the requests for the quotas mirrored in the
+// virtual namespaces of its host namespace, since its defaults exclude resources from them.
+func (r *ResourceQuotaSyncer) hostLimitRangeRequests(ctx context.Context, limitRange *corev1.LimitRange) []reconcile.Request {
+	if limitRange.Labels[policy.ManagedByLabelKey] != policy.VirtualPolicyControllerName {
+		return nil
+	}
+
+	var requests []reconcile.Request
+
+	for _, namespace := range r.mirroredQuotaNamespaces(ctx) {
+		if r.Translator.HostNamespace(namespace) == limitRange.Namespace {
+			requests = append(requests, mirroredQuotaRequest(namespace))
+		}
+	}
+
+	return requests
+}
+
+// hostPodRequests maps a host pod of the cluster to the requests for all the mirrored quotas with the PerNamespace
+// host namespace mode, since its usage is counted in the quota of the namespace of the cluster.
+func (r *ResourceQuotaSyncer) hostPodRequests(ctx context.Context, hostPod *corev1.Pod) []reconcile.Request {
+	if !r.Translator.NamespacePerVirtualNamespace || !r.Translator.IsHostObject(hostPod) {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, namespace := range r.mirroredQuotaNamespaces(ctx) {
+		requests = append(requests, mirroredQuotaRequest(namespace))
+	}
+
+	return requests
+}
+
+// quotaNamespace returns the host namespace of the ResourceQuotas limiting the pods of a virtual namespace. The
+// dedicated host namespaces of the PerNamespace host namespace mode are limited by the quotas of the namespace of the cluster.
+func (r *ResourceQuotaSyncer) quotaNamespace(namespace string) string {
+	if r.Translator.NamespacePerVirtualNamespace {
+		return r.ClusterNamespace
+	}
+
+	return r.Translator.HostNamespace(namespace)
+}
+
+func isTerminatedPod(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
+
+func (r *ResourceQuotaSyncer) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithValues("cluster", r.ClusterName, "clusterNamespace", r.ClusterNamespace)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	if req.Name != HostQuotaName || (!r.Translator.NamespacePerVirtualNamespace && req.Namespace != metav1.NamespaceSystem) {
+		return reconcile.Result{}, nil
+	}
+
+	var cluster v1beta1.Cluster
+	if err := r.HostClient.Get(ctx, types.NamespacedName{Name: r.ClusterName, Namespace: r.ClusterNamespace}, &cluster); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	quotas, err := r.hostQuotas(ctx, &cluster, r.quotaNamespace(req.Namespace))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	defaulted, err := r.defaultedResources(ctx, r.Translator.HostNamespace(req.Namespace))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	var podList corev1.PodList
+	if err := r.VirtualClient.List(ctx, &podList, ctrlruntimeclient.InNamespace(req.Namespace)); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	pods := make([]*corev1.Pod, 0, len(podList.Items))
+	for i := range podList.Items {
+		pods = append(pods, &podList.Items[i])
+	}
+
+	hard := mirroredQuotaHard(effectiveQuota(quotas...), policy.PodsQuotaUsage(pods...), defaulted)
+
+	var virtQuota corev1.ResourceQuota
+
+	virtQuotaExists := true
+
+	if err := r.VirtualClient.Get(ctx, req.NamespacedName, &virtQuota); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		virtQuotaExists = false
+	}
+
+	// the ResourceQuotas not created by the controller are not managed
+	isMirrored := virtQuotaExists && virtQuota.Labels[ResourceQuotaMirroredLabel] == "true"
+
+	if len(hard) == 0 {
+		if isMirrored {
+			log.Info("deleting the mirrored resource quota", "namespace", req.Namespace)
+			return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(r.VirtualClient.Delete(ctx, &virtQuota))
+		}
+
+		return reconcile.Result{}, nil
+	}
+
+	// a ResourceQuota with the same name created in the virtual cluster is replaced by the mirrored one
+	if virtQuotaExists && !isMirrored {
+		log.Info("recreating the mirrored resource quota", "namespace", req.Namespace)
+		return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(r.VirtualClient.Delete(ctx, &virtQuota))
+	}
+
+	spec := corev1.ResourceQuotaSpec{Hard: hard}
+
+	if !virtQuotaExists {
+		virtQuota = corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      HostQuotaName,
+				Namespace: req.Namespace,
+				Labels: map[string]string{
+					ResourceQuotaMirroredLabel: "true",
+				},
+			},
+			Spec: spec,
+		}
+
+		log.Info("creating the mirrored resource quota", "namespace", req.Namespace)
+
+		return reconcile.Result{}, r.VirtualClient.Create(ctx, &virtQuota)
+	}
+
+	// the changes made in the virtual cluster are reverted
+	if equality.Semantic.DeepEqual(virtQuota.Spec, spec) {
+		return reconcile.Result{}, nil
+	}
+
+	log.V(1).Info("updating the mirrored resource quota", "namespace", req.Namespace)
+
+	virtQuota.Spec = spec
+
+	return reconcile.Result{}, r.VirtualClient.Update(ctx, &virtQuota)
+}
+
+// mirroredQuotaHard returns the hard limits of a mirrored quota: for each resource of the pods, the amount still available
+// in the host cluster added to the usage of the virtual namespace. The quota controller of the virtual cluster then admits
+// the pods fitting in the host quotas, and reports the usage of the namespace. The resources defaulted by the LimitRanges of
+// the host are excluded: the virtual cluster would reject the pods not requesting them, admitted by the host.
+func mirroredQuotaHard(effective corev1.ResourceQuotaStatus, virtualUsage corev1.ResourceList, defaulted []corev1.ResourceName) corev1.ResourceList {
+	names := quota.Difference(policy.PodsQuotaResources(quota.ResourceNames(effective.Hard)...), defaulted)
+	if len(names) == 0 {
+		return nil
+	}
+
+	hard := corev1.ResourceList{}
+
+	for _, name := range names {
+		available := effective.Hard[name].DeepCopy()
+		available.Sub(effective.Used[name])
+
+		if available.Sign() < 0 {
+			available.Set(0)
+		}
+
+		available.Add(virtualUsage[name])
+		hard[name] = available
+	}
+
+	return hard
+}
+
+// defaultedResources returns the quota resources of the pods defaulted by the LimitRanges of the VirtualClusterPolicy in a
+// host namespace. The requests are defaulted by the default requests or, when not set, by the default limits.
+func (r *ResourceQuotaSyncer) defaultedResources(ctx context.Context, hostNamespace string) ([]corev1.ResourceName, error) {
+	var limitRangeList corev1.LimitRangeList
+	if err := r.HostClient.List(ctx, &limitRangeList, ctrlruntimeclient.InNamespace(hostNamespace), ctrlruntimeclient.MatchingLabels{
+		policy.ManagedByLabelKey: policy.VirtualPolicyControllerName,
+	}); err != nil {
+		return nil, err
+	}
+
+	var defaulted []corev1.ResourceName
+
+	for _, limitRange := range limitRangeList.Items {
+		for _, limit := range limitRange.Spec.Limits {
+			if limit.Type != corev1.LimitTypeContainer {
+				continue
+			}
+
+			for name := range limit.Default {
+				defaulted = append(defaulted, name, corev1.ResourceName(corev1.DefaultResourceRequestsPrefix+name), "limits."+name)
+			}
+
+			for name := range limit.DefaultRequest {
+				defaulted = append(defaulted, name, corev1.ResourceName(corev1.DefaultResourceRequestsPrefix+name))
+			}
+		}
+	}
+
+	return defaulted, nil
+}
+
+// hostQuotas returns the quotas of the pods in a host namespace: the status of the unscoped ResourceQuotas of the
+// VirtualClusterPolicy, and the workloads quota of the Cluster. With the PerNamespace host namespace mode, the usage
+// of the pods in all the host namespaces of the cluster is added to the quotas of the namespace of the cluster.
+func (r *ResourceQuotaSyncer) hostQuotas(ctx context.Context, cluster *v1beta1.Cluster, hostNamespace string) ([]corev1.ResourceQuotaStatus, error) {
+	var quotas []corev1.ResourceQuotaStatus
+
+	var hostQuotaList corev1.ResourceQuotaList
+	if err := r.HostClient.List(ctx, &hostQuotaList, ctrlruntimeclient.InNamespace(hostNamespace), ctrlruntimeclient.MatchingLabels{
+		policy.ManagedByLabelKey: policy.VirtualPolicyControllerName,
+	}); err != nil {
+		return nil, err
+	}
+
+	var podsUsage corev1.ResourceList
+
+	if r.Translator.NamespacePerVirtualNamespace {
+		var podList corev1.PodList
+		if err := r.HostClient.List(ctx, &podList, r.Translator.ListOptions(nil)...); err != nil {
+			return nil, err
+		}
+
+		pods := make([]*corev1.Pod, 0, len(podList.Items))
+		for i := range podList.Items {
+			pods = append(pods, &podList.Items[i])
+		}
+
+		podsUsage = policy.PodsQuotaUsage(pods...)
+	}
+
+	for _, hostQuota := range hostQuotaList.Items {
+		if len(hostQuota.Spec.Scopes) == 0 && hostQuota.Spec.ScopeSelector == nil {
+			status := hostQuota.Status.DeepCopy()
+			status.Used = quota.Add(status.Used, quota.Mask(podsUsage, quota.ResourceNames(status.Hard)))
+
+			quotas = append(quotas, *status)
+		}
+	}
+
+	if cluster.Status.Quota != nil && cluster.Status.Quota.Workloads != nil {
+		quotas = append(quotas, *cluster.Status.Quota.Workloads)
+	}
+
+	return quotas, nil
+}
+
+// effectiveQuota merges the hard limits and the usage of the quotas, keeping for each resource the quota with
+// the least amount available.
+func effectiveQuota(quotas ...corev1.ResourceQuotaStatus) corev1.ResourceQuotaStatus {
+	effective := corev1.ResourceQuotaStatus{}
+
+	for _, quota := range quotas {
+		for name, hard := range quota.Hard {
+			used := quota.Used[name]
+
+			available := hard.DeepCopy()
+			available.Sub(used)
+
+			if currentHard, found := effective.Hard[name]; found {
+				currentAvailable := currentHard.DeepCopy()
+				currentAvailable.Sub(effective.Used[name])
+
+				if currentAvailable.Cmp(available) <= 0 {
+					continue
+				}
+			}
+
+			if effective.Hard == nil {
+				effective.Hard = corev1.ResourceList{}
+				effective.Used = corev1.ResourceList{}
+			}
+
+			effective.Hard[name] = hard.DeepCopy()
+			effective.Used[name] = used.DeepCopy()
+		}
+	}
+
+	return effective
+}
@@ -0,0 +1,259 @@
+package syncer_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/k3k/k3k-kubelet/controller/syncer"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller/policy"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var ResourceQuotaTests = func() {
+	var (
+		namespace string
+		cluster   v1beta1.Cluster
+		hostQuota *v1.ResourceQuota
+	)
+
+	mirroredQuotaKey := client.ObjectKey{Name: syncer.HostQuotaName, Namespace: metav1.NamespaceSystem}
+
+	BeforeEach(func() {
+		ctx := context.Background()
+
+		ns := v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "ns-"},
+		}
+		err := hostTestEnv.k8sClient.Create(ctx, &ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = ns.Name
+
+		cluster = v1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "cluster-",
+				Namespace:    namespace,
+			},
+		}
+		err = hostTestEnv.k8sClient.Create(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		hostQuota = &v1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "policy-quota-",
+				Namespace:    namespace,
+				Labels: map[string]string{
+					policy.ManagedByLabelKey: policy.VirtualPolicyControllerName,
+				},
+			},
+			Spec: v1.ResourceQuotaSpec{
+				Hard: v1.ResourceList{
+					v1.ResourcePods:        resource.MustParse("10"),
+					v1.ResourceRequestsCPU: resource.MustParse("4"),
+				},
+			},
+		}
+		err = hostTestEnv.k8sClient.Create(ctx, hostQuota)
+		Expect(err).NotTo(HaveOccurred())
+
+		// no quota controller is running in the test environment
+		hostQuota.Status = v1.ResourceQuotaStatus{
+			Hard: hostQuota.Spec.Hard,
+			Used: v1.ResourceList{
+				v1.ResourcePods:        resource.MustParse("2"),
+				v1.ResourceRequestsCPU: resource.MustParse("1"),
+			},
+		}
+		err = hostTestEnv.k8sClient.Status().Update(ctx, hostQuota)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = syncer.AddResourceQuotaSyncer(ctx, virtManager, hostManager, cluster.Name, cluster.Namespace)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ns := v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		err := hostTestEnv.k8sClient.Delete(context.Background(), &ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		mirroredQuota := v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Name: mirroredQuotaKey.Name, Namespace: mirroredQuotaKey.Namespace}}
+		err = virtTestEnv.k8sClient.Delete(context.Background(), &mirroredQuota)
+		Expect(client.IgnoreNotFound(err)).NotTo(HaveOccurred())
+	})
+
+	It("mirrors the quota of the host namespace in the virtual cluster", func() {
+		ctx := context.Background()
+
+		var mirroredQuota v1.ResourceQuota
+
+		Eventually(func() error {
+			return virtTestEnv.k8sClient.Get(ctx, mirroredQuotaKey, &mirroredQuota)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		By(fmt.Sprintf("Mirrored ResourceQuota %s in virtual cluster", hostQuota.Name))
+
+		Expect(mirroredQuota.Labels).To(HaveKeyWithValue(syncer.ResourceQuotaMirroredLabel, "true"))
+
+		// the hard limits are the amounts still available in the host namespace
+		Expect(mirroredQuota.Spec.Hard).To(HaveLen(2))
+		Expect(mirroredQuota.Spec.Hard.Pods().Equal(resource.MustParse("8"))).To(BeTrue())
+
+		cpu := mirroredQuota.Spec.Hard[v1.ResourceRequestsCPU]
+		Expect(cpu.Equal(resource.MustParse("3"))).To(BeTrue())
+	})
+
+	It("adds the usage of the virtual namespace to the hard limits", func() {
+		ctx := context.Background()
+
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "pod-",
+				Namespace:    metav1.NamespaceSystem,
+			},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{{Name: "nginx", Image: "nginx"}},
+			},
+		}
+		err := virtTestEnv.k8sClient.Create(ctx, pod)
+		Expect(err).NotTo(HaveOccurred())
+
+		DeferCleanup(func() {
+			err := virtTestEnv.k8sClient.Delete(context.Background(), pod)
+			Expect(client.IgnoreNotFound(err)).NotTo(HaveOccurred())
+		})
+
+		var mirroredQuota v1.ResourceQuota
+
+		Eventually(func() bool {
+			err := virtTestEnv.k8sClient.Get(ctx, mirroredQuotaKey, &mirroredQuota)
+			return err == nil && mirroredQuota.Spec.Hard.Pods().Equal(resource.MustParse("9"))
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeTrue())
+	})
+
+	It("keeps the quota of the cluster with the least amount available", func() {
+		ctx := context.Background()
+
+		cluster.Status.Quota = &v1beta1.ClusterQuotaStatus{
+			Workloads: &v1.ResourceQuotaStatus{
+				Hard: v1.ResourceList{v1.ResourcePods: resource.MustParse("5")},
+				Used: v1.ResourceList{v1.ResourcePods: resource.MustParse("4")},
+			},
+		}
+		err := hostTestEnv.k8sClient.Status().Update(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		var mirroredQuota v1.ResourceQuota
+
+		Eventually(func() bool {
+			err := virtTestEnv.k8sClient.Get(ctx, mirroredQuotaKey, &mirroredQuota)
+			return err == nil && mirroredQuota.Spec.Hard.Pods().Equal(resource.MustParse("1"))
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeTrue())
+
+		cpu := mirroredQuota.Spec.Hard[v1.ResourceRequestsCPU]
+		Expect(cpu.Equal(resource.MustParse("3"))).To(BeTrue())
+	})
+
+	It("excludes the resources defaulted by the limit range of the host namespace", func() {
+		ctx := context.Background()
+
+		limitRange := &v1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "policy-limit-",
+				Namespace:    namespace,
+				Labels: map[string]string{
+					policy.ManagedByLabelKey: policy.VirtualPolicyControllerName,
+				},
+			},
+			Spec: v1.LimitRangeSpec{
+				Limits: []v1.LimitRangeItem{{
+					Type:           v1.LimitTypeContainer,
+					DefaultRequest: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")},
+				}},
+			},
+		}
+		err := hostTestEnv.k8sClient.Create(ctx, limitRange)
+		Expect(err).NotTo(HaveOccurred())
+
+		var mirroredQuota v1.ResourceQuota
+
+		// the virtual cluster would reject the pods without cpu requests, defaulted by the host
+		Eventually(func() bool {
+			err := virtTestEnv.k8sClient.Get(ctx, mirroredQuotaKey, &mirroredQuota)
+			return err == nil && len(mirroredQuota.Spec.Hard) == 1
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeTrue())
+
+		Expect(mirroredQuota.Spec.Hard).To(HaveKey(v1.ResourcePods))
+	})
+
+	It("reverts the changes made in the virtual cluster", func() {
+		ctx := context.Background()
+
+		var mirroredQuota v1.ResourceQuota
+
+		Eventually(func() error {
+			return virtTestEnv.k8sClient.Get(ctx, mirroredQuotaKey, &mirroredQuota)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		mirroredQuota.Spec.Hard[v1.ResourcePods] = resource.MustParse("100")
+		err := virtTestEnv.k8sClient.Update(ctx, &mirroredQuota)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() bool {
+			err := virtTestEnv.k8sClient.Get(ctx, mirroredQuotaKey, &mirroredQuota)
+			return err == nil && mirroredQuota.Spec.Hard.Pods().Equal(resource.MustParse("8"))
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeTrue())
+	})
+
+	It("deletes the mirrored quota when the host quota is removed", func() {
+		ctx := context.Background()
+
+		var mirroredQuota v1.ResourceQuota
+
+		Eventually(func() error {
+			return virtTestEnv.k8sClient.Get(ctx, mirroredQuotaKey, &mirroredQuota)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		err := hostTestEnv.k8sClient.Delete(ctx, hostQuota)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() bool {
+			err := virtTestEnv.k8sClient.Get(ctx, mirroredQuotaKey, &mirroredQuota)
+			return apierrors.IsNotFound(err)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeTrue())
+	})
+}
@@ -0,0 +1,242 @@
+package syncer
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller"
+)
+
+const (
+	// StorageClassMappedLabel is the label of the StorageClasses created in the virtual cluster from the StorageClass mapping.
+	StorageClassMappedLabel = "storageclass.k3k.io/mapped"
+	// HostStorageClassAnnotation is the annotation with the name of the host StorageClass mapped to a virtual StorageClass.
+	HostStorageClassAnnotation = "storageclass.k3k.io/host-storage-class"
+
+	isDefaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+	storageClassControllerName = "storageclass-syncer-controller"
+)
+
+type StorageClassReconciler struct {
+	*SyncerContext
+}
+
+// AddStorageClassSyncer adds the controller creating the StorageClasses of the StorageClass mapping in the virtual cluster.
+// The StorageClasses are read-only: the changes made in the virtual cluster are reverted, and the deleted classes are recreated.
+func AddStorageClassSyncer(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) error {
+	syncerContext, err := newSyncerContext(ctx, virtMgr, hostMgr, clusterName, clusterNamespace)
+	if err != nil {
+		return err
+	}
+
+	reconciler := StorageClassReconciler{
+		SyncerContext: syncerContext,
+	}
+
+	name := reconciler.Translator.TranslateName(clusterNamespace, storageClassControllerName)
+
+	// the mapping is read from the Cluster, and the virtual StorageClasses are copied from the host ones
+	clusterSource := source.Kind(hostMgr.GetCache(), &v1beta1.Cluster{},
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.clusterStorageClassRequests),
+	)
+
+	hostStorageClassSource := source.Kind(hostMgr.GetCache(), &storagev1.StorageClass{},
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.hostStorageClassRequests),
+	)
+
+	return ctrl.NewControllerManagedBy(virtMgr).
+		Named(name).
+		For(&storagev1.StorageClass{}).
+		WatchesRawSource(clusterSource).
+		WatchesRawSource(hostStorageClassSource).
+		Complete(&reconciler)
+}
+
+// clusterStorageClassRequests maps the Cluster to the requests for the mapped StorageClasses, and for the virtual
+// StorageClasses created from a previous mapping.
+func (r *StorageClassReconciler) clusterStorageClassRequests(ctx context.Context, cluster *v1beta1.Cluster) []reconcile.Request {
+	if cluster.Name != r.ClusterName || cluster.Namespace != r.ClusterNamespace {
+		return nil
+	}
+
+	names := make(map[string]struct{})
+
+	if syncConfig := controller.SyncConfig(cluster); syncConfig != nil {
+		for name := range syncConfig.PersistentVolumeClaims.StorageClassMapping {
+			names[name] = struct{}{}
+		}
+	}
+
+	var virtStorageClasses storagev1.StorageClassList
+	if err := r.VirtualClient.List(ctx, &virtStorageClasses, ctrlruntimeclient.HasLabels{StorageClassMappedLabel}); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "failed to list the mapped storage classes")
+	}
+
+	for _, storageClass := range virtStorageClasses.Items {
+		names[storageClass.Name] = struct{}{}
+	}
+
+	var requests []reconcile.Request
+
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		if name != "" {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+		}
+	}
+
+	return requests
+}
+
+// hostStorageClassRequests maps a host StorageClass to the requests for the virtual StorageClasses mapped to it.
+func (r *StorageClassReconciler) hostStorageClassRequests(ctx context.Context, hostStorageClass *storagev1.StorageClass) []reconcile.Request {
+	var cluster v1beta1.Cluster
+	if err := r.HostClient.Get(ctx, types.NamespacedName{Name: r.ClusterName, Namespace: r.ClusterNamespace}, &cluster); err != nil || controller.SyncConfig(&cluster) == nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+
+	for name, hostName := range controller.SyncConfig(&cluster).PersistentVolumeClaims.StorageClassMapping {
+		if hostName == hostStorageClass.Name && name != "" {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+		}
+	}
+
+	return requests
+}
+
+func (r *StorageClassReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithValues("cluster", r.ClusterName, "clusterNamespace", r.ClusterNamespace)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	var cluster v1beta1.Cluster
+	if err := r.HostClient.Get(ctx, types.NamespacedName{Name: r.ClusterName, Namespace: r.ClusterNamespace}, &cluster); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	var syncConfig v1beta1.PersistentVolumeClaimSyncConfig
+	if clusterSyncConfig := controller.SyncConfig(&cluster); clusterSyncConfig != nil {
+		syncConfig = clusterSyncConfig.PersistentVolumeClaims
+	}
+
+	var virtStorageClass storagev1.StorageClass
+
+	virtStorageClassExists := true
+
+	if err := r.VirtualClient.Get(ctx, req.NamespacedName, &virtStorageClass); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		virtStorageClassExists = false
+	}
+
+	// the StorageClasses not created by the controller are not managed
+	isMapped := virtStorageClassExists && virtStorageClass.Labels[StorageClassMappedLabel] == "true"
+
+	var hostStorageClass storagev1.StorageClass
+
+	hostStorageClassName, found := syncConfig.StorageClassMapping[req.Name]
+	if found {
+		if err := r.HostClient.Get(ctx, types.NamespacedName{Name: hostStorageClassName}, &hostStorageClass); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return reconcile.Result{}, err
+			}
+
+			log.Info("host storage class not found", "storageClass", hostStorageClassName)
+
+			found = false
+		}
+	}
+
+	// deleting the StorageClasses removed from the mapping
+	if !found {
+		if isMapped {
+			log.Info("deleting the storage class removed from the mapping", "storageClass", req.Name)
+			return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(r.VirtualClient.Delete(ctx, &virtStorageClass))
+		}
+
+		return reconcile.Result{}, nil
+	}
+
+	storageClass := virtualStorageClass(req.Name, &hostStorageClass, req.Name == syncConfig.DefaultStorageClassName)
+
+	if !virtStorageClassExists {
+		log.Info("creating the mapped storage class", "storageClass", req.Name)
+		return reconcile.Result{}, r.VirtualClient.Create(ctx, storageClass)
+	}
+
+	// the provisioner, the parameters and the policies of the StorageClasses are immutable, so the StorageClass is recreated.
+	// A StorageClass with the same name created in the virtual cluster is replaced by the mapped one.
+	if !isMapped || !equalImmutableStorageClassFields(&virtStorageClass, storageClass) {
+		log.Info("recreating the mapped storage class", "storageClass", req.Name)
+		return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(r.VirtualClient.Delete(ctx, &virtStorageClass))
+	}
+
+	if equality.Semantic.DeepEqual(virtStorageClass.Labels, storageClass.Labels) &&
+		equality.Semantic.DeepEqual(virtStorageClass.Annotations, storageClass.Annotations) &&
+		equality.Semantic.DeepEqual(virtStorageClass.AllowVolumeExpansion, storageClass.AllowVolumeExpansion) {
+		return reconcile.Result{}, nil
+	}
+
+	log.Info("updating the mapped storage class", "storageClass", req.Name)
+
+	virtStorageClass.Labels = storageClass.Labels
+	virtStorageClass.Annotations = storageClass.Annotations
+	virtStorageClass.AllowVolumeExpansion = storageClass.AllowVolumeExpansion
+
+	return reconcile.Result{}, r.VirtualClient.Update(ctx, &virtStorageClass)
+}
+
+// virtualStorageClass returns the StorageClass of the virtual cluster mapped to the host StorageClass.
+// The parameters and the allowed topologies of the host StorageClass are not copied, since they are used only by the host provisioner.
+func virtualStorageClass(name string, hostStorageClass *storagev1.StorageClass, isDefault bool) *storagev1.StorageClass {
+	storageClass := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				StorageClassMappedLabel: "true",
+			},
+			Annotations: map[string]string{
+				HostStorageClassAnnotation: hostStorageClass.Name,
+			},
+		},
+		Provisioner:          hostStorageClass.Provisioner,
+		ReclaimPolicy:        hostStorageClass.ReclaimPolicy,
+		AllowVolumeExpansion: hostStorageClass.AllowVolumeExpansion,
+		VolumeBindingMode:    hostStorageClass.VolumeBindingMode,
+		MountOptions:         hostStorageClass.MountOptions,
+	}
+
+	if isDefault {
+		storageClass.Annotations[isDefaultStorageClassAnnotation] = "true"
+	}
+
+	return storageClass
+}
+
+// equalImmutableStorageClassFields returns true if the immutable fields of the StorageClasses are equal.
+func equalImmutableStorageClassFields(a, b *storagev1.StorageClass) bool {
+	return a.Provisioner == b.Provisioner &&
+		equality.Semantic.DeepEqual(a.Parameters, b.Parameters) &&
+		equality.Semantic.DeepEqual(a.ReclaimPolicy, b.ReclaimPolicy) &&
+		equality.Semantic.DeepEqual(a.VolumeBindingMode, b.VolumeBindingMode) &&
+		equality.Semantic.DeepEqual(a.MountOptions, b.MountOptions) &&
+		equality.Semantic.DeepEqual(a.AllowedTopologies, b.AllowedTopologies)
+}
@@ -0,0 +1,170 @@
+package syncer_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/k3k/k3k-kubelet/controller/syncer"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var StorageClassTests = func() {
+	var (
+		namespace        string
+		cluster          v1beta1.Cluster
+		hostStorageClass *storagev1.StorageClass
+		storageClassName string
+	)
+
+	BeforeEach(func() {
+		ctx := context.Background()
+
+		ns := v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "ns-"},
+		}
+		err := hostTestEnv.k8sClient.Create(ctx, &ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = ns.Name
+
+		hostStorageClass = &storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{GenerateName: "host-sc-"},
+			Provisioner: "test.csi.k8s.io",
+			Parameters: map[string]string{
+				"type": "ssd",
+			},
+		}
+		err = hostTestEnv.k8sClient.Create(ctx, hostStorageClass)
+		Expect(err).NotTo(HaveOccurred())
+
+		storageClassName = "virtual-" + hostStorageClass.Name
+
+		cluster = v1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "cluster-",
+				Namespace:    namespace,
+			},
+			Spec: v1beta1.ClusterSpec{
+				Sync: &v1beta1.SyncConfig{
+					PersistentVolumeClaims: v1beta1.PersistentVolumeClaimSyncConfig{
+						Enabled: true,
+						StorageClassMapping: map[string]string{
+							storageClassName: hostStorageClass.Name,
+						},
+						DefaultStorageClassName: storageClassName,
+					},
+				},
+			},
+		}
+		err = hostTestEnv.k8sClient.Create(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = syncer.AddStorageClassSyncer(ctx, virtManager, hostManager, cluster.Name, cluster.Namespace)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ns := v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		err := hostTestEnv.k8sClient.Delete(context.Background(), &ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = hostTestEnv.k8sClient.Delete(context.Background(), hostStorageClass)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("creates the mapped StorageClass in the virtual cluster", func() {
+		ctx := context.Background()
+
+		var storageClass storagev1.StorageClass
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: storageClassName}
+			return virtTestEnv.k8sClient.Get(ctx, key, &storageClass)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		By(fmt.Sprintf("Created StorageClass %s in virtual cluster", storageClassName))
+
+		Expect(storageClass.Provisioner).To(Equal("test.csi.k8s.io"))
+		Expect(storageClass.Parameters).To(BeEmpty())
+		Expect(storageClass.Labels).To(HaveKeyWithValue(syncer.StorageClassMappedLabel, "true"))
+		Expect(storageClass.Annotations).To(HaveKeyWithValue(syncer.HostStorageClassAnnotation, hostStorageClass.Name))
+		Expect(storageClass.Annotations).To(HaveKeyWithValue("storageclass.kubernetes.io/is-default-class", "true"))
+	})
+
+	It("recreates the mapped StorageClass deleted in the virtual cluster", func() {
+		ctx := context.Background()
+
+		var storageClass storagev1.StorageClass
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: storageClassName}
+			return virtTestEnv.k8sClient.Get(ctx, key, &storageClass)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		uid := storageClass.UID
+
+		err := virtTestEnv.k8sClient.Delete(ctx, &storageClass)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Deleted StorageClass %s in virtual cluster", storageClassName))
+
+		Eventually(func() bool {
+			key := client.ObjectKey{Name: storageClassName}
+			err := virtTestEnv.k8sClient.Get(ctx, key, &storageClass)
+			return err == nil && storageClass.UID != uid
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeTrue())
+	})
+
+	It("deletes the StorageClass removed from the mapping", func() {
+		ctx := context.Background()
+
+		var storageClass storagev1.StorageClass
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: storageClassName}
+			return virtTestEnv.k8sClient.Get(ctx, key, &storageClass)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		err := hostTestEnv.k8sClient.Get(ctx, client.ObjectKeyFromObject(&cluster), &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		cluster.Spec.Sync.PersistentVolumeClaims.StorageClassMapping = nil
+		cluster.Spec.Sync.PersistentVolumeClaims.DefaultStorageClassName = ""
+		err = hostTestEnv.k8sClient.Update(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Removed the StorageClass mapping")
+
+		Eventually(func() bool {
+			key := client.ObjectKey{Name: storageClassName}
+			err := virtTestEnv.k8sClient.Get(ctx, key, &storageClass)
+			return apierrors.IsNotFound(err)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeTrue())
+	})
+}
@@ -1,9 +1,14 @@
 package syncer
 
 import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
 )
 
 type SyncerContext struct {
@@ -13,3 +18,20 @@ type SyncerContext struct {
 	HostClient       client.Client
 	Translator       translate.ToHostTranslator
 }
+
+// newSyncerContext returns the context of the syncers of a virtual cluster. The Cluster is read to translate
+// the host objects according to its host namespace mode, which is immutable.
+func newSyncerContext(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) (*SyncerContext, error) {
+	var cluster v1beta1.Cluster
+	if err := hostMgr.GetAPIReader().Get(ctx, types.NamespacedName{Name: clusterName, Namespace: clusterNamespace}, &cluster); err != nil {
+		return nil, err
+	}
+
+	return &SyncerContext{
+		ClusterName:      clusterName,
+		ClusterNamespace: clusterNamespace,
+		VirtualClient:    virtMgr.GetClient(),
+		HostClient:       hostMgr.GetClient(),
+		Translator:       *translate.NewHostTranslator(&cluster),
+	}, nil
+}
@@ -15,9 +15,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
 
 	"github.com/rancher/k3k/k3k-kubelet/translate"
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
@@ -92,7 +94,7 @@ func NewTestEnv() *TestEnv {
 	By("bootstrapping test environment")
 
 	testEnv := &envtest.Environment{
-		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "..", "charts", "k3k", "crds")},
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "..", "charts", "k3k", "crds"), filepath.Join("testdata", "crds")},
 		ErrorIfCRDPathMissing: true,
 		BinaryAssetsDirectory: tempDir,
 		Scheme:                buildScheme(),
@@ -121,6 +123,10 @@ func buildScheme() *runtime.Scheme {
 	Expect(err).NotTo(HaveOccurred())
 	err = v1beta1.AddToScheme(scheme)
 	Expect(err).NotTo(HaveOccurred())
+	err = gwapiv1.Install(scheme)
+	Expect(err).NotTo(HaveOccurred())
+	err = snapshotv1.AddToScheme(scheme)
+	Expect(err).NotTo(HaveOccurred())
 
 	return scheme
 }
@@ -171,7 +177,11 @@ var _ = Describe("Kubelet Controller", func() {
 	Describe("Secret Syncer", SecretTests)
 	Describe("Service Syncer", ServiceTests)
 	Describe("Ingress Syncer", IngressTests)
+	Describe("HTTPRoute Syncer", HTTPRouteTests)
 	Describe("PersistentVolumeClaim Syncer", PVCTests)
+	Describe("StorageClass Syncer", StorageClassTests)
+	Describe("ResourceQuota Syncer", ResourceQuotaTests)
+	Describe("VolumeSnapshot Syncer", VolumeSnapshotTests)
 })
 
 func translateName(cluster v1beta1.Cluster, namespace, name string) string {
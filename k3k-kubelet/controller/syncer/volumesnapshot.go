@@ -0,0 +1,337 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller"
+)
+
+const (
+	// VolumeSnapshotNotSyncedReason is the reason of the events recorded when a VolumeSnapshot cannot be synced to the host cluster.
+	VolumeSnapshotNotSyncedReason = "VolumeSnapshotNotSynced"
+
+	volumeSnapshotControllerName = "volumesnapshot-syncer-controller"
+	volumeSnapshotFinalizerName  = "volumesnapshot.k3k.io/finalizer"
+)
+
+type VolumeSnapshotReconciler struct {
+	*SyncerContext
+	record.EventRecorder
+}
+
+// AddVolumeSnapshotSyncer adds the VolumeSnapshot syncer controller to the manager of the virtual cluster
+func AddVolumeSnapshotSyncer(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) error {
+	syncerContext, err := newSyncerContext(ctx, virtMgr, hostMgr, clusterName, clusterNamespace)
+	if err != nil {
+		return err
+	}
+
+	reconciler := VolumeSnapshotReconciler{
+		SyncerContext: syncerContext,
+	}
+
+	name := reconciler.Translator.TranslateName(clusterNamespace, volumeSnapshotControllerName)
+
+	reconciler.EventRecorder = virtMgr.GetEventRecorderFor(name)
+
+	// the host VolumeSnapshots are watched to reflect their status back to the virtual VolumeSnapshots
+	hostVolumeSnapshotSource := source.Kind(hostMgr.GetCache(), &snapshotv1.VolumeSnapshot{},
+		handler.TypedEnqueueRequestsFromMapFunc(reconciler.virtualVolumeSnapshotRequest),
+		predicate.TypedFuncs[*snapshotv1.VolumeSnapshot]{
+			CreateFunc:  func(event.TypedCreateEvent[*snapshotv1.VolumeSnapshot]) bool { return false },
+			DeleteFunc:  func(event.TypedDeleteEvent[*snapshotv1.VolumeSnapshot]) bool { return false },
+			GenericFunc: func(event.TypedGenericEvent[*snapshotv1.VolumeSnapshot]) bool { return false },
+			UpdateFunc: func(e event.TypedUpdateEvent[*snapshotv1.VolumeSnapshot]) bool {
+				return !equality.Semantic.DeepEqual(e.ObjectOld.Status, e.ObjectNew.Status)
+			},
+		},
+	)
+
+	return ctrl.NewControllerManagedBy(virtMgr).
+		Named(name).
+		For(&snapshotv1.VolumeSnapshot{}).
+		WithEventFilter(predicate.NewPredicateFuncs(reconciler.filterResources)).
+		WatchesRawSource(hostVolumeSnapshotSource).
+		Complete(&reconciler)
+}
+
+// virtualVolumeSnapshotRequest maps a synced host VolumeSnapshot to the request for its virtual VolumeSnapshot.
+func (r *VolumeSnapshotReconciler) virtualVolumeSnapshotRequest(_ context.Context, hostSnapshot *snapshotv1.VolumeSnapshot) []reconcile.Request {
+	if !r.Translator.IsHostObject(hostSnapshot) {
+		return nil
+	}
+
+	name := hostSnapshot.Annotations[translate.ResourceNameAnnotation]
+	namespace := hostSnapshot.Annotations[translate.ResourceNamespaceAnnotation]
+
+	if name == "" || namespace == "" {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}}
+}
+
+func (r *VolumeSnapshotReconciler) filterResources(object ctrlruntimeclient.Object) bool {
+	var cluster v1beta1.Cluster
+
+	ctx := context.Background()
+
+	if err := r.HostClient.Get(ctx, types.NamespacedName{Name: r.ClusterName, Namespace: r.ClusterNamespace}, &cluster); err != nil {
+		return false
+	}
+
+	syncConfig := controller.SyncConfig(&cluster).VolumeSnapshots
+
+	// If syncing is disabled, only process deletions to allow for cleanup.
+	if !syncConfig.Enabled {
+		return object.GetDeletionTimestamp() != nil
+	}
+
+	labelSelector := labels.SelectorFromSet(syncConfig.Selector)
+	if labelSelector.Empty() {
+		return true
+	}
+
+	return labelSelector.Matches(labels.Set(object.GetLabels()))
+}
+
+func (r *VolumeSnapshotReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithValues("cluster", r.ClusterName, "clusterNamespace", r.ClusterNamespace)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	log.Info("reconciling volumesnapshot object")
+
+	var (
+		virtSnapshot snapshotv1.VolumeSnapshot
+		cluster      v1beta1.Cluster
+	)
+
+	if err := r.HostClient.Get(ctx, types.NamespacedName{Name: r.ClusterName, Namespace: r.ClusterNamespace}, &cluster); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if err := r.VirtualClient.Get(ctx, req.NamespacedName, &virtSnapshot); err != nil {
+		return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
+	}
+
+	// the host VolumeSnapshots events are not filtered by the sync config
+	if !r.filterResources(&virtSnapshot) {
+		return reconcile.Result{}, nil
+	}
+
+	syncConfig := controller.SyncConfig(&cluster).VolumeSnapshots
+
+	syncedSnapshot, syncErr := r.volumeSnapshot(&virtSnapshot, syncConfig)
+	if err := translate.SetClusterOwner(&cluster, syncedSnapshot, r.HostClient.Scheme()); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// handle deletion
+	if !virtSnapshot.DeletionTimestamp.IsZero() {
+		// deleting the synced snapshot if exists, the snapshot data is deleted according to the host VolumeSnapshotClass
+		if err := r.HostClient.Delete(ctx, syncedSnapshot); err != nil && !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		// deleting the VolumeSnapshotContent bound to the virtual snapshot
+		virtContent := snapshotv1.VolumeSnapshotContent{ObjectMeta: metav1.ObjectMeta{Name: virtualVolumeSnapshotContentName(&virtSnapshot)}}
+		if err := r.VirtualClient.Delete(ctx, &virtContent); err != nil && !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		// remove the finalizer after cleaning up the synced snapshot
+		if controllerutil.RemoveFinalizer(&virtSnapshot, volumeSnapshotFinalizerName) {
+			if err := r.VirtualClient.Update(ctx, &virtSnapshot); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+
+		return reconcile.Result{}, nil
+	}
+
+	if syncErr != nil {
+		log.Info("volumesnapshot cannot be synced to the host cluster", "reason", syncErr.Error())
+		r.Eventf(&virtSnapshot, v1.EventTypeWarning, VolumeSnapshotNotSyncedReason, "VolumeSnapshot not synced to the host cluster: %s", syncErr.Error())
+
+		return reconcile.Result{}, nil
+	}
+
+	// Add finalizer if it does not exist
+	if controllerutil.AddFinalizer(&virtSnapshot, volumeSnapshotFinalizerName) {
+		if err := r.VirtualClient.Update(ctx, &virtSnapshot); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	var hostSnapshot snapshotv1.VolumeSnapshot
+	if err := r.HostClient.Get(ctx, ctrlruntimeclient.ObjectKeyFromObject(syncedSnapshot), &hostSnapshot); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		// the spec of a VolumeSnapshot is immutable, so it's only created
+		log.Info("creating the volumesnapshot for the first time on the host cluster")
+
+		return reconcile.Result{}, ctrlruntimeclient.IgnoreAlreadyExists(r.HostClient.Create(ctx, syncedSnapshot))
+	}
+
+	return reconcile.Result{}, r.reflectStatus(ctx, &virtSnapshot, &hostSnapshot)
+}
+
+// reflectStatus reflects the status of the host VolumeSnapshot to the virtual one. The host VolumeSnapshotContent
+// is reflected as a pre-provisioned VolumeSnapshotContent in the virtual cluster.
+func (r *VolumeSnapshotReconciler) reflectStatus(ctx context.Context, virtSnapshot, hostSnapshot *snapshotv1.VolumeSnapshot) error {
+	if hostSnapshot.Status == nil {
+		return nil
+	}
+
+	status := hostSnapshot.Status.DeepCopy()
+	status.BoundVolumeSnapshotContentName = nil
+	status.VolumeGroupSnapshotName = nil
+
+	if hostSnapshot.Status.BoundVolumeSnapshotContentName != nil {
+		var hostContent snapshotv1.VolumeSnapshotContent
+
+		key := types.NamespacedName{Name: *hostSnapshot.Status.BoundVolumeSnapshotContentName}
+		if err := r.HostClient.Get(ctx, key, &hostContent); ctrlruntimeclient.IgnoreNotFound(err) != nil {
+			return err
+		}
+
+		if hostContent.Status != nil && hostContent.Status.SnapshotHandle != nil {
+			virtContent, err := r.ensureVirtualVolumeSnapshotContent(ctx, virtSnapshot, &hostContent)
+			if err != nil {
+				return err
+			}
+
+			status.BoundVolumeSnapshotContentName = &virtContent.Name
+		}
+	}
+
+	if equality.Semantic.DeepEqual(virtSnapshot.Status, status) {
+		return nil
+	}
+
+	ctrl.LoggerFrom(ctx).Info("updating volumesnapshot status on the virtual cluster")
+
+	virtSnapshot.Status = status
+
+	return r.VirtualClient.Status().Update(ctx, virtSnapshot)
+}
+
+// ensureVirtualVolumeSnapshotContent creates the VolumeSnapshotContent bound to the virtual VolumeSnapshot, referencing
+// the snapshot handle of the host VolumeSnapshotContent, and updates its status.
+// The snapshot data is owned by the host cluster, so the deletion policy of the virtual VolumeSnapshotContent is Retain.
+func (r *VolumeSnapshotReconciler) ensureVirtualVolumeSnapshotContent(ctx context.Context, virtSnapshot *snapshotv1.VolumeSnapshot, hostContent *snapshotv1.VolumeSnapshotContent) (*snapshotv1.VolumeSnapshotContent, error) {
+	virtContent := &snapshotv1.VolumeSnapshotContent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: virtualVolumeSnapshotContentName(virtSnapshot),
+		},
+		Spec: snapshotv1.VolumeSnapshotContentSpec{
+			VolumeSnapshotRef: v1.ObjectReference{
+				APIVersion: snapshotv1.SchemeGroupVersion.String(),
+				Kind:       "VolumeSnapshot",
+				Namespace:  virtSnapshot.Namespace,
+				Name:       virtSnapshot.Name,
+				UID:        virtSnapshot.UID,
+			},
+			DeletionPolicy:          snapshotv1.VolumeSnapshotContentRetain,
+			Driver:                  hostContent.Spec.Driver,
+			VolumeSnapshotClassName: virtSnapshot.Spec.VolumeSnapshotClassName,
+			Source: snapshotv1.VolumeSnapshotContentSource{
+				SnapshotHandle: hostContent.Status.SnapshotHandle,
+			},
+			SourceVolumeMode: hostContent.Spec.SourceVolumeMode,
+		},
+	}
+
+	var currentContent snapshotv1.VolumeSnapshotContent
+
+	if err := r.VirtualClient.Get(ctx, ctrlruntimeclient.ObjectKeyFromObject(virtContent), &currentContent); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
+		if err := r.VirtualClient.Create(ctx, virtContent); err != nil {
+			return nil, err
+		}
+
+		currentContent = *virtContent
+	}
+
+	status := hostContent.Status.DeepCopy()
+	status.VolumeGroupSnapshotHandle = nil
+
+	if !equality.Semantic.DeepEqual(currentContent.Status, status) {
+		currentContent.Status = status
+
+		if err := r.VirtualClient.Status().Update(ctx, &currentContent); err != nil {
+			return nil, err
+		}
+	}
+
+	return &currentContent, nil
+}
+
+// volumeSnapshot translates the virtual VolumeSnapshot to the host one, taking the snapshot of the synced PVC.
+// Only the snapshots of PVCs can be synced: the pre-provisioned VolumeSnapshotContents are not synced.
+// The VolumeSnapshotClass is mapped to the host VolumeSnapshotClass, and an error is returned if it's not mapped.
+func (r *VolumeSnapshotReconciler) volumeSnapshot(obj *snapshotv1.VolumeSnapshot, syncConfig v1beta1.VolumeSnapshotSyncConfig) (*snapshotv1.VolumeSnapshot, error) {
+	hostSnapshot := obj.DeepCopy()
+	r.Translator.TranslateTo(hostSnapshot)
+
+	// the status is reflected from the host to the virtual cluster
+	hostSnapshot.Status = nil
+
+	if obj.Spec.Source.PersistentVolumeClaimName == nil {
+		return hostSnapshot, errors.New("only the snapshots of PersistentVolumeClaims are supported")
+	}
+
+	pvcName := r.Translator.TranslateName(obj.Namespace, *obj.Spec.Source.PersistentVolumeClaimName)
+	hostSnapshot.Spec.Source.PersistentVolumeClaimName = &pvcName
+
+	if len(syncConfig.VolumeSnapshotClassMapping) == 0 {
+		return hostSnapshot, nil
+	}
+
+	// the default VolumeSnapshotClass of the host cluster is not allowed if a mapping is specified
+	if obj.Spec.VolumeSnapshotClassName == nil {
+		return hostSnapshot, errors.New("volumeSnapshotClassName is required")
+	}
+
+	hostClassName, found := syncConfig.VolumeSnapshotClassMapping[*obj.Spec.VolumeSnapshotClassName]
+	if !found {
+		return hostSnapshot, fmt.Errorf("volumeSnapshotClassName %q is not allowed", *obj.Spec.VolumeSnapshotClassName)
+	}
+
+	hostSnapshot.Spec.VolumeSnapshotClassName = &hostClassName
+
+	return hostSnapshot, nil
+}
+
+// virtualVolumeSnapshotContentName returns the name of the VolumeSnapshotContent bound to the virtual VolumeSnapshot.
+func virtualVolumeSnapshotContentName(snapshot *snapshotv1.VolumeSnapshot) string {
+	return "snapcontent-" + string(snapshot.UID)
+}
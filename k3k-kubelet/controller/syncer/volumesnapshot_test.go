@@ -0,0 +1,326 @@
+package syncer_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/k3k/k3k-kubelet/controller/syncer"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var VolumeSnapshotTests = func() {
+	var (
+		namespace string
+		cluster   v1beta1.Cluster
+	)
+
+	BeforeEach(func() {
+		ctx := context.Background()
+
+		ns := v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "ns-"},
+		}
+		err := hostTestEnv.k8sClient.Create(ctx, &ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = ns.Name
+
+		cluster = v1beta1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "cluster-",
+				Namespace:    namespace,
+			},
+			Spec: v1beta1.ClusterSpec{
+				Sync: &v1beta1.SyncConfig{
+					VolumeSnapshots: v1beta1.VolumeSnapshotSyncConfig{
+						Enabled: true,
+					},
+				},
+			},
+		}
+		err = hostTestEnv.k8sClient.Create(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = syncer.AddVolumeSnapshotSyncer(ctx, virtManager, hostManager, cluster.Name, cluster.Namespace)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ns := v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		err := hostTestEnv.k8sClient.Delete(context.Background(), &ns)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("creates a VolumeSnapshot of the synced pvc on the host cluster", func() {
+		ctx := context.Background()
+
+		snapshot := &snapshotv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "snapshot-",
+				Namespace:    "default",
+			},
+			Spec: snapshotv1.VolumeSnapshotSpec{
+				Source: snapshotv1.VolumeSnapshotSource{
+					PersistentVolumeClaimName: ptr.To("test-pvc"),
+				},
+				VolumeSnapshotClassName: ptr.To("test-snapshot-class"),
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, snapshot)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created VolumeSnapshot %s in virtual cluster", snapshot.Name))
+
+		var hostSnapshot snapshotv1.VolumeSnapshot
+		hostSnapshotName := translateName(cluster, snapshot.Namespace, snapshot.Name)
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: hostSnapshotName, Namespace: namespace}
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostSnapshot)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		By(fmt.Sprintf("Created VolumeSnapshot %s in host cluster", hostSnapshotName))
+
+		Expect(*hostSnapshot.Spec.Source.PersistentVolumeClaimName).To(Equal(translateName(cluster, snapshot.Namespace, "test-pvc")))
+		Expect(*hostSnapshot.Spec.VolumeSnapshotClassName).To(Equal("test-snapshot-class"))
+	})
+
+	It("will not create a VolumeSnapshot of a VolumeSnapshotContent", func() {
+		ctx := context.Background()
+
+		snapshot := &snapshotv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "snapshot-",
+				Namespace:    "default",
+			},
+			Spec: snapshotv1.VolumeSnapshotSpec{
+				Source: snapshotv1.VolumeSnapshotSource{
+					VolumeSnapshotContentName: ptr.To("test-content"),
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, snapshot)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created VolumeSnapshot %s in virtual cluster", snapshot.Name))
+
+		var hostSnapshot snapshotv1.VolumeSnapshot
+		hostSnapshotName := translateName(cluster, snapshot.Namespace, snapshot.Name)
+
+		Consistently(func() bool {
+			key := client.ObjectKey{Name: hostSnapshotName, Namespace: namespace}
+			err := hostTestEnv.k8sClient.Get(ctx, key, &hostSnapshot)
+			return apierrors.IsNotFound(err)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 3).
+			Should(BeTrue())
+	})
+
+	It("maps the VolumeSnapshotClass of the VolumeSnapshot to the host VolumeSnapshotClass", func() {
+		ctx := context.Background()
+
+		err := hostTestEnv.k8sClient.Get(ctx, client.ObjectKeyFromObject(&cluster), &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		cluster.Spec.Sync.VolumeSnapshots.VolumeSnapshotClassMapping = map[string]string{
+			"fast": "host-fast",
+		}
+		err = hostTestEnv.k8sClient.Update(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		snapshot := &snapshotv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "snapshot-",
+				Namespace:    "default",
+			},
+			Spec: snapshotv1.VolumeSnapshotSpec{
+				Source: snapshotv1.VolumeSnapshotSource{
+					PersistentVolumeClaimName: ptr.To("test-pvc"),
+				},
+				VolumeSnapshotClassName: ptr.To("fast"),
+			},
+		}
+
+		err = virtTestEnv.k8sClient.Create(ctx, snapshot)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created VolumeSnapshot %s in virtual cluster", snapshot.Name))
+
+		var hostSnapshot snapshotv1.VolumeSnapshot
+		hostSnapshotName := translateName(cluster, snapshot.Namespace, snapshot.Name)
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: hostSnapshotName, Namespace: namespace}
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostSnapshot)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		Expect(hostSnapshot.Spec.VolumeSnapshotClassName).To(Equal(ptr.To("host-fast")))
+	})
+
+	It("will not create a VolumeSnapshot with a VolumeSnapshotClass not mapped", func() {
+		ctx := context.Background()
+
+		err := hostTestEnv.k8sClient.Get(ctx, client.ObjectKeyFromObject(&cluster), &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		cluster.Spec.Sync.VolumeSnapshots.VolumeSnapshotClassMapping = map[string]string{
+			"fast": "host-fast",
+		}
+		err = hostTestEnv.k8sClient.Update(ctx, &cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, volumeSnapshotClassName := range []*string{ptr.To("expensive"), nil} {
+			snapshot := &snapshotv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "snapshot-",
+					Namespace:    "default",
+				},
+				Spec: snapshotv1.VolumeSnapshotSpec{
+					Source: snapshotv1.VolumeSnapshotSource{
+						PersistentVolumeClaimName: ptr.To("test-pvc"),
+					},
+					VolumeSnapshotClassName: volumeSnapshotClassName,
+				},
+			}
+
+			err := virtTestEnv.k8sClient.Create(ctx, snapshot)
+			Expect(err).NotTo(HaveOccurred())
+
+			By(fmt.Sprintf("Created VolumeSnapshot %s in virtual cluster", snapshot.Name))
+
+			var hostSnapshot snapshotv1.VolumeSnapshot
+			hostSnapshotName := translateName(cluster, snapshot.Namespace, snapshot.Name)
+
+			Consistently(func() bool {
+				key := client.ObjectKey{Name: hostSnapshotName, Namespace: namespace}
+				err := hostTestEnv.k8sClient.Get(ctx, key, &hostSnapshot)
+				return apierrors.IsNotFound(err)
+			}).
+				WithPolling(time.Millisecond * 300).
+				WithTimeout(time.Second * 3).
+				Should(BeTrue())
+		}
+	})
+
+	It("reflects the status of the host VolumeSnapshot", func() {
+		ctx := context.Background()
+
+		snapshot := &snapshotv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "snapshot-",
+				Namespace:    "default",
+			},
+			Spec: snapshotv1.VolumeSnapshotSpec{
+				Source: snapshotv1.VolumeSnapshotSource{
+					PersistentVolumeClaimName: ptr.To("test-pvc"),
+				},
+			},
+		}
+
+		err := virtTestEnv.k8sClient.Create(ctx, snapshot)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("Created VolumeSnapshot %s in virtual cluster", snapshot.Name))
+
+		var hostSnapshot snapshotv1.VolumeSnapshot
+		hostSnapshotName := translateName(cluster, snapshot.Namespace, snapshot.Name)
+
+		Eventually(func() error {
+			key := client.ObjectKey{Name: hostSnapshotName, Namespace: namespace}
+			return hostTestEnv.k8sClient.Get(ctx, key, &hostSnapshot)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeNil())
+
+		// bind the host snapshot to a VolumeSnapshotContent, as done by the host snapshot controller
+		hostContent := &snapshotv1.VolumeSnapshotContent{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "snapcontent-"},
+			Spec: snapshotv1.VolumeSnapshotContentSpec{
+				VolumeSnapshotRef: v1.ObjectReference{
+					Namespace: hostSnapshot.Namespace,
+					Name:      hostSnapshot.Name,
+					UID:       hostSnapshot.UID,
+				},
+				DeletionPolicy: snapshotv1.VolumeSnapshotContentDelete,
+				Driver:         "test.csi.k8s.io",
+				Source: snapshotv1.VolumeSnapshotContentSource{
+					VolumeHandle: ptr.To("volume-1"),
+				},
+			},
+		}
+
+		err = hostTestEnv.k8sClient.Create(ctx, hostContent)
+		Expect(err).NotTo(HaveOccurred())
+
+		DeferCleanup(func() {
+			Expect(hostTestEnv.k8sClient.Delete(context.Background(), hostContent)).To(Succeed())
+		})
+
+		restoreSize := resource.MustParse("1Gi")
+
+		hostContent.Status = &snapshotv1.VolumeSnapshotContentStatus{
+			SnapshotHandle: ptr.To("snapshot-1"),
+			ReadyToUse:     ptr.To(true),
+			RestoreSize:    ptr.To(restoreSize.Value()),
+		}
+		err = hostTestEnv.k8sClient.Status().Update(ctx, hostContent)
+		Expect(err).NotTo(HaveOccurred())
+
+		hostSnapshot.Status = &snapshotv1.VolumeSnapshotStatus{
+			BoundVolumeSnapshotContentName: ptr.To(hostContent.Name),
+			ReadyToUse:                     ptr.To(true),
+			RestoreSize:                    &restoreSize,
+		}
+		err = hostTestEnv.k8sClient.Status().Update(ctx, &hostSnapshot)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Updated the status of the host VolumeSnapshot")
+
+		Eventually(func() bool {
+			key := client.ObjectKeyFromObject(snapshot)
+			err := virtTestEnv.k8sClient.Get(ctx, key, snapshot)
+			Expect(err).NotTo(HaveOccurred())
+
+			return snapshot.Status != nil && ptr.Deref(snapshot.Status.ReadyToUse, false)
+		}).
+			WithPolling(time.Millisecond * 300).
+			WithTimeout(time.Second * 10).
+			Should(BeTrue())
+
+		Expect(snapshot.Status.RestoreSize.String()).To(Equal("1Gi"))
+		Expect(snapshot.Status.BoundVolumeSnapshotContentName).NotTo(BeNil())
+
+		// the host VolumeSnapshotContent is reflected in the virtual cluster
+		var virtContent snapshotv1.VolumeSnapshotContent
+		err = virtTestEnv.k8sClient.Get(ctx, client.ObjectKey{Name: *snapshot.Status.BoundVolumeSnapshotContentName}, &virtContent)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(virtContent.Spec.Driver).To(Equal("test.csi.k8s.io"))
+		Expect(virtContent.Spec.DeletionPolicy).To(Equal(snapshotv1.VolumeSnapshotContentRetain))
+		Expect(virtContent.Spec.Source.SnapshotHandle).To(Equal(ptr.To("snapshot-1")))
+		Expect(virtContent.Spec.VolumeSnapshotRef.UID).To(Equal(snapshot.UID))
+	})
+}
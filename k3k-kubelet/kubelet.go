@@ -6,9 +6,12 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -16,7 +19,10 @@ import (
 	"github.com/virtual-kubelet/virtual-kubelet/log/klogv2"
 	"github.com/virtual-kubelet/virtual-kubelet/node"
 	"github.com/virtual-kubelet/virtual-kubelet/node/nodeutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/client-go/kubernetes"
@@ -25,25 +31,33 @@ import (
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 	certutil "github.com/rancher/dynamiclistener/cert"
 	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	ctrl "sigs.k8s.io/controller-runtime"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gwapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
+	"github.com/rancher/k3k/k3k-kubelet/controller/garbagecollector"
 	"github.com/rancher/k3k/k3k-kubelet/controller/syncer"
 	k3kwebhook "github.com/rancher/k3k/k3k-kubelet/controller/webhook"
 	"github.com/rancher/k3k/k3k-kubelet/provider"
+	"github.com/rancher/k3k/k3k-kubelet/translate"
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
 	"github.com/rancher/k3k/pkg/controller"
 	"github.com/rancher/k3k/pkg/controller/certs"
 	"github.com/rancher/k3k/pkg/controller/cluster/server"
 	"github.com/rancher/k3k/pkg/controller/cluster/server/bootstrap"
+	"github.com/rancher/k3k/pkg/controller/policy"
 )
 
 var baseScheme = runtime.NewScheme()
@@ -51,6 +65,9 @@ var baseScheme = runtime.NewScheme()
 func init() {
 	_ = clientgoscheme.AddToScheme(baseScheme)
 	_ = v1beta1.AddToScheme(baseScheme)
+	_ = gwapiv1.Install(baseScheme)
+	_ = gwapiv1alpha2.Install(baseScheme)
+	_ = snapshotv1.AddToScheme(baseScheme)
 }
 
 type kubelet struct {
@@ -84,6 +101,11 @@ func newKubelet(ctx context.Context, c *config, logger logr.Logger) (*kubelet, e
 		return nil, err
 	}
 
+	var virtualCluster v1beta1.Cluster
+	if err := hostClient.Get(ctx, types.NamespacedName{Name: c.ClusterName, Namespace: c.ClusterNamespace}, &virtualCluster); err != nil {
+		return nil, errors.New("failed to get virtualCluster spec: " + err.Error())
+	}
+
 	virtConfig, err := virtRestConfig(ctx, c.VirtKubeconfig, hostClient, c.ClusterName, c.ClusterNamespace, c.Token, logger)
 	if err != nil {
 		return nil, err
@@ -104,6 +126,11 @@ func newKubelet(ctx context.Context, c *config, logger logr.Logger) (*kubelet, e
 		virtualMetricsBindAddress = "0"
 	}
 
+	cacheOptions, err := hostCacheOptions(&virtualCluster, c.HostNamespaces, hostClient.RESTMapper())
+	if err != nil {
+		return nil, errors.New("unable to configure the cache of the host cluster: " + err.Error())
+	}
+
 	hostMgr, err := ctrl.NewManager(hostConfig, manager.Options{
 		Scheme:                  baseScheme,
 		LeaderElection:          true,
@@ -112,22 +139,30 @@ func newKubelet(ctx context.Context, c *config, logger logr.Logger) (*kubelet, e
 		Metrics: ctrlserver.Options{
 			BindAddress: hostMetricsBindAddress,
 		},
-		Cache: cache.Options{
-			DefaultNamespaces: map[string]cache.Config{
-				c.ClusterNamespace: {},
-			},
-		},
+		Cache: cacheOptions,
 	})
 	if err != nil {
 		return nil, errors.New("unable to create controller-runtime mgr for host cluster: " + err.Error())
 	}
 
-	// virtual client will only use core types (for now), no need to add anything other than the basics
+	// virtual client will only use core types, the Gateway API routes and the VolumeSnapshots
 	virtualScheme := runtime.NewScheme()
 	if err := clientgoscheme.AddToScheme(virtualScheme); err != nil {
 		return nil, errors.New("unable to add client go types to virtual cluster scheme: " + err.Error())
 	}
 
+	if err := gwapiv1.Install(virtualScheme); err != nil {
+		return nil, errors.New("unable to add gateway api types to virtual cluster scheme: " + err.Error())
+	}
+
+	if err := gwapiv1alpha2.Install(virtualScheme); err != nil {
+		return nil, errors.New("unable to add gateway api types to virtual cluster scheme: " + err.Error())
+	}
+
+	if err := snapshotv1.AddToScheme(virtualScheme); err != nil {
+		return nil, errors.New("unable to add volumesnapshot types to virtual cluster scheme: " + err.Error())
+	}
+
 	webhookServer := webhook.NewServer(webhook.Options{
 		CertDir: "/opt/rancher/k3k-webhook",
 		Port:    c.WebhookPort,
@@ -170,11 +205,6 @@ func newKubelet(ctx context.Context, c *config, logger logr.Logger) (*kubelet, e
 		return nil, errors.New("failed to get the DNS service for the cluster: " + err.Error())
 	}
 
-	var virtualCluster v1beta1.Cluster
-	if err := hostClient.Get(ctx, types.NamespacedName{Name: c.ClusterName, Namespace: c.ClusterNamespace}, &virtualCluster); err != nil {
-		return nil, errors.New("failed to get virtualCluster spec: " + err.Error())
-	}
-
 	return &kubelet{
 		virtualCluster: virtualCluster,
 
@@ -193,6 +223,72 @@ func newKubelet(ctx context.Context, c *config, logger logr.Logger) (*kubelet, e
 	}, nil
 }
 
+// hostCacheOptions returns the options of the cache of the host manager. The host objects are cached from the namespace
+// of the cluster and, with the PerNamespace host namespace mode, from its dedicated host namespaces. These are created
+// by the controller, that restarts the agents with the new ones, so that the agents don't need the access to the
+// other namespaces.
+func hostCacheOptions(cluster *v1beta1.Cluster, hostNamespaces []string, restMapper meta.RESTMapper) (cache.Options, error) {
+	options := cache.Options{
+		DefaultNamespaces: map[string]cache.Config{
+			cluster.Namespace: {},
+		},
+	}
+
+	translator := translate.NewHostTranslator(cluster)
+	if !translator.NamespacePerVirtualNamespace {
+		return options, nil
+	}
+
+	hostObjectsSelector := labels.SelectorFromSet(translator.HostLabels())
+
+	// the ResourceQuotas and the LimitRanges of the policy are mirrored from the host namespaces, not labelled as the synced objects
+	policyQuotaSelector := labels.SelectorFromSet(labels.Set{policy.ManagedByLabelKey: policy.VirtualPolicyControllerName})
+
+	quotaNamespaces := map[string]cache.Config{
+		cluster.Namespace: {LabelSelector: policyQuotaSelector},
+	}
+
+	syncedNamespaces := map[string]cache.Config{
+		cluster.Namespace: {},
+	}
+
+	for _, namespace := range hostNamespaces {
+		options.DefaultNamespaces[namespace] = cache.Config{}
+		quotaNamespaces[namespace] = cache.Config{LabelSelector: policyQuotaSelector}
+		syncedNamespaces[namespace] = cache.Config{LabelSelector: hostObjectsSelector}
+	}
+
+	options.ByObject = map[ctrlruntimeclient.Object]cache.ByObject{
+		&v1.ResourceQuota{}: {Namespaces: quotaNamespaces},
+		&v1.LimitRange{}:    {Namespaces: maps.Clone(quotaNamespaces)},
+	}
+
+	// the synced objects are cached from the host namespaces, the Gateway API routes and the VolumeSnapshots only if served
+	syncedObjects := []ctrlruntimeclient.Object{
+		&v1.Pod{}, &v1.Secret{}, &v1.ConfigMap{}, &v1.Service{}, &v1.PersistentVolumeClaim{}, &networkingv1.Ingress{},
+		&gwapiv1.HTTPRoute{}, &gwapiv1.GRPCRoute{}, &gwapiv1alpha2.TLSRoute{}, &snapshotv1.VolumeSnapshot{},
+	}
+
+	for _, obj := range syncedObjects {
+		gvk, err := apiutil.GVKForObject(obj, baseScheme)
+		if err != nil {
+			return options, err
+		}
+
+		if _, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+
+			return options, err
+		}
+
+		options.ByObject[obj] = cache.ByObject{Namespaces: maps.Clone(syncedNamespaces)}
+	}
+
+	return options, nil
+}
+
 func clusterIP(ctx context.Context, serviceName, clusterNamespace string, hostClient ctrlruntimeclient.Client) (string, error) {
 	var service v1.Service
 
@@ -265,12 +361,19 @@ func (k *kubelet) start(ctx context.Context) {
 
 func (k *kubelet) newProviderFunc(cfg config) nodeutil.NewProviderFunc {
 	return func(pc nodeutil.ProviderConfig) (nodeutil.Provider, node.NodeProvider, error) {
-		utilProvider, err := provider.New(*k.hostConfig, k.hostMgr, k.virtualMgr, k.logger, cfg.ClusterNamespace, cfg.ClusterName, cfg.ServerIP, k.dnsIP)
+		utilProvider, err := provider.New(*k.hostConfig, k.hostMgr, k.virtualMgr, k.logger, &k.virtualCluster, cfg.ServerIP, k.dnsIP)
 		if err != nil {
 			return nil, nil, errors.New("unable to make nodeutil provider: " + err.Error())
 		}
 
-		provider.ConfigureNode(k.logger, pc.Node, cfg.AgentHostname, k.port, k.agentIP, utilProvider.CoreClient, utilProvider.VirtualClient, k.virtualCluster, cfg.Version, cfg.MirrorHostNodes)
+		// the service account tokens of the pods are refreshed before they expire
+		if err := k.hostMgr.Add(manager.RunnableFunc(utilProvider.RefreshTokens)); err != nil {
+			return nil, nil, errors.New("unable to add token refresher: " + err.Error())
+		}
+
+		if err := provider.ConfigureNode(pc.Node, cfg.AgentHostname, k.port, k.agentIP, utilProvider.CoreClient, k.hostMgr, utilProvider.VirtualClient, k.virtualCluster, cfg.Version, cfg.MirrorHostNodes); err != nil {
+			return nil, nil, errors.New("unable to configure node: " + err.Error())
+		}
 
 		return utilProvider, &provider.Node{}, nil
 	}
@@ -432,6 +535,13 @@ func addControllers(ctx context.Context, hostMgr, virtualMgr manager.Manager, c
 		return err
 	}
 
+	// the host pods are translated back to the virtual pods with the reverse lookup of their names,
+	// if their annotations were erased
+	translator := translate.NewHostTranslator(&cluster)
+	if err := translator.IndexHostName(ctx, virtualMgr.GetFieldIndexer(), &v1.Pod{}); err != nil {
+		return errors.New("failed to add pod host name index: " + err.Error())
+	}
+
 	if err := syncer.AddConfigMapSyncer(ctx, virtualMgr, hostMgr, c.ClusterName, c.ClusterNamespace); err != nil {
 		return errors.New("failed to add configmap global syncer: " + err.Error())
 	}
@@ -458,17 +568,107 @@ func addControllers(ctx context.Context, hostMgr, virtualMgr manager.Manager, c
 		return errors.New("failed to add pvc syncer controller: " + err.Error())
 	}
 
+	logger.Info("adding storageclass syncer controller")
+
+	if err := syncer.AddStorageClassSyncer(ctx, virtualMgr, hostMgr, c.ClusterName, c.ClusterNamespace); err != nil {
+		return errors.New("failed to add storageclass syncer controller: " + err.Error())
+	}
+
 	logger.Info("adding pod pvc controller")
 
 	if err := syncer.AddPodPVCController(ctx, virtualMgr, hostMgr, c.ClusterName, c.ClusterNamespace); err != nil {
 		return errors.New("failed to add pod pvc controller: " + err.Error())
 	}
 
+	logger.Info("adding persistentvolume controller")
+
+	if err := syncer.AddPVController(ctx, virtualMgr, hostMgr, c.ClusterName, c.ClusterNamespace); err != nil {
+		return errors.New("failed to add persistentvolume controller: " + err.Error())
+	}
+
 	logger.Info("adding priorityclass controller")
 
 	if err := syncer.AddPriorityClassSyncer(ctx, virtualMgr, hostMgr, c.ClusterName, c.ClusterNamespace); err != nil {
 		return errors.New("failed to add priorityclass controller: " + err.Error())
 	}
 
+	logger.Info("adding resourcequota controller")
+
+	if err := syncer.AddResourceQuotaSyncer(ctx, virtualMgr, hostMgr, c.ClusterName, c.ClusterNamespace); err != nil {
+		return errors.New("failed to add resourcequota controller: " + err.Error())
+	}
+
+	// the kinds of the host objects collected when orphaned, including the Gateway API routes if served
+	gcKinds := slices.Clone(garbagecollector.DefaultKinds)
+
+	gatewayRouteSyncers := []struct {
+		gvk schema.GroupVersionKind
+		add func(ctx context.Context, virtMgr, hostMgr manager.Manager, clusterName, clusterNamespace string) error
+	}{
+		{gvk: gwapiv1.SchemeGroupVersion.WithKind("HTTPRoute"), add: syncer.AddHTTPRouteSyncer},
+		{gvk: gwapiv1.SchemeGroupVersion.WithKind("GRPCRoute"), add: syncer.AddGRPCRouteSyncer},
+		{gvk: gwapiv1alpha2.SchemeGroupVersion.WithKind("TLSRoute"), add: syncer.AddTLSRouteSyncer},
+	}
+
+	for _, gatewayRouteSyncer := range gatewayRouteSyncers {
+		kind := gatewayRouteSyncer.gvk.Kind
+
+		// the Gateway API CRDs are optional, the routes are synced only if they are served by both clusters
+		served, err := isServed(gatewayRouteSyncer.gvk, hostMgr, virtualMgr)
+		if err != nil {
+			return err
+		}
+
+		if !served {
+			logger.Info("skipping " + strings.ToLower(kind) + " syncer controller, the resource is not served")
+			continue
+		}
+
+		logger.Info("adding " + strings.ToLower(kind) + " syncer controller")
+
+		if err := gatewayRouteSyncer.add(ctx, virtualMgr, hostMgr, c.ClusterName, c.ClusterNamespace); err != nil {
+			return errors.New("failed to add " + strings.ToLower(kind) + " syncer controller: " + err.Error())
+		}
+
+		gcKinds = append(gcKinds, gatewayRouteSyncer.gvk)
+	}
+
+	// the VolumeSnapshot CRDs are optional, the snapshots are synced only if they are served by both clusters
+	served, err := isServed(snapshotv1.SchemeGroupVersion.WithKind("VolumeSnapshot"), hostMgr, virtualMgr)
+	if err != nil {
+		return err
+	}
+
+	if served {
+		logger.Info("adding volumesnapshot syncer controller")
+
+		if err := syncer.AddVolumeSnapshotSyncer(ctx, virtualMgr, hostMgr, c.ClusterName, c.ClusterNamespace); err != nil {
+			return errors.New("failed to add volumesnapshot syncer controller: " + err.Error())
+		}
+	} else {
+		logger.Info("skipping volumesnapshot syncer controller, the resource is not served")
+	}
+
+	logger.Info("adding garbage collector")
+
+	if err := garbagecollector.AddGarbageCollector(virtualMgr, hostMgr, c.ClusterName, c.ClusterNamespace, gcKinds); err != nil {
+		return errors.New("failed to add garbage collector: " + err.Error())
+	}
+
 	return nil
 }
+
+// isServed returns true if the resource kind is served by the API servers of all the managers.
+func isServed(gvk schema.GroupVersionKind, managers ...manager.Manager) (bool, error) {
+	for _, mgr := range managers {
+		if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			if meta.IsNoMatchError(err) {
+				return false, nil
+			}
+
+			return false, err
+		}
+	}
+
+	return true, nil
+}
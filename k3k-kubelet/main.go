@@ -58,6 +58,7 @@ func main() {
 	rootCmd.PersistentFlags().StringVar(&cfg.Version, "version", "", "Version of kubernetes server")
 	rootCmd.PersistentFlags().StringVar(&configFile, "config", "/opt/rancher/k3k/config.yaml", "Path to k3k-kubelet config file")
 	rootCmd.PersistentFlags().BoolVar(&cfg.MirrorHostNodes, "mirror-host-nodes", false, "Mirror real node objects from host cluster")
+	rootCmd.PersistentFlags().StringSliceVar(&cfg.HostNamespaces, "host-namespaces", nil, "Dedicated host namespaces of the k3k cluster, created by the k3k controller")
 
 	if err := rootCmd.Execute(); err != nil {
 		logrus.Fatal(err)
@@ -0,0 +1,267 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	podresource "k8s.io/kubernetes/pkg/api/v1/resource"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller/policy"
+)
+
+const nodeCapacityControllerName = "node-capacity-controller"
+
+// quotaNodeResources maps the ResourceQuota resource names to the node resources they are limiting.
+// Only the requests are considered, since they are the ones used by the scheduler to fit a pod on a node.
+var quotaNodeResources = map[corev1.ResourceName]corev1.ResourceName{
+	corev1.ResourceCPU:                      corev1.ResourceCPU,
+	corev1.ResourceRequestsCPU:              corev1.ResourceCPU,
+	corev1.ResourceMemory:                   corev1.ResourceMemory,
+	corev1.ResourceRequestsMemory:           corev1.ResourceMemory,
+	corev1.ResourceEphemeralStorage:         corev1.ResourceEphemeralStorage,
+	corev1.ResourceRequestsEphemeralStorage: corev1.ResourceEphemeralStorage,
+	corev1.ResourcePods:                     corev1.ResourcePods,
+}
+
+// NodeCapacityReconciler keeps the capacity (and the allocatable field) of the virtual node in sync with
+// the sum of the resources of the host nodes. It is triggered by the changes of the host nodes, of the
+// ResourceQuotas created by the VirtualClusterPolicy, and of the Cluster itself.
+type NodeCapacityReconciler struct {
+	HostClient       client.Client
+	VirtualClient    client.Client
+	ClusterName      string
+	ClusterNamespace string
+	VirtualNodeName  string
+}
+
+// addNodeCapacityController adds the controller updating the capacity of the virtual node to the host manager.
+// Every k3k-kubelet is responsible of its own virtual node, so the controller doesn't need the leader election.
+func addNodeCapacityController(hostMgr manager.Manager, virtualClient client.Client, clusterName, clusterNamespace, virtualNodeName string) error {
+	reconciler := NodeCapacityReconciler{
+		HostClient:       hostMgr.GetClient(),
+		VirtualClient:    virtualClient,
+		ClusterName:      clusterName,
+		ClusterNamespace: clusterNamespace,
+		VirtualNodeName:  virtualNodeName,
+	}
+
+	// all the events are collapsed into a single request for the virtual node
+	enqueueVirtualNode := handler.EnqueueRequestsFromMapFunc(func(context.Context, client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: virtualNodeName}}}
+	})
+
+	isPolicyQuota := predicate.NewPredicateFuncs(func(object client.Object) bool {
+		return object.GetNamespace() == clusterNamespace &&
+			object.GetLabels()[policy.ManagedByLabelKey] == policy.VirtualPolicyControllerName
+	})
+
+	isCluster := predicate.NewPredicateFuncs(func(object client.Object) bool {
+		return object.GetNamespace() == clusterNamespace && object.GetName() == clusterName
+	})
+
+	return ctrl.NewControllerManagedBy(hostMgr).
+		Named(nodeCapacityControllerName).
+		Watches(&corev1.Node{}, enqueueVirtualNode, builder.WithPredicates(nodeCapacityChangedPredicate)).
+		Watches(&corev1.ResourceQuota{}, enqueueVirtualNode, builder.WithPredicates(isPolicyQuota)).
+		Watches(&v1beta1.Cluster{}, enqueueVirtualNode, builder.WithPredicates(isCluster)).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+			NeedLeaderElection:      ptr.To(false),
+		}).
+		Complete(&reconciler)
+}
+
+// nodeCapacityChangedPredicate filters out the Node updates that are not changing the computed capacity,
+// i.e. the heartbeats of the host kubelets.
+var nodeCapacityChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, okOld := e.ObjectOld.(*corev1.Node)
+		newNode, okNew := e.ObjectNew.(*corev1.Node)
+
+		if !okOld || !okNew {
+			return true
+		}
+
+		return oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable ||
+			isNodeReady(oldNode) != isNodeReady(newNode) ||
+			!reflect.DeepEqual(oldNode.Labels, newNode.Labels) ||
+			!equality.Semantic.DeepEqual(oldNode.Status.Capacity, newNode.Status.Capacity) ||
+			!equality.Semantic.DeepEqual(oldNode.Status.Allocatable, newNode.Status.Allocatable)
+	},
+}
+
+func (r *NodeCapacityReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithValues("cluster", r.ClusterName, "clusterNamespace", r.ClusterNamespace, "node", r.VirtualNodeName)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	var cluster v1beta1.Cluster
+	if err := r.HostClient.Get(ctx, types.NamespacedName{Name: r.ClusterName, Namespace: r.ClusterNamespace}, &cluster); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// if the nodeSelector is specified only the matching nodes will be considered
+	var nodeList corev1.NodeList
+	if err := r.HostClient.List(ctx, &nodeList, client.MatchingLabels(cluster.Spec.NodeSelector)); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	capacity, allocatable := nodeResources(nodeList.Items)
+
+	// only the allowed extended resources are advertised on the virtual node
+	capacity = advertisedResources(capacity, cluster.Spec.ExtendedResources)
+	allocatable = advertisedResources(allocatable, cluster.Spec.ExtendedResources)
+
+	// the allocatable resources are limited by the ResourceQuotas of the VirtualClusterPolicy
+	var quotaList corev1.ResourceQuotaList
+	if err := r.HostClient.List(ctx, &quotaList, client.InNamespace(r.ClusterNamespace), client.MatchingLabels{
+		policy.ManagedByLabelKey: policy.VirtualPolicyControllerName,
+	}); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if len(quotaList.Items) > 0 {
+		// the pods of the cluster are in its dedicated host namespaces with the PerNamespace host namespace mode
+		var podList corev1.PodList
+		if err := r.HostClient.List(ctx, &podList, translate.NewHostTranslator(&cluster).ListOptions(nil)...); err != nil {
+			return reconcile.Result{}, err
+		}
+
+		allocatable = limitByQuotas(allocatable, quotaList.Items, podsRequests(podList.Items))
+	}
+
+	// the virtual node could be not registered yet
+	var virtualNode corev1.Node
+	if err := r.VirtualClient.Get(ctx, types.NamespacedName{Name: r.VirtualNodeName}, &virtualNode); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{RequeueAfter: time.Second * 10}, nil
+		}
+
+		return reconcile.Result{}, err
+	}
+
+	if equality.Semantic.DeepEqual(virtualNode.Status.Capacity, capacity) &&
+		equality.Semantic.DeepEqual(virtualNode.Status.Allocatable, allocatable) {
+		return reconcile.Result{}, nil
+	}
+
+	log.V(1).Info("Updating virtual node capacity")
+
+	virtualNode.Status.Capacity = capacity
+	virtualNode.Status.Allocatable = allocatable
+
+	return reconcile.Result{}, r.VirtualClient.Status().Update(ctx, &virtualNode)
+}
+
+// nodeResources will return a sum of all the resource capacity of the host nodes, and the allocatable resources.
+// The nodes that are not Ready or that are cordoned are not considered.
+func nodeResources(nodes []corev1.Node) (corev1.ResourceList, corev1.ResourceList) {
+	capacity := corev1.ResourceList{}
+	allocatable := corev1.ResourceList{}
+
+	for _, node := range nodes {
+		if node.Spec.Unschedulable || !isNodeReady(&node) {
+			continue
+		}
+
+		addResources(capacity, node.Status.Capacity)
+		addResources(allocatable, node.Status.Allocatable)
+	}
+
+	return capacity, allocatable
+}
+
+// limitByQuotas will cap the allocatable resources with what is still available in the ResourceQuotas.
+// The resources already requested by the pods of the virtual cluster are added back to the available ones,
+// because they are already accounted on the virtual node by the scheduler.
+func limitByQuotas(allocatable corev1.ResourceList, quotas []corev1.ResourceQuota, clusterUsage corev1.ResourceList) corev1.ResourceList {
+	limited := allocatable.DeepCopy()
+
+	for _, quota := range quotas {
+		for quotaResource, hard := range quota.Spec.Hard {
+			nodeResource, found := quotaNodeResources[quotaResource]
+			if !found {
+				continue
+			}
+
+			available := hard.DeepCopy()
+
+			if used, found := quota.Status.Used[quotaResource]; found {
+				available.Sub(used)
+			}
+
+			if usage, found := clusterUsage[nodeResource]; found {
+				available.Add(usage)
+			}
+
+			if available.Sign() < 0 {
+				available.Set(0)
+			}
+
+			current, found := limited[nodeResource]
+			if !found || available.Cmp(current) < 0 {
+				limited[nodeResource] = available
+			}
+		}
+	}
+
+	return limited
+}
+
+// podsRequests returns the sum of the resources requested by the active pods, including the pods count.
+func podsRequests(pods []corev1.Pod) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+
+	var activePods int64
+
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		activePods++
+
+		addResources(requests, podresource.PodRequests(&pod, podresource.PodResourcesOptions{}))
+	}
+
+	requests[corev1.ResourcePods] = *resource.NewQuantity(activePods, resource.DecimalSI)
+
+	return requests
+}
+
+func addResources(total, resources corev1.ResourceList) {
+	for resourceName, resourceQuantity := range resources {
+		virtualResource := total[resourceName]
+
+		(&virtualResource).Add(resourceQuantity)
+		total[resourceName] = virtualResource
+	}
+}
+
+func isNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
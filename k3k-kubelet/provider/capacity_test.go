@@ -0,0 +1,216 @@
+package provider
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_nodeResources(t *testing.T) {
+	tests := []struct {
+		name                string
+		nodes               []corev1.Node
+		expectedCapacity    corev1.ResourceList
+		expectedAllocatable corev1.ResourceList
+	}{
+		{
+			name:                "no nodes",
+			nodes:               []corev1.Node{},
+			expectedCapacity:    corev1.ResourceList{},
+			expectedAllocatable: corev1.ResourceList{},
+		},
+		{
+			name: "ready nodes are summed",
+			nodes: []corev1.Node{
+				testNode("2", "4Gi", true, false),
+				testNode("4", "8Gi", true, false),
+			},
+			expectedCapacity: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("6"),
+				corev1.ResourceMemory: resource.MustParse("12Gi"),
+			},
+			expectedAllocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("6"),
+				corev1.ResourceMemory: resource.MustParse("12Gi"),
+			},
+		},
+		{
+			name: "not ready nodes are skipped",
+			nodes: []corev1.Node{
+				testNode("2", "4Gi", true, false),
+				testNode("4", "8Gi", false, false),
+			},
+			expectedCapacity: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+			},
+			expectedAllocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+			},
+		},
+		{
+			name: "cordoned nodes are skipped",
+			nodes: []corev1.Node{
+				testNode("2", "4Gi", true, true),
+				testNode("4", "8Gi", true, false),
+			},
+			expectedCapacity: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("4"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+			},
+			expectedAllocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("4"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capacity, allocatable := nodeResources(tt.nodes)
+
+			if !equality.Semantic.DeepEqual(capacity, tt.expectedCapacity) {
+				t.Errorf("nodeResources() capacity = %v, want %v", capacity, tt.expectedCapacity)
+			}
+
+			if !equality.Semantic.DeepEqual(allocatable, tt.expectedAllocatable) {
+				t.Errorf("nodeResources() allocatable = %v, want %v", allocatable, tt.expectedAllocatable)
+			}
+		})
+	}
+}
+
+func Test_limitByQuotas(t *testing.T) {
+	allocatable := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("8"),
+		corev1.ResourceMemory: resource.MustParse("16Gi"),
+		corev1.ResourcePods:   resource.MustParse("110"),
+	}
+
+	tests := []struct {
+		name         string
+		quotas       []corev1.ResourceQuota
+		clusterUsage corev1.ResourceList
+		want         corev1.ResourceList
+	}{
+		{
+			name:   "no quotas",
+			quotas: []corev1.ResourceQuota{},
+			want:   allocatable,
+		},
+		{
+			name: "quota lower than allocatable",
+			quotas: []corev1.ResourceQuota{
+				testQuota(
+					corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("2")},
+					corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("500m")},
+				),
+			},
+			clusterUsage: corev1.ResourceList{},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1500m"),
+				corev1.ResourceMemory: resource.MustParse("16Gi"),
+				corev1.ResourcePods:   resource.MustParse("110"),
+			},
+		},
+		{
+			name: "usage of the virtual cluster is added back",
+			quotas: []corev1.ResourceQuota{
+				testQuota(
+					corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi"), corev1.ResourcePods: resource.MustParse("10")},
+					corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("3Gi"), corev1.ResourcePods: resource.MustParse("4")},
+				),
+			},
+			clusterUsage: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("2Gi"),
+				corev1.ResourcePods:   resource.MustParse("3"),
+			},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("8"),
+				corev1.ResourceMemory: resource.MustParse("3Gi"),
+				corev1.ResourcePods:   resource.MustParse("9"),
+			},
+		},
+		{
+			name: "quota higher than allocatable",
+			quotas: []corev1.ResourceQuota{
+				testQuota(
+					corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100")},
+					corev1.ResourceList{},
+				),
+			},
+			clusterUsage: corev1.ResourceList{},
+			want:         allocatable,
+		},
+		{
+			name: "exhausted quota",
+			quotas: []corev1.ResourceQuota{
+				testQuota(
+					corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+					corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+				),
+			},
+			clusterUsage: corev1.ResourceList{},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("0"),
+				corev1.ResourceMemory: resource.MustParse("16Gi"),
+				corev1.ResourcePods:   resource.MustParse("110"),
+			},
+		},
+		{
+			name: "limits are not considered",
+			quotas: []corev1.ResourceQuota{
+				testQuota(
+					corev1.ResourceList{corev1.ResourceLimitsCPU: resource.MustParse("1")},
+					corev1.ResourceList{},
+				),
+			},
+			clusterUsage: corev1.ResourceList{},
+			want:         allocatable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := limitByQuotas(allocatable, tt.quotas, tt.clusterUsage); !equality.Semantic.DeepEqual(got, tt.want) {
+				t.Errorf("limitByQuotas() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testNode(cpu, memory string, ready, unschedulable bool) corev1.Node {
+	readyStatus := corev1.ConditionFalse
+	if ready {
+		readyStatus = corev1.ConditionTrue
+	}
+
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse(memory),
+	}
+
+	return corev1.Node{
+		Spec: corev1.NodeSpec{
+			Unschedulable: unschedulable,
+		},
+		Status: corev1.NodeStatus{
+			Capacity:    resources,
+			Allocatable: resources,
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: readyStatus},
+			},
+		},
+	}
+}
+
+func testQuota(hard, used corev1.ResourceList) corev1.ResourceQuota {
+	return corev1.ResourceQuota{
+		Spec:   corev1.ResourceQuotaSpec{Hard: hard},
+		Status: corev1.ResourceQuotaStatus{Hard: hard, Used: used},
+	}
+}
@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/distribution/reference"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+// ImagePolicyRejectedReason is the reason of the failed status and of the events of the pods rejected by the image policy.
+const ImagePolicyRejectedReason = "ImagePolicyRejected"
+
+// applyImagePolicy rewrites the images of the registries mirrored by the image policy in all the containers
+// of the host pod, and returns the violations of the policy by the rewritten images.
+func applyImagePolicy(pod *corev1.Pod, policy *v1beta1.ImagePolicy) []string {
+	var violations []string
+
+	for i := range pod.Spec.InitContainers {
+		violations = append(violations, applyImagePolicyToImage(&pod.Spec.InitContainers[i].Image, pod.Spec.InitContainers[i].Name, policy)...)
+	}
+
+	for i := range pod.Spec.Containers {
+		violations = append(violations, applyImagePolicyToImage(&pod.Spec.Containers[i].Image, pod.Spec.Containers[i].Name, policy)...)
+	}
+
+	for i := range pod.Spec.EphemeralContainers {
+		container := &pod.Spec.EphemeralContainers[i].EphemeralContainerCommon
+		violations = append(violations, applyImagePolicyToImage(&container.Image, container.Name, policy)...)
+	}
+
+	return violations
+}
+
+// applyImagePolicyToImage rewrites the image of a container if its registry is mirrored, and checks it.
+func applyImagePolicyToImage(image *string, containerName string, policy *v1beta1.ImagePolicy) []string {
+	named, err := reference.ParseNormalizedNamed(*image)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid image %q of container %q: %v", *image, containerName, err)}
+	}
+
+	if mirrored, found := mirrorImage(named, policy.RegistryMirrors); found {
+		named = mirrored
+		*image = named.String()
+	}
+
+	var violations []string
+
+	if !registryAllowed(named, policy.AllowedRegistries) {
+		violations = append(violations, fmt.Sprintf("image %q of container %q is not from an allowed registry", *image, containerName))
+	}
+
+	if _, digested := named.(reference.Digested); policy.RequireDigest && !digested {
+		violations = append(violations, fmt.Sprintf("image %q of container %q is not referenced by digest", *image, containerName))
+	}
+
+	return violations
+}
+
+// mirrorImage returns the image of the mirror of its registry, with the same repository path, tag and digest.
+// It returns false if the registry of the image is not mirrored.
+func mirrorImage(named reference.Named, mirrors []v1beta1.RegistryMirror) (reference.Named, bool) {
+	domain := reference.Domain(named)
+
+	index := slices.IndexFunc(mirrors, func(mirror v1beta1.RegistryMirror) bool {
+		return strings.TrimSuffix(mirror.Registry, "/") == domain
+	})
+	if index < 0 {
+		return nil, false
+	}
+
+	mirrored := strings.TrimSuffix(mirrors[index].Mirror, "/") + "/" + reference.Path(named)
+
+	if tagged, ok := named.(reference.Tagged); ok {
+		mirrored += ":" + tagged.Tag()
+	}
+
+	if digested, ok := named.(reference.Digested); ok {
+		mirrored += "@" + digested.Digest().String()
+	}
+
+	// an invalid mirror leaves the image unchanged, and it's checked against the allowed registries
+	mirroredNamed, err := reference.ParseNormalizedNamed(mirrored)
+	if err != nil {
+		return nil, false
+	}
+
+	return mirroredNamed, true
+}
+
+// registryAllowed returns true if the repository of the image is under one of the allowed registries,
+// or if all the registries are allowed.
+func registryAllowed(named reference.Named, allowedRegistries []string) bool {
+	if len(allowedRegistries) == 0 {
+		return true
+	}
+
+	name := named.Name()
+
+	return slices.ContainsFunc(allowedRegistries, func(registry string) bool {
+		registry = strings.TrimSuffix(registry, "/")
+		return name == registry || strings.HasPrefix(name, registry+"/")
+	})
+}
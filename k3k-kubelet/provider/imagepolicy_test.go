@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+func Test_applyImagePolicy(t *testing.T) {
+	const digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+	policy := &v1beta1.ImagePolicy{
+		AllowedRegistries: []string{"mirror.example.com", "registry.example.com/team-a"},
+		RegistryMirrors: []v1beta1.RegistryMirror{
+			{Registry: "docker.io", Mirror: "mirror.example.com/docker-hub"},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		image          string
+		policy         *v1beta1.ImagePolicy
+		wantImage      string
+		wantViolations int
+	}{
+		{
+			name:      "mirrored short name",
+			image:     "nginx:1.27",
+			policy:    policy,
+			wantImage: "mirror.example.com/docker-hub/library/nginx:1.27",
+		},
+		{
+			name:      "mirrored with digest",
+			image:     "docker.io/bitnami/redis@" + digest,
+			policy:    policy,
+			wantImage: "mirror.example.com/docker-hub/bitnami/redis@" + digest,
+		},
+		{
+			name:      "allowed repository prefix",
+			image:     "registry.example.com/team-a/app:v1",
+			policy:    policy,
+			wantImage: "registry.example.com/team-a/app:v1",
+		},
+		{
+			name:           "registry not allowed",
+			image:          "registry.example.com/team-b/app:v1",
+			policy:         policy,
+			wantImage:      "registry.example.com/team-b/app:v1",
+			wantViolations: 1,
+		},
+		{
+			name:           "digest required",
+			image:          "quay.io/app:v1",
+			policy:         &v1beta1.ImagePolicy{RequireDigest: true},
+			wantImage:      "quay.io/app:v1",
+			wantViolations: 1,
+		},
+		{
+			name:      "digest provided",
+			image:     "quay.io/app:v1@" + digest,
+			policy:    &v1beta1.ImagePolicy{RequireDigest: true},
+			wantImage: "quay.io/app:v1@" + digest,
+		},
+		{
+			name:           "invalid image",
+			image:          "Invalid:Image",
+			policy:         policy,
+			wantImage:      "Invalid:Image",
+			wantViolations: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: tt.image}},
+				},
+			}
+
+			violations := applyImagePolicy(pod, tt.policy)
+			if len(violations) != tt.wantViolations {
+				t.Errorf("applyImagePolicy() = %v, want %d violations", violations, tt.wantViolations)
+			}
+
+			if image := pod.Spec.Containers[0].Image; image != tt.wantImage {
+				t.Errorf("applyImagePolicy() image = %q, want %q", image, tt.wantImage)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+// HostPodOverlayConflictReason is the reason of the events recorded when a hostPodOverlay overrides a value of the translated pod.
+const HostPodOverlayConflictReason = "HostPodOverlayConflict"
+
+// hostPodOverlay is a strategic merge patch applied to the host pods, with a description of where it's defined.
+type hostPodOverlay struct {
+	source string
+	patch  []byte
+}
+
+// clusterPolicy returns the VirtualClusterPolicy bound to the namespace of the cluster, if any.
+func (p *Provider) clusterPolicy(ctx context.Context, cluster *v1beta1.Cluster) (*v1beta1.VirtualClusterPolicy, error) {
+	if cluster.Status.PolicyName == "" {
+		return nil, nil
+	}
+
+	var policy v1beta1.VirtualClusterPolicy
+	if err := p.HostClient.Get(ctx, types.NamespacedName{Name: cluster.Status.PolicyName}, &policy); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	return &policy, nil
+}
+
+// currentPolicy returns the VirtualClusterPolicy bound to the namespace of the cluster of the provider, if any.
+func (p *Provider) currentPolicy(ctx context.Context) (*v1beta1.VirtualClusterPolicy, error) {
+	var cluster v1beta1.Cluster
+
+	clusterKey := types.NamespacedName{Namespace: p.ClusterNamespace, Name: p.ClusterName}
+	if err := p.HostClient.Get(ctx, clusterKey, &cluster); err != nil {
+		return nil, fmt.Errorf("unable to get cluster %s in namespace %s: %w", p.ClusterName, p.ClusterNamespace, err)
+	}
+
+	return p.clusterPolicy(ctx, &cluster)
+}
+
+// applyHostPodOverlays applies the hostPodOverlays of the policy and of the cluster, in this order, to the translated
+// host pod. An overlay overriding a value of the pod is recorded as a conflict event on the virtual pod. The name,
+// the namespace and the metadata tracking the virtual pod can't be changed.
+func (p *Provider) applyHostPodOverlays(cluster *v1beta1.Cluster, policy *v1beta1.VirtualClusterPolicy, virtualPod, hostPod *corev1.Pod) error {
+	for _, overlay := range hostPodOverlays(cluster, policy) {
+		conflicts, err := applyHostPodOverlay(hostPod, overlay.patch)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", overlay.source, err)
+		}
+
+		if len(conflicts) > 0 {
+			p.eventRecorder.Eventf(virtualPod, corev1.EventTypeWarning, HostPodOverlayConflictReason,
+				"The %s overrides %s", overlay.source, strings.Join(conflicts, ", "))
+		}
+	}
+
+	return nil
+}
+
+// hostPodOverlays returns the hostPodOverlays of the policy and of the cluster, in the order they are applied.
+func hostPodOverlays(cluster *v1beta1.Cluster, policy *v1beta1.VirtualClusterPolicy) []hostPodOverlay {
+	var overlays []hostPodOverlay
+
+	if policy != nil && policy.Spec.HostPodOverlay != nil && len(policy.Spec.HostPodOverlay.Raw) > 0 {
+		overlays = append(overlays, hostPodOverlay{source: "hostPodOverlay of the VirtualClusterPolicy " + policy.Name, patch: policy.Spec.HostPodOverlay.Raw})
+	}
+
+	if cluster.Spec.HostPodOverlay != nil && len(cluster.Spec.HostPodOverlay.Raw) > 0 {
+		overlays = append(overlays, hostPodOverlay{source: "hostPodOverlay of the cluster", patch: cluster.Spec.HostPodOverlay.Raw})
+	}
+
+	return overlays
+}
+
+// applyClusterScheduling sets the priorityClass of the cluster on the host pod without one, and merges the node
+// selector of the cluster in the one of the host pod. The node selector of the cluster is a hard constraint,
+// overriding the values of the pod and of the overlays.
+func applyClusterScheduling(cluster *v1beta1.Cluster, hostPod *corev1.Pod) {
+	if hostPod.Spec.PriorityClassName == "" && cluster.Spec.PriorityClass != "" {
+		hostPod.Spec.PriorityClassName = cluster.Spec.PriorityClass
+		hostPod.Spec.Priority = nil
+	}
+
+	if len(cluster.Spec.NodeSelector) == 0 {
+		return
+	}
+
+	if hostPod.Spec.NodeSelector == nil {
+		hostPod.Spec.NodeSelector = make(map[string]string, len(cluster.Spec.NodeSelector))
+	}
+
+	maps.Copy(hostPod.Spec.NodeSelector, cluster.Spec.NodeSelector)
+}
+
+// applyHostPodOverlay applies a strategic merge patch to the host pod, and returns the paths of the values it overrides.
+func applyHostPodOverlay(hostPod *corev1.Pod, patch []byte) ([]string, error) {
+	original, err := json.Marshal(hostPod)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := strategicpatch.StrategicMergePatch(original, patch, corev1.Pod{})
+	if err != nil {
+		return nil, err
+	}
+
+	var patchedPod corev1.Pod
+	if err := json.Unmarshal(patched, &patchedPod); err != nil {
+		return nil, err
+	}
+
+	// the host pod can't be detached from its virtual pod
+	patchedPod.Name = hostPod.Name
+	patchedPod.Namespace = hostPod.Namespace
+	restoreValues(&patchedPod.Labels, hostPod.Labels, translate.ClusterNameLabel, translate.ClusterNamespaceLabel, translate.ResourceNamespaceLabel)
+	restoreValues(&patchedPod.Annotations, hostPod.Annotations, translate.ResourceNameAnnotation, translate.ResourceNamespaceAnnotation)
+
+	if patched, err = json.Marshal(&patchedPod); err != nil {
+		return nil, err
+	}
+
+	var originalFields, patchedFields map[string]any
+
+	if err := json.Unmarshal(original, &originalFields); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(patched, &patchedFields); err != nil {
+		return nil, err
+	}
+
+	conflicts := overriddenFields("", originalFields, patchedFields)
+	slices.Sort(conflicts)
+
+	*hostPod = patchedPod
+
+	return conflicts, nil
+}
+
+// restoreValues restores the values of the given keys of a map, removing the ones not set in the original map.
+func restoreValues(values *map[string]string, original map[string]string, keys ...string) {
+	for _, key := range keys {
+		value, found := original[key]
+		if !found {
+			delete(*values, key)
+			continue
+		}
+
+		if *values == nil {
+			*values = map[string]string{}
+		}
+
+		(*values)[key] = value
+	}
+}
+
+// overriddenFields returns the paths of the scalar values of the original object changed or removed in the patched one.
+// The lists are not compared, since they are merged by key or replaced as a whole by the overlays to add new elements.
+func overriddenFields(path string, original, patched map[string]any) []string {
+	var fields []string
+
+	for key, value := range original {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+
+		patchedValue, found := patched[key]
+
+		switch value := value.(type) {
+		case map[string]any:
+			patchedMap, _ := patchedValue.(map[string]any)
+			fields = append(fields, overriddenFields(fieldPath, value, patchedMap)...)
+		case []any:
+			// the lists are not compared
+		default:
+			if !found || !reflect.DeepEqual(value, patchedValue) {
+				fields = append(fields, fieldPath)
+			}
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"slices"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+func Test_applyHostPodOverlay(t *testing.T) {
+	hostPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx-default-mycluster-abcde",
+			Namespace: "k3k-mycluster",
+			Labels:    map[string]string{"app": "nginx", translate.ClusterNameLabel: "mycluster"},
+		},
+		Spec: corev1.PodSpec{
+			Containers:        []corev1.Container{{Name: "nginx", Image: "nginx"}},
+			PriorityClassName: "low",
+		},
+	}
+
+	overlay := `{
+		"metadata": {"name": "renamed", "labels": {"cost-center": "team-a", "k3k.io/clusterName": "other"}},
+		"spec": {
+			"runtimeClassName": "gvisor",
+			"priorityClassName": "high",
+			"tolerations": [{"key": "tenants", "operator": "Exists", "effect": "NoSchedule"}],
+			"containers": [{"name": "log-shipper", "image": "fluent-bit"}]
+		}
+	}`
+
+	conflicts, err := applyHostPodOverlay(hostPod, []byte(overlay))
+	if err != nil {
+		t.Fatalf("applyHostPodOverlay() error = %v", err)
+	}
+
+	// the overridden priorityClassName is a conflict, the tracking metadata is restored
+	if !slices.Equal(conflicts, []string{"spec.priorityClassName"}) {
+		t.Errorf("applyHostPodOverlay() conflicts = %v", conflicts)
+	}
+
+	if hostPod.Name != "nginx-default-mycluster-abcde" || hostPod.Labels[translate.ClusterNameLabel] != "mycluster" {
+		t.Errorf("the tracking metadata of the host pod was changed: %s %v", hostPod.Name, hostPod.Labels)
+	}
+
+	if hostPod.Labels["cost-center"] != "team-a" || hostPod.Labels["app"] != "nginx" {
+		t.Errorf("unexpected labels %v", hostPod.Labels)
+	}
+
+	if hostPod.Spec.RuntimeClassName == nil || *hostPod.Spec.RuntimeClassName != "gvisor" || hostPod.Spec.PriorityClassName != "high" || len(hostPod.Spec.Tolerations) != 1 {
+		t.Errorf("unexpected spec %v", hostPod.Spec)
+	}
+
+	// the containers are merged by name
+	if len(hostPod.Spec.Containers) != 2 || hostPod.Spec.Containers[0].Name != "log-shipper" || hostPod.Spec.Containers[1].Name != "nginx" {
+		t.Errorf("unexpected containers %v", hostPod.Spec.Containers)
+	}
+}
+
+func Test_applyClusterScheduling(t *testing.T) {
+	cluster := &v1beta1.Cluster{
+		Spec: v1beta1.ClusterSpec{
+			NodeSelector:  map[string]string{"pool": "tenants"},
+			PriorityClass: "tenants",
+		},
+	}
+
+	hostPod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers:   []corev1.Container{{Name: "nginx", Image: "nginx"}},
+			NodeSelector: map[string]string{"disktype": "ssd"},
+		},
+	}
+
+	// the overlay of the policy can't override the node selector of the cluster
+	if _, err := applyHostPodOverlay(hostPod, []byte(`{"spec":{"nodeSelector":{"pool":"other"},"runtimeClassName":"gvisor"}}`)); err != nil {
+		t.Fatalf("applyHostPodOverlay() error = %v", err)
+	}
+
+	applyClusterScheduling(cluster, hostPod)
+
+	if hostPod.Spec.NodeSelector["pool"] != "tenants" || hostPod.Spec.NodeSelector["disktype"] != "ssd" || hostPod.Spec.PriorityClassName != "tenants" {
+		t.Errorf("unexpected spec %v", hostPod.Spec)
+	}
+
+	if hostPod.Spec.RuntimeClassName == nil || *hostPod.Spec.RuntimeClassName != "gvisor" || len(hostPod.Spec.Containers) != 1 {
+		t.Errorf("unexpected spec %v", hostPod.Spec)
+	}
+}
+
+func Test_applyHostPodOverlays(t *testing.T) {
+	cluster := &v1beta1.Cluster{
+		Spec: v1beta1.ClusterSpec{
+			NodeSelector:   map[string]string{"pool": "tenants"},
+			HostPodOverlay: &runtime.RawExtension{Raw: []byte(`{"spec":{"nodeSelector":{"pool":"other"},"runtimeClassName":"kata","hostNetwork":true}}`)},
+		},
+	}
+
+	policy := &v1beta1.VirtualClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy"},
+		Spec: v1beta1.VirtualClusterPolicySpec{
+			HostPodOverlay:  &runtime.RawExtension{Raw: []byte(`{"spec":{"runtimeClassName":"gvisor"}}`)},
+			HostPodSecurity: &v1beta1.HostPodSecurityPolicy{},
+		},
+	}
+
+	hostPod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nginx", Image: "nginx"}},
+		},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	p := &Provider{eventRecorder: recorder}
+
+	if err := p.applyHostPodOverlays(cluster, policy, &corev1.Pod{}, hostPod); err != nil {
+		t.Fatalf("applyHostPodOverlays() error = %v", err)
+	}
+
+	applyClusterScheduling(cluster, hostPod)
+
+	// the overlay of the cluster is applied after the one of the policy, and can't override the node selector of the cluster
+	if hostPod.Spec.RuntimeClassName == nil || *hostPod.Spec.RuntimeClassName != "kata" || hostPod.Spec.NodeSelector["pool"] != "tenants" {
+		t.Errorf("unexpected spec %v", hostPod.Spec)
+	}
+
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected 1 conflict event, got %d", len(recorder.Events))
+	}
+
+	// the host pod is checked against the policy after the overlays
+	if reason, _ := checkHostPod(policy, hostPod); reason != HostPodSecurityRejectedReason {
+		t.Errorf("checkHostPod() reason = %q, want %q", reason, HostPodSecurityRejectedReason)
+	}
+}
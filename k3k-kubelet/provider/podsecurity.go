@@ -0,0 +1,185 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+// HostPodSecurityRejectedReason is the reason of the failed status and of the events of the pods rejected by the host pod security policy.
+const HostPodSecurityRejectedReason = "HostPodSecurityRejected"
+
+// allCapabilities allows or denies all the capabilities.
+const allCapabilities = "ALL"
+
+// hostPodSecurityViolations returns the violations of the host pod security policy by a host pod.
+func hostPodSecurityViolations(pod *corev1.Pod, policy *v1beta1.HostPodSecurityPolicy) []string {
+	var violations []string
+
+	if !policy.AllowHostNamespaces {
+		if pod.Spec.HostNetwork {
+			violations = append(violations, "hostNetwork is not allowed")
+		}
+
+		if pod.Spec.HostPID {
+			violations = append(violations, "hostPID is not allowed")
+		}
+
+		if pod.Spec.HostIPC {
+			violations = append(violations, "hostIPC is not allowed")
+		}
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.HostPath == nil {
+			continue
+		}
+
+		if !hostPathAllowed(volume.HostPath.Path, policy) {
+			violations = append(violations, fmt.Sprintf("hostPath %q of volume %q is not allowed", volume.HostPath.Path, volume.Name))
+		}
+	}
+
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			violations = append(violations, containerViolations(&container, pod.Spec.HostNetwork, policy)...)
+		}
+	}
+
+	// the ephemeral containers added with kubectl debug are checked as the other containers
+	for _, ephemeralContainer := range pod.Spec.EphemeralContainers {
+		container := corev1.Container(ephemeralContainer.EphemeralContainerCommon)
+		violations = append(violations, containerViolations(&container, pod.Spec.HostNetwork, policy)...)
+	}
+
+	return violations
+}
+
+// containerViolations returns the violations of the host pod security policy by a container.
+func containerViolations(container *corev1.Container, hostNetwork bool, policy *v1beta1.HostPodSecurityPolicy) []string {
+	var violations []string
+
+	if securityContext := container.SecurityContext; securityContext != nil {
+		if !policy.AllowPrivileged && securityContext.Privileged != nil && *securityContext.Privileged {
+			violations = append(violations, fmt.Sprintf("privileged container %q is not allowed", container.Name))
+		}
+
+		if securityContext.Capabilities != nil {
+			for _, capability := range securityContext.Capabilities.Add {
+				if !capabilityAllowed(capability, policy) {
+					violations = append(violations, fmt.Sprintf("capability %s of container %q is not allowed", capability, container.Name))
+				}
+			}
+		}
+	}
+
+	for _, port := range container.Ports {
+		hostPort := port.HostPort
+		// the container ports are host ports in the network namespace of the host
+		if hostNetwork && hostPort == 0 {
+			hostPort = port.ContainerPort
+		}
+
+		if hostPort != 0 && !hostPortAllowed(hostPort, policy) {
+			violations = append(violations, fmt.Sprintf("hostPort %d of container %q is not allowed", hostPort, container.Name))
+		}
+	}
+
+	return violations
+}
+
+// hostPathAllowed returns true if a hostPath is not under a denied prefix, and under an allowed one if any.
+func hostPathAllowed(hostPath string, policy *v1beta1.HostPodSecurityPolicy) bool {
+	hostPath = path.Clean(hostPath)
+
+	underPrefix := func(prefix string) bool {
+		prefix = path.Clean(prefix)
+		return prefix == "/" || hostPath == prefix || strings.HasPrefix(hostPath, prefix+"/")
+	}
+
+	if slices.ContainsFunc(policy.DeniedHostPaths, underPrefix) {
+		return false
+	}
+
+	return len(policy.AllowedHostPaths) == 0 || slices.ContainsFunc(policy.AllowedHostPaths, underPrefix)
+}
+
+// capabilityAllowed returns true if a capability is not denied, and allowed if there is an allow-list.
+// The capabilities are compared without their "CAP_" prefix.
+func capabilityAllowed(capability corev1.Capability, policy *v1beta1.HostPodSecurityPolicy) bool {
+	name := normalizeCapability(capability)
+
+	matches := func(other corev1.Capability) bool {
+		otherName := normalizeCapability(other)
+		return otherName == allCapabilities || otherName == name
+	}
+
+	if slices.ContainsFunc(policy.DeniedCapabilities, matches) {
+		return false
+	}
+
+	return len(policy.AllowedCapabilities) == 0 || slices.ContainsFunc(policy.AllowedCapabilities, matches)
+}
+
+func normalizeCapability(capability corev1.Capability) string {
+	return strings.TrimPrefix(strings.ToUpper(string(capability)), "CAP_")
+}
+
+// hostPortAllowed returns true if a host port is not in a denied range, and in an allowed one if any.
+func hostPortAllowed(port int32, policy *v1beta1.HostPodSecurityPolicy) bool {
+	inRange := func(portRange v1beta1.HostPortRange) bool {
+		return port >= portRange.Min && port <= portRange.Max
+	}
+
+	if slices.ContainsFunc(policy.DeniedHostPorts, inRange) {
+		return false
+	}
+
+	return len(policy.AllowedHostPorts) == 0 || slices.ContainsFunc(policy.AllowedHostPorts, inRange)
+}
+
+// checkHostPod checks the final host pod against the host pod security policy, the image policy and the PriorityClasses
+// of the policy, rewriting the images of the mirrored registries. The PriorityClasses of the pod translated by the virtual
+// kubelet are allowed. It returns the reason and the message of the first check failed, or an empty reason if the pod is allowed.
+func checkHostPod(policy *v1beta1.VirtualClusterPolicy, hostPod *corev1.Pod, allowedPriorityClasses ...string) (string, string) {
+	if policy.Spec.HostPodSecurity != nil {
+		if violations := hostPodSecurityViolations(hostPod, policy.Spec.HostPodSecurity); len(violations) > 0 {
+			return HostPodSecurityRejectedReason, "Pod rejected by the host pod security policy: " + strings.Join(violations, "; ")
+		}
+	}
+
+	if policy.Spec.ImagePolicy != nil {
+		if violations := applyImagePolicy(hostPod, policy.Spec.ImagePolicy); len(violations) > 0 {
+			return ImagePolicyRejectedReason, "Pod rejected by the image policy: " + strings.Join(violations, "; ")
+		}
+	}
+
+	if !priorityClassAllowed(hostPod.Spec.PriorityClassName, policy, allowedPriorityClasses) {
+		return PriorityClassRejectedReason, fmt.Sprintf("Pod rejected by the priority classes policy: PriorityClass %q is not allowed", hostPod.Spec.PriorityClassName)
+	}
+
+	return "", ""
+}
+
+// rejectPod fails a virtual pod not allowed in the host cluster, recording the reason in its status and in an event.
+func (p *Provider) rejectPod(ctx context.Context, virtualPod *corev1.Pod, reason, message string) error {
+	p.logger.Info("rejecting pod", "namespace", virtualPod.Namespace, "name", virtualPod.Name, "reason", reason, "message", message)
+	p.eventRecorder.Event(virtualPod, corev1.EventTypeWarning, reason, message)
+
+	// the service account tokens of a pod rejected after their Secret was created are not left in the host cluster
+	if err := p.deleteTokenSecret(ctx, virtualPod); err != nil {
+		return err
+	}
+
+	virtualPod.Status.Phase = corev1.PodFailed
+	virtualPod.Status.Reason = reason
+	virtualPod.Status.Message = message
+
+	return p.VirtualClient.Status().Update(ctx, virtualPod)
+}
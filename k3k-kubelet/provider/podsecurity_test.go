@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"testing"
+
+	"k8s.io/utils/ptr"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+func Test_hostPodSecurityViolations(t *testing.T) {
+	policy := &v1beta1.HostPodSecurityPolicy{
+		AllowedHostPaths:   []string{"/var/log"},
+		DeniedHostPaths:    []string{"/var/log/secure"},
+		DeniedCapabilities: []corev1.Capability{"SYS_ADMIN"},
+		AllowedHostPorts:   []v1beta1.HostPortRange{{Min: 30000, Max: 32767}},
+	}
+
+	hostPathPod := func(path string) *corev1.Pod {
+		return &corev1.Pod{
+			Spec: corev1.PodSpec{
+				Volumes: []corev1.Volume{
+					{Name: "logs", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: path}}},
+				},
+			},
+		}
+	}
+
+	containerPod := func(container corev1.Container) *corev1.Pod {
+		container.Name = "app"
+		return &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container}}}
+	}
+
+	tests := []struct {
+		name           string
+		pod            *corev1.Pod
+		wantViolations int
+	}{
+		{
+			name:           "allowed hostPath",
+			pod:            hostPathPod("/var/log/pods/"),
+			wantViolations: 0,
+		},
+		{
+			name:           "denied hostPath",
+			pod:            hostPathPod("/var/log/secure"),
+			wantViolations: 1,
+		},
+		{
+			name:           "hostPath not allowed",
+			pod:            hostPathPod("/var/logs"),
+			wantViolations: 1,
+		},
+		{
+			name: "privileged",
+			pod: containerPod(corev1.Container{
+				SecurityContext: &corev1.SecurityContext{Privileged: ptr.To(true)},
+			}),
+			wantViolations: 1,
+		},
+		{
+			name: "capabilities",
+			pod: containerPod(corev1.Container{
+				SecurityContext: &corev1.SecurityContext{
+					Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_ADMIN", "CAP_SYS_ADMIN"}},
+				},
+			}),
+			wantViolations: 1,
+		},
+		{
+			name: "host ports",
+			pod: containerPod(corev1.Container{
+				Ports: []corev1.ContainerPort{{ContainerPort: 80, HostPort: 80}, {ContainerPort: 80, HostPort: 30080}},
+			}),
+			wantViolations: 1,
+		},
+		{
+			name: "host network",
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					HostNetwork: true,
+					Containers:  []corev1.Container{{Name: "app", Ports: []corev1.ContainerPort{{ContainerPort: 8080}}}},
+				},
+			},
+			wantViolations: 2,
+		},
+		{
+			name: "privileged ephemeral container",
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app"}},
+					EphemeralContainers: []corev1.EphemeralContainer{
+						{EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+							Name:            "debugger",
+							SecurityContext: &corev1.SecurityContext{Privileged: ptr.To(true)},
+						}},
+					},
+				},
+			},
+			wantViolations: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if violations := hostPodSecurityViolations(tt.pod, policy); len(violations) != tt.wantViolations {
+				t.Errorf("hostPodSecurityViolations() = %v, want %d violations", violations, tt.wantViolations)
+			}
+		})
+	}
+}
+
+func Test_checkHostPod(t *testing.T) {
+	policy := &v1beta1.VirtualClusterPolicy{
+		Spec: v1beta1.VirtualClusterPolicySpec{
+			HostPodSecurity: &v1beta1.HostPodSecurityPolicy{},
+			ImagePolicy:     &v1beta1.ImagePolicy{AllowedRegistries: []string{"registry.example.com"}},
+			PriorityClasses: &v1beta1.PriorityClassPolicy{AllowedHostClasses: []string{"high"}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		spec       corev1.PodSpec
+		wantReason string
+	}{
+		{
+			name: "allowed",
+			spec: corev1.PodSpec{
+				Containers:        []corev1.Container{{Name: "app", Image: "registry.example.com/app"}},
+				PriorityClassName: "translated",
+			},
+		},
+		{
+			name: "allowed host priorityClass",
+			spec: corev1.PodSpec{
+				Containers:        []corev1.Container{{Name: "app", Image: "registry.example.com/app"}},
+				PriorityClassName: "high",
+			},
+		},
+		{
+			name: "privileged sidecar",
+			spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "app", Image: "registry.example.com/app"},
+					{Name: "sidecar", Image: "registry.example.com/sidecar", SecurityContext: &corev1.SecurityContext{Privileged: ptr.To(true)}},
+				},
+			},
+			wantReason: HostPodSecurityRejectedReason,
+		},
+		{
+			name: "image not allowed",
+			spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "app", Image: "docker.io/app"}},
+			},
+			wantReason: ImagePolicyRejectedReason,
+		},
+		{
+			name: "priorityClass not allowed",
+			spec: corev1.PodSpec{
+				Containers:        []corev1.Container{{Name: "app", Image: "registry.example.com/app"}},
+				PriorityClassName: "system-node-critical",
+			},
+			wantReason: PriorityClassRejectedReason,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, message := checkHostPod(policy, &corev1.Pod{Spec: tt.spec}, "translated")
+			if reason != tt.wantReason {
+				t.Errorf("checkHostPod() = %q %q, want reason %q", reason, message, tt.wantReason)
+			}
+		})
+	}
+}
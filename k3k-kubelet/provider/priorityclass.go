@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/rancher/k3k/k3k-kubelet/controller/syncer"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+// PriorityClassRejectedReason is the reason of the failed status and of the events of the pods with a host PriorityClass
+// not allowed by the policy.
+const PriorityClassRejectedReason = "PriorityClassRejected"
+
+// translatePriorityClass sets the host PriorityClass of the pod.
+// The PriorityClass requested by the pod is used if it was synced to the host cluster, or if it's one of the host
+// PriorityClasses allowed by the policy. Otherwise the per-cluster default is used: the globalDefault PriorityClass
+// of the virtual cluster if synced, or the PriorityClass of the cluster, set after the hostPodOverlays.
+// Note: the core-dns and local-path-provisioner pod are scheduled by k3s with the
+// 'system-cluster-critical' and 'system-node-critical' default priority classes.
+func (p *Provider) translatePriorityClass(ctx context.Context, policy *v1beta1.VirtualClusterPolicy, virtualPod, hostPod *corev1.Pod) error {
+	priorityClassName := virtualPod.Spec.PriorityClassName
+
+	if strings.HasPrefix(priorityClassName, "system-") {
+		return nil
+	}
+
+	var allowedHostClasses []string
+
+	if policy != nil && policy.Spec.PriorityClasses != nil {
+		allowedHostClasses = policy.Spec.PriorityClasses.AllowedHostClasses
+	}
+
+	hostPriorityClassName := ""
+
+	if priorityClassName != "" {
+		translatedName := p.Translator.TranslateName("", priorityClassName)
+
+		found, err := p.hostPriorityClassExists(ctx, translatedName)
+		if err != nil {
+			return err
+		}
+
+		if found {
+			hostPriorityClassName = translatedName
+		} else if slices.Contains(allowedHostClasses, priorityClassName) {
+			if found, err = p.hostPriorityClassExists(ctx, priorityClassName); err != nil {
+				return err
+			}
+
+			if found {
+				hostPriorityClassName = priorityClassName
+			}
+		}
+
+		if hostPriorityClassName == "" {
+			p.eventRecorder.Eventf(virtualPod, corev1.EventTypeWarning, syncer.PriorityClassNotAllowedReason,
+				"PriorityClass %q is not available in the host cluster, the default one will be used", priorityClassName)
+		}
+	}
+
+	if hostPriorityClassName == "" {
+		defaultName, err := p.defaultPriorityClass(ctx)
+		if err != nil {
+			return err
+		}
+
+		hostPriorityClassName = defaultName
+	}
+
+	hostPod.Spec.PriorityClassName = hostPriorityClassName
+	// the priority will be resolved by the host cluster from the PriorityClass
+	hostPod.Spec.Priority = nil
+
+	return nil
+}
+
+// defaultPriorityClass returns the host PriorityClass synced from the globalDefault PriorityClass of the virtual cluster.
+// The globalDefault PriorityClass is not a global default in the host cluster, so it's used as a per-cluster default.
+func (p *Provider) defaultPriorityClass(ctx context.Context) (string, error) {
+	var priorityClasses schedulingv1.PriorityClassList
+	if err := p.VirtualClient.List(ctx, &priorityClasses); err != nil {
+		return "", err
+	}
+
+	for _, priorityClass := range priorityClasses.Items {
+		if !priorityClass.GlobalDefault {
+			continue
+		}
+
+		hostName := p.Translator.TranslateName("", priorityClass.Name)
+
+		found, err := p.hostPriorityClassExists(ctx, hostName)
+		if err != nil {
+			return "", err
+		}
+
+		if found {
+			return hostName, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (p *Provider) hostPriorityClassExists(ctx context.Context, name string) (bool, error) {
+	var priorityClass schedulingv1.PriorityClass
+	if err := p.HostClient.Get(ctx, types.NamespacedName{Name: name}, &priorityClass); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// priorityClassAllowed returns true if the host PriorityClass of the final host pod is empty, one of the given
+// PriorityClasses, or one of the host PriorityClasses allowed by the policy.
+func priorityClassAllowed(priorityClassName string, policy *v1beta1.VirtualClusterPolicy, allowedPriorityClasses []string) bool {
+	if priorityClassName == "" || slices.Contains(allowedPriorityClasses, priorityClassName) {
+		return true
+	}
+
+	return policy.Spec.PriorityClasses != nil && slices.Contains(policy.Spec.PriorityClasses.AllowedHostClasses, priorityClassName)
+}
@@ -16,18 +16,16 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/virtual-kubelet/virtual-kubelet/node/api"
 	"github.com/virtual-kubelet/virtual-kubelet/node/nodeutil"
-	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/tools/remotecommand"
 	"k8s.io/client-go/transport/spdy"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	dto "github.com/prometheus/client_model/go"
@@ -53,6 +51,7 @@ var _ nodeutil.Provider = (*Provider)(nil)
 type Provider struct {
 	Translator       translate.ToHostTranslator
 	HostClient       client.Client
+	HostReader       client.Reader
 	VirtualClient    client.Client
 	VirtualManager   manager.Manager
 	ClientConfig     rest.Config
@@ -61,31 +60,29 @@ type Provider struct {
 	ClusterName      string
 	serverIP         string
 	dnsIP            string
+	eventRecorder    record.EventRecorder
 	logger           logr.Logger
 }
 
 var ErrRetryTimeout = errors.New("provider timed out")
 
-func New(hostConfig rest.Config, hostMgr, virtualMgr manager.Manager, logger logr.Logger, namespace, name, serverIP, dnsIP string) (*Provider, error) {
+func New(hostConfig rest.Config, hostMgr, virtualMgr manager.Manager, logger logr.Logger, cluster *v1beta1.Cluster, serverIP, dnsIP string) (*Provider, error) {
 	coreClient, err := cv1.NewForConfig(&hostConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	translator := translate.ToHostTranslator{
-		ClusterName:      name,
-		ClusterNamespace: namespace,
-	}
-
 	p := Provider{
 		HostClient:       hostMgr.GetClient(),
+		HostReader:       hostMgr.GetAPIReader(),
 		VirtualClient:    virtualMgr.GetClient(),
 		VirtualManager:   virtualMgr,
-		Translator:       translator,
+		Translator:       *translate.NewHostTranslator(cluster),
 		ClientConfig:     hostConfig,
 		CoreClient:       coreClient,
-		ClusterNamespace: namespace,
-		ClusterName:      name,
+		ClusterNamespace: cluster.Namespace,
+		ClusterName:      cluster.Name,
+		eventRecorder:    virtualMgr.GetEventRecorderFor("k3k-kubelet"),
 		logger:           logger,
 		serverIP:         serverIP,
 		dnsIP:            dnsIP,
@@ -124,8 +121,10 @@ func (p *Provider) GetContainerLogs(ctx context.Context, namespace, podName, con
 		options.SinceTime = &sinceTime
 	}
 
-	closer, err := p.CoreClient.Pods(p.ClusterNamespace).GetLogs(hostPodName, &options).Stream(ctx)
-	p.logger.Error(err, fmt.Sprintf("got error when getting logs for %s in %s", hostPodName, p.ClusterNamespace))
+	hostNamespace := p.Translator.HostNamespace(namespace)
+
+	closer, err := p.CoreClient.Pods(hostNamespace).GetLogs(hostPodName, &options).Stream(ctx)
+	p.logger.Error(err, fmt.Sprintf("got error when getting logs for %s in %s", hostPodName, hostNamespace))
 
 	return closer, err
 }
@@ -137,7 +136,7 @@ func (p *Provider) RunInContainer(ctx context.Context, namespace, podName, conta
 	req := p.CoreClient.RESTClient().Post().
 		Resource("pods").
 		Name(hostPodName).
-		Namespace(p.ClusterNamespace).
+		Namespace(p.Translator.HostNamespace(namespace)).
 		SubResource("exec")
 	req.VersionedParams(&corev1.PodExecOptions{
 		Container: containerName,
@@ -171,7 +170,7 @@ func (p *Provider) AttachToContainer(ctx context.Context, namespace, podName, co
 	req := p.CoreClient.RESTClient().Post().
 		Resource("pods").
 		Name(hostPodName).
-		Namespace(p.ClusterNamespace).
+		Namespace(p.Translator.HostNamespace(namespace)).
 		SubResource("attach")
 	req.VersionedParams(&corev1.PodAttachOptions{
 		Container: containerName,
@@ -244,11 +243,11 @@ func (p *Provider) GetStatsSummary(ctx context.Context) (*stats.Summary, error)
 		return nil, err
 	}
 
-	podsNameMap := make(map[string]*corev1.Pod)
+	// the pods are mapped by their host name and namespace, so the pods of the other clusters are skipped
+	podsNameMap := make(map[types.NamespacedName]*corev1.Pod)
 
 	for _, pod := range pods {
-		hostPodName := p.Translator.TranslateName(pod.Namespace, pod.Name)
-		podsNameMap[hostPodName] = pod
+		podsNameMap[p.Translator.NamespacedName(pod)] = pod
 	}
 
 	filteredStats := &stats.Summary{
@@ -257,13 +256,10 @@ func (p *Provider) GetStatsSummary(ctx context.Context) (*stats.Summary, error)
 	}
 
 	for _, podStat := range allPodsStats {
-		// skip pods that are not in the cluster namespace
-		if podStat.PodRef.Namespace != p.ClusterNamespace {
-			continue
-		}
+		hostPodKey := types.NamespacedName{Name: podStat.PodRef.Name, Namespace: podStat.PodRef.Namespace}
 
 		// rewrite the PodReference to match the data of the virtual cluster
-		if pod, found := podsNameMap[podStat.PodRef.Name]; found {
+		if pod, found := podsNameMap[hostPodKey]; found {
 			podStat.PodRef = stats.PodReference{
 				Name:      pod.Name,
 				Namespace: pod.Namespace,
@@ -300,7 +296,7 @@ func (p *Provider) PortForward(ctx context.Context, namespace, pod string, port
 	req := p.CoreClient.RESTClient().Post().
 		Resource("pods").
 		Name(hostPodName).
-		Namespace(p.ClusterNamespace).
+		Namespace(p.Translator.HostNamespace(namespace)).
 		SubResource("portforward")
 
 	transport, upgrader, err := spdy.RoundTripperFor(&p.ClientConfig)
@@ -353,6 +349,11 @@ func (p *Provider) createPod(ctx context.Context, pod *corev1.Pod) error {
 		return fmt.Errorf("unable to get cluster %s in namespace %s: %w", p.ClusterName, p.ClusterNamespace, err)
 	}
 
+	policy, err := p.clusterPolicy(ctx, &cluster)
+	if err != nil {
+		return fmt.Errorf("unable to get the policy of cluster %s in namespace %s: %w", p.ClusterName, p.ClusterNamespace, err)
+	}
+
 	// these values shouldn't be set on create
 	tPod.UID = ""
 	tPod.ResourceVersion = ""
@@ -360,28 +361,28 @@ func (p *Provider) createPod(ctx context.Context, pod *corev1.Pod) error {
 	// the node was scheduled on the virtual kubelet, but leaving it this way will make it pending indefinitely
 	tPod.Spec.NodeName = ""
 
-	tPod.Spec.NodeSelector = cluster.Spec.NodeSelector
+	// merge the scheduling constraints of the pod with the ones of the cluster
+	if err := p.translateScheduling(ctx, &cluster, pod.Namespace, tPod); err != nil {
+		return fmt.Errorf("invalid scheduling constraints for pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+
+	// the pod can only request the resources advertised on the virtual node
+	if err := translateExtendedResources(tPod, cluster.Spec.ExtendedResources); err != nil {
+		return fmt.Errorf("invalid resources for pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
 
 	// setting the hostname for the pod if its not set
 	if pod.Spec.Hostname == "" {
 		tPod.Spec.Hostname = k3kcontroller.SafeConcatName(pod.Name)
 	}
 
-	// if the priorityClass for the virtual cluster is set then override the provided value
-	// Note: the core-dns and local-path-provisioner pod are scheduled by k3s with the
-	// 'system-cluster-critical' and 'system-node-critical' default priority classes.
-	if !strings.HasPrefix(tPod.Spec.PriorityClassName, "system-") {
-		if tPod.Spec.PriorityClassName != "" {
-			tPriorityClassName := p.Translator.TranslateName("", tPod.Spec.PriorityClassName)
-			tPod.Spec.PriorityClassName = tPriorityClassName
-		}
-
-		if cluster.Spec.PriorityClass != "" {
-			tPod.Spec.PriorityClassName = cluster.Spec.PriorityClass
-			tPod.Spec.Priority = nil
-		}
+	// use the requested priorityClass if available in the host cluster, or the default one of the virtual cluster
+	if err := p.translatePriorityClass(ctx, policy, &sourcePod, tPod); err != nil {
+		return fmt.Errorf("unable to translate priorityClass for pod %s/%s: %w", pod.Namespace, pod.Name, err)
 	}
 
+	translatedPriorityClass := tPod.Spec.PriorityClassName
+
 	// fieldpath annotations
 	if err := p.configureFieldPathEnv(&sourcePod, tPod); err != nil {
 		return fmt.Errorf("unable to fetch fieldpath annotations for pod %s/%s: %w", pod.Namespace, pod.Name, err)
@@ -391,10 +392,8 @@ func (p *Provider) createPod(ctx context.Context, pod *corev1.Pod) error {
 	if err := p.transformVolumes(pod.Namespace, tPod.Spec.Volumes); err != nil {
 		return fmt.Errorf("unable to sync volumes for pod %s/%s: %w", pod.Namespace, pod.Name, err)
 	}
-	// sync serviceaccount token to a the host cluster
-	if err := p.transformTokens(ctx, pod, tPod); err != nil {
-		return fmt.Errorf("unable to transform tokens for pod %s/%s: %w", pod.Namespace, pod.Name, err)
-	}
+	// the projected serviceaccount tokens are mounted from a host Secret, created once the pod is allowed
+	p.transformTokens(pod, tPod)
 
 	for i, imagePullSecret := range tPod.Spec.ImagePullSecrets {
 		tPod.Spec.ImagePullSecrets[i].Name = p.Translator.TranslateName(pod.Namespace, imagePullSecret.Name)
@@ -403,17 +402,57 @@ func (p *Provider) createPod(ctx context.Context, pod *corev1.Pod) error {
 	// inject networking information to the pod including the virtual cluster controlplane endpoint
 	configureNetworking(tPod, pod.Name, pod.Namespace, p.serverIP, p.dnsIP)
 
+	// apply the host-only settings of the hostPodOverlays of the policy and of the cluster
+	if err := p.applyHostPodOverlays(&cluster, policy, &sourcePod, tPod); err != nil {
+		return fmt.Errorf("unable to apply the host pod overlays for pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+
+	// the node selector of the cluster is applied last, and can't be overridden by the pod or the overlays
+	applyClusterScheduling(&cluster, tPod)
+
+	// the final host pod is checked against the policy, the pods not allowed are failed and not created in the host cluster
+	if policy != nil {
+		if reason, message := checkHostPod(policy, tPod, translatedPriorityClass, cluster.Spec.PriorityClass); reason != "" {
+			return p.rejectPod(ctx, &sourcePod, reason, message)
+		}
+	}
+
+	// the host pod has to fit in the quotas of the cluster
+	if err := p.checkWorkloadQuota(ctx, &cluster, policy, &sourcePod, tPod); err != nil {
+		return err
+	}
+
+	// mint the projected serviceaccount tokens and store them in the host cluster
+	if err := p.ensureTokenSecret(ctx, pod); err != nil {
+		return fmt.Errorf("unable to transform tokens for pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+
 	p.logger.Info("creating pod",
 		"host_namespace", tPod.Namespace, "host_name", tPod.Name,
 		"virtual_namespace", pod.Namespace, "virtual_name", pod.Name,
 	)
 
 	// set ownerReference to the cluster object
-	if err := controllerutil.SetControllerReference(&cluster, tPod, p.HostClient.Scheme()); err != nil {
+	if err := translate.SetClusterOwner(&cluster, tPod, p.HostClient.Scheme()); err != nil {
+		return err
+	}
+
+	if err := p.HostClient.Create(ctx, tPod); err != nil {
+		// the pods rejected by a quota of the host namespace are failed, with the message of the host cluster
+		if isQuotaRejection(err) {
+			return p.rejectPod(ctx, &sourcePod, HostQuotaRejectedReason, err.Error())
+		}
+
 		return err
 	}
 
-	return p.HostClient.Create(ctx, tPod)
+	// the Secret with the service account tokens is deleted with the host pod. The host pod is already created, so the
+	// owner is set again by the refresh of the tokens if it fails, and the Secret is deleted if the pod is deleted.
+	if err := p.setTokenSecretOwner(ctx, pod, tPod); err != nil {
+		p.logger.Error(err, "unable to set the owner of the service account tokens secret, retrying with the refresh of the tokens", "pod", pod.Name, "namespace", pod.Namespace)
+	}
+
+	return nil
 }
 
 // withRetry retries passed function with interval and timeout
@@ -501,10 +540,7 @@ func (p *Provider) updatePod(ctx context.Context, pod *corev1.Pod) error {
 		return fmt.Errorf("unable to get pod to update from virtual cluster: %w", err)
 	}
 
-	hostNamespaceName := types.NamespacedName{
-		Namespace: p.ClusterNamespace,
-		Name:      p.Translator.TranslateName(pod.Namespace, pod.Name),
-	}
+	hostNamespaceName := p.Translator.NamespacedName(pod)
 
 	var currentHostPod corev1.Pod
 
@@ -512,13 +548,31 @@ func (p *Provider) updatePod(ctx context.Context, pod *corev1.Pod) error {
 		return fmt.Errorf("unable to get pod to update from host cluster: %w", err)
 	}
 
+	// the ephemeral containers and the updated images are applied to a copy of the host pod,
+	// checked against the policy as the created pods
+	updatedHostPod := currentHostPod.DeepCopy()
+	updatedHostPod.Spec.EphemeralContainers = pod.Spec.EphemeralContainers
+	updatedHostPod.Spec.Containers = updateContainerImages(updatedHostPod.Spec.Containers, pod.Spec.Containers)
+	updatedHostPod.Spec.InitContainers = updateContainerImages(updatedHostPod.Spec.InitContainers, pod.Spec.InitContainers)
+
+	policy, err := p.currentPolicy(ctx)
+	if err != nil {
+		return err
+	}
+
+	if policy != nil {
+		if reason, message := checkHostPod(policy, updatedHostPod, currentHostPod.Spec.PriorityClassName); reason != "" {
+			p.eventRecorder.Event(pod, corev1.EventTypeWarning, reason, message)
+
+			return fmt.Errorf("unable to update pod %s/%s: %s", pod.Namespace, pod.Name, message)
+		}
+	}
+
 	// Handle ephemeral containers
-	if !cmp.Equal(currentHostPod.Spec.EphemeralContainers, pod.Spec.EphemeralContainers) {
+	if !cmp.Equal(currentHostPod.Spec.EphemeralContainers, updatedHostPod.Spec.EphemeralContainers) {
 		p.logger.Info("Updating ephemeral containers")
 
-		currentHostPod.Spec.EphemeralContainers = pod.Spec.EphemeralContainers
-
-		if _, err := p.CoreClient.Pods(p.ClusterNamespace).UpdateEphemeralContainers(ctx, currentHostPod.Name, &currentHostPod, metav1.UpdateOptions{}); err != nil {
+		if _, err := p.CoreClient.Pods(currentHostPod.Namespace).UpdateEphemeralContainers(ctx, currentHostPod.Name, updatedHostPod, metav1.UpdateOptions{}); err != nil {
 			p.logger.Error(err, "error when updating ephemeral containers")
 			return err
 		}
@@ -546,8 +600,8 @@ func (p *Provider) updatePod(ctx context.Context, pod *corev1.Pod) error {
 	}
 
 	// Update Pod in the host cluster
-	currentHostPod.Spec.Containers = updateContainerImages(currentHostPod.Spec.Containers, pod.Spec.Containers)
-	currentHostPod.Spec.InitContainers = updateContainerImages(currentHostPod.Spec.InitContainers, pod.Spec.InitContainers)
+	currentHostPod.Spec.Containers = updatedHostPod.Spec.Containers
+	currentHostPod.Spec.InitContainers = updatedHostPod.Spec.InitContainers
 
 	// update ActiveDeadlineSeconds and Tolerations
 	currentHostPod.Spec.ActiveDeadlineSeconds = pod.Spec.ActiveDeadlineSeconds
@@ -592,18 +646,19 @@ func (p *Provider) DeletePod(ctx context.Context, pod *corev1.Pod) error {
 func (p *Provider) deletePod(ctx context.Context, pod *corev1.Pod) error {
 	p.logger.Info(fmt.Sprintf("got request to delete pod %s/%s", pod.Namespace, pod.Name))
 	hostName := p.Translator.TranslateName(pod.Namespace, pod.Name)
+	hostNamespace := p.Translator.HostNamespace(pod.Namespace)
 
-	err := p.CoreClient.Pods(p.ClusterNamespace).Delete(ctx, hostName, metav1.DeleteOptions{})
+	err := p.CoreClient.Pods(hostNamespace).Delete(ctx, hostName, metav1.DeleteOptions{})
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			p.logger.Info(fmt.Sprintf("pod %s/%s already deleted from host cluster", p.ClusterNamespace, hostName))
+			p.logger.Info(fmt.Sprintf("pod %s/%s already deleted from host cluster", hostNamespace, hostName))
 			return nil
 		}
 
 		return fmt.Errorf("unable to delete pod %s/%s: %w", pod.Namespace, pod.Name, err)
 	}
 
-	p.logger.Info(fmt.Sprintf("pod %s/%s deleted from host cluster", p.ClusterNamespace, hostName))
+	p.logger.Info(fmt.Sprintf("pod %s/%s deleted from host cluster", hostNamespace, hostName))
 
 	return nil
 }
@@ -615,7 +670,7 @@ func (p *Provider) deletePod(ctx context.Context, pod *corev1.Pod) error {
 func (p *Provider) GetPod(ctx context.Context, namespace, name string) (*corev1.Pod, error) {
 	p.logger.V(1).Info("got a request for get pod", "namespace", namespace, "name", name)
 	hostNamespaceName := types.NamespacedName{
-		Namespace: p.ClusterNamespace,
+		Namespace: p.Translator.HostNamespace(namespace),
 		Name:      p.Translator.TranslateName(namespace, name),
 	}
 
@@ -625,7 +680,9 @@ func (p *Provider) GetPod(ctx context.Context, namespace, name string) (*corev1.
 		return nil, fmt.Errorf("error when retrieving pod: %w", err)
 	}
 
-	p.Translator.TranslateFrom(&pod)
+	if err := p.translateFrom(ctx, &pod); err != nil {
+		return nil, fmt.Errorf("error when translating pod: %w", err)
+	}
 
 	return &pod, nil
 }
@@ -652,32 +709,39 @@ func (p *Provider) GetPodStatus(ctx context.Context, namespace, name string) (*c
 // concurrently outside of the calling goroutine. Therefore it is recommended
 // to return a version after DeepCopy.
 func (p *Provider) GetPods(ctx context.Context) ([]*corev1.Pod, error) {
-	selector := labels.NewSelector()
-
-	requirement, err := labels.NewRequirement(translate.ClusterNameLabel, selection.Equals, []string{p.ClusterName})
-	if err != nil {
-		return nil, fmt.Errorf("unable to create label selector: %w", err)
-	}
-
-	selector = selector.Add(*requirement)
-
 	var podList corev1.PodList
 
-	err = p.HostClient.List(ctx, &podList, &client.ListOptions{LabelSelector: selector})
-	if err != nil {
+	if err := p.HostClient.List(ctx, &podList, p.Translator.ListOptions(nil)...); err != nil {
 		return nil, fmt.Errorf("unable to list pods: %w", err)
 	}
 
 	retPods := []*corev1.Pod{}
 
 	for _, pod := range podList.DeepCopy().Items {
-		p.Translator.TranslateFrom(&pod)
+		if err := p.translateFrom(ctx, &pod); err != nil {
+			p.logger.Error(err, "skipping host pod", "pod", pod.Name)
+			continue
+		}
+
 		retPods = append(retPods, &pod)
 	}
 
 	return retPods, nil
 }
 
+// translateFrom translates a host pod to its virtual pod. If the annotations of the host pod were erased,
+// the virtual pod is looked up in the host name index of the virtual pods.
+func (p *Provider) translateFrom(ctx context.Context, pod *corev1.Pod) error {
+	key, err := p.Translator.LookupVirtualName(ctx, p.VirtualClient, pod, &corev1.PodList{})
+	if err != nil {
+		return err
+	}
+
+	translate.SetVirtualName(pod, key)
+
+	return p.Translator.TranslateFrom(pod)
+}
+
 // configureNetworking will inject network information to each pod to connect them to the
 // virtual cluster api server, as well as confiugre DNS information to connect them to the
 // synced coredns on the host cluster.
@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	quota "k8s.io/apiserver/pkg/quota/v1"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller"
+	"github.com/rancher/k3k/pkg/controller/policy"
+)
+
+const (
+	// WorkloadQuotaExceededReason is the reason of the events of the pods exceeding the workloads quota of the cluster.
+	WorkloadQuotaExceededReason = "WorkloadQuotaExceeded"
+	// HostQuotaRejectedReason is the reason of the pods failed because rejected by a quota of the host cluster.
+	HostQuotaRejectedReason = "HostQuotaRejected"
+
+	// workloadReservationTTL is the time after which the reservation of a pod not created in the host cluster is released.
+	workloadReservationTTL = time.Minute
+)
+
+// checkWorkloadQuota returns an error if the host pod doesn't fit in the per-cluster workloads quota of the policy,
+// or in the quota of the policy with the PerNamespace host namespace mode, together with the host pods of the cluster
+// already created. The pod is kept pending, and its creation retried.
+//
+// A ResourceQuota can't select the pods of a single cluster, that share the host namespace with its servers, its agents
+// and the other clusters, nor the pods of all the host namespaces of a cluster, so the quotas are enforced by the
+// k3k-kubelet. The agents running on the different nodes reserve the resources of the pods in a ConfigMap of the
+// cluster, updated with an optimistic lock, so that two pods created at the same time can't both fit in the remaining
+// quota. The reservations are counted until the host pods are listed, and released after the workloadReservationTTL.
+func (p *Provider) checkWorkloadQuota(ctx context.Context, cluster *v1beta1.Cluster, clusterPolicy *v1beta1.VirtualClusterPolicy, virtualPod, hostPod *corev1.Pod) error {
+	quotas, err := p.workloadQuotas(ctx, cluster, clusterPolicy)
+	if err != nil || len(quotas) == 0 {
+		return err
+	}
+
+	var exceeded []corev1.ResourceName
+
+	isRetriable := func(err error) bool {
+		return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+	}
+
+	err = retry.OnError(retry.DefaultRetry, isRetriable, func() error {
+		var err error
+
+		exceeded, err = p.reserveWorkloadQuota(ctx, cluster, quotas, hostPod)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(exceeded) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("Pod exceeds the quota of the cluster: %v", exceeded)
+	p.eventRecorder.Event(virtualPod, corev1.EventTypeWarning, WorkloadQuotaExceededReason, message)
+
+	return fmt.Errorf("unable to create pod %s/%s: %s", virtualPod.Namespace, virtualPod.Name, message)
+}
+
+// workloadQuota is a quota enforced on the host pods of the cluster.
+type workloadQuota struct {
+	// Hard is the hard limits of the quota.
+	Hard corev1.ResourceList
+	// Used is the usage of the quota not counted from the host pods of the cluster.
+	Used corev1.ResourceList
+}
+
+// workloadQuotas returns the quotas enforced on the host pods of the cluster: the per-cluster workloads quota of the
+// policy and, with the PerNamespace host namespace mode, the quota of the policy in the namespace of the cluster. The
+// dedicated host namespaces don't have their own quota, and the pods in all of them are counted in the quota of the
+// namespace of the cluster, together with its usage in that namespace.
+func (p *Provider) workloadQuotas(ctx context.Context, cluster *v1beta1.Cluster, clusterPolicy *v1beta1.VirtualClusterPolicy) ([]workloadQuota, error) {
+	if clusterPolicy == nil {
+		return nil, nil
+	}
+
+	var quotas []workloadQuota
+
+	if clusterQuotas := clusterPolicy.Spec.ClusterQuotas; clusterQuotas != nil && len(clusterQuotas.Workloads) > 0 {
+		quotas = append(quotas, workloadQuota{Hard: clusterQuotas.Workloads})
+	}
+
+	namespaceQuota := clusterPolicy.Spec.Quota
+	if !p.Translator.NamespacePerVirtualNamespace || namespaceQuota == nil || len(namespaceQuota.Hard) == 0 ||
+		len(namespaceQuota.Scopes) > 0 || namespaceQuota.ScopeSelector != nil {
+		return quotas, nil
+	}
+
+	var resourceQuota corev1.ResourceQuota
+
+	key := types.NamespacedName{Name: controller.SafeConcatNameWithPrefix(clusterPolicy.Name), Namespace: cluster.Namespace}
+	if err := p.HostClient.Get(ctx, key, &resourceQuota); client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+
+	return append(quotas, workloadQuota{Hard: namespaceQuota.Hard, Used: resourceQuota.Status.Used}), nil
+}
+
+// workloadReservation is the usage of a host pod reserved in the workloads quota before its creation.
+type workloadReservation struct {
+	ReservedAt metav1.Time         `json:"reservedAt"`
+	Usage      corev1.ResourceList `json:"usage"`
+}
+
+// reserveWorkloadQuota reserves the usage of the host pod in the quotas of the cluster, and returns the exceeded
+// resources if it doesn't fit. The ConfigMap with the reservations is read from the API server, and its update fails
+// with a conflict if another pod was reserved in the meantime.
+func (p *Provider) reserveWorkloadQuota(ctx context.Context, cluster *v1beta1.Cluster, quotas []workloadQuota, hostPod *corev1.Pod) ([]corev1.ResourceName, error) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      controller.SafeConcatNameWithPrefix(cluster.Name, "workloads-quota"),
+			Namespace: cluster.Namespace,
+		},
+	}
+
+	err := p.HostReader.Get(ctx, client.ObjectKeyFromObject(configMap), configMap)
+	if client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+
+	found := err == nil
+
+	var podList corev1.PodList
+	if err := p.HostClient.List(ctx, &podList, p.Translator.ListOptions(nil)...); err != nil {
+		return nil, err
+	}
+
+	pods := make([]*corev1.Pod, 0, len(podList.Items))
+	hostPods := make(map[string]bool, len(podList.Items))
+
+	for i := range podList.Items {
+		pods = append(pods, &podList.Items[i])
+		hostPods[podList.Items[i].Name] = true
+	}
+
+	used := policy.PodsQuotaUsage(pods...)
+	reservations := make(map[string]string, len(configMap.Data))
+
+	for name, data := range configMap.Data {
+		var reservation workloadReservation
+		if err := json.Unmarshal([]byte(data), &reservation); err != nil {
+			continue
+		}
+
+		// the reservations are kept for a while also when the pods are listed, since the caches of the other agents
+		// could list them later
+		if time.Since(reservation.ReservedAt.Time) > workloadReservationTTL {
+			continue
+		}
+
+		reservations[name] = data
+
+		if !hostPods[name] && name != hostPod.Name {
+			used = quota.Add(used, reservation.Usage)
+		}
+	}
+
+	usage := policy.PodsQuotaUsage(hostPod)
+
+	var exceeded []corev1.ResourceName
+
+	for _, workloadQuota := range quotas {
+		for _, name := range policy.ExceededQuota(workloadQuota.Hard, quota.Add(workloadQuota.Used, quota.Add(used, usage))) {
+			if !slices.Contains(exceeded, name) {
+				exceeded = append(exceeded, name)
+			}
+		}
+	}
+
+	if len(exceeded) > 0 {
+		slices.Sort(exceeded)
+		return exceeded, nil
+	}
+
+	data, err := json.Marshal(workloadReservation{ReservedAt: metav1.Now(), Usage: usage})
+	if err != nil {
+		return nil, err
+	}
+
+	reservations[hostPod.Name] = string(data)
+	configMap.Data = reservations
+
+	if found {
+		return nil, p.HostClient.Update(ctx, configMap)
+	}
+
+	if err := translate.SetClusterOwner(cluster, configMap, p.HostClient.Scheme()); err != nil {
+		return nil, err
+	}
+
+	return nil, p.HostClient.Create(ctx, configMap)
+}
+
+// isQuotaRejection returns true if the host cluster refused to create a pod because of a ResourceQuota.
+func isQuotaRejection(err error) bool {
+	if !apierrors.IsForbidden(err) {
+		return false
+	}
+
+	message := err.Error()
+
+	return strings.Contains(message, "exceeded quota") || strings.Contains(message, "failed quota")
+}
@@ -0,0 +1,267 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+func Test_checkWorkloadQuota(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &v1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: "k3k-mycluster"}}
+
+	hostPod := func(name, clusterName, cpu string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "k3k-mycluster",
+				Labels:    map[string]string{translate.ClusterNameLabel: clusterName},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name: "app",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+					},
+				}},
+			},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+
+	hostClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		hostPod("running", "mycluster", "1", corev1.PodRunning),
+		hostPod("succeeded", "mycluster", "1", corev1.PodSucceeded),
+		hostPod("other-cluster", "othercluster", "1", corev1.PodRunning),
+	).Build()
+
+	p := &Provider{
+		HostClient:    hostClient,
+		HostReader:    hostClient,
+		Translator:    *translate.NewHostTranslator(cluster),
+		eventRecorder: record.NewFakeRecorder(10),
+	}
+
+	tests := []struct {
+		name      string
+		workloads corev1.ResourceList
+		cpu       string
+		wantErr   bool
+	}{
+		{
+			name:      "no workloads quota",
+			workloads: nil,
+			cpu:       "10",
+			wantErr:   false,
+		},
+		{
+			name:      "within the quota",
+			workloads: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("2"), corev1.ResourcePods: resource.MustParse("2")},
+			cpu:       "1",
+			wantErr:   false,
+		},
+		{
+			name:      "exceeding the cpu",
+			workloads: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("2")},
+			cpu:       "1500m",
+			wantErr:   true,
+		},
+		{
+			name:      "exceeding the pods",
+			workloads: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")},
+			cpu:       "100m",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusterPolicy := &v1beta1.VirtualClusterPolicy{Spec: v1beta1.VirtualClusterPolicySpec{ClusterQuotas: &v1beta1.ClusterQuotas{Workloads: tt.workloads}}}
+			virtualPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "new", Namespace: "default"}}
+
+			err := p.checkWorkloadQuota(context.Background(), cluster, clusterPolicy, virtualPod, hostPod("new", "mycluster", tt.cpu, ""))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkWorkloadQuota() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_checkWorkloadQuota_reservations(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &v1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: "k3k-mycluster", UID: "cluster-uid"}}
+
+	hostClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	p := &Provider{
+		HostClient:    hostClient,
+		HostReader:    hostClient,
+		Translator:    *translate.NewHostTranslator(cluster),
+		eventRecorder: record.NewFakeRecorder(10),
+	}
+
+	clusterPolicy := &v1beta1.VirtualClusterPolicy{
+		Spec: v1beta1.VirtualClusterPolicySpec{
+			ClusterQuotas: &v1beta1.ClusterQuotas{Workloads: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")}},
+		},
+	}
+
+	hostPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "k3k-mycluster"}}
+	}
+
+	virtualPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+
+	if err := p.checkWorkloadQuota(context.Background(), cluster, clusterPolicy, virtualPod, hostPod("first")); err != nil {
+		t.Fatalf("checkWorkloadQuota() of the first pod error = %v", err)
+	}
+
+	// the first pod is not created yet, but its reservation is counted
+	if err := p.checkWorkloadQuota(context.Background(), cluster, clusterPolicy, virtualPod, hostPod("second")); err == nil {
+		t.Errorf("checkWorkloadQuota() of the second pod expected an error")
+	}
+
+	// the reservation of the same pod is not counted twice when its creation is retried
+	if err := p.checkWorkloadQuota(context.Background(), cluster, clusterPolicy, virtualPod, hostPod("first")); err != nil {
+		t.Errorf("checkWorkloadQuota() of the first pod retried error = %v", err)
+	}
+}
+
+func Test_checkWorkloadQuota_perNamespace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &v1beta1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: "k3k-mycluster"},
+		Spec: v1beta1.ClusterSpec{
+			Mode:              v1beta1.SharedClusterMode,
+			HostNamespaceMode: v1beta1.PerNamespaceHostNamespaceMode,
+		},
+	}
+	translator := translate.NewHostTranslator(cluster)
+
+	clusterPolicy := &v1beta1.VirtualClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "mypolicy"},
+		Spec: v1beta1.VirtualClusterPolicySpec{
+			Quota: &corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("4")}},
+		},
+	}
+
+	// the server of the cluster is counted by the quota of the namespace of the cluster
+	namespaceQuota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "k3k-mypolicy", Namespace: "k3k-mycluster"},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("4")},
+			Used: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")},
+		},
+	}
+
+	hostPod := func(name, virtualNamespace string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: translator.HostNamespace(virtualNamespace),
+				Labels:    translator.HostLabels(),
+			},
+		}
+	}
+
+	hostClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		namespaceQuota,
+		hostPod("app", "default"),
+		hostPod("db", "storage"),
+	).Build()
+
+	p := &Provider{
+		HostClient:    hostClient,
+		HostReader:    hostClient,
+		Translator:    *translator,
+		eventRecorder: record.NewFakeRecorder(10),
+	}
+
+	virtualPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "frontend"}}
+
+	// the pods of all the host namespaces are counted together
+	if err := p.checkWorkloadQuota(context.Background(), cluster, clusterPolicy, virtualPod, hostPod("web", "frontend")); err != nil {
+		t.Fatalf("checkWorkloadQuota() of the fourth pod error = %v", err)
+	}
+
+	if err := p.checkWorkloadQuota(context.Background(), cluster, clusterPolicy, virtualPod, hostPod("cache", "other")); err == nil {
+		t.Errorf("checkWorkloadQuota() of the fifth pod expected an error")
+	}
+}
+
+func Test_isQuotaRejection(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "exceeded quota",
+			err:  apierrors.NewForbidden(pods, "app", errors.New("exceeded quota: policy-quota, requested: pods=1, used: pods=10, limited: pods=10")),
+			want: true,
+		},
+		{
+			name: "failed quota",
+			err:  apierrors.NewForbidden(pods, "app", errors.New("failed quota: policy-quota: must specify limits.cpu for: app")),
+			want: true,
+		},
+		{
+			name: "forbidden by the pod security admission",
+			err:  apierrors.NewForbidden(pods, "app", errors.New("violates PodSecurity \"baseline:latest\": privileged")),
+			want: false,
+		},
+		{
+			name: "not forbidden",
+			err:  errors.New("exceeded quota"),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isQuotaRejection(tt.err); got != tt.want {
+				t.Errorf("isQuotaRejection() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
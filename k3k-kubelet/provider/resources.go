@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	k3kcontroller "github.com/rancher/k3k/pkg/controller"
+)
+
+// advertisedResources returns the resources of the host nodes that will be advertised on the virtual node.
+// If the ExtendedResources config is set only the core resources and the allowed extended resources are kept,
+// and the latter are renamed accordingly.
+func advertisedResources(resources corev1.ResourceList, config *v1beta1.ExtendedResourcesConfig) corev1.ResourceList {
+	if config == nil {
+		return resources
+	}
+
+	advertised := corev1.ResourceList{}
+
+	for resourceName, quantity := range resources {
+		if slices.Contains(k3kcontroller.CoreResources, resourceName) {
+			advertised[resourceName] = quantity.DeepCopy()
+			continue
+		}
+
+		if !slices.Contains(config.Allowed, resourceName) {
+			continue
+		}
+
+		// a name already used by a core resource or by a previous allowed resource is not advertised again,
+		// so that the requests of the pods are translated to the same host resource
+		virtualName := virtualResourceName(resourceName, config)
+		if hostName, _ := hostResourceName(virtualName, config); hostName != resourceName {
+			continue
+		}
+
+		advertised[virtualName] = quantity.DeepCopy()
+	}
+
+	return advertised
+}
+
+// virtualResourceName returns the name of an allowed host resource on the virtual node.
+func virtualResourceName(hostResourceName corev1.ResourceName, config *v1beta1.ExtendedResourcesConfig) corev1.ResourceName {
+	if renamed, found := config.Rename[hostResourceName]; found && renamed != "" {
+		return renamed
+	}
+
+	return hostResourceName
+}
+
+// hostResourceName returns the name of the host resource advertised on the virtual node with the given name.
+// It returns false if the resource is not advertised.
+func hostResourceName(resourceName corev1.ResourceName, config *v1beta1.ExtendedResourcesConfig) (corev1.ResourceName, bool) {
+	if slices.Contains(k3kcontroller.CoreResources, resourceName) {
+		return resourceName, true
+	}
+
+	for _, allowed := range config.Allowed {
+		if virtualResourceName(allowed, config) == resourceName {
+			return allowed, true
+		}
+	}
+
+	return "", false
+}
+
+// translateExtendedResources checks that the resources requested by the containers of the pod are advertised
+// on the virtual node, and translates the renamed extended resources back to their names in the host cluster.
+func translateExtendedResources(pod *corev1.Pod, config *v1beta1.ExtendedResourcesConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	var errs []error
+
+	translateResources := func(containerName string, resources corev1.ResourceList) corev1.ResourceList {
+		if resources == nil {
+			return nil
+		}
+
+		translated := corev1.ResourceList{}
+
+		for resourceName, quantity := range resources {
+			hostName, found := hostResourceName(resourceName, config)
+			if !found {
+				errs = append(errs, fmt.Errorf("resource %q requested by container %q is not allowed", resourceName, containerName))
+				continue
+			}
+
+			translated[hostName] = quantity
+		}
+
+		return translated
+	}
+
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			container := &containers[i]
+			container.Resources.Requests = translateResources(container.Name, container.Resources.Requests)
+			container.Resources.Limits = translateResources(container.Name, container.Resources.Limits)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,224 @@
+package provider
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+const (
+	fakeGPU      = corev1.ResourceName("example.com/fake-gpu")
+	fakeNIC      = corev1.ResourceName("example.com/fake-nic")
+	hugepages2Mi = corev1.ResourceName("hugepages-2Mi")
+	renamedGPU   = corev1.ResourceName("tenant.io/gpu")
+)
+
+func Test_advertisedResources(t *testing.T) {
+	// resources advertised by a kind node with a fake device plugin
+	nodeResources := corev1.ResourceList{
+		corev1.ResourceCPU:              resource.MustParse("4"),
+		corev1.ResourceMemory:           resource.MustParse("8Gi"),
+		corev1.ResourcePods:             resource.MustParse("110"),
+		corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+		hugepages2Mi:                    resource.MustParse("0"),
+		fakeGPU:                         resource.MustParse("2"),
+		fakeNIC:                         resource.MustParse("8"),
+	}
+
+	tests := []struct {
+		name   string
+		config *v1beta1.ExtendedResourcesConfig
+		want   corev1.ResourceList
+	}{
+		{
+			name:   "no config",
+			config: nil,
+			want:   nodeResources,
+		},
+		{
+			name:   "empty allowlist",
+			config: &v1beta1.ExtendedResourcesConfig{},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("4"),
+				corev1.ResourceMemory:           resource.MustParse("8Gi"),
+				corev1.ResourcePods:             resource.MustParse("110"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+			},
+		},
+		{
+			name: "allowed resources",
+			config: &v1beta1.ExtendedResourcesConfig{
+				Allowed: []corev1.ResourceName{fakeGPU},
+			},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("4"),
+				corev1.ResourceMemory:           resource.MustParse("8Gi"),
+				corev1.ResourcePods:             resource.MustParse("110"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+				fakeGPU:                         resource.MustParse("2"),
+			},
+		},
+		{
+			name: "renamed resources",
+			config: &v1beta1.ExtendedResourcesConfig{
+				Allowed: []corev1.ResourceName{fakeGPU, fakeNIC},
+				Rename:  map[corev1.ResourceName]corev1.ResourceName{fakeGPU: renamedGPU},
+			},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("4"),
+				corev1.ResourceMemory:           resource.MustParse("8Gi"),
+				corev1.ResourcePods:             resource.MustParse("110"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+				renamedGPU:                      resource.MustParse("2"),
+				fakeNIC:                         resource.MustParse("8"),
+			},
+		},
+		{
+			name: "duplicate rename targets",
+			config: &v1beta1.ExtendedResourcesConfig{
+				Allowed: []corev1.ResourceName{fakeGPU, fakeNIC, hugepages2Mi},
+				Rename: map[corev1.ResourceName]corev1.ResourceName{
+					fakeGPU:      renamedGPU,
+					fakeNIC:      renamedGPU,
+					hugepages2Mi: corev1.ResourceCPU,
+				},
+			},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("4"),
+				corev1.ResourceMemory:           resource.MustParse("8Gi"),
+				corev1.ResourcePods:             resource.MustParse("110"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+				renamedGPU:                      resource.MustParse("2"),
+			},
+		},
+		{
+			name: "renamed resources not allowed",
+			config: &v1beta1.ExtendedResourcesConfig{
+				Rename: map[corev1.ResourceName]corev1.ResourceName{fakeGPU: renamedGPU},
+			},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("4"),
+				corev1.ResourceMemory:           resource.MustParse("8Gi"),
+				corev1.ResourcePods:             resource.MustParse("110"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := advertisedResources(nodeResources, tt.config); !equality.Semantic.DeepEqual(got, tt.want) {
+				t.Errorf("advertisedResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_translateExtendedResources(t *testing.T) {
+	config := &v1beta1.ExtendedResourcesConfig{
+		Allowed: []corev1.ResourceName{fakeGPU, hugepages2Mi},
+		Rename:  map[corev1.ResourceName]corev1.ResourceName{fakeGPU: renamedGPU},
+	}
+
+	tests := []struct {
+		name      string
+		config    *v1beta1.ExtendedResourcesConfig
+		resources corev1.ResourceRequirements
+		want      corev1.ResourceRequirements
+		wantErr   bool
+	}{
+		{
+			name:   "no config",
+			config: nil,
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{fakeNIC: resource.MustParse("1")},
+			},
+			want: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{fakeNIC: resource.MustParse("1")},
+			},
+		},
+		{
+			name:   "core resources",
+			config: config,
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+			},
+			want: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+			},
+		},
+		{
+			name:   "ephemeral storage",
+			config: config,
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("1Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("2Gi")},
+			},
+			want: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("1Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("2Gi")},
+			},
+		},
+		{
+			name:   "renamed resources are translated to the host name",
+			config: config,
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{renamedGPU: resource.MustParse("1"), hugepages2Mi: resource.MustParse("4Mi")},
+				Limits:   corev1.ResourceList{renamedGPU: resource.MustParse("1"), hugepages2Mi: resource.MustParse("4Mi")},
+			},
+			want: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{fakeGPU: resource.MustParse("1"), hugepages2Mi: resource.MustParse("4Mi")},
+				Limits:   corev1.ResourceList{fakeGPU: resource.MustParse("1"), hugepages2Mi: resource.MustParse("4Mi")},
+			},
+		},
+		{
+			name:   "host name of a renamed resource is not allowed",
+			config: config,
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{fakeGPU: resource.MustParse("1")},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "resource not allowed",
+			config: config,
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{fakeNIC: resource.MustParse("1")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "init", Resources: *tt.resources.DeepCopy()}},
+					Containers:     []corev1.Container{{Name: "main", Resources: *tt.resources.DeepCopy()}},
+				},
+			}
+
+			err := translateExtendedResources(pod, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("translateExtendedResources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+				if !equality.Semantic.DeepEqual(container.Resources, tt.want) {
+					t.Errorf("translateExtendedResources() container %s = %v, want %v", container.Name, container.Resources, tt.want)
+				}
+			}
+		})
+	}
+}
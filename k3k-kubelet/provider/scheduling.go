@@ -0,0 +1,205 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+// translateScheduling translates the scheduling constraints of the host pod.
+// The node selector of the pod is validated against the one of the cluster, and the pod affinity terms and topology spread
+// constraints are restricted to the host pods of the virtual cluster, in the host namespaces of the selected virtual namespaces.
+func (p *Provider) translateScheduling(ctx context.Context, cluster *v1beta1.Cluster, namespace string, hostPod *corev1.Pod) error {
+	nodeSelector, err := hostNodeSelector(cluster.Spec.NodeSelector, hostPod.Spec.NodeSelector, cluster.Spec.MirrorHostNodes)
+	if err != nil {
+		return err
+	}
+
+	hostPod.Spec.NodeSelector = nodeSelector
+
+	// the label selected by the pod affinity terms and topology spread constraints of the pods of the same namespace
+	hostPod.Labels[translate.ResourceNamespaceLabel] = namespace
+
+	for i := range hostPod.Spec.TopologySpreadConstraints {
+		constraint := &hostPod.Spec.TopologySpreadConstraints[i]
+		constraint.LabelSelector = hostLabelSelector(&p.Translator, constraint.LabelSelector, []string{namespace})
+	}
+
+	affinity := hostPod.Spec.Affinity
+	if affinity == nil {
+		return nil
+	}
+
+	// the node affinity of the pod was matched against the virtual node by the virtual scheduler
+	if !cluster.Spec.MirrorHostNodes {
+		affinity.NodeAffinity = nil
+	}
+
+	var terms []*corev1.PodAffinityTerm
+
+	if affinity.PodAffinity != nil {
+		terms = append(terms, podAffinityTerms(affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution, affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution)...)
+	}
+
+	if affinity.PodAntiAffinity != nil {
+		terms = append(terms, podAffinityTerms(affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution)...)
+	}
+
+	for _, term := range terms {
+		namespaces, all, err := p.affinityNamespaces(ctx, namespace, term)
+		if err != nil {
+			return err
+		}
+
+		translatePodAffinityTerm(&p.Translator, term, namespaces, all)
+	}
+
+	return nil
+}
+
+// hostNodeSelector returns the node selector of the pod to keep on the host pod, merged with the node selector of the
+// cluster by its defaults overlay. When the host nodes are mirrored, the node selector of the pod is kept, but it can't
+// override the one of the cluster. Otherwise it selects the labels of the virtual node, already matched by the virtual scheduler.
+func hostNodeSelector(clusterSelector, podSelector map[string]string, mirrorHostNodes bool) (map[string]string, error) {
+	if !mirrorHostNodes || len(podSelector) == 0 {
+		return nil, nil
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(podSelector)) {
+		value := podSelector[key]
+
+		if clusterValue, found := clusterSelector[key]; found && clusterValue != value {
+			return nil, fmt.Errorf("node selector %s=%s is not allowed, the cluster requires %s=%s", key, value, key, clusterValue)
+		}
+	}
+
+	return maps.Clone(podSelector), nil
+}
+
+// podAffinityTerms returns the required and preferred terms of a pod affinity or anti-affinity.
+func podAffinityTerms(required []corev1.PodAffinityTerm, preferred []corev1.WeightedPodAffinityTerm) []*corev1.PodAffinityTerm {
+	terms := make([]*corev1.PodAffinityTerm, 0, len(required)+len(preferred))
+
+	for i := range required {
+		terms = append(terms, &required[i])
+	}
+
+	for i := range preferred {
+		terms = append(terms, &preferred[i].PodAffinityTerm)
+	}
+
+	return terms
+}
+
+// affinityNamespaces returns the virtual namespaces of the pods selected by a pod affinity term, or all if
+// all the namespaces are selected. The namespace selector is resolved with the namespaces of the virtual cluster,
+// since their labels are not synced to the host cluster.
+func (p *Provider) affinityNamespaces(ctx context.Context, namespace string, term *corev1.PodAffinityTerm) ([]string, bool, error) {
+	if term.NamespaceSelector == nil {
+		if len(term.Namespaces) == 0 {
+			return []string{namespace}, false, nil
+		}
+
+		return term.Namespaces, false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(term.NamespaceSelector)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if selector.Empty() {
+		return nil, true, nil
+	}
+
+	var namespaceList corev1.NamespaceList
+	if err := p.VirtualClient.List(ctx, &namespaceList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, false, err
+	}
+
+	// not nil, even if no namespace is selected
+	namespaces := append(make([]string, 0, len(term.Namespaces)), term.Namespaces...)
+
+	for _, ns := range namespaceList.Items {
+		if !slices.Contains(namespaces, ns.Name) {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+
+	return namespaces, false, nil
+}
+
+// translatePodAffinityTerm restricts a pod affinity term to the host pods of the virtual cluster in the given virtual
+// namespaces, or in all its host namespaces, and translates the namespaces to the host namespaces.
+func translatePodAffinityTerm(translator *translate.ToHostTranslator, term *corev1.PodAffinityTerm, namespaces []string, all bool) {
+	if all {
+		term.LabelSelector = hostLabelSelector(translator, term.LabelSelector, nil)
+
+		if translator.NamespacePerVirtualNamespace {
+			term.Namespaces = nil
+			term.NamespaceSelector = &metav1.LabelSelector{MatchLabels: translator.HostLabels()}
+		} else {
+			term.Namespaces = []string{translator.ClusterNamespace}
+			term.NamespaceSelector = nil
+		}
+
+		return
+	}
+
+	term.LabelSelector = hostLabelSelector(translator, term.LabelSelector, namespaces)
+
+	var hostNamespaces []string
+
+	for _, namespace := range namespaces {
+		if hostNamespace := translator.HostNamespace(namespace); !slices.Contains(hostNamespaces, hostNamespace) {
+			hostNamespaces = append(hostNamespaces, hostNamespace)
+		}
+	}
+
+	term.Namespaces = hostNamespaces
+	term.NamespaceSelector = nil
+}
+
+// hostLabelSelector returns the label selector of the host pods selected by the label selector of a virtual pod,
+// restricted to the pods of the virtual cluster in the given virtual namespaces, if not nil.
+// A nil label selector matches no pods, and is returned as is.
+func hostLabelSelector(translator *translate.ToHostTranslator, selector *metav1.LabelSelector, namespaces []string) *metav1.LabelSelector {
+	if selector == nil {
+		return nil
+	}
+
+	hostSelector := selector.DeepCopy()
+
+	if hostSelector.MatchLabels == nil {
+		hostSelector.MatchLabels = map[string]string{}
+	}
+
+	maps.Copy(hostSelector.MatchLabels, translator.HostLabels())
+
+	switch {
+	case namespaces == nil:
+	case len(namespaces) == 0:
+		// no virtual namespace is selected, the cluster label can't match
+		hostSelector.MatchExpressions = append(hostSelector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      translate.ClusterNameLabel,
+			Operator: metav1.LabelSelectorOpDoesNotExist,
+		})
+	default:
+		hostSelector.MatchExpressions = append(hostSelector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      translate.ResourceNamespaceLabel,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   slices.Sorted(slices.Values(namespaces)),
+		})
+	}
+
+	return hostSelector
+}
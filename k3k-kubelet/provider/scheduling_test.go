@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+)
+
+func Test_hostNodeSelector(t *testing.T) {
+	clusterSelector := map[string]string{"pool": "tenants"}
+
+	tests := []struct {
+		name            string
+		podSelector     map[string]string
+		mirrorHostNodes bool
+		want            map[string]string
+		wantErr         bool
+	}{
+		{
+			name:        "virtual nodes",
+			podSelector: map[string]string{"kubernetes.io/os": "linux"},
+			want:        nil,
+		},
+		{
+			name:            "mirrored nodes",
+			podSelector:     map[string]string{"disktype": "ssd"},
+			mirrorHostNodes: true,
+			want:            map[string]string{"disktype": "ssd"},
+		},
+		{
+			name:            "same value as the cluster",
+			podSelector:     map[string]string{"pool": "tenants"},
+			mirrorHostNodes: true,
+			want:            map[string]string{"pool": "tenants"},
+		},
+		{
+			name:            "not allowed",
+			podSelector:     map[string]string{"pool": "system"},
+			mirrorHostNodes: true,
+			wantErr:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hostNodeSelector(clusterSelector, tt.podSelector, tt.mirrorHostNodes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("hostNodeSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !equality.Semantic.DeepEqual(got, tt.want) {
+				t.Errorf("hostNodeSelector() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_translatePodAffinityTerm(t *testing.T) {
+	single := &translate.ToHostTranslator{ClusterName: "mycluster", ClusterNamespace: "k3k-mycluster"}
+	perNamespace := &translate.ToHostTranslator{ClusterName: "mycluster", ClusterNamespace: "k3k-mycluster", NamespacePerVirtualNamespace: true}
+
+	appSelector := func() *metav1.LabelSelector {
+		return &metav1.LabelSelector{MatchLabels: map[string]string{"app": "nginx"}}
+	}
+
+	namespaceRequirement := func(namespaces ...string) []metav1.LabelSelectorRequirement {
+		return []metav1.LabelSelectorRequirement{
+			{Key: translate.ResourceNamespaceLabel, Operator: metav1.LabelSelectorOpIn, Values: namespaces},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		translator *translate.ToHostTranslator
+		namespaces []string
+		all        bool
+		want       corev1.PodAffinityTerm
+	}{
+		{
+			name:       "single",
+			translator: single,
+			namespaces: []string{"default", "apps"},
+			want: corev1.PodAffinityTerm{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels:      map[string]string{"app": "nginx", translate.ClusterNameLabel: "mycluster"},
+					MatchExpressions: namespaceRequirement("apps", "default"),
+				},
+				Namespaces: []string{"k3k-mycluster"},
+			},
+		},
+		{
+			name:       "single all namespaces",
+			translator: single,
+			all:        true,
+			want: corev1.PodAffinityTerm{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "nginx", translate.ClusterNameLabel: "mycluster"},
+				},
+				Namespaces: []string{"k3k-mycluster"},
+			},
+		},
+		{
+			name:       "per namespace",
+			translator: perNamespace,
+			namespaces: []string{"default", "apps"},
+			want: corev1.PodAffinityTerm{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels:      map[string]string{"app": "nginx", translate.ClusterNameLabel: "mycluster", translate.ClusterNamespaceLabel: "k3k-mycluster"},
+					MatchExpressions: namespaceRequirement("apps", "default"),
+				},
+				Namespaces: []string{"mycluster-default-xvkay2l5lxigl", "mycluster-apps-7y7tqhgatek66"},
+			},
+		},
+		{
+			name:       "per namespace all namespaces",
+			translator: perNamespace,
+			all:        true,
+			want: corev1.PodAffinityTerm{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "nginx", translate.ClusterNameLabel: "mycluster", translate.ClusterNamespaceLabel: "k3k-mycluster"},
+				},
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{translate.ClusterNameLabel: "mycluster", translate.ClusterNamespaceLabel: "k3k-mycluster"},
+				},
+			},
+		},
+		{
+			name:       "no namespace",
+			translator: single,
+			namespaces: []string{},
+			want: corev1.PodAffinityTerm{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "nginx", translate.ClusterNameLabel: "mycluster"},
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: translate.ClusterNameLabel, Operator: metav1.LabelSelectorOpDoesNotExist},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			term := corev1.PodAffinityTerm{
+				LabelSelector:     appSelector(),
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+				TopologyKey:       "kubernetes.io/hostname",
+			}
+
+			translatePodAffinityTerm(tt.translator, &term, tt.namespaces, tt.all)
+
+			tt.want.TopologyKey = "kubernetes.io/hostname"
+
+			if !equality.Semantic.DeepEqual(term, tt.want) {
+				t.Errorf("translatePodAffinityTerm() = %v, want %v", term, tt.want)
+			}
+		})
+	}
+}
@@ -2,110 +2,534 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/rancher/k3k/k3k-kubelet/translate"
 	k3kcontroller "github.com/rancher/k3k/pkg/controller"
 )
 
 const (
 	kubeAPIAccessPrefix          = "kube-api-access"
 	serviceAccountTokenMountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	// TokenSecretPodAnnotation is the annotation with the name of the virtual pod of a host Secret
+	// containing the service account tokens of the pod.
+	TokenSecretPodAnnotation = "token.k3k.io/pod"
+	// TokenSecretLabel is the label of the host Secrets containing the service account tokens of the pods.
+	TokenSecretLabel = "token.k3k.io/secret"
+
+	// tokenRefreshAfterAnnotation is the annotation with the time after which the tokens of the Secret are refreshed.
+	tokenRefreshAfterAnnotation = "token.k3k.io/refresh-after"
+
+	// tokenRefreshInterval is the interval used to check the tokens to refresh.
+	tokenRefreshInterval = time.Minute
+
+	// defaultTokenExpirationSeconds is the default expiration of the projected service account tokens.
+	defaultTokenExpirationSeconds = int64(3600)
+
+	// legacyTokenSecretManager is the field manager of the long-lived service account token Secrets created in the
+	// virtual cluster by the previous releases, named after their service account.
+	legacyTokenSecretManager = "k3k-kubelet"
+
+	rootCAConfigMapName = "kube-root-ca.crt"
+	rootCAKey           = "ca.crt"
+	namespaceKey        = "namespace"
+	tokenKey            = "token"
 )
 
-// transformTokens copies the serviceaccount tokens used by pod's serviceaccount to a secret on the host cluster and mount it
-// to look like the serviceaccount token
-func (p *Provider) transformTokens(ctx context.Context, pod, tPod *corev1.Pod) error {
+// tokenProjection is a service account token projected in a volume of a pod.
+type tokenProjection struct {
+	// Key is the key of the token in the host Secret.
+	Key string
+	// VolumeIndex and SourceIndex are the indexes of the projected source in the volumes of the pod.
+	VolumeIndex int
+	SourceIndex int
+	// Source is the projection of the token.
+	Source corev1.ServiceAccountTokenProjection
+	// KubeAPIAccess is true for the token of the kube-api-access volume.
+	KubeAPIAccess bool
+}
+
+// transformTokens replaces the service account tokens projected in the pod's volumes with the keys of the host Secret
+// storing them, keeping their audience and expiration. The Secret is created by ensureTokenSecret, only for the pods
+// allowed in the host cluster.
+func (p *Provider) transformTokens(pod, tPod *corev1.Pod) {
 	p.logger.Info("transforming token", "pod", pod.Name, "namespace", pod.Namespace, "serviceAccountName", pod.Spec.ServiceAccountName)
 
-	// skip this process if the kube-api-access is already removed from the pod
+	// the service account of the virtual cluster doesn't exist in the host cluster, and the host service account
+	// token must never be mounted in the pods of the virtual cluster
+	tPod.Spec.ServiceAccountName = ""
+	tPod.Spec.DeprecatedServiceAccount = ""
+	tPod.Spec.AutomountServiceAccountToken = ptr.To(false)
+
+	// the pods without projected tokens don't need a Secret
 	// this is needed in case users already adds their own custom tokens like in rancher imported clusters
-	if !isKubeAccessVolumeFound(pod) {
+	projections := tokenProjections(pod)
+	if len(projections) == 0 {
+		return
+	}
+
+	translateTokenProjections(tPod, projections, p.Translator.TranslateName(pod.Namespace, tokenSecretName(pod)))
+}
+
+// ensureTokenSecret mints the service account tokens projected in the pod's volumes with the TokenRequest API of
+// the virtual cluster, and stores them in a Secret on the host cluster. The tokens are refreshed before they expire.
+func (p *Provider) ensureTokenSecret(ctx context.Context, pod *corev1.Pod) error {
+	projections := tokenProjections(pod)
+	if len(projections) == 0 {
 		return nil
 	}
 
-	virtualSecretName := k3kcontroller.SafeConcatNameWithPrefix(pod.Spec.ServiceAccountName, "token")
+	hostSecret, err := p.tokenSecret(ctx, pod, projections)
+	if err != nil {
+		return err
+	}
+
+	var currentSecret corev1.Secret
+	if err := p.HostClient.Get(ctx, client.ObjectKeyFromObject(hostSecret), &currentSecret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
 
-	virtualSecret := virtualSecret(virtualSecretName, pod.Namespace, pod.Spec.ServiceAccountName)
-	if err := p.VirtualClient.Create(ctx, virtualSecret); err != nil {
-		if !apierrors.IsAlreadyExists(err) {
+		if err := p.HostClient.Create(ctx, hostSecret); err != nil {
 			return err
 		}
+	} else {
+		currentSecret.Annotations = hostSecret.Annotations
+		currentSecret.Data = hostSecret.Data
+
+		if err := p.HostClient.Update(ctx, &currentSecret); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteTokenSecret deletes the host Secret with the service account tokens of the pod, if any.
+func (p *Provider) deleteTokenSecret(ctx context.Context, pod *corev1.Pod) error {
+	hostSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.Translator.TranslateName(pod.Namespace, tokenSecretName(pod)),
+			Namespace: p.Translator.HostNamespace(pod.Namespace),
+		},
+	}
+
+	return client.IgnoreNotFound(p.HostClient.Delete(ctx, hostSecret))
+}
+
+// tokenSecret returns the host Secret with the service account tokens of the projections, minted for the virtual pod.
+func (p *Provider) tokenSecret(ctx context.Context, pod *corev1.Pod, projections []tokenProjection) (*corev1.Secret, error) {
+	data, refreshAfter, err := p.tokenSecretData(ctx, pod, projections)
+	if err != nil {
+		return nil, err
 	}
 
-	// extracting the tokens data from the secret we just created
-	virtualSecretKey := types.NamespacedName{
-		Name:      virtualSecret.Name,
-		Namespace: virtualSecret.Namespace,
+	hostSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tokenSecretName(pod),
+			Namespace: pod.Namespace,
+			Labels: map[string]string{
+				TokenSecretLabel: "true",
+			},
+		},
+		Data: data,
 	}
-	if err := p.VirtualClient.Get(ctx, virtualSecretKey, virtualSecret); err != nil {
+
+	p.Translator.TranslateTo(hostSecret)
+
+	hostSecret.Annotations[TokenSecretPodAnnotation] = pod.Name
+	hostSecret.Annotations[tokenRefreshAfterAnnotation] = refreshAfter.UTC().Format(time.RFC3339)
+
+	return hostSecret, nil
+}
+
+// tokenSecretData requests the tokens of the projections, bound to the virtual pod. The Secret of the kube-api-access
+// volume contains also the CA certificate of the virtual cluster and the namespace of the pod.
+// The returned time is the time after which the tokens must be refreshed, when 80% of their lifetime has passed.
+func (p *Provider) tokenSecretData(ctx context.Context, pod *corev1.Pod, projections []tokenProjection) (map[string][]byte, time.Time, error) {
+	var refreshAfter time.Time
+
+	data := make(map[string][]byte)
+	now := time.Now()
+
+	for _, projection := range projections {
+		tokenRequest := &authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				ExpirationSeconds: ptr.To(ptr.Deref(projection.Source.ExpirationSeconds, defaultTokenExpirationSeconds)),
+				BoundObjectRef: &authenticationv1.BoundObjectReference{
+					APIVersion: "v1",
+					Kind:       "Pod",
+					Name:       pod.Name,
+					UID:        pod.UID,
+				},
+			},
+		}
+
+		if projection.Source.Audience != "" {
+			tokenRequest.Spec.Audiences = []string{projection.Source.Audience}
+		}
+
+		serviceAccount := &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      serviceAccountName(pod),
+				Namespace: pod.Namespace,
+			},
+		}
+
+		if err := p.VirtualClient.SubResource("token").Create(ctx, serviceAccount, tokenRequest); err != nil {
+			return nil, refreshAfter, fmt.Errorf("unable to request token for service account %s/%s: %w", serviceAccount.Namespace, serviceAccount.Name, err)
+		}
+
+		data[projection.Key] = []byte(tokenRequest.Status.Token)
+
+		tokenRefreshAfter := now.Add(tokenRequest.Status.ExpirationTimestamp.Sub(now) * 4 / 5)
+		if refreshAfter.IsZero() || tokenRefreshAfter.Before(refreshAfter) {
+			refreshAfter = tokenRefreshAfter
+		}
+
+		if projection.KubeAPIAccess {
+			var rootCA corev1.ConfigMap
+			if err := p.VirtualClient.Get(ctx, types.NamespacedName{Name: rootCAConfigMapName, Namespace: pod.Namespace}, &rootCA); err != nil {
+				return nil, refreshAfter, err
+			}
+
+			data[rootCAKey] = []byte(rootCA.Data[rootCAKey])
+			data[namespaceKey] = []byte(pod.Namespace)
+		}
+	}
+
+	return data, refreshAfter, nil
+}
+
+// RefreshTokens refreshes periodically the service account tokens of the host Secrets before they expire, until the
+// context is done. The tokens of the pods deleted from the virtual cluster are not refreshed.
+// The legacy service account token Secrets are deleted once they aren't used by the host pods anymore.
+func (p *Provider) RefreshTokens(ctx context.Context) error {
+	ticker := time.NewTicker(tokenRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := p.refreshTokens(ctx); err != nil {
+				p.logger.Error(err, "failed to refresh service account tokens")
+			}
+
+			if err := p.deleteLegacyTokenSecrets(ctx); err != nil {
+				p.logger.Error(err, "failed to delete the legacy service account token secrets")
+			}
+		}
+	}
+}
+
+func (p *Provider) refreshTokens(ctx context.Context) error {
+	var hostSecrets corev1.SecretList
+
+	listOptions := p.Translator.ListOptions(map[string]string{TokenSecretLabel: "true"})
+
+	if err := p.HostClient.List(ctx, &hostSecrets, listOptions...); err != nil {
 		return err
 	}
-	// To avoid race conditions we need to check if the secret's data has been populated
-	// including the token, ca.crt and namespace
-	if len(virtualSecret.Data) < 3 {
-		return fmt.Errorf("token secret %s/%s data is empty", virtualSecret.Namespace, virtualSecret.Name)
+
+	var errs []error
+
+	for _, hostSecret := range hostSecrets.Items {
+		podKey := types.NamespacedName{
+			Name:      hostSecret.Annotations[TokenSecretPodAnnotation],
+			Namespace: hostSecret.Annotations[translate.ResourceNamespaceAnnotation],
+		}
+
+		var pod corev1.Pod
+		if err := p.VirtualClient.Get(ctx, podKey, &pod); err != nil {
+			if !apierrors.IsNotFound(err) {
+				errs = append(errs, err)
+				continue
+			}
+
+			// the Secret is deleted with the host pod, unless the host pod wasn't created or didn't become its owner
+			if len(hostSecret.OwnerReferences) == 0 {
+				p.logger.Info("deleting the orphaned service account tokens secret", "secret", hostSecret.Name, "namespace", hostSecret.Namespace)
+
+				if err := p.HostClient.Delete(ctx, &hostSecret); client.IgnoreNotFound(err) != nil {
+					errs = append(errs, err)
+				}
+			}
+
+			continue
+		}
+
+		// the tokens of the terminated pods, or of the pods rejected by the policy, are not needed anymore
+		if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
+			p.logger.Info("deleting the service account tokens secret of the terminated pod", "pod", pod.Name, "namespace", pod.Namespace)
+
+			if err := p.HostClient.Delete(ctx, &hostSecret); client.IgnoreNotFound(err) != nil {
+				errs = append(errs, err)
+			}
+
+			continue
+		}
+
+		// the owner that failed to be set when the host pod was created is set again
+		ownerSet, err := p.setMissingTokenSecretOwner(ctx, &pod, &hostSecret)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		refreshAfter, err := time.Parse(time.RFC3339, hostSecret.Annotations[tokenRefreshAfterAnnotation])
+		if err == nil && time.Now().Before(refreshAfter) {
+			if ownerSet {
+				if err := p.HostClient.Update(ctx, &hostSecret); err != nil {
+					errs = append(errs, err)
+				}
+			}
+
+			continue
+		}
+
+		p.logger.Info("refreshing service account tokens", "pod", pod.Name, "namespace", pod.Namespace)
+
+		data, refreshAfter, err := p.tokenSecretData(ctx, &pod, tokenProjections(&pod))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		hostSecret.Data = data
+		hostSecret.Annotations[tokenRefreshAfterAnnotation] = refreshAfter.UTC().Format(time.RFC3339)
+
+		if err := p.HostClient.Update(ctx, &hostSecret); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	hostSecret := virtualSecret.DeepCopy()
-	hostSecret.Type = ""
-	hostSecret.Annotations = make(map[string]string)
+	return errors.Join(errs...)
+}
 
-	p.Translator.TranslateTo(hostSecret)
+// setMissingTokenSecretOwner sets the host pod of the virtual pod as the owner of the tokens Secret, if the Secret
+// has no owner and the host pod exists. It returns true if the owner was set, and the Secret must be updated.
+func (p *Provider) setMissingTokenSecretOwner(ctx context.Context, pod *corev1.Pod, hostSecret *corev1.Secret) (bool, error) {
+	if len(hostSecret.OwnerReferences) > 0 {
+		return false, nil
+	}
 
-	if err := p.HostClient.Create(ctx, hostSecret); err != nil {
-		if !apierrors.IsAlreadyExists(err) {
+	var hostPod corev1.Pod
+
+	key := types.NamespacedName{
+		Name:      p.Translator.TranslateName(pod.Namespace, pod.Name),
+		Namespace: p.Translator.HostNamespace(pod.Namespace),
+	}
+
+	// the host pod is not created yet
+	if err := p.HostClient.Get(ctx, key, &hostPod); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+
+	if err := controllerutil.SetOwnerReference(&hostPod, hostSecret, p.HostClient.Scheme()); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// setTokenSecretOwner sets the host pod as the owner of its tokens Secret, so the Secret is deleted with the pod.
+// The update is retried on conflicts, since the tokens of the Secret can be refreshed concurrently.
+func (p *Provider) setTokenSecretOwner(ctx context.Context, pod, hostPod *corev1.Pod) error {
+	if len(tokenProjections(pod)) == 0 {
+		return nil
+	}
+
+	key := types.NamespacedName{
+		Name:      p.Translator.TranslateName(pod.Namespace, tokenSecretName(pod)),
+		Namespace: p.Translator.HostNamespace(pod.Namespace),
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var hostSecret corev1.Secret
+		if err := p.HostClient.Get(ctx, key, &hostSecret); err != nil {
+			return err
+		}
+
+		if err := controllerutil.SetOwnerReference(hostPod, &hostSecret, p.HostClient.Scheme()); err != nil {
 			return err
 		}
+
+		return p.HostClient.Update(ctx, &hostSecret)
+	})
+}
+
+// deleteLegacyTokenSecrets deletes the long-lived service account token Secrets created in the virtual cluster by the
+// previous releases, and their copies in the host cluster, once they aren't mounted by any host pod. Deleting the
+// virtual Secrets invalidates their tokens.
+func (p *Provider) deleteLegacyTokenSecrets(ctx context.Context) error {
+	var virtSecrets corev1.SecretList
+	if err := p.VirtualClient.List(ctx, &virtSecrets); err != nil {
+		return err
+	}
+
+	var hostPods corev1.PodList
+	if err := p.HostClient.List(ctx, &hostPods, p.Translator.ListOptions(nil)...); err != nil {
+		return err
+	}
+
+	mountedSecrets := make(map[types.NamespacedName]bool)
+
+	for _, hostPod := range hostPods.Items {
+		for _, volume := range hostPod.Spec.Volumes {
+			if volume.Secret != nil {
+				mountedSecrets[types.NamespacedName{Name: volume.Secret.SecretName, Namespace: hostPod.Namespace}] = true
+			}
+		}
 	}
 
-	p.translateToken(tPod, hostSecret.Name)
+	var errs []error
 
-	return nil
+	for _, virtSecret := range virtSecrets.Items {
+		if !isLegacyTokenSecret(&virtSecret) {
+			continue
+		}
+
+		hostNamespace := p.Translator.HostNamespace(virtSecret.Namespace)
+
+		hostNames := []string{
+			p.Translator.TranslateName(virtSecret.Namespace, virtSecret.Name),
+			p.Translator.LegacyTranslateName(virtSecret.Namespace, virtSecret.Name),
+		}
+
+		if slices.ContainsFunc(hostNames, func(name string) bool {
+			return mountedSecrets[types.NamespacedName{Name: name, Namespace: hostNamespace}]
+		}) {
+			continue
+		}
+
+		p.logger.Info("deleting the legacy service account token secret", "secret", virtSecret.Name, "namespace", virtSecret.Namespace)
+
+		for _, name := range hostNames {
+			hostSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: hostNamespace}}
+			if err := p.HostClient.Delete(ctx, hostSecret); client.IgnoreNotFound(err) != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if err := p.VirtualClient.Delete(ctx, &virtSecret); client.IgnoreNotFound(err) != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
-func virtualSecret(name, namespace, serviceAccountName string) *corev1.Secret {
-	return &corev1.Secret{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "Secret",
-			APIVersion: "v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
-			Annotations: map[string]string{
-				corev1.ServiceAccountNameKey: serviceAccountName,
-			},
-		},
-		Type: corev1.SecretTypeServiceAccountToken,
+// isLegacyTokenSecret returns true if the virtual Secret is a service account token Secret created by the previous
+// releases of the virtual kubelet, named after its service account.
+func isLegacyTokenSecret(secret *corev1.Secret) bool {
+	serviceAccountName := secret.Annotations[corev1.ServiceAccountNameKey]
+
+	if secret.Type != corev1.SecretTypeServiceAccountToken || serviceAccountName == "" ||
+		secret.Name != k3kcontroller.SafeConcatNameWithPrefix(serviceAccountName, "token") {
+		return false
 	}
+
+	return slices.ContainsFunc(secret.ManagedFields, func(entry metav1.ManagedFieldsEntry) bool {
+		return entry.Manager == legacyTokenSecretManager && entry.Operation == metav1.ManagedFieldsOperationUpdate
+	})
 }
 
-// translateToken will remove the serviceaccount from the pod and replace the kube-api-access volume
-// with a custom token volume and mount it to all containers within the pod
-func (p *Provider) translateToken(pod *corev1.Pod, hostSecretName string) {
-	pod.Spec.ServiceAccountName = ""
-	pod.Spec.DeprecatedServiceAccount = ""
-	pod.Spec.AutomountServiceAccountToken = ptr.To(false)
-	removeKubeAccessVolume(pod)
-	addKubeAccessVolume(pod, hostSecretName)
+// tokenProjections returns the service account tokens projected in the volumes of the pod.
+func tokenProjections(pod *corev1.Pod) []tokenProjection {
+	var projections []tokenProjection
+
+	for i, volume := range pod.Spec.Volumes {
+		if volume.Projected == nil {
+			continue
+		}
+
+		kubeAPIAccess := strings.HasPrefix(volume.Name, kubeAPIAccessPrefix)
+
+		for j, source := range volume.Projected.Sources {
+			if source.ServiceAccountToken == nil {
+				continue
+			}
+
+			key := fmt.Sprintf("%s.%d", volume.Name, j)
+			if kubeAPIAccess {
+				key = tokenKey
+			}
+
+			projections = append(projections, tokenProjection{
+				Key:           key,
+				VolumeIndex:   i,
+				SourceIndex:   j,
+				Source:        *source.ServiceAccountToken,
+				KubeAPIAccess: kubeAPIAccess,
+			})
+		}
+	}
+
+	return projections
 }
 
-func isKubeAccessVolumeFound(pod *corev1.Pod) bool {
-	for _, volume := range pod.Spec.Volumes {
-		if strings.HasPrefix(volume.Name, kubeAPIAccessPrefix) {
-			return true
+// translateTokenProjections replaces the projected service account tokens of the host pod with the keys of the host Secret.
+// The kube-api-access volume is replaced with a volume mounting the token, the CA certificate and the namespace.
+func translateTokenProjections(pod *corev1.Pod, projections []tokenProjection, hostSecretName string) {
+	var kubeAPIAccess bool
+
+	for _, projection := range projections {
+		if projection.KubeAPIAccess {
+			kubeAPIAccess = true
+			continue
+		}
+
+		pod.Spec.Volumes[projection.VolumeIndex].Projected.Sources[projection.SourceIndex] = corev1.VolumeProjection{
+			Secret: &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: hostSecretName},
+				Items: []corev1.KeyToPath{
+					{Key: projection.Key, Path: projection.Source.Path},
+				},
+			},
 		}
 	}
 
-	return false
+	// the kube-api-access volume is removed last, to keep the indexes of the other volumes
+	if kubeAPIAccess {
+		removeKubeAccessVolume(pod)
+		addKubeAccessVolume(pod, hostSecretName)
+	}
+}
+
+// tokenSecretName returns the name of the Secret with the service account tokens of the pod, before the translation.
+func tokenSecretName(pod *corev1.Pod) string {
+	return k3kcontroller.SafeConcatNameWithPrefix(pod.Name, "token")
+}
+
+// serviceAccountName returns the service account of the pod.
+func serviceAccountName(pod *corev1.Pod) string {
+	if pod.Spec.ServiceAccountName != "" {
+		return pod.Spec.ServiceAccountName
+	}
+
+	if pod.Spec.DeprecatedServiceAccount != "" {
+		return pod.Spec.DeprecatedServiceAccount
+	}
+
+	return "default"
 }
 
 func removeKubeAccessVolume(pod *corev1.Pod) {
@@ -148,11 +572,17 @@ func removeKubeAccessVolume(pod *corev1.Pod) {
 func addKubeAccessVolume(pod *corev1.Pod, hostSecretName string) {
 	tokenVolumeName := k3kcontroller.SafeConcatNameWithPrefix(kubeAPIAccessPrefix)
 
+	// only the keys of the kube-api-access volume are mounted, the Secret can contain other projected tokens
 	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
 		Name: tokenVolumeName,
 		VolumeSource: corev1.VolumeSource{
 			Secret: &corev1.SecretVolumeSource{
 				SecretName: hostSecretName,
+				Items: []corev1.KeyToPath{
+					{Key: tokenKey, Path: tokenKey},
+					{Key: rootCAKey, Path: rootCAKey},
+					{Key: namespaceKey, Path: namespaceKey},
+				},
 			},
 		},
 	})
@@ -0,0 +1,359 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	k3kcontroller "github.com/rancher/k3k/pkg/controller"
+)
+
+// kubeAPIAccessVolume returns the kube-api-access volume added by the ServiceAccount admission plugin.
+func kubeAPIAccessVolume() corev1.Volume {
+	return corev1.Volume{
+		Name: "kube-api-access-abcde",
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: "token", ExpirationSeconds: ptr.To[int64](3607)}},
+					{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "kube-root-ca.crt"}}},
+					{DownwardAPI: &corev1.DownwardAPIProjection{}},
+				},
+			},
+		},
+	}
+}
+
+// vaultTokenVolume returns a projected volume with a token for a custom audience.
+func vaultTokenVolume() corev1.Volume {
+	return corev1.Volume{
+		Name: "vault-token",
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "vault-ca"}}},
+					{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: "vault/token", Audience: "vault", ExpirationSeconds: ptr.To[int64](600)}},
+				},
+			},
+		},
+	}
+}
+
+func Test_tokenProjections(t *testing.T) {
+	tests := []struct {
+		name    string
+		volumes []corev1.Volume
+		want    []tokenProjection
+	}{
+		{
+			name: "no projected tokens",
+			volumes: []corev1.Volume{
+				{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			},
+			want: nil,
+		},
+		{
+			name:    "kube-api-access volume",
+			volumes: []corev1.Volume{kubeAPIAccessVolume()},
+			want: []tokenProjection{
+				{
+					Key:           "token",
+					Source:        corev1.ServiceAccountTokenProjection{Path: "token", ExpirationSeconds: ptr.To[int64](3607)},
+					KubeAPIAccess: true,
+				},
+			},
+		},
+		{
+			name:    "custom audience",
+			volumes: []corev1.Volume{kubeAPIAccessVolume(), vaultTokenVolume()},
+			want: []tokenProjection{
+				{
+					Key:           "token",
+					Source:        corev1.ServiceAccountTokenProjection{Path: "token", ExpirationSeconds: ptr.To[int64](3607)},
+					KubeAPIAccess: true,
+				},
+				{
+					Key:         "vault-token.1",
+					VolumeIndex: 1,
+					SourceIndex: 1,
+					Source:      corev1.ServiceAccountTokenProjection{Path: "vault/token", Audience: "vault", ExpirationSeconds: ptr.To[int64](600)},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{Spec: corev1.PodSpec{Volumes: tt.volumes}}
+
+			if got := tokenProjections(pod); !equality.Semantic.DeepEqual(got, tt.want) {
+				t.Errorf("tokenProjections() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_translateTokenProjections(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{kubeAPIAccessVolume(), vaultTokenVolume()},
+			Containers: []corev1.Container{
+				{
+					Name: "app",
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "kube-api-access-abcde", MountPath: serviceAccountTokenMountPath},
+						{Name: "vault-token", MountPath: "/var/run/secrets/vault"},
+					},
+				},
+			},
+		},
+	}
+
+	translateTokenProjections(pod, tokenProjections(pod), "host-secret")
+
+	if len(pod.Spec.Volumes) != 2 {
+		t.Fatalf("expected 2 volumes, got %d", len(pod.Spec.Volumes))
+	}
+
+	// the projected token is replaced with the key of the host Secret, the other sources are kept
+	vaultVolume := pod.Spec.Volumes[0]
+	wantSources := []corev1.VolumeProjection{
+		{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "vault-ca"}}},
+		{
+			Secret: &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "host-secret"},
+				Items:                []corev1.KeyToPath{{Key: "vault-token.1", Path: "vault/token"}},
+			},
+		},
+	}
+
+	if vaultVolume.Name != "vault-token" || !equality.Semantic.DeepEqual(vaultVolume.Projected.Sources, wantSources) {
+		t.Errorf("unexpected vault-token volume %v", vaultVolume)
+	}
+
+	// the kube-api-access volume mounts only its keys of the host Secret
+	kubeAPIAccess := pod.Spec.Volumes[1]
+	if kubeAPIAccess.Secret == nil || kubeAPIAccess.Secret.SecretName != "host-secret" || len(kubeAPIAccess.Secret.Items) != 3 {
+		t.Errorf("unexpected kube-api-access volume %v", kubeAPIAccess)
+	}
+
+	mounts := pod.Spec.Containers[0].VolumeMounts
+	if len(mounts) != 2 || mounts[0].Name != "vault-token" || mounts[1].Name != kubeAPIAccess.Name || mounts[1].MountPath != serviceAccountTokenMountPath {
+		t.Errorf("unexpected volume mounts %v", mounts)
+	}
+}
+
+func Test_refreshTokens_owners(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &v1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: "k3k-mycluster"}}
+	translator := translate.NewHostTranslator(cluster)
+
+	virtualPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	failedPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "rejected", Namespace: "default"},
+		Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+	hostPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: translator.TranslateName("default", "web"), Namespace: "k3k-mycluster", UID: "host-pod-uid"}}
+
+	tokenSecret := func(podName string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      tokenSecretName(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: podName}}),
+				Namespace: "default",
+				Labels:    map[string]string{TokenSecretLabel: "true"},
+			},
+		}
+
+		translator.TranslateTo(secret)
+
+		secret.Annotations[TokenSecretPodAnnotation] = podName
+		secret.Annotations[tokenRefreshAfterAnnotation] = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+		return secret
+	}
+
+	runningSecret := tokenSecret("web")
+	orphanedSecret := tokenSecret("deleted")
+	failedSecret := tokenSecret("rejected")
+
+	hostClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hostPod, runningSecret, orphanedSecret, failedSecret).Build()
+	virtualClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(virtualPod, failedPod).Build()
+
+	p := &Provider{
+		HostClient:    hostClient,
+		VirtualClient: virtualClient,
+		Translator:    *translator,
+		logger:        logr.Discard(),
+	}
+
+	if err := p.refreshTokens(context.Background()); err != nil {
+		t.Fatalf("refreshTokens() error = %v", err)
+	}
+
+	// the host pod becomes the owner of the Secret of the running pod
+	var secret corev1.Secret
+	if err := hostClient.Get(context.Background(), client.ObjectKeyFromObject(runningSecret), &secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].UID != hostPod.UID {
+		t.Errorf("unexpected owner references %v", secret.OwnerReferences)
+	}
+
+	// the Secret without owner of the deleted pod is deleted
+	err := hostClient.Get(context.Background(), client.ObjectKeyFromObject(orphanedSecret), &secret)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected the orphaned secret to be deleted, got %v", err)
+	}
+
+	// the Secret of the failed pod is deleted, and its tokens are not refreshed
+	err = hostClient.Get(context.Background(), client.ObjectKeyFromObject(failedSecret), &secret)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected the secret of the failed pod to be deleted, got %v", err)
+	}
+}
+
+func Test_rejectPod(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &v1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: "k3k-mycluster"}}
+	translator := translate.NewHostTranslator(cluster)
+
+	virtualPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	hostSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      translator.TranslateName("default", tokenSecretName(virtualPod)),
+			Namespace: translator.HostNamespace("default"),
+		},
+	}
+
+	hostClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hostSecret).Build()
+	virtualClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(virtualPod).WithStatusSubresource(virtualPod).Build()
+
+	p := &Provider{
+		HostClient:    hostClient,
+		VirtualClient: virtualClient,
+		Translator:    *translator,
+		eventRecorder: record.NewFakeRecorder(10),
+		logger:        logr.Discard(),
+	}
+
+	if err := p.rejectPod(context.Background(), virtualPod, HostQuotaRejectedReason, "exceeded quota"); err != nil {
+		t.Fatalf("rejectPod() error = %v", err)
+	}
+
+	var pod corev1.Pod
+	if err := virtualClient.Get(context.Background(), client.ObjectKeyFromObject(virtualPod), &pod); err != nil {
+		t.Fatal(err)
+	}
+
+	if pod.Status.Phase != corev1.PodFailed || pod.Status.Reason != HostQuotaRejectedReason {
+		t.Errorf("unexpected status %v", pod.Status)
+	}
+
+	err := hostClient.Get(context.Background(), client.ObjectKeyFromObject(hostSecret), &corev1.Secret{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected the tokens secret to be deleted, got %v", err)
+	}
+}
+
+func Test_deleteLegacyTokenSecrets(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &v1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: "k3k-mycluster"}}
+	translator := translate.NewHostTranslator(cluster)
+
+	legacySecret := func(serviceAccountName, manager string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:          k3kcontroller.SafeConcatNameWithPrefix(serviceAccountName, "token"),
+				Namespace:     "default",
+				Annotations:   map[string]string{corev1.ServiceAccountNameKey: serviceAccountName},
+				ManagedFields: []metav1.ManagedFieldsEntry{{Manager: manager, Operation: metav1.ManagedFieldsOperationUpdate}},
+			},
+			Type: corev1.SecretTypeServiceAccountToken,
+		}
+	}
+
+	unusedSecret := legacySecret("unused", legacyTokenSecretManager)
+	mountedSecret := legacySecret("mounted", legacyTokenSecretManager)
+	userSecret := legacySecret("user", "kubectl-create")
+
+	hostSecret := func(virtSecret *corev1.Secret) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: translator.TranslateName("default", virtSecret.Name), Namespace: "k3k-mycluster"}}
+	}
+
+	// the host pods created by the previous releases mount the host copy of the legacy Secret
+	hostPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "legacy-pod", Namespace: "k3k-mycluster", Labels: translator.HostLabels()},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{{
+				Name:         "kube-api-access",
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: hostSecret(mountedSecret).Name}},
+			}},
+		},
+	}
+
+	hostClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hostPod, hostSecret(unusedSecret), hostSecret(mountedSecret)).Build()
+	virtualClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(unusedSecret, mountedSecret, userSecret).Build()
+
+	p := &Provider{
+		HostClient:    hostClient,
+		VirtualClient: virtualClient,
+		Translator:    *translator,
+		logger:        logr.Discard(),
+	}
+
+	if err := p.deleteLegacyTokenSecrets(context.Background()); err != nil {
+		t.Fatalf("deleteLegacyTokenSecrets() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		client  client.Client
+		secret  *corev1.Secret
+		deleted bool
+	}{
+		{name: "unused legacy secret", client: virtualClient, secret: unusedSecret, deleted: true},
+		{name: "host copy of the unused legacy secret", client: hostClient, secret: hostSecret(unusedSecret), deleted: true},
+		{name: "mounted legacy secret", client: virtualClient, secret: mountedSecret, deleted: false},
+		{name: "host copy of the mounted legacy secret", client: hostClient, secret: hostSecret(mountedSecret), deleted: false},
+		{name: "secret created by the user", client: virtualClient, secret: userSecret, deleted: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var secret corev1.Secret
+
+			err := tt.client.Get(context.Background(), client.ObjectKeyFromObject(tt.secret), &secret)
+			if apierrors.IsNotFound(err) != tt.deleted {
+				t.Errorf("expected deleted = %v, got error %v", tt.deleted, err)
+			}
+		})
+	}
+}
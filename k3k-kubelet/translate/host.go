@@ -1,11 +1,17 @@
 package translate
 
 import (
+	"crypto/sha256"
+	"encoding/base32"
 	"encoding/hex"
+	"errors"
+	"maps"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
 	"github.com/rancher/k3k/pkg/controller"
@@ -15,18 +21,36 @@ const (
 	// ClusterNameLabel is the key for the label that contains the name of the virtual cluster
 	// this resource was made in
 	ClusterNameLabel = "k3k.io/clusterName"
+	// ClusterNamespaceLabel is the key for the label that contains the namespace of the virtual cluster
+	// this resource was made in. It's only set on the objects in the dedicated host namespaces of a cluster
+	// with the PerNamespace host namespace mode, and on these namespaces.
+	ClusterNamespaceLabel = "k3k.io/clusterNamespace"
 	// ResourceNameAnnotation is the key for the annotation that contains the original name of this
 	// resource in the virtual cluster
 	ResourceNameAnnotation = "k3k.io/name"
 	// ResourceNamespaceAnnotation is the key for the annotation that contains the original namespace of this
 	// resource in the virtual cluster
 	ResourceNamespaceAnnotation = "k3k.io/namespace"
+	// ResourceNamespaceLabel is the key for the label that contains the original namespace of a pod in the
+	// virtual cluster, selected by the pod affinity terms and topology spread constraints of the host pods
+	ResourceNamespaceLabel = "k3k.io/namespace"
 	// MetadataNameField is the downwardapi field for object's name
 	MetadataNameField = "metadata.name"
 	// MetadataNamespaceField is the downward field for the object's namespace
 	MetadataNamespaceField = "metadata.namespace"
+
+	// maxHostNameLength is the maximum length of the host names, so that they are valid DNS labels.
+	maxHostNameLength = 63
+	// hostNameHashLength is the length of the hash suffix of the host names.
+	hostNameHashLength = 13
 )
 
+// ErrMissingAnnotations is returned when the annotations with the virtual name and namespace of a host object are missing.
+var ErrMissingAnnotations = errors.New("missing " + ResourceNameAnnotation + " and " + ResourceNamespaceAnnotation + " annotations")
+
+// hashEncoding encodes the hash of the host names with lowercase letters and digits.
+var hashEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
 type ToHostTranslator struct {
 	// ClusterName is the name of the virtual cluster whose resources we are
 	// translating to a host cluster
@@ -34,13 +58,77 @@ type ToHostTranslator struct {
 	// ClusterNamespace is the namespace of the virtual cluster whose resources
 	// we are translating to a host cluster
 	ClusterNamespace string
+	// NamespacePerVirtualNamespace is true if each virtual namespace is translated to a dedicated host namespace,
+	// instead of the namespace of the virtual cluster
+	NamespacePerVirtualNamespace bool
 }
 
 func NewHostTranslator(cluster *v1beta1.Cluster) *ToHostTranslator {
 	return &ToHostTranslator{
-		ClusterName:      cluster.Name,
-		ClusterNamespace: cluster.Namespace,
+		ClusterName:                  cluster.Name,
+		ClusterNamespace:             cluster.Namespace,
+		NamespacePerVirtualNamespace: cluster.Spec.Mode == v1beta1.SharedClusterMode && cluster.Spec.HostNamespaceMode == v1beta1.PerNamespaceHostNamespaceMode,
+	}
+}
+
+// HostNamespace returns the host namespace of the objects of a virtual namespace. The dedicated host namespaces are
+// named after the cluster and the virtual namespace, with a hash including the namespace of the cluster, so that the
+// clusters with the same name in different namespaces don't share them.
+func (t *ToHostTranslator) HostNamespace(namespace string) string {
+	if !t.NamespacePerVirtualNamespace || namespace == "" {
+		return t.ClusterNamespace
+	}
+
+	return hashedHostName([]string{t.ClusterName, namespace}, []string{namespace, t.ClusterName, t.ClusterNamespace})
+}
+
+// HostLabels returns the labels identifying the host objects of the virtual cluster, in all its host namespaces.
+func (t *ToHostTranslator) HostLabels() map[string]string {
+	labels := map[string]string{ClusterNameLabel: t.ClusterName}
+
+	if t.NamespacePerVirtualNamespace {
+		labels[ClusterNamespaceLabel] = t.ClusterNamespace
+	}
+
+	return labels
+}
+
+// ListOptions returns the options to list the host objects of the virtual cluster in all its host namespaces,
+// with the additional labels if any.
+func (t *ToHostTranslator) ListOptions(matchingLabels map[string]string) []client.ListOption {
+	labels := t.HostLabels()
+	maps.Copy(labels, matchingLabels)
+
+	if t.NamespacePerVirtualNamespace {
+		return []client.ListOption{client.MatchingLabels(labels)}
 	}
+
+	return []client.ListOption{client.InNamespace(t.ClusterNamespace), client.MatchingLabels(labels)}
+}
+
+// IsHostObject returns true if the host object was synced from an object of the virtual cluster.
+func (t *ToHostTranslator) IsHostObject(obj client.Object) bool {
+	labels := obj.GetLabels()
+	if labels[ClusterNameLabel] != t.ClusterName {
+		return false
+	}
+
+	if t.NamespacePerVirtualNamespace {
+		return labels[ClusterNamespaceLabel] == t.ClusterNamespace
+	}
+
+	return obj.GetNamespace() == t.ClusterNamespace
+}
+
+// SetClusterOwner sets the Cluster as the controller of a host object in the cluster namespace.
+// The host objects in the dedicated host namespaces can't be owned by the Cluster, since owner references
+// across namespaces are not allowed: they are deleted with their namespace instead.
+func SetClusterOwner(cluster *v1beta1.Cluster, obj client.Object, scheme *runtime.Scheme) error {
+	if obj.GetNamespace() != cluster.Namespace {
+		return nil
+	}
+
+	return controllerutil.SetControllerReference(cluster, obj, scheme)
 }
 
 // Translate translates a virtual cluster object to a host cluster object. This should only be used for
@@ -66,7 +154,7 @@ func (t *ToHostTranslator) TranslateTo(obj client.Object) {
 		labels = map[string]string{}
 	}
 
-	labels[ClusterNameLabel] = t.ClusterName
+	maps.Copy(labels, t.HostLabels())
 	obj.SetLabels(labels)
 
 	// resource version/UID won't match what's in the host cluster.
@@ -76,23 +164,27 @@ func (t *ToHostTranslator) TranslateTo(obj client.Object) {
 	// set the name and the namespace so that this goes in the proper host namespace
 	// and doesn't collide with other resources
 	obj.SetName(t.TranslateName(obj.GetNamespace(), obj.GetName()))
-	obj.SetNamespace(t.ClusterNamespace)
+	obj.SetNamespace(t.HostNamespace(obj.GetNamespace()))
 	obj.SetFinalizers(nil)
 }
 
-func (t *ToHostTranslator) TranslateFrom(obj client.Object) {
+// TranslateFrom translates a host cluster object to a virtual cluster object, using the annotations with the
+// virtual name and namespace of the host object. ErrMissingAnnotations is returned if they were erased in the host cluster,
+// and the object is not modified. The virtual name can be restored with LookupVirtualName and SetVirtualName.
+func (t *ToHostTranslator) TranslateFrom(obj client.Object) error {
+	key, err := VirtualName(obj)
+	if err != nil {
+		return err
+	}
+
 	// owning objects may be in the virtual cluster, but may not be in the host cluster
 	obj.SetOwnerReferences(nil)
 
+	obj.SetName(key.Name)
+	obj.SetNamespace(key.Namespace)
+
 	// remove the annotations added to track original name
 	annotations := obj.GetAnnotations()
-	// TODO: It's possible that this was erased by a change on the host cluster
-	// In this case, we need to have some sort of fallback or error return
-	name := annotations[ResourceNameAnnotation]
-	namespace := annotations[ResourceNamespaceAnnotation]
-
-	obj.SetName(name)
-	obj.SetNamespace(namespace)
 	delete(annotations, ResourceNameAnnotation)
 	delete(annotations, ResourceNamespaceAnnotation)
 	obj.SetAnnotations(annotations)
@@ -100,30 +192,71 @@ func (t *ToHostTranslator) TranslateFrom(obj client.Object) {
 	// remove the clusteName tracking label
 	labels := obj.GetLabels()
 	delete(labels, ClusterNameLabel)
+	delete(labels, ClusterNamespaceLabel)
+	delete(labels, ResourceNamespaceLabel)
 	obj.SetLabels(labels)
 
 	// resource version/UID won't match what's in the virtual cluster.
 	obj.SetResourceVersion("")
 	obj.SetUID("")
+
+	return nil
+}
+
+// VirtualName returns the name and the namespace of the virtual object of a host object, from its annotations.
+func VirtualName(obj client.Object) (types.NamespacedName, error) {
+	annotations := obj.GetAnnotations()
+
+	name, nameFound := annotations[ResourceNameAnnotation]
+	namespace, namespaceFound := annotations[ResourceNamespaceAnnotation]
+
+	if !nameFound || !namespaceFound || name == "" {
+		return types.NamespacedName{}, ErrMissingAnnotations
+	}
+
+	return types.NamespacedName{Name: name, Namespace: namespace}, nil
+}
+
+// SetVirtualName sets the annotations with the virtual name and namespace of a host object.
+func SetVirtualName(obj client.Object, key types.NamespacedName) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[ResourceNameAnnotation] = key.Name
+	annotations[ResourceNamespaceAnnotation] = key.Namespace
+	obj.SetAnnotations(annotations)
 }
 
 // TranslateName returns the name of the resource in the host cluster. Will not update the object with this name.
+// The name is made of the name, the namespace and the cluster name, truncated if needed, followed by a hash of them,
+// so that it is a valid DNS label, unique for this combination of name/namespace/cluster.
 func (t *ToHostTranslator) TranslateName(namespace string, name string) string {
-	var names []string
+	names := hostNameParts(namespace, name, t.ClusterName)
 
-	// some resources are not namespaced (i.e. priorityclasses)
-	/// for these resources we skip the namespace to avoid having a name like: prioritclass--cluster-123
-	if namespace == "" {
-		names = []string{name, t.ClusterName}
-	} else {
-		names = []string{name, namespace, t.ClusterName}
+	return hashedHostName(names, names)
+}
+
+// hashedHostName returns a host name made of the readable parts, truncated if needed, and of the hash of the unique parts.
+func hashedHostName(parts, uniqueParts []string) string {
+	// use + as a separator since it can't be in an object name
+	digest := sha256.Sum256([]byte(strings.Join(uniqueParts, "+")))
+	hash := hashEncoding.EncodeToString(digest[:])[:hostNameHashLength]
+
+	prefix := strings.Join(parts, "-")
+	if maxPrefixLength := maxHostNameLength - hostNameHashLength - 1; len(prefix) > maxPrefixLength {
+		// the name can't end with a separator
+		prefix = strings.TrimRight(prefix[:maxPrefixLength], "-.")
 	}
 
-	// we need to come up with a name which is:
-	// - somewhat connectable to the original resource
-	// - a valid k8s name
-	// - idempotently calculatable
-	// - unique for this combination of name/namespace/cluster
+	return prefix + "-" + hash
+}
+
+// LegacyTranslateName returns the name of the resource in the host cluster used by the previous releases.
+// It's only used to migrate the host objects to the names returned by TranslateName.
+func (t *ToHostTranslator) LegacyTranslateName(namespace string, name string) string {
+	names := hostNameParts(namespace, name, t.ClusterName)
 
 	namePrefix := strings.Join(names, "-")
 
@@ -135,10 +268,21 @@ func (t *ToHostTranslator) TranslateName(namespace string, name string) string {
 	return controller.SafeConcatName(namePrefix, nameSuffix)
 }
 
+// hostNameParts returns the parts of the host name of a resource.
+// Some resources are not namespaced (i.e. priorityclasses), for these resources we skip the namespace
+// to avoid having a name like: prioritclass--cluster-123
+func hostNameParts(namespace, name, clusterName string) []string {
+	if namespace == "" {
+		return []string{name, clusterName}
+	}
+
+	return []string{name, namespace, clusterName}
+}
+
 // NamespacedName returns the types.NamespacedName of the resource in the host cluster
 func (t *ToHostTranslator) NamespacedName(obj client.Object) types.NamespacedName {
 	return types.NamespacedName{
-		Namespace: t.ClusterNamespace,
+		Namespace: t.HostNamespace(obj.GetNamespace()),
 		Name:      t.TranslateName(obj.GetNamespace(), obj.GetName()),
 	}
 }
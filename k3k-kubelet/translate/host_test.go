@@ -0,0 +1,171 @@
+package translate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_TranslateName(t *testing.T) {
+	translator := ToHostTranslator{ClusterName: "mycluster", ClusterNamespace: "k3k-mycluster"}
+
+	tests := []struct {
+		name       string
+		namespace  string
+		objName    string
+		wantPrefix string
+	}{
+		{
+			name:       "namespaced",
+			namespace:  "default",
+			objName:    "nginx",
+			wantPrefix: "nginx-default-mycluster-",
+		},
+		{
+			name:       "not namespaced",
+			namespace:  "",
+			objName:    "high-priority",
+			wantPrefix: "high-priority-mycluster-",
+		},
+		{
+			name:       "long name",
+			namespace:  "default",
+			objName:    strings.Repeat("a", 48) + "-" + strings.Repeat("b", 100),
+			wantPrefix: strings.Repeat("a", 48) + "-",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := translator.TranslateName(tt.namespace, tt.objName)
+
+			if !strings.HasPrefix(got, tt.wantPrefix) {
+				t.Errorf("TranslateName() = %s, want prefix %s", got, tt.wantPrefix)
+			}
+
+			if errs := validation.IsDNS1035Label(got); len(errs) > 0 {
+				t.Errorf("TranslateName() = %s is not a valid DNS label: %v", got, errs)
+			}
+
+			if again := translator.TranslateName(tt.namespace, tt.objName); again != got {
+				t.Errorf("TranslateName() is not deterministic: %s != %s", again, got)
+			}
+		})
+	}
+}
+
+func Test_TranslateName_collisions(t *testing.T) {
+	translator := ToHostTranslator{ClusterName: "mycluster", ClusterNamespace: "k3k-mycluster"}
+	otherTranslator := ToHostTranslator{ClusterName: "other", ClusterNamespace: "k3k-mycluster"}
+
+	long := strings.Repeat("a", 80)
+
+	names := map[string]string{
+		// the same prefix for different names and namespaces
+		"a-b/c":  translator.TranslateName("a-b", "c"),
+		"a/b-c":  translator.TranslateName("a", "b-c"),
+		"long/1": translator.TranslateName("default", long+"1"),
+		"long/2": translator.TranslateName("default", long+"2"),
+		"other":  otherTranslator.TranslateName("a-b", "c"),
+	}
+
+	seen := map[string]string{}
+
+	for key, name := range names {
+		if other, found := seen[name]; found {
+			t.Errorf("TranslateName() collision for %s and %s: %s", key, other, name)
+		}
+
+		seen[name] = key
+	}
+}
+
+func Test_TranslateFrom(t *testing.T) {
+	translator := ToHostTranslator{ClusterName: "mycluster", ClusterNamespace: "k3k-mycluster"}
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "default"}}
+	translator.TranslateTo(pod)
+
+	if err := translator.TranslateFrom(pod); err != nil {
+		t.Fatalf("TranslateFrom() error = %v", err)
+	}
+
+	if pod.Name != "nginx" || pod.Namespace != "default" {
+		t.Errorf("TranslateFrom() = %s/%s, want default/nginx", pod.Namespace, pod.Name)
+	}
+
+	// the annotations erased on the host cluster
+	pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "default"}}
+	translator.TranslateTo(pod)
+	pod.Annotations = nil
+
+	if err := translator.TranslateFrom(pod); !errors.Is(err, ErrMissingAnnotations) {
+		t.Errorf("TranslateFrom() error = %v, want %v", err, ErrMissingAnnotations)
+	}
+}
+
+func Test_HostNamespace(t *testing.T) {
+	single := ToHostTranslator{ClusterName: "mycluster", ClusterNamespace: "k3k-mycluster"}
+	perNamespace := ToHostTranslator{ClusterName: "mycluster", ClusterNamespace: "k3k-mycluster", NamespacePerVirtualNamespace: true}
+
+	tests := []struct {
+		name       string
+		translator ToHostTranslator
+		namespace  string
+		want       string
+	}{
+		{
+			name:       "single",
+			translator: single,
+			namespace:  "default",
+			want:       "k3k-mycluster",
+		},
+		{
+			name:       "per namespace",
+			translator: perNamespace,
+			namespace:  "default",
+			want:       "mycluster-default-xvkay2l5lxigl",
+		},
+		{
+			name:       "per namespace not namespaced",
+			translator: perNamespace,
+			namespace:  "",
+			want:       "k3k-mycluster",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.translator.HostNamespace(tt.namespace); got != tt.want {
+				t.Errorf("HostNamespace() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// the objects are translated to the host namespace, and labelled with the cluster namespace
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "default"}}
+	perNamespace.TranslateTo(pod)
+
+	if pod.Namespace != "mycluster-default-xvkay2l5lxigl" || pod.Labels[ClusterNamespaceLabel] != "k3k-mycluster" {
+		t.Errorf("TranslateTo() = %s with labels %v", pod.Namespace, pod.Labels)
+	}
+
+	if !perNamespace.IsHostObject(pod) || single.IsHostObject(pod) {
+		t.Errorf("IsHostObject() doesn't match the host namespace mode")
+	}
+
+	if err := perNamespace.TranslateFrom(pod); err != nil || pod.Namespace != "default" || pod.Labels[ClusterNamespaceLabel] != "" {
+		t.Errorf("TranslateFrom() = %s with labels %v, error %v", pod.Namespace, pod.Labels, err)
+	}
+
+	// the clusters with the same name in different namespaces have different host namespaces
+	otherNamespace := ToHostTranslator{ClusterName: "mycluster", ClusterNamespace: "team-b", NamespacePerVirtualNamespace: true}
+	if otherNamespace.HostNamespace("default") == perNamespace.HostNamespace("default") {
+		t.Errorf("HostNamespace() = %s for both the clusters", perNamespace.HostNamespace("default"))
+	}
+}
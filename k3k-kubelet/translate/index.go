@@ -0,0 +1,55 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// HostNameIndexField is the field index of the virtual objects by the name of their host objects.
+// It is the reverse lookup of TranslateName, used when the annotations of the host objects were erased.
+const HostNameIndexField = "k3k.io/hostName"
+
+// IndexHostName adds the index of the virtual objects of the kind of obj by their host name to the indexer of the virtual cluster.
+func (t *ToHostTranslator) IndexHostName(ctx context.Context, indexer client.FieldIndexer, obj client.Object) error {
+	return indexer.IndexField(ctx, obj, HostNameIndexField, func(obj client.Object) []string {
+		return []string{t.TranslateName(obj.GetNamespace(), obj.GetName())}
+	})
+}
+
+// LookupVirtualName returns the name and the namespace of the virtual object of a host object. They are read from the
+// annotations of the host object if they match its name, otherwise they are looked up in the host name index of the
+// virtual objects, listed with the given empty list of their kind.
+func (t *ToHostTranslator) LookupVirtualName(ctx context.Context, virtReader client.Reader, hostObj client.Object, list client.ObjectList) (types.NamespacedName, error) {
+	key, err := VirtualName(hostObj)
+	if err == nil && t.TranslateName(key.Namespace, key.Name) == hostObj.GetName() {
+		return key, nil
+	}
+
+	if err := virtReader.List(ctx, list, client.MatchingFields{HostNameIndexField: hostObj.GetName()}); err != nil {
+		return types.NamespacedName{}, err
+	}
+
+	var keys []types.NamespacedName
+
+	if err := meta.EachListItem(list, func(item runtime.Object) error {
+		obj, ok := item.(client.Object)
+		if ok {
+			keys = append(keys, client.ObjectKeyFromObject(obj))
+		}
+
+		return nil
+	}); err != nil {
+		return types.NamespacedName{}, err
+	}
+
+	if len(keys) != 1 {
+		return types.NamespacedName{}, fmt.Errorf("%w: virtual object of %s not found", ErrMissingAnnotations, hostObj.GetName())
+	}
+
+	return keys[0], nil
+}
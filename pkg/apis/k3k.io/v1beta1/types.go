@@ -2,7 +2,9 @@ package v1beta1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // +genclient
@@ -52,6 +54,16 @@ type ClusterSpec struct {
 	// +optional
 	Mode ClusterMode `json:"mode,omitempty"`
 
+	// HostNamespaceMode specifies how the namespaces of a shared mode cluster are laid out on the host cluster:
+	// "Single" syncs the objects of all the virtual namespaces to the cluster namespace, while "PerNamespace"
+	// syncs the objects of each virtual namespace to a dedicated host namespace named "<cluster>-<namespace>-<hash>".
+	// Defaults to "Single". Only used in shared mode. This field is immutable.
+	//
+	// +kubebuilder:default="Single"
+	// +kubebuilder:validation:XValidation:message="hostNamespaceMode is immutable",rule="self == oldSelf"
+	// +optional
+	HostNamespaceMode HostNamespaceMode `json:"hostNamespaceMode,omitempty"`
+
 	// Servers specifies the number of K3s pods to run in server (control plane) mode.
 	// Must be at least 1. Defaults to 1.
 	//
@@ -114,7 +126,7 @@ type ClusterSpec struct {
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
 
 	// PriorityClass specifies the priorityClassName for server/agent pods.
-	// In "shared" mode, this also applies to workloads.
+	// In "shared" mode, this is also used for the workloads that don't request a PriorityClass available in the host cluster.
 	//
 	// +optional
 	PriorityClass string `json:"priorityClass,omitempty"`
@@ -173,16 +185,48 @@ type ClusterSpec struct {
 	// +optional
 	MirrorHostNodes bool `json:"mirrorHostNodes,omitempty"`
 
+	// ExtendedResources specifies which resources of the host nodes, other than cpu, memory, pods and ephemeral-storage,
+	// are advertised on the virtual nodes, and under which name.
+	// If not specified, all the resources of the host nodes are advertised.
+	// This field is only relevant in "shared" mode.
+	//
+	// +optional
+	ExtendedResources *ExtendedResourcesConfig `json:"extendedResources,omitempty"`
+
+	// HostPodOverlay specifies a strategic merge patch, with the shape of a PodTemplateSpec, applied to the host pods
+	// of the workloads after their translation and the HostPodOverlay of the VirtualClusterPolicy, to add host-only
+	// settings such as a runtimeClassName, tolerations, sidecar containers or labels. The host pods are still checked
+	// against the VirtualClusterPolicy after the overlay, and the nodeSelector of the cluster can't be overridden.
+	// This field is only relevant in "shared" mode.
+	//
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	HostPodOverlay *runtime.RawExtension `json:"hostPodOverlay,omitempty"`
+
 	// CustomCAs specifies the cert/key pairs for custom CA certificates.
 	//
 	// +optional
 	CustomCAs *CustomCAs `json:"customCAs,omitempty"`
 
 	// Sync specifies the resources types that will be synced from virtual cluster to host cluster.
+	// It can only narrow the sync configuration of the VirtualClusterPolicy bound to the cluster, and the
+	// effective configuration is reported in the status.
 	//
 	// +kubebuilder:default={}
 	// +optional
 	Sync *SyncConfig `json:"sync,omitempty"`
+
+	// TTL is the lifetime of the cluster from its creation. The expired clusters are deleted.
+	//
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// ExpiresAt is the time when the cluster expires and is deleted. The earliest of ExpiresAt, TTL and the
+	// MaxLifetime of the VirtualClusterPolicy applies.
+	//
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
 }
 
 // SyncConfig will contain the resources that should be synced from virtual cluster to host cluster.
@@ -207,6 +251,11 @@ type SyncConfig struct {
 	// +kubebuilder:default={"enabled": false}
 	// +optional
 	Ingresses IngressSyncConfig `json:"ingresses"`
+	// GatewayRoutes resources sync configuration.
+	//
+	// +kubebuilder:default={"enabled": false}
+	// +optional
+	GatewayRoutes GatewayRouteSyncConfig `json:"gatewayRoutes"`
 	// PersistentVolumeClaims resources sync configuration.
 	//
 	// +kubebuilder:default={"enabled": true}
@@ -217,6 +266,16 @@ type SyncConfig struct {
 	// +kubebuilder:default={"enabled": false}
 	// +optional
 	PriorityClasses PriorityClassSyncConfig `json:"priorityClasses"`
+	// VolumeSnapshots resources sync configuration.
+	//
+	// +kubebuilder:default={"enabled": false}
+	// +optional
+	VolumeSnapshots VolumeSnapshotSyncConfig `json:"volumeSnapshots"`
+	// GarbageCollection configures the deletion of the orphaned host objects.
+	//
+	// +kubebuilder:default={"enabled": false}
+	// +optional
+	GarbageCollection GarbageCollectionConfig `json:"garbageCollection"`
 }
 
 // SecretSyncConfig specifies the sync options for services.
@@ -277,9 +336,59 @@ type IngressSyncConfig struct {
 	//
 	// +optional
 	Selector map[string]string `json:"selector,omitempty"`
+
+	// IngressClassMapping maps the ingressClassName of the Ingresses of the virtual cluster to the IngressClass
+	// used in the host cluster. If specified, only the Ingresses with a mapped ingressClassName are synced.
+	// The empty key can be used to map the Ingresses without an ingressClassName.
+	//
+	// +optional
+	IngressClassMapping map[string]string `json:"ingressClassMapping,omitempty"`
+}
+
+// GatewayRouteSyncConfig specifies the sync options for the Gateway API routes (HTTPRoutes, GRPCRoutes and TLSRoutes).
+type GatewayRouteSyncConfig struct {
+	// Enabled is an on/off switch for syncing resources.
+	//
+	// +kubebuilder:default=false
+	// +required
+	Enabled bool `json:"enabled"`
+
+	// Selector specifies set of labels of the resources that will be synced, if empty
+	// then all resources of the given type will be synced.
+	//
+	// +optional
+	Selector map[string]string `json:"selector,omitempty"`
+
+	// Gateways are the host Gateways the routes of the virtual cluster can be attached to.
+	// The parentRefs of the routes are matched by name with the Gateways, and the routes without parentRefs are attached
+	// to the first Gateway. Routes referencing other Gateways are not synced.
+	//
+	// +optional
+	Gateways []GatewayReference `json:"gateways,omitempty"`
+}
+
+// GatewayReference references a Gateway of the host cluster.
+type GatewayReference struct {
+	// Name is the name of the Gateway.
+	//
+	// +required
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the Gateway.
+	//
+	// +required
+	Namespace string `json:"namespace"`
+
+	// SectionName is the name of the listener of the Gateway the routes are attached to.
+	// If empty, the routes are attached to all the compatible listeners.
+	//
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
 }
 
 // PersistentVolumeClaimSyncConfig specifies the sync options for services.
+//
+// +kubebuilder:validation:XValidation:message="defaultStorageClassName must be mapped in storageClassMapping",rule="!has(self.defaultStorageClassName) || (has(self.storageClassMapping) && self.defaultStorageClassName in self.storageClassMapping)"
 type PersistentVolumeClaimSyncConfig struct {
 	// Enabled is an on/off switch for syncing resources.
 	//
@@ -292,6 +401,19 @@ type PersistentVolumeClaimSyncConfig struct {
 	//
 	// +optional
 	Selector map[string]string `json:"selector,omitempty"`
+
+	// StorageClassMapping maps the StorageClasses of the virtual cluster to the StorageClasses of the host cluster.
+	// If specified, a read-only StorageClass is created in the virtual cluster for each mapped class, and only the
+	// PersistentVolumeClaims with a mapped storageClassName are synced.
+	//
+	// +optional
+	StorageClassMapping map[string]string `json:"storageClassMapping,omitempty"`
+
+	// DefaultStorageClassName is the StorageClass of the virtual cluster used by the PersistentVolumeClaims
+	// without a storageClassName. It must be one of the StorageClasses of the StorageClassMapping.
+	//
+	// +optional
+	DefaultStorageClassName string `json:"defaultStorageClassName,omitempty"`
 }
 
 // PriorityClassSyncConfig specifies the sync options for services.
@@ -309,6 +431,60 @@ type PriorityClassSyncConfig struct {
 	Selector map[string]string `json:"selector,omitempty"`
 }
 
+// VolumeSnapshotSyncConfig specifies the sync options for the VolumeSnapshots.
+type VolumeSnapshotSyncConfig struct {
+	// Enabled is an on/off switch for syncing resources.
+	//
+	// +kubebuilder:default=false
+	// +required
+	Enabled bool `json:"enabled"`
+
+	// Selector specifies set of labels of the resources that will be synced, if empty
+	// then all resources of the given type will be synced.
+	//
+	// +optional
+	Selector map[string]string `json:"selector,omitempty"`
+
+	// VolumeSnapshotClassMapping maps the VolumeSnapshotClasses of the virtual cluster to the VolumeSnapshotClasses
+	// of the host cluster. If specified, only the VolumeSnapshots with a mapped volumeSnapshotClassName are synced.
+	//
+	// +optional
+	VolumeSnapshotClassMapping map[string]string `json:"volumeSnapshotClassMapping,omitempty"`
+}
+
+// GarbageCollectionConfig specifies the options for the garbage collection of the orphaned host objects,
+// i.e. the objects created by the virtual kubelet whose virtual objects don't exist anymore.
+type GarbageCollectionConfig struct {
+	// Enabled is an on/off switch for the garbage collection. It's disabled by default,
+	// since the orphaned host objects are deleted unless in dry-run mode.
+	//
+	// +kubebuilder:default=false
+	// +required
+	Enabled bool `json:"enabled"`
+
+	// DryRun reports the orphaned host objects without deleting them.
+	//
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// AdditionalKinds are the kinds of the orphaned host objects collected besides the default ones. The orphaned
+	// PersistentVolumeClaims are always reported, and only deleted if listed here and bound to a PersistentVolume
+	// with the Retain reclaim policy, so that their data is kept.
+	//
+	// +optional
+	AdditionalKinds []GarbageCollectedKind `json:"additionalKinds,omitempty"`
+}
+
+// GarbageCollectedKind is a kind of the orphaned host objects collected only if listed in the AdditionalKinds.
+//
+// +kubebuilder:validation:Enum=PersistentVolumeClaim
+type GarbageCollectedKind string
+
+const (
+	// PersistentVolumeClaimGarbageCollectedKind collects the orphaned host PersistentVolumeClaims.
+	PersistentVolumeClaimGarbageCollectedKind = GarbageCollectedKind("PersistentVolumeClaim")
+)
+
 // ClusterMode is the possible provisioning mode of a Cluster.
 //
 // +kubebuilder:validation:Enum=shared;virtual
@@ -323,6 +499,20 @@ const (
 	VirtualClusterMode = ClusterMode("virtual")
 )
 
+// HostNamespaceMode is the possible layout of the host namespaces of a shared mode Cluster.
+//
+// +kubebuilder:validation:Enum=Single;PerNamespace
+// +kubebuilder:default="Single"
+type HostNamespaceMode string
+
+const (
+	// SingleHostNamespaceMode syncs the objects of all the virtual namespaces to the cluster namespace.
+	SingleHostNamespaceMode = HostNamespaceMode("Single")
+
+	// PerNamespaceHostNamespaceMode syncs the objects of each virtual namespace to a dedicated host namespace.
+	PerNamespaceHostNamespaceMode = HostNamespaceMode("PerNamespace")
+)
+
 // PersistenceMode is the storage mode of a Cluster.
 //
 // +kubebuilder:default="dynamic"
@@ -431,6 +621,22 @@ type NodePortConfig struct {
 	ETCDPort *int32 `json:"etcdPort,omitempty"`
 }
 
+// ExtendedResourcesConfig specifies the extended resources of the host nodes advertised on the virtual nodes.
+type ExtendedResourcesConfig struct {
+	// Allowed is the list of host resources advertised on the virtual nodes (e.g. "nvidia.com/gpu", "hugepages-2Mi").
+	// The cpu, memory, pods and ephemeral-storage resources are always advertised. If empty, no other resource will be advertised.
+	//
+	// +optional
+	Allowed []v1.ResourceName `json:"allowed,omitempty"`
+
+	// Rename maps the name of an allowed host resource to the name advertised on the virtual nodes.
+	// Pods requesting the renamed resource will request the original one in the host cluster.
+	// The advertised names must be unique, and different from the resources always advertised.
+	//
+	// +optional
+	Rename map[v1.ResourceName]v1.ResourceName `json:"rename,omitempty"`
+}
+
 // CustomCAs specifies the cert/key pairs for custom CA certificates.
 type CustomCAs struct {
 	// Enabled toggles this feature on or off.
@@ -521,6 +727,28 @@ type ClusterStatus struct {
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 
+	// Quota reports the per-cluster quotas of the VirtualClusterPolicy bound to the cluster, and their usage.
+	//
+	// +optional
+	Quota *ClusterQuotaStatus `json:"quota,omitempty"`
+
+	// ExpiresAt is the time when the cluster expires, from its TTL, its ExpiresAt and the MaxLifetime of the
+	// VirtualClusterPolicy bound to it.
+	//
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// RemainingTime is the time left before the cluster expires, updated periodically.
+	//
+	// +optional
+	RemainingTime string `json:"remainingTime,omitempty"`
+
+	// Sync is the effective sync configuration of the cluster, merged from its own and the one of the
+	// VirtualClusterPolicy bound to it. It's the configuration used by the syncers of the virtual kubelet.
+	//
+	// +optional
+	Sync *SyncConfig `json:"sync,omitempty"`
+
 	// Phase is a high-level summary of the cluster's current lifecycle state.
 	//
 	// +kubebuilder:default="Unknown"
@@ -529,6 +757,19 @@ type ClusterStatus struct {
 	Phase ClusterPhase `json:"phase,omitempty"`
 }
 
+// ClusterQuotaStatus reports the per-cluster quotas of a cluster and their usage.
+type ClusterQuotaStatus struct {
+	// Workloads is the workloads quota of the cluster, and the resources used by the host pods of its workloads.
+	//
+	// +optional
+	Workloads *v1.ResourceQuotaStatus `json:"workloads,omitempty"`
+
+	// ControlPlane is the control plane quota of the cluster, and the resources requested by its servers and agents.
+	//
+	// +optional
+	ControlPlane *v1.ResourceQuotaStatus `json:"controlPlane,omitempty"`
+}
+
 // ClusterPhase is a high-level summary of the cluster's current lifecycle state.
 type ClusterPhase string
 
@@ -579,6 +820,18 @@ type VirtualClusterPolicy struct {
 
 // VirtualClusterPolicySpec defines the desired state of a VirtualClusterPolicy.
 type VirtualClusterPolicySpec struct {
+	// NamespaceSelector selects the namespaces bound to the policy, in addition to the ones with the
+	// "policy.k3k.io/policy-name" label. The namespaces labelled by hand are not selected.
+	//
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Priority resolves the conflicts between the policies selecting the same namespace with their NamespaceSelector.
+	// The namespace is bound to the policy with the highest priority, and to the first one by name if they have the same priority.
+	//
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
 	// Quota specifies the resource limits for clusters within a clusterpolicy.
 	//
 	// +optional
@@ -590,16 +843,53 @@ type VirtualClusterPolicySpec struct {
 	// +optional
 	Limit *v1.LimitRangeSpec `json:"limit,omitempty"`
 
-	// DefaultNodeSelector specifies the node selector that applies to all clusters (server + agent) in the target Namespace.
+	// ClusterQuotas specifies the quotas of each cluster in the target Namespace. Unlike the Quota, shared by all
+	// the clusters of the namespace, they are accounted separately for every cluster.
+	//
+	// +optional
+	ClusterQuotas *ClusterQuotas `json:"clusterQuotas,omitempty"`
+
+	// DefaultNodeSelector specifies the node selector of the clusters in the target Namespace not setting one.
+	// Deprecated: use Defaults.NodeSelector, that takes precedence.
 	//
 	// +optional
 	DefaultNodeSelector map[string]string `json:"defaultNodeSelector,omitempty"`
 
-	// DefaultPriorityClass specifies the priorityClassName applied to all pods of all clusters in the target Namespace.
+	// DefaultPriorityClass specifies the priorityClassName of the clusters in the target Namespace not setting one.
+	// Deprecated: use Defaults.PriorityClass, that takes precedence.
 	//
 	// +optional
 	DefaultPriorityClass string `json:"defaultPriorityClass,omitempty"`
 
+	// Defaults specifies the values applied to the clusters in the target Namespace leaving the fields unset.
+	// The values set by the clusters are kept, and a default changed in the policy updates the clusters still using it.
+	//
+	// +optional
+	Defaults *ClusterValues `json:"defaults,omitempty"`
+
+	// Enforced specifies the values applied to all the clusters in the target Namespace, overriding their own.
+	// The node selector and the limits are merged with the ones of the clusters.
+	//
+	// +optional
+	Enforced *ClusterValues `json:"enforced,omitempty"`
+
+	// PriorityClasses specifies the constraints on the PriorityClasses used by the workloads of the clusters in the target Namespace.
+	//
+	// +optional
+	PriorityClasses *PriorityClassPolicy `json:"priorityClasses,omitempty"`
+
+	// HostPodOverlay specifies a strategic merge patch, with the shape of a PodTemplateSpec, applied to the host pods
+	// of the workloads of the clusters in the target Namespace after their translation, to add host-only settings such as
+	// a runtimeClassName, tolerations, sidecar containers or labels. The HostPodOverlay of the clusters is applied after
+	// this one. The host pods are checked against the HostPodSecurity,
+	// the ImagePolicy and the PriorityClasses of the policy after the overlay, and the nodeSelector of the cluster can't be
+	// overridden.
+	//
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	HostPodOverlay *runtime.RawExtension `json:"hostPodOverlay,omitempty"`
+
 	// AllowedMode specifies the allowed cluster provisioning mode. Defaults to "shared".
 	//
 	// +kubebuilder:default=shared
@@ -607,23 +897,331 @@ type VirtualClusterPolicySpec struct {
 	// +optional
 	AllowedMode ClusterMode `json:"allowedMode,omitempty"`
 
+	// Constraints restricts the specifications of the clusters in the target Namespace. The clusters not satisfying
+	// them stay pending, with all the violations reported in their Ready condition.
+	//
+	// +optional
+	Constraints *ClusterConstraints `json:"constraints,omitempty"`
+
+	// MaxLifetime is the maximum lifetime of the clusters in the target Namespace from their creation,
+	// whatever their TTL and ExpiresAt. The expired clusters are deleted. The clusters already older than a new
+	// MaxLifetime are deleted after a warning period.
+	//
+	// +optional
+	MaxLifetime *metav1.Duration `json:"maxLifetime,omitempty"`
+
 	// DisableNetworkPolicy indicates whether to disable the creation of a default network policy for cluster isolation.
 	//
 	// +optional
 	DisableNetworkPolicy bool `json:"disableNetworkPolicy,omitempty"`
 
+	// NetworkPolicy specifies the traffic allowed by the default network policy, in addition to the traffic of the clusters
+	// within the namespace, to the addresses out of the cluster network and to the DNS of the host cluster.
+	//
+	// +optional
+	NetworkPolicy *NetworkPolicyConfig `json:"networkPolicy,omitempty"`
+
 	// PodSecurityAdmissionLevel specifies the pod security admission level applied to the pods in the namespace.
 	//
 	// +optional
 	PodSecurityAdmissionLevel *PodSecurityAdmissionLevel `json:"podSecurityAdmissionLevel,omitempty"`
 
-	// Sync specifies the resources types that will be synced from virtual cluster to host cluster.
+	// HostPodSecurity specifies the checks run by the virtual kubelet on the pods of the workloads before creating them
+	// in the host cluster, in addition to the pod security admission of the host namespace. The rejected pods are failed.
+	//
+	// +optional
+	HostPodSecurity *HostPodSecurityPolicy `json:"hostPodSecurity,omitempty"`
+
+	// ImagePolicy specifies the registries and the images allowed for the workloads of the clusters in the target Namespace,
+	// and the mirrors of the registries. It's enforced by the virtual kubelet in "shared" mode. In "virtual" mode only
+	// the mirrors are configured in the registries.yaml of the agents, and the AllowedRegistries and RequireDigest
+	// are not enforced.
+	//
+	// +optional
+	ImagePolicy *ImagePolicy `json:"imagePolicy,omitempty"`
+
+	// Sync specifies the resources types that will be synced from virtual cluster to host cluster, for the clusters
+	// in the target Namespace. A resource type is synced only if enabled by both the policy and the cluster,
+	// the selectors of the cluster are merged with the ones of the policy, and the mappings of the host
+	// classes and Gateways of the policy limit the ones of the cluster.
 	//
 	// +kubebuilder:default={}
 	// +optional
 	Sync *SyncConfig `json:"sync,omitempty"`
 }
 
+// PriorityClassPolicy specifies the constraints on the PriorityClasses used by the workloads of the virtual clusters.
+//
+// +kubebuilder:validation:XValidation:message="minValue must be lower than or equal to maxValue",rule="!has(self.minValue) || !has(self.maxValue) || self.minValue <= self.maxValue"
+type PriorityClassPolicy struct {
+	// MinValue is the minimum value allowed for the PriorityClasses synced from the virtual clusters.
+	//
+	// +optional
+	MinValue *int32 `json:"minValue,omitempty"`
+
+	// MaxValue is the maximum value allowed for the PriorityClasses synced from the virtual clusters.
+	//
+	// +optional
+	MaxValue *int32 `json:"maxValue,omitempty"`
+
+	// AllowedHostClasses is the list of the host PriorityClasses that can be requested by the pods of the virtual clusters,
+	// and used as the priorityClass of the clusters. If empty, any host PriorityClass can be used as the priorityClass of the clusters.
+	//
+	// +optional
+	AllowedHostClasses []string `json:"allowedHostClasses,omitempty"`
+}
+
+// ClusterQuotas define the quotas applied to each cluster bound to a VirtualClusterPolicy.
+// The resources have the names used by the ResourceQuotas, i.e. "pods", "requests.cpu" or "limits.memory".
+type ClusterQuotas struct {
+	// Workloads limits the resources used by the host pods of the workloads of a cluster in shared mode.
+	// It is enforced by the k3k-kubelet, and the pods exceeding it are kept pending until the resources are available.
+	//
+	// +optional
+	Workloads v1.ResourceList `json:"workloads,omitempty"`
+
+	// ControlPlane limits the resources requested by the servers of a cluster, and by its agents in virtual mode,
+	// computed from the serverLimit and the workerLimit. A cluster exceeding it fails the validation.
+	//
+	// +optional
+	ControlPlane v1.ResourceList `json:"controlPlane,omitempty"`
+}
+
+// ClusterValues specify the values of the fields of the clusters set by a VirtualClusterPolicy.
+type ClusterValues struct {
+	// Version is the K3s version of the clusters.
+	//
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// NodeSelector is the node selector of the servers and agents of the clusters.
+	//
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// PriorityClass is the priorityClassName of the servers and agents of the clusters.
+	//
+	// +optional
+	PriorityClass string `json:"priorityClass,omitempty"`
+
+	// ServerLimit is the resource limits of the servers of the clusters.
+	//
+	// +optional
+	ServerLimit v1.ResourceList `json:"serverLimit,omitempty"`
+
+	// WorkerLimit is the resource limits of the agents of the clusters.
+	//
+	// +optional
+	WorkerLimit v1.ResourceList `json:"workerLimit,omitempty"`
+}
+
+// ClusterConstraints specify the constraints on the specifications of the clusters bound to a VirtualClusterPolicy.
+// An empty allow-list allows all the values.
+type ClusterConstraints struct {
+	// AllowedPersistenceTypes is the list of the persistence types allowed for the clusters.
+	//
+	// +optional
+	AllowedPersistenceTypes []PersistenceMode `json:"allowedPersistenceTypes,omitempty"`
+
+	// MaxStorageRequestSize is the maximum storage size requested by the clusters with the dynamic persistence.
+	//
+	// +optional
+	MaxStorageRequestSize *resource.Quantity `json:"maxStorageRequestSize,omitempty"`
+
+	// AllowedExposeTypes is the list of the ways allowed to expose the API server of the clusters.
+	// The clusters not exposed are always allowed.
+	//
+	// +optional
+	AllowedExposeTypes []ExposeType `json:"allowedExposeTypes,omitempty"`
+
+	// MaxServers is the maximum number of servers of the clusters.
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxServers *int32 `json:"maxServers,omitempty"`
+
+	// MaxAgents is the maximum number of agents of the clusters.
+	//
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxAgents *int32 `json:"maxAgents,omitempty"`
+
+	// AllowedServerArgs is the list of the flags that the clusters can set in their serverArgs, i.e. "--disable".
+	//
+	// +optional
+	AllowedServerArgs []string `json:"allowedServerArgs,omitempty"`
+
+	// DeniedServerArgs is the list of the flags that the clusters can't set in their serverArgs.
+	//
+	// +optional
+	DeniedServerArgs []string `json:"deniedServerArgs,omitempty"`
+
+	// MinVersion is the minimum K3s version of the clusters, i.e. "v1.30".
+	//
+	// +optional
+	MinVersion string `json:"minVersion,omitempty"`
+
+	// MaxVersion is the maximum K3s version of the clusters, i.e. "v1.32". The patch releases of a
+	// minor version without patch are allowed.
+	//
+	// +optional
+	MaxVersion string `json:"maxVersion,omitempty"`
+
+	// DisallowMirrorHostNodes denies the mirroring of the host nodes in the clusters.
+	//
+	// +optional
+	DisallowMirrorHostNodes bool `json:"disallowMirrorHostNodes,omitempty"`
+}
+
+// ExposeType is the way of exposing the API server of a Cluster.
+//
+// +kubebuilder:validation:Enum=Ingress;LoadBalancer;NodePort
+type ExposeType string
+
+const (
+	// IngressExposeType exposes the API server through an Ingress.
+	IngressExposeType = ExposeType("Ingress")
+
+	// LoadBalancerExposeType exposes the API server through a LoadBalancer service.
+	LoadBalancerExposeType = ExposeType("LoadBalancer")
+
+	// NodePortExposeType exposes the API server through a NodePort service.
+	NodePortExposeType = ExposeType("NodePort")
+)
+
+// HostPodSecurityPolicy specifies the host-side security checks of the pods of the virtual clusters.
+// The denied values are always rejected, and an empty allow-list allows all the values not denied.
+type HostPodSecurityPolicy struct {
+	// AllowPrivileged allows the privileged containers.
+	//
+	// +optional
+	AllowPrivileged bool `json:"allowPrivileged,omitempty"`
+
+	// AllowHostNamespaces allows the pods to use the network, PID and IPC namespaces of the host nodes.
+	//
+	// +optional
+	AllowHostNamespaces bool `json:"allowHostNamespaces,omitempty"`
+
+	// AllowedHostPaths is the list of the path prefixes allowed for the hostPath volumes.
+	//
+	// +optional
+	AllowedHostPaths []string `json:"allowedHostPaths,omitempty"`
+
+	// DeniedHostPaths is the list of the path prefixes denied for the hostPath volumes.
+	//
+	// +optional
+	DeniedHostPaths []string `json:"deniedHostPaths,omitempty"`
+
+	// AllowedCapabilities is the list of the capabilities that the containers can add. "ALL" allows all of them.
+	//
+	// +optional
+	AllowedCapabilities []v1.Capability `json:"allowedCapabilities,omitempty"`
+
+	// DeniedCapabilities is the list of the capabilities that the containers can't add. "ALL" denies all of them.
+	//
+	// +optional
+	DeniedCapabilities []v1.Capability `json:"deniedCapabilities,omitempty"`
+
+	// AllowedHostPorts is the list of the host port ranges allowed for the containers.
+	//
+	// +optional
+	AllowedHostPorts []HostPortRange `json:"allowedHostPorts,omitempty"`
+
+	// DeniedHostPorts is the list of the host port ranges denied for the containers.
+	//
+	// +optional
+	DeniedHostPorts []HostPortRange `json:"deniedHostPorts,omitempty"`
+}
+
+// HostPortRange is an inclusive range of host ports.
+//
+// +kubebuilder:validation:XValidation:message="min must be lower than or equal to max",rule="self.min <= self.max"
+type HostPortRange struct {
+	// Min is the first port of the range.
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Min int32 `json:"min"`
+
+	// Max is the last port of the range.
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Max int32 `json:"max"`
+}
+
+// NetworkPolicyConfig specifies the additional traffic allowed by the network policy of a VirtualClusterPolicy.
+// The CIDRs can be IPv4 or IPv6.
+type NetworkPolicyConfig struct {
+	// AllowedEgressCIDRs is the list of the CIDRs reachable by the pods, including the ones in the cluster network
+	// of the host, e.g. internal registries.
+	//
+	// +optional
+	AllowedEgressCIDRs []string `json:"allowedEgressCIDRs,omitempty"`
+
+	// AllowedEgressNamespaces is the list of the host namespaces reachable by the pods, e.g. a shared monitoring namespace.
+	//
+	// +optional
+	AllowedEgressNamespaces []string `json:"allowedEgressNamespaces,omitempty"`
+
+	// RestrictIngress allows the incoming traffic only from the namespaces of the clusters and from the allowed
+	// ingress CIDRs and namespaces, instead of from everywhere.
+	//
+	// +optional
+	RestrictIngress bool `json:"restrictIngress,omitempty"`
+
+	// AllowedIngressCIDRs is the list of the CIDRs allowed to reach the pods when the ingress is restricted.
+	//
+	// +optional
+	AllowedIngressCIDRs []string `json:"allowedIngressCIDRs,omitempty"`
+
+	// AllowedIngressNamespaces is the list of the host namespaces allowed to reach the pods when the ingress is restricted,
+	// e.g. the namespace of the K3k controller and of the ingress controller.
+	//
+	// +optional
+	AllowedIngressNamespaces []string `json:"allowedIngressNamespaces,omitempty"`
+}
+
+// ImagePolicy specifies the images allowed for the workloads of the virtual clusters, and the mirrors of the registries.
+type ImagePolicy struct {
+	// AllowedRegistries is the list of the registries allowed for the images, optionally with a repository prefix,
+	// e.g. "registry.example.com" or "docker.io/library". The images are checked after the mirroring of their registry.
+	// An empty list allows all the registries.
+	//
+	// +optional
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+
+	// RequireDigest requires the images to be referenced by digest.
+	//
+	// +optional
+	RequireDigest bool `json:"requireDigest,omitempty"`
+
+	// RegistryMirrors is the list of the registries whose images are pulled from a mirror.
+	//
+	// +optional
+	RegistryMirrors []RegistryMirror `json:"registryMirrors,omitempty"`
+}
+
+// RegistryMirror rewrites the images of a registry to the ones of its mirror.
+type RegistryMirror struct {
+	// Registry is the registry mirrored, e.g. "docker.io".
+	//
+	// +kubebuilder:validation:MinLength=1
+	Registry string `json:"registry"`
+
+	// Mirror is the registry serving the images, optionally with a repository prefix,
+	// e.g. "mirror.example.com/docker-hub".
+	//
+	// +kubebuilder:validation:MinLength=1
+	Mirror string `json:"mirror"`
+
+	// Insecure pulls the images from the mirror over plain HTTP in "virtual" mode. In "shared" mode, the protocol is
+	// the one configured in the host nodes.
+	//
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+}
+
 // PodSecurityAdmissionLevel is the policy level applied to the pods in the namespace.
 //
 // +kubebuilder:validation:Enum=privileged;baseline;restricted
@@ -663,6 +1261,50 @@ type VirtualClusterPolicyStatus struct {
 	// +patchMergeKey=type
 	// +patchStrategy=merge
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// Namespaces reports the namespaces bound to the policy, with their clusters and the result of their reconciliation.
+	//
+	// +optional
+	Namespaces []PolicyNamespaceStatus `json:"namespaces,omitempty"`
+
+	// Quota is the aggregated status of the ResourceQuotas of the policy in the bound namespaces.
+	//
+	// +optional
+	Quota *v1.ResourceQuotaStatus `json:"quota,omitempty"`
+}
+
+// PolicyNamespaceStatus reports the reconciliation of a namespace bound to a VirtualClusterPolicy.
+type PolicyNamespaceStatus struct {
+	// Name is the name of the namespace.
+	Name string `json:"name"`
+
+	// Clusters are the names of the clusters in the namespace.
+	//
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+
+	// NetworkPolicy is the result of the reconciliation of the NetworkPolicy.
+	NetworkPolicy PolicyResourceStatus `json:"networkPolicy"`
+
+	// Quota is the result of the reconciliation of the ResourceQuota.
+	Quota PolicyResourceStatus `json:"quota"`
+
+	// LimitRange is the result of the reconciliation of the LimitRange.
+	LimitRange PolicyResourceStatus `json:"limitRange"`
+
+	// PodSecurityAdmission is the result of the reconciliation of the pod security admission labels.
+	PodSecurityAdmission PolicyResourceStatus `json:"podSecurityAdmission"`
+}
+
+// PolicyResourceStatus is the result of the reconciliation of a resource of a VirtualClusterPolicy in a namespace.
+type PolicyResourceStatus struct {
+	// Synced indicates whether the resource was reconciled successfully.
+	Synced bool `json:"synced"`
+
+	// Error is the error of the last reconciliation, if it failed.
+	//
+	// +optional
+	Error string `json:"error,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
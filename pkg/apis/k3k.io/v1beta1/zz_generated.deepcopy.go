@@ -52,6 +52,56 @@ func (in *Cluster) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterConstraints) DeepCopyInto(out *ClusterConstraints) {
+	*out = *in
+	if in.AllowedPersistenceTypes != nil {
+		in, out := &in.AllowedPersistenceTypes, &out.AllowedPersistenceTypes
+		*out = make([]PersistenceMode, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxStorageRequestSize != nil {
+		in, out := &in.MaxStorageRequestSize, &out.MaxStorageRequestSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.AllowedExposeTypes != nil {
+		in, out := &in.AllowedExposeTypes, &out.AllowedExposeTypes
+		*out = make([]ExposeType, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxServers != nil {
+		in, out := &in.MaxServers, &out.MaxServers
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxAgents != nil {
+		in, out := &in.MaxAgents, &out.MaxAgents
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AllowedServerArgs != nil {
+		in, out := &in.AllowedServerArgs, &out.AllowedServerArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeniedServerArgs != nil {
+		in, out := &in.DeniedServerArgs, &out.DeniedServerArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterConstraints.
+func (in *ClusterConstraints) DeepCopy() *ClusterConstraints {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterConstraints)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterList) DeepCopyInto(out *ClusterList) {
 	*out = *in
@@ -84,6 +134,60 @@ func (in *ClusterList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterQuotaStatus) DeepCopyInto(out *ClusterQuotaStatus) {
+	*out = *in
+	if in.Workloads != nil {
+		in, out := &in.Workloads, &out.Workloads
+		*out = new(v1.ResourceQuotaStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ControlPlane != nil {
+		in, out := &in.ControlPlane, &out.ControlPlane
+		*out = new(v1.ResourceQuotaStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterQuotaStatus.
+func (in *ClusterQuotaStatus) DeepCopy() *ClusterQuotaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterQuotaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterQuotas) DeepCopyInto(out *ClusterQuotas) {
+	*out = *in
+	if in.Workloads != nil {
+		in, out := &in.Workloads, &out.Workloads
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.ControlPlane != nil {
+		in, out := &in.ControlPlane, &out.ControlPlane
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterQuotas.
+func (in *ClusterQuotas) DeepCopy() *ClusterQuotas {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterQuotas)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 	*out = *in
@@ -163,6 +267,16 @@ func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 			(*out)[key] = val.DeepCopy()
 		}
 	}
+	if in.ExtendedResources != nil {
+		in, out := &in.ExtendedResources, &out.ExtendedResources
+		*out = new(ExtendedResourcesConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HostPodOverlay != nil {
+		in, out := &in.HostPodOverlay, &out.HostPodOverlay
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.CustomCAs != nil {
 		in, out := &in.CustomCAs, &out.CustomCAs
 		*out = new(CustomCAs)
@@ -173,6 +287,15 @@ func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 		*out = new(SyncConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterSpec.
@@ -200,6 +323,20 @@ func (in *ClusterStatus) DeepCopyInto(out *ClusterStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = new(ClusterQuotaStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Sync != nil {
+		in, out := &in.Sync, &out.Sync
+		*out = new(SyncConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterStatus.
@@ -212,6 +349,42 @@ func (in *ClusterStatus) DeepCopy() *ClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterValues) DeepCopyInto(out *ClusterValues) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ServerLimit != nil {
+		in, out := &in.ServerLimit, &out.ServerLimit
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.WorkerLimit != nil {
+		in, out := &in.WorkerLimit, &out.WorkerLimit
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterValues.
+func (in *ClusterValues) DeepCopy() *ClusterValues {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterValues)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigMapSyncConfig) DeepCopyInto(out *ConfigMapSyncConfig) {
 	*out = *in
@@ -316,6 +489,180 @@ func (in *ExposeConfig) DeepCopy() *ExposeConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExtendedResourcesConfig) DeepCopyInto(out *ExtendedResourcesConfig) {
+	*out = *in
+	if in.Allowed != nil {
+		in, out := &in.Allowed, &out.Allowed
+		*out = make([]v1.ResourceName, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rename != nil {
+		in, out := &in.Rename, &out.Rename
+		*out = make(map[v1.ResourceName]v1.ResourceName, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExtendedResourcesConfig.
+func (in *ExtendedResourcesConfig) DeepCopy() *ExtendedResourcesConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ExtendedResourcesConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GarbageCollectionConfig) DeepCopyInto(out *GarbageCollectionConfig) {
+	*out = *in
+	if in.AdditionalKinds != nil {
+		in, out := &in.AdditionalKinds, &out.AdditionalKinds
+		*out = make([]GarbageCollectedKind, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GarbageCollectionConfig.
+func (in *GarbageCollectionConfig) DeepCopy() *GarbageCollectionConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GarbageCollectionConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayReference) DeepCopyInto(out *GatewayReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayReference.
+func (in *GatewayReference) DeepCopy() *GatewayReference {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayRouteSyncConfig) DeepCopyInto(out *GatewayRouteSyncConfig) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Gateways != nil {
+		in, out := &in.Gateways, &out.Gateways
+		*out = make([]GatewayReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayRouteSyncConfig.
+func (in *GatewayRouteSyncConfig) DeepCopy() *GatewayRouteSyncConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayRouteSyncConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPodSecurityPolicy) DeepCopyInto(out *HostPodSecurityPolicy) {
+	*out = *in
+	if in.AllowedHostPaths != nil {
+		in, out := &in.AllowedHostPaths, &out.AllowedHostPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeniedHostPaths != nil {
+		in, out := &in.DeniedHostPaths, &out.DeniedHostPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedCapabilities != nil {
+		in, out := &in.AllowedCapabilities, &out.AllowedCapabilities
+		*out = make([]v1.Capability, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeniedCapabilities != nil {
+		in, out := &in.DeniedCapabilities, &out.DeniedCapabilities
+		*out = make([]v1.Capability, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedHostPorts != nil {
+		in, out := &in.AllowedHostPorts, &out.AllowedHostPorts
+		*out = make([]HostPortRange, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeniedHostPorts != nil {
+		in, out := &in.DeniedHostPorts, &out.DeniedHostPorts
+		*out = make([]HostPortRange, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPodSecurityPolicy.
+func (in *HostPodSecurityPolicy) DeepCopy() *HostPodSecurityPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPodSecurityPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPortRange) DeepCopyInto(out *HostPortRange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPortRange.
+func (in *HostPortRange) DeepCopy() *HostPortRange {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPortRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImagePolicy) DeepCopyInto(out *ImagePolicy) {
+	*out = *in
+	if in.AllowedRegistries != nil {
+		in, out := &in.AllowedRegistries, &out.AllowedRegistries
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RegistryMirrors != nil {
+		in, out := &in.RegistryMirrors, &out.RegistryMirrors
+		*out = make([]RegistryMirror, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImagePolicy.
+func (in *ImagePolicy) DeepCopy() *ImagePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ImagePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IngressConfig) DeepCopyInto(out *IngressConfig) {
 	*out = *in
@@ -348,6 +695,13 @@ func (in *IngressSyncConfig) DeepCopyInto(out *IngressSyncConfig) {
 			(*out)[key] = val
 		}
 	}
+	if in.IngressClassMapping != nil {
+		in, out := &in.IngressClassMapping, &out.IngressClassMapping
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressSyncConfig.
@@ -385,6 +739,41 @@ func (in *LoadBalancerConfig) DeepCopy() *LoadBalancerConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyConfig) DeepCopyInto(out *NetworkPolicyConfig) {
+	*out = *in
+	if in.AllowedEgressCIDRs != nil {
+		in, out := &in.AllowedEgressCIDRs, &out.AllowedEgressCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedEgressNamespaces != nil {
+		in, out := &in.AllowedEgressNamespaces, &out.AllowedEgressNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedIngressCIDRs != nil {
+		in, out := &in.AllowedIngressCIDRs, &out.AllowedIngressCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedIngressNamespaces != nil {
+		in, out := &in.AllowedIngressNamespaces, &out.AllowedIngressNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyConfig.
+func (in *NetworkPolicyConfig) DeepCopy() *NetworkPolicyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodePortConfig) DeepCopyInto(out *NodePortConfig) {
 	*out = *in
@@ -440,6 +829,13 @@ func (in *PersistentVolumeClaimSyncConfig) DeepCopyInto(out *PersistentVolumeCla
 			(*out)[key] = val
 		}
 	}
+	if in.StorageClassMapping != nil {
+		in, out := &in.StorageClassMapping, &out.StorageClassMapping
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PersistentVolumeClaimSyncConfig.
@@ -452,6 +848,75 @@ func (in *PersistentVolumeClaimSyncConfig) DeepCopy() *PersistentVolumeClaimSync
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyNamespaceStatus) DeepCopyInto(out *PolicyNamespaceStatus) {
+	*out = *in
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.NetworkPolicy = in.NetworkPolicy
+	out.Quota = in.Quota
+	out.LimitRange = in.LimitRange
+	out.PodSecurityAdmission = in.PodSecurityAdmission
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyNamespaceStatus.
+func (in *PolicyNamespaceStatus) DeepCopy() *PolicyNamespaceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyNamespaceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyResourceStatus) DeepCopyInto(out *PolicyResourceStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyResourceStatus.
+func (in *PolicyResourceStatus) DeepCopy() *PolicyResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PriorityClassPolicy) DeepCopyInto(out *PriorityClassPolicy) {
+	*out = *in
+	if in.MinValue != nil {
+		in, out := &in.MinValue, &out.MinValue
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxValue != nil {
+		in, out := &in.MaxValue, &out.MaxValue
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AllowedHostClasses != nil {
+		in, out := &in.AllowedHostClasses, &out.AllowedHostClasses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PriorityClassPolicy.
+func (in *PriorityClassPolicy) DeepCopy() *PriorityClassPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PriorityClassPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PriorityClassSyncConfig) DeepCopyInto(out *PriorityClassSyncConfig) {
 	*out = *in
@@ -474,6 +939,21 @@ func (in *PriorityClassSyncConfig) DeepCopy() *PriorityClassSyncConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryMirror) DeepCopyInto(out *RegistryMirror) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryMirror.
+func (in *RegistryMirror) DeepCopy() *RegistryMirror {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryMirror)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretSyncConfig) DeepCopyInto(out *SecretSyncConfig) {
 	*out = *in
@@ -525,8 +1005,11 @@ func (in *SyncConfig) DeepCopyInto(out *SyncConfig) {
 	in.ConfigMaps.DeepCopyInto(&out.ConfigMaps)
 	in.Secrets.DeepCopyInto(&out.Secrets)
 	in.Ingresses.DeepCopyInto(&out.Ingresses)
+	in.GatewayRoutes.DeepCopyInto(&out.GatewayRoutes)
 	in.PersistentVolumeClaims.DeepCopyInto(&out.PersistentVolumeClaims)
 	in.PriorityClasses.DeepCopyInto(&out.PriorityClasses)
+	in.VolumeSnapshots.DeepCopyInto(&out.VolumeSnapshots)
+	in.GarbageCollection.DeepCopyInto(&out.GarbageCollection)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncConfig.
@@ -601,6 +1084,11 @@ func (in *VirtualClusterPolicyList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtualClusterPolicySpec) DeepCopyInto(out *VirtualClusterPolicySpec) {
 	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Quota != nil {
 		in, out := &in.Quota, &out.Quota
 		*out = new(v1.ResourceQuotaSpec)
@@ -611,6 +1099,11 @@ func (in *VirtualClusterPolicySpec) DeepCopyInto(out *VirtualClusterPolicySpec)
 		*out = new(v1.LimitRangeSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ClusterQuotas != nil {
+		in, out := &in.ClusterQuotas, &out.ClusterQuotas
+		*out = new(ClusterQuotas)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.DefaultNodeSelector != nil {
 		in, out := &in.DefaultNodeSelector, &out.DefaultNodeSelector
 		*out = make(map[string]string, len(*in))
@@ -618,11 +1111,56 @@ func (in *VirtualClusterPolicySpec) DeepCopyInto(out *VirtualClusterPolicySpec)
 			(*out)[key] = val
 		}
 	}
+	if in.Defaults != nil {
+		in, out := &in.Defaults, &out.Defaults
+		*out = new(ClusterValues)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Enforced != nil {
+		in, out := &in.Enforced, &out.Enforced
+		*out = new(ClusterValues)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PriorityClasses != nil {
+		in, out := &in.PriorityClasses, &out.PriorityClasses
+		*out = new(PriorityClassPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HostPodOverlay != nil {
+		in, out := &in.HostPodOverlay, &out.HostPodOverlay
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Constraints != nil {
+		in, out := &in.Constraints, &out.Constraints
+		*out = new(ClusterConstraints)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxLifetime != nil {
+		in, out := &in.MaxLifetime, &out.MaxLifetime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(NetworkPolicyConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.PodSecurityAdmissionLevel != nil {
 		in, out := &in.PodSecurityAdmissionLevel, &out.PodSecurityAdmissionLevel
 		*out = new(PodSecurityAdmissionLevel)
 		**out = **in
 	}
+	if in.HostPodSecurity != nil {
+		in, out := &in.HostPodSecurity, &out.HostPodSecurity
+		*out = new(HostPodSecurityPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImagePolicy != nil {
+		in, out := &in.ImagePolicy, &out.ImagePolicy
+		*out = new(ImagePolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Sync != nil {
 		in, out := &in.Sync, &out.Sync
 		*out = new(SyncConfig)
@@ -650,6 +1188,18 @@ func (in *VirtualClusterPolicyStatus) DeepCopyInto(out *VirtualClusterPolicyStat
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]PolicyNamespaceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = new(v1.ResourceQuotaStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualClusterPolicyStatus.
@@ -661,3 +1211,32 @@ func (in *VirtualClusterPolicyStatus) DeepCopy() *VirtualClusterPolicyStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotSyncConfig) DeepCopyInto(out *VolumeSnapshotSyncConfig) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.VolumeSnapshotClassMapping != nil {
+		in, out := &in.VolumeSnapshotClassMapping, &out.VolumeSnapshotClassMapping
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotSyncConfig.
+func (in *VolumeSnapshotSyncConfig) DeepCopy() *VolumeSnapshotSyncConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotSyncConfig)
+	in.DeepCopyInto(out)
+	return out
+}
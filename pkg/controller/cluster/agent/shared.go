@@ -3,9 +3,12 @@ package agent
 import (
 	"context"
 	"crypto"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -27,6 +30,10 @@ import (
 const (
 	SharedNodeAgentName = "kubelet"
 	SharedNodeMode      = "shared"
+
+	// hostNamespacesChecksumAnnotation is the checksum of the dedicated host namespaces cached by the agents,
+	// to restart them when a host namespace is created.
+	hostNamespacesChecksumAnnotation = "k3k.io/host-namespaces-checksum"
 )
 
 type SharedAgent struct {
@@ -39,9 +46,10 @@ type SharedAgent struct {
 	kubeletPort      int
 	webhookPort      int
 	imagePullSecrets []string
+	hostNamespaces   []string
 }
 
-func NewSharedAgent(config *Config, serviceIP, image, imagePullPolicy, token string, kubeletPort, webhookPort int, imagePullSecrets []string) *SharedAgent {
+func NewSharedAgent(config *Config, serviceIP, image, imagePullPolicy, token string, kubeletPort, webhookPort int, imagePullSecrets, hostNamespaces []string) *SharedAgent {
 	return &SharedAgent{
 		Config:           config,
 		serviceIP:        serviceIP,
@@ -51,6 +59,7 @@ func NewSharedAgent(config *Config, serviceIP, image, imagePullPolicy, token str
 		kubeletPort:      kubeletPort,
 		webhookPort:      webhookPort,
 		imagePullSecrets: imagePullSecrets,
+		hostNamespaces:   hostNamespaces,
 	}
 }
 
@@ -58,6 +67,15 @@ func (s *SharedAgent) Name() string {
 	return controller.SafeConcatNameWithPrefix(s.cluster.Name, SharedNodeAgentName)
 }
 
+// Image returns the image of the agent, with its registry.
+func (s *SharedAgent) Image() string {
+	if s.imageRegistry != "" {
+		return s.imageRegistry + "/" + s.image
+	}
+
+	return s.image
+}
+
 func (s *SharedAgent) EnsureResources(ctx context.Context) error {
 	if err := errors.Join(
 		s.config(ctx),
@@ -80,7 +98,7 @@ func (s *SharedAgent) ensureObject(ctx context.Context, obj ctrlruntimeclient.Ob
 }
 
 func (s *SharedAgent) config(ctx context.Context) error {
-	config := sharedAgentData(s.cluster, s.Name(), s.token, s.serviceIP, s.kubeletPort, s.webhookPort)
+	config := sharedAgentData(s.cluster, s.Name(), s.token, s.serviceIP, s.kubeletPort, s.webhookPort, s.hostNamespaces)
 
 	configSecret := &v1.Secret{
 		TypeMeta: metav1.TypeMeta{
@@ -99,13 +117,13 @@ func (s *SharedAgent) config(ctx context.Context) error {
 	return s.ensureObject(ctx, configSecret)
 }
 
-func sharedAgentData(cluster *v1beta1.Cluster, serviceName, token, ip string, kubeletPort, webhookPort int) string {
+func sharedAgentData(cluster *v1beta1.Cluster, serviceName, token, ip string, kubeletPort, webhookPort int, hostNamespaces []string) string {
 	version := cluster.Spec.Version
 	if cluster.Spec.Version == "" {
 		version = cluster.Status.HostVersion
 	}
 
-	return fmt.Sprintf(`clusterName: %s
+	config := fmt.Sprintf(`clusterName: %s
 clusterNamespace: %s
 serverIP: %s
 serviceName: %s
@@ -115,6 +133,12 @@ version: %s
 webhookPort: %d
 kubeletPort: %d`,
 		cluster.Name, cluster.Namespace, ip, serviceName, token, cluster.Spec.MirrorHostNodes, version, webhookPort, kubeletPort)
+
+	if len(hostNamespaces) > 0 {
+		config += "\nhostNamespaces: " + strings.Join(hostNamespaces, ",")
+	}
+
+	return config
 }
 
 func (s *SharedAgent) daemonset(ctx context.Context) error {
@@ -147,6 +171,14 @@ func (s *SharedAgent) daemonset(ctx context.Context) error {
 		},
 	}
 
+	if len(s.hostNamespaces) > 0 {
+		checksum := sha256.Sum256([]byte(strings.Join(s.hostNamespaces, ",")))
+
+		deploy.Spec.Template.Annotations = map[string]string{
+			hostNamespacesChecksumAnnotation: hex.EncodeToString(checksum[:]),
+		}
+	}
+
 	return s.ensureObject(ctx, deploy)
 }
 
@@ -159,12 +191,6 @@ func (s *SharedAgent) podSpec() v1.PodSpec {
 		dnsPolicy = v1.DNSClusterFirstWithHostNet
 	}
 
-	image := s.image
-
-	if s.imageRegistry != "" {
-		image = s.imageRegistry + "/" + s.image
-	}
-
 	podSpec := v1.PodSpec{
 		HostNetwork:        hostNetwork,
 		DNSPolicy:          dnsPolicy,
@@ -211,7 +237,7 @@ func (s *SharedAgent) podSpec() v1.PodSpec {
 		Containers: []v1.Container{
 			{
 				Name:            s.Name(),
-				Image:           image,
+				Image:           s.Image(),
 				ImagePullPolicy: v1.PullPolicy(s.imagePullPolicy),
 				Resources: v1.ResourceRequirements{
 					Limits: v1.ResourceList{},
@@ -381,11 +407,31 @@ func (s *SharedAgent) role(ctx context.Context) error {
 				Resources: []string{"persistentvolumeclaims", "pods", "pods/log", "pods/attach", "pods/exec", "pods/ephemeralcontainers", "secrets", "configmaps", "services"},
 				Verbs:     []string{"*"},
 			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"resourcequotas", "limitranges"},
+				Verbs:     []string{"get", "watch", "list"},
+			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"events"},
+				Verbs:     []string{"create", "patch"},
+			},
 			{
 				APIGroups: []string{"networking.k8s.io"},
 				Resources: []string{"ingresses"},
 				Verbs:     []string{"*"},
 			},
+			{
+				APIGroups: []string{"gateway.networking.k8s.io"},
+				Resources: []string{"httproutes", "grpcroutes", "tlsroutes"},
+				Verbs:     []string{"*"},
+			},
+			{
+				APIGroups: []string{"snapshot.storage.k8s.io"},
+				Resources: []string{"volumesnapshots"},
+				Verbs:     []string{"*"},
+			},
 			{
 				APIGroups: []string{"k3k.io"},
 				Resources: []string{"clusters"},
@@ -13,12 +13,13 @@ import (
 
 func Test_sharedAgentData(t *testing.T) {
 	type args struct {
-		cluster     *v1beta1.Cluster
-		serviceName string
-		ip          string
-		kubeletPort int
-		webhookPort int
-		token       string
+		cluster        *v1beta1.Cluster
+		serviceName    string
+		ip             string
+		kubeletPort    int
+		webhookPort    int
+		token          string
+		hostNamespaces []string
 	}
 
 	tests := []struct {
@@ -119,11 +120,44 @@ func Test_sharedAgentData(t *testing.T) {
 				"webhookPort":      "9443",
 			},
 		},
+		{
+			name: "host namespaces",
+			args: args{
+				cluster: &v1beta1.Cluster{
+					ObjectMeta: v1.ObjectMeta{
+						Name:      "mycluster",
+						Namespace: "ns-1",
+					},
+					Spec: v1beta1.ClusterSpec{
+						Version:           "v1.2.3",
+						HostNamespaceMode: v1beta1.PerNamespaceHostNamespaceMode,
+					},
+				},
+				kubeletPort:    10250,
+				webhookPort:    9443,
+				ip:             "10.0.0.21",
+				serviceName:    "service-name",
+				token:          "dnjklsdjnksd892389238",
+				hostNamespaces: []string{"mycluster-default", "mycluster-kube-system"},
+			},
+			expectedData: map[string]string{
+				"clusterName":      "mycluster",
+				"clusterNamespace": "ns-1",
+				"serverIP":         "10.0.0.21",
+				"serviceName":      "service-name",
+				"token":            "dnjklsdjnksd892389238",
+				"version":          "v1.2.3",
+				"mirrorHostNodes":  "false",
+				"kubeletPort":      "10250",
+				"webhookPort":      "9443",
+				"hostNamespaces":   "mycluster-default,mycluster-kube-system",
+			},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			config := sharedAgentData(tt.args.cluster, tt.args.serviceName, tt.args.token, tt.args.ip, tt.args.kubeletPort, tt.args.webhookPort)
+			config := sharedAgentData(tt.args.cluster, tt.args.serviceName, tt.args.token, tt.args.ip, tt.args.kubeletPort, tt.args.webhookPort, tt.args.hostNamespaces)
 
 			data := make(map[string]string)
 			err := yaml.Unmarshal([]byte(config), data)
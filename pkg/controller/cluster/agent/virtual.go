@@ -2,9 +2,14 @@ package agent
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
+	"gopkg.in/yaml.v2"
 	"k8s.io/utils/ptr"
 
 	apps "k8s.io/api/apps/v1"
@@ -12,12 +17,22 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
 	"github.com/rancher/k3k/pkg/controller"
 )
 
 const (
 	VirtualNodeMode      = "virtual"
 	virtualNodeAgentName = "agent"
+
+	registriesFileName = "registries.yaml"
+
+	// configVolumeName is the volume of the agent pods mounting the configuration Secret.
+	configVolumeName = "config"
+
+	// registriesChecksumAnnotation is the checksum of the registries configuration of the agents,
+	// changed to restart them when the registry mirrors of the image policy change.
+	registriesChecksumAnnotation = "k3k.io/registries-checksum"
 )
 
 type VirtualAgent struct {
@@ -28,9 +43,10 @@ type VirtualAgent struct {
 	ImagePullPolicy  string
 	ImageRegistry    string
 	imagePullSecrets []string
+	imagePolicy      *v1beta1.ImagePolicy
 }
 
-func NewVirtualAgent(config *Config, serviceIP, token, Image, ImagePullPolicy string, imagePullSecrets []string) *VirtualAgent {
+func NewVirtualAgent(config *Config, serviceIP, token, Image, ImagePullPolicy string, imagePullSecrets []string, imagePolicy *v1beta1.ImagePolicy) *VirtualAgent {
 	return &VirtualAgent{
 		Config:           config,
 		serviceIP:        serviceIP,
@@ -38,6 +54,7 @@ func NewVirtualAgent(config *Config, serviceIP, token, Image, ImagePullPolicy st
 		Image:            Image,
 		ImagePullPolicy:  ImagePullPolicy,
 		imagePullSecrets: imagePullSecrets,
+		imagePolicy:      imagePolicy,
 	}
 }
 
@@ -61,7 +78,12 @@ func (v *VirtualAgent) ensureObject(ctx context.Context, obj ctrlruntimeclient.O
 }
 
 func (v *VirtualAgent) config(ctx context.Context) error {
-	config := virtualAgentData(v.serviceIP, v.token)
+	registries, err := registriesData(v.imagePolicy)
+	if err != nil {
+		return err
+	}
+
+	config := virtualAgentData(v.serviceIP, v.token, registries != "")
 
 	configSecret := &v1.Secret{
 		TypeMeta: metav1.TypeMeta{
@@ -77,18 +99,82 @@ func (v *VirtualAgent) config(ctx context.Context) error {
 		},
 	}
 
+	if registries != "" {
+		configSecret.Data[registriesFileName] = []byte(registries)
+	}
+
 	return v.ensureObject(ctx, configSecret)
 }
 
-func virtualAgentData(serviceIP, token string) string {
-	return fmt.Sprintf(`server: https://%s
+func virtualAgentData(serviceIP, token string, privateRegistry bool) string {
+	data := fmt.Sprintf(`server: https://%s
 token: %s
 with-node-id: true`, serviceIP, token)
+
+	if privateRegistry {
+		// the mirrored registries must not be reached when their mirror is unreachable
+		data += "\nprivate-registry: /opt/rancher/k3s/" + registriesFileName
+		data += "\ndisable-default-registry-endpoint: true"
+	}
+
+	return data
+}
+
+type registryMirror struct {
+	Endpoint []string          `yaml:"endpoint"`
+	Rewrite  map[string]string `yaml:"rewrite,omitempty"`
+}
+
+type registries struct {
+	Mirrors map[string]registryMirror `yaml:"mirrors"`
+}
+
+// registriesData returns the k3s registries configuration of the registry mirrors of the image policy,
+// or an empty string if there are no mirrors. The repository path of a mirror is prepended to the images.
+func registriesData(imagePolicy *v1beta1.ImagePolicy) (string, error) {
+	if imagePolicy == nil || len(imagePolicy.RegistryMirrors) == 0 {
+		return "", nil
+	}
+
+	config := registries{
+		Mirrors: make(map[string]registryMirror),
+	}
+
+	for _, mirror := range imagePolicy.RegistryMirrors {
+		host, path, _ := strings.Cut(strings.TrimSuffix(mirror.Mirror, "/"), "/")
+
+		scheme := "https://"
+		if mirror.Insecure {
+			scheme = "http://"
+		}
+
+		registryMirror := registryMirror{
+			Endpoint: []string{scheme + host},
+		}
+
+		if path != "" {
+			registryMirror.Rewrite = map[string]string{"^(.*)$": path + "/$1"}
+		}
+
+		config.Mirrors[strings.TrimSuffix(mirror.Registry, "/")] = registryMirror
+	}
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize registries: %w", err)
+	}
+
+	return string(out), nil
 }
 
 func (v *VirtualAgent) deployment(ctx context.Context) error {
 	image := controller.K3SImage(v.cluster, v.Image)
 
+	registries, err := registriesData(v.imagePolicy)
+	if err != nil {
+		return err
+	}
+
 	const name = "k3k-agent"
 
 	selector := metav1.LabelSelector{
@@ -121,6 +207,28 @@ func (v *VirtualAgent) deployment(ctx context.Context) error {
 		},
 	}
 
+	if registries != "" {
+		checksum := sha256.Sum256([]byte(registries))
+
+		deployment.Spec.Template.Annotations = map[string]string{
+			registriesChecksumAnnotation: hex.EncodeToString(checksum[:]),
+		}
+
+		volumes := deployment.Spec.Template.Spec.Volumes
+
+		index := slices.IndexFunc(volumes, func(volume v1.Volume) bool {
+			return volume.Name == configVolumeName
+		})
+		if index < 0 {
+			return fmt.Errorf("volume %q not found in the agent pod spec", configVolumeName)
+		}
+
+		volumes[index].Secret.Items = append(volumes[index].Secret.Items, v1.KeyToPath{
+			Key:  registriesFileName,
+			Path: registriesFileName,
+		})
+	}
+
 	return v.ensureObject(ctx, deployment)
 }
 
@@ -132,7 +240,7 @@ func (v *VirtualAgent) podSpec(image, name string, args []string, affinitySelect
 	podSpec := v1.PodSpec{
 		Volumes: []v1.Volume{
 			{
-				Name: "config",
+				Name: configVolumeName,
 				VolumeSource: v1.VolumeSource{
 					Secret: &v1.SecretVolumeSource{
 						SecretName: configSecretName(v.cluster.Name),
@@ -200,7 +308,7 @@ func (v *VirtualAgent) podSpec(image, name string, args []string, affinitySelect
 				Env: v.cluster.Spec.AgentEnvs,
 				VolumeMounts: []v1.VolumeMount{
 					{
-						Name:      "config",
+						Name:      configVolumeName,
 						MountPath: "/opt/rancher/k3s/",
 						ReadOnly:  false,
 					},
@@ -5,12 +5,15 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v2"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
 )
 
 func Test_virtualAgentData(t *testing.T) {
 	type args struct {
-		serviceIP string
-		token     string
+		serviceIP       string
+		token           string
+		privateRegistry bool
 	}
 
 	tests := []struct {
@@ -30,11 +33,26 @@ func Test_virtualAgentData(t *testing.T) {
 				"with-node-id": "true",
 			},
 		},
+		{
+			name: "config with private registry",
+			args: args{
+				serviceIP:       "10.0.0.21",
+				token:           "dnjklsdjnksd892389238",
+				privateRegistry: true,
+			},
+			expectedData: map[string]string{
+				"server":                            "https://10.0.0.21",
+				"token":                             "dnjklsdjnksd892389238",
+				"with-node-id":                      "true",
+				"private-registry":                  "/opt/rancher/k3s/registries.yaml",
+				"disable-default-registry-endpoint": "true",
+			},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			config := virtualAgentData(tt.args.serviceIP, tt.args.token)
+			config := virtualAgentData(tt.args.serviceIP, tt.args.token, tt.args.privateRegistry)
 
 			data := make(map[string]string)
 			err := yaml.Unmarshal([]byte(config), data)
@@ -44,3 +62,56 @@ func Test_virtualAgentData(t *testing.T) {
 		})
 	}
 }
+
+func Test_registriesData(t *testing.T) {
+	tests := []struct {
+		name         string
+		imagePolicy  *v1beta1.ImagePolicy
+		expectedData registries
+	}{
+		{
+			name: "no image policy",
+		},
+		{
+			name:        "no registry mirrors",
+			imagePolicy: &v1beta1.ImagePolicy{RequireDigest: true},
+		},
+		{
+			name: "registry mirrors",
+			imagePolicy: &v1beta1.ImagePolicy{
+				RegistryMirrors: []v1beta1.RegistryMirror{
+					{Registry: "docker.io", Mirror: "mirror.example.com/docker-hub"},
+					{Registry: "quay.io/", Mirror: "quay-mirror.example.com/"},
+					{Registry: "ghcr.io", Mirror: "ghcr-mirror.local:5000", Insecure: true},
+				},
+			},
+			expectedData: registries{
+				Mirrors: map[string]registryMirror{
+					"docker.io": {
+						Endpoint: []string{"https://mirror.example.com"},
+						Rewrite:  map[string]string{"^(.*)$": "docker-hub/$1"},
+					},
+					"quay.io": {
+						Endpoint: []string{"https://quay-mirror.example.com"},
+					},
+					"ghcr.io": {
+						Endpoint: []string{"http://ghcr-mirror.local:5000"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := registriesData(tt.imagePolicy)
+			assert.NoError(t, err)
+
+			var config registries
+			err = yaml.Unmarshal([]byte(data), &config)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedData, config)
+		})
+	}
+}
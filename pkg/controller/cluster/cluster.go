@@ -17,11 +17,13 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	apps "k8s.io/api/apps/v1"
@@ -33,6 +35,8 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	ctrlcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
 
+	"github.com/rancher/k3k/k3k-kubelet/controller/migration"
+	"github.com/rancher/k3k/k3k-kubelet/translate"
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
 	"github.com/rancher/k3k/pkg/controller"
 	"github.com/rancher/k3k/pkg/controller/cluster/agent"
@@ -75,6 +79,8 @@ type Config struct {
 type ClusterReconciler struct {
 	DiscoveryClient *discovery.DiscoveryClient
 	Client          client.Client
+	// MigrationClient is not cached, so that the objects migrated from the legacy names are not watched
+	MigrationClient client.Client
 	Scheme          *runtime.Scheme
 	PortAllocator   *agent.PortAllocator
 
@@ -93,6 +99,11 @@ func Add(ctx context.Context, mgr manager.Manager, config *Config, maxConcurrent
 		return errors.New("missing shared agent image")
 	}
 
+	migrationClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		return err
+	}
+
 	if eventRecorder == nil {
 		eventRecorder = mgr.GetEventRecorderFor(clusterController)
 	}
@@ -101,6 +112,7 @@ func Add(ctx context.Context, mgr manager.Manager, config *Config, maxConcurrent
 	reconciler := ClusterReconciler{
 		DiscoveryClient: discoveryClient,
 		Client:          mgr.GetClient(),
+		MigrationClient: migrationClient,
 		Scheme:          mgr.GetScheme(),
 		EventRecorder:   eventRecorder,
 		PortAllocator:   portAllocator,
@@ -119,6 +131,8 @@ func Add(ctx context.Context, mgr manager.Manager, config *Config, maxConcurrent
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1beta1.Cluster{}).
 		Watches(&v1.Namespace{}, namespaceEventHandler(&reconciler)).
+		Watches(&v1beta1.VirtualClusterPolicy{}, policyEventHandler(&reconciler), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1.Pod{}, workloadPodEventHandler(&reconciler), builder.WithPredicates(workloadPodPredicate)).
 		Owns(&apps.StatefulSet{}).
 		Owns(&v1.Service{}).
 		WithOptions(ctrlcontroller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}).
@@ -127,8 +141,13 @@ func Add(ctx context.Context, mgr manager.Manager, config *Config, maxConcurrent
 
 func namespaceEventHandler(r *ClusterReconciler) handler.Funcs {
 	return handler.Funcs{
-		// We don't need to update for create or delete events
-		CreateFunc: func(context.Context, event.CreateEvent, workqueue.TypedRateLimitingInterface[reconcile.Request]) {},
+		// When a dedicated host namespace of a Cluster is created, the Cluster is reconciled to grant its agent the access to it
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			if key, found := hostNamespaceCluster(e.Object); found {
+				q.Add(reconcile.Request{NamespacedName: key})
+			}
+		},
+		// We don't need to update for delete events
 		DeleteFunc: func(context.Context, event.DeleteEvent, workqueue.TypedRateLimitingInterface[reconcile.Request]) {},
 		// When a Namespace is updated, if it has the "policy.k3k.io/policy-name" label
 		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
@@ -139,6 +158,15 @@ func namespaceEventHandler(r *ClusterReconciler) handler.Funcs {
 				return
 			}
 
+			// the policy label of a dedicated host namespace is kept in sync with the one of the cluster namespace
+			if key, found := hostNamespaceCluster(newNs); found {
+				if oldNs.Labels[policy.PolicyNameLabelKey] != newNs.Labels[policy.PolicyNameLabelKey] {
+					q.Add(reconcile.Request{NamespacedName: key})
+				}
+
+				return
+			}
+
 			oldVCPName := oldNs.Labels[policy.PolicyNameLabelKey]
 			newVCPName := newNs.Labels[policy.PolicyNameLabelKey]
 
@@ -160,6 +188,26 @@ func namespaceEventHandler(r *ClusterReconciler) handler.Funcs {
 	}
 }
 
+// policyEventHandler enqueues the Clusters bound to a VirtualClusterPolicy when its spec changes.
+func policyEventHandler(r *ClusterReconciler) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, object client.Object) []reconcile.Request {
+		var clusterList v1beta1.ClusterList
+		if err := r.Client.List(ctx, &clusterList); err != nil {
+			return nil
+		}
+
+		var requests []reconcile.Request
+
+		for _, cluster := range clusterList.Items {
+			if cluster.Status.PolicyName == object.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&cluster)})
+			}
+		}
+
+		return requests
+	})
+}
+
 func (c *ClusterReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
 	log := ctrl.LoggerFrom(ctx)
 	log.Info("Reconciling Cluster")
@@ -216,6 +264,11 @@ func (c *ClusterReconciler) Reconcile(ctx context.Context, req reconcile.Request
 		}
 	}
 
+	// the expired clusters are deleted, even if they are not provisioned
+	if expired(&cluster) {
+		return reconcile.Result{}, c.deleteExpiredCluster(ctx, &cluster)
+	}
+
 	// if there was an error during the reconciliation, return
 	if reconcilerErr != nil {
 		if errors.Is(reconcilerErr, bootstrap.ErrServerNotReady) {
@@ -223,6 +276,11 @@ func (c *ClusterReconciler) Reconcile(ctx context.Context, req reconcile.Request
 			return reconcile.Result{RequeueAfter: time.Second * 10}, nil
 		}
 
+		if errors.Is(reconcilerErr, migration.ErrMigrationInProgress) {
+			log.V(1).Info("Migration of the host objects in progress, requeueing")
+			return reconcile.Result{RequeueAfter: time.Second * 10}, nil
+		}
+
 		return reconcile.Result{}, reconcilerErr
 	}
 
@@ -235,7 +293,7 @@ func (c *ClusterReconciler) Reconcile(ctx context.Context, req reconcile.Request
 		}
 	}
 
-	return reconcile.Result{}, nil
+	return reconcile.Result{RequeueAfter: hostNamespacesRequeueAfter(&cluster, expirationRequeueAfter(&cluster))}, nil
 }
 
 func (c *ClusterReconciler) reconcileCluster(ctx context.Context, cluster *v1beta1.Cluster) error {
@@ -256,15 +314,35 @@ func (c *ClusterReconciler) reconcile(ctx context.Context, cluster *v1beta1.Clus
 	policyName, found := ns.Labels[policy.PolicyNameLabelKey]
 	cluster.Status.PolicyName = policyName
 
+	var (
+		clusterPolicy *v1beta1.VirtualClusterPolicy
+		imagePolicy   *v1beta1.ImagePolicy
+	)
+
 	if found && policyName != "" {
-		var policy v1beta1.VirtualClusterPolicy
-		if err := c.Client.Get(ctx, client.ObjectKey{Name: policyName}, &policy); err != nil {
+		clusterPolicy = &v1beta1.VirtualClusterPolicy{}
+		if err := c.Client.Get(ctx, client.ObjectKey{Name: policyName}, clusterPolicy); err != nil {
 			return err
 		}
 
-		if err := c.validate(cluster, policy); err != nil {
+		// the expiration and the usage of the quotas are reported also when the cluster is not valid
+		c.reconcileExpiration(cluster, clusterPolicy)
+
+		c.reconcileSyncConfig(cluster, clusterPolicy)
+
+		if err := c.reconcileQuotaStatus(ctx, cluster, clusterPolicy); err != nil {
 			return err
 		}
+
+		imagePolicy = clusterPolicy.Spec.ImagePolicy
+	} else {
+		c.reconcileExpiration(cluster, nil)
+		cluster.Status.Quota = nil
+		c.reconcileSyncConfig(cluster, nil)
+	}
+
+	if err := c.validate(cluster, clusterPolicy); err != nil {
+		return err
 	}
 
 	// if the Version is not specified we will try to use the same Kubernetes version of the host.
@@ -319,7 +397,7 @@ func (c *ClusterReconciler) reconcile(ctx context.Context, cluster *v1beta1.Clus
 		}
 	}
 
-	if err := c.ensureNetworkPolicy(ctx, cluster); err != nil {
+	if err := c.ensureNetworkPolicy(ctx, cluster, cluster.Namespace); err != nil {
 		return err
 	}
 
@@ -338,7 +416,7 @@ func (c *ClusterReconciler) reconcile(ctx context.Context, cluster *v1beta1.Clus
 		return err
 	}
 
-	if err := c.ensureAgent(ctx, cluster, serviceIP, token); err != nil {
+	if err := c.ensureAgent(ctx, cluster, imagePolicy, serviceIP, token); err != nil {
 		return err
 	}
 
@@ -354,6 +432,10 @@ func (c *ClusterReconciler) reconcile(ctx context.Context, cluster *v1beta1.Clus
 		return err
 	}
 
+	if err := c.reconcileHostNamespaces(ctx, cluster); err != nil {
+		return err
+	}
+
 	return c.bindClusterRoles(ctx, cluster)
 }
 
@@ -464,9 +546,10 @@ func (c *ClusterReconciler) createClusterConfigs(ctx context.Context, cluster *v
 	return nil
 }
 
-func (c *ClusterReconciler) ensureNetworkPolicy(ctx context.Context, cluster *v1beta1.Cluster) error {
+// ensureNetworkPolicy ensures the network policy of the cluster in its namespace, or in one of its dedicated host namespaces.
+func (c *ClusterReconciler) ensureNetworkPolicy(ctx context.Context, cluster *v1beta1.Cluster, namespace string) error {
 	log := ctrl.LoggerFrom(ctx)
-	log.V(1).Info("Ensuring network policy")
+	log.V(1).Info("Ensuring network policy", "namespace", namespace)
 
 	networkPolicyName := controller.SafeConcatNameWithPrefix(cluster.Name)
 
@@ -475,17 +558,48 @@ func (c *ClusterReconciler) ensureNetworkPolicy(ctx context.Context, cluster *v1
 		netpol := &networkingv1.NetworkPolicy{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      networkPolicyName,
-				Namespace: cluster.Namespace,
+				Namespace: namespace,
 			},
 		}
 
 		return client.IgnoreNotFound(c.Client.Delete(ctx, netpol))
 	}
 
+	// the cluster CIDR of a dual-stack cluster has a CIDR for each IP family
+	egressPeers := policy.ExternalPeers(strings.Split(cluster.Status.ClusterCIDR, ","))
+	egressPeers = append(egressPeers,
+		networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"kubernetes.io/metadata.name": cluster.Namespace,
+				},
+			},
+		},
+		networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					translate.ClusterNamespaceLabel: cluster.Namespace,
+				},
+			},
+		},
+		networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"kubernetes.io/metadata.name": metav1.NamespaceSystem,
+				},
+			},
+			PodSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"k8s-app": "kube-dns",
+				},
+			},
+		},
+	)
+
 	expectedNetworkPolicy := &networkingv1.NetworkPolicy{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      controller.SafeConcatNameWithPrefix(cluster.Name),
-			Namespace: cluster.Namespace,
+			Name:      networkPolicyName,
+			Namespace: namespace,
 		},
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "NetworkPolicy",
@@ -501,33 +615,7 @@ func (c *ClusterReconciler) ensureNetworkPolicy(ctx context.Context, cluster *v1
 			},
 			Egress: []networkingv1.NetworkPolicyEgressRule{
 				{
-					To: []networkingv1.NetworkPolicyPeer{
-						{
-							IPBlock: &networkingv1.IPBlock{
-								CIDR:   "0.0.0.0/0",
-								Except: []string{cluster.Status.ClusterCIDR},
-							},
-						},
-						{
-							NamespaceSelector: &metav1.LabelSelector{
-								MatchLabels: map[string]string{
-									"kubernetes.io/metadata.name": cluster.Namespace,
-								},
-							},
-						},
-						{
-							NamespaceSelector: &metav1.LabelSelector{
-								MatchLabels: map[string]string{
-									"kubernetes.io/metadata.name": metav1.NamespaceSystem,
-								},
-							},
-							PodSelector: &metav1.LabelSelector{
-								MatchLabels: map[string]string{
-									"k8s-app": "kube-dns",
-								},
-							},
-						},
-					},
+					To: egressPeers,
 				},
 			},
 		},
@@ -536,7 +624,7 @@ func (c *ClusterReconciler) ensureNetworkPolicy(ctx context.Context, cluster *v1
 	currentNetworkPolicy := expectedNetworkPolicy.DeepCopy()
 
 	result, err := controllerutil.CreateOrUpdate(ctx, c.Client, currentNetworkPolicy, func() error {
-		if err := controllerutil.SetControllerReference(cluster, currentNetworkPolicy, c.Scheme); err != nil {
+		if err := translate.SetClusterOwner(cluster, currentNetworkPolicy, c.Scheme); err != nil {
 			return err
 		}
 
@@ -662,12 +750,14 @@ func (c *ClusterReconciler) server(ctx context.Context, cluster *v1beta1.Cluster
 	return err
 }
 
-func (c *ClusterReconciler) bindClusterRoles(ctx context.Context, cluster *v1beta1.Cluster) error {
-	clusterRoles := []string{"k3k-kubelet-node", "k3k-priorityclass"}
+// agentClusterRoles are the ClusterRoles bound to the service account of the agent of the cluster.
+// The access to the dedicated host namespaces is granted with a RoleBinding in each of them.
+var agentClusterRoles = []string{"k3k-kubelet-node", "k3k-priorityclass"}
 
+func (c *ClusterReconciler) bindClusterRoles(ctx context.Context, cluster *v1beta1.Cluster) error {
 	var err error
 
-	for _, clusterRole := range clusterRoles {
+	for _, clusterRole := range agentClusterRoles {
 		var clusterRoleBinding rbacv1.ClusterRoleBinding
 		if getErr := c.Client.Get(ctx, types.NamespacedName{Name: clusterRole}, &clusterRoleBinding); getErr != nil {
 			err = errors.Join(err, fmt.Errorf("failed to get or find %s ClusterRoleBinding: %w", clusterRole, getErr))
@@ -692,12 +782,12 @@ func (c *ClusterReconciler) bindClusterRoles(ctx context.Context, cluster *v1bet
 	return err
 }
 
-func (c *ClusterReconciler) ensureAgent(ctx context.Context, cluster *v1beta1.Cluster, serviceIP, token string) error {
+func (c *ClusterReconciler) ensureAgent(ctx context.Context, cluster *v1beta1.Cluster, imagePolicy *v1beta1.ImagePolicy, serviceIP, token string) error {
 	config := agent.NewConfig(cluster, c.Client, c.Scheme)
 
 	var agentEnsurer agent.ResourceEnsurer
 	if cluster.Spec.Mode == agent.VirtualNodeMode {
-		agentEnsurer = agent.NewVirtualAgent(config, serviceIP, token, c.VirtualAgentImage, c.VirtualAgentImagePullPolicy, c.AgentImagePullSecrets)
+		agentEnsurer = agent.NewVirtualAgent(config, serviceIP, token, c.VirtualAgentImage, c.VirtualAgentImagePullPolicy, c.AgentImagePullSecrets, imagePolicy)
 	} else {
 		// Assign port from pool if shared agent enabled mirroring of host nodes
 		kubeletPort := 10250
@@ -721,35 +811,111 @@ func (c *ClusterReconciler) ensureAgent(ctx context.Context, cluster *v1beta1.Cl
 			cluster.Status.WebhookPort = webhookPort
 		}
 
-		agentEnsurer = agent.NewSharedAgent(config, serviceIP, c.SharedAgentImage, c.SharedAgentImagePullPolicy, token, kubeletPort, webhookPort, c.AgentImagePullSecrets)
+		hostNamespaces, err := c.hostNamespaceNames(ctx, cluster)
+		if err != nil {
+			return err
+		}
+
+		sharedAgent := agent.NewSharedAgent(config, serviceIP, c.SharedAgentImage, c.SharedAgentImagePullPolicy, token, kubeletPort, webhookPort, c.AgentImagePullSecrets, hostNamespaces)
+
+		if err := c.migrateHostObjects(ctx, cluster, sharedAgent); err != nil {
+			return fmt.Errorf("failed to migrate the names of the host objects: %w", err)
+		}
+
+		agentEnsurer = sharedAgent
 	}
 
 	return agentEnsurer.EnsureResources(ctx)
 }
 
-func (c *ClusterReconciler) validate(cluster *v1beta1.Cluster, policy v1beta1.VirtualClusterPolicy) error {
+// validate returns an error wrapping ErrClusterValidation with all the violations of the cluster, including the ones
+// of the policy bound to its namespace, if any.
+func (c *ClusterReconciler) validate(cluster *v1beta1.Cluster, policy *v1beta1.VirtualClusterPolicy) error {
 	if cluster.Name == ClusterInvalidName {
 		return fmt.Errorf("%w: invalid cluster name %q", ErrClusterValidation, cluster.Name)
 	}
 
-	if cluster.Spec.Mode != policy.Spec.AllowedMode {
-		return fmt.Errorf("%w: mode %q is not allowed by the policy %q", ErrClusterValidation, cluster.Spec.Mode, policy.Name)
+	// all the violations are collected, to report them at once
+	var errs []error
+
+	if policy != nil {
+		errs = append(errs, policyViolations(cluster, policy)...)
+	}
+
+	if err := validateExtendedResources(cluster.Spec.ExtendedResources); err != nil {
+		errs = append(errs, err)
 	}
 
 	if cluster.Spec.CustomCAs != nil && cluster.Spec.CustomCAs.Enabled {
 		if err := c.validateCustomCACerts(cluster.Spec.CustomCAs.Sources); err != nil {
-			return fmt.Errorf("%w: %w", ErrClusterValidation, err)
+			errs = append(errs, err)
 		}
 	}
 
-	// validate sync policy
-	if !equality.Semantic.DeepEqual(cluster.Spec.Sync, policy.Spec.Sync) {
-		return fmt.Errorf("sync configuration %v is not allowed by the policy %q", cluster.Spec.Sync, policy.Name)
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrClusterValidation, errors.Join(errs...))
 	}
 
 	return nil
 }
 
+// policyViolations returns the violations of the policy by the cluster.
+func policyViolations(cluster *v1beta1.Cluster, policy *v1beta1.VirtualClusterPolicy) []error {
+	var errs []error
+
+	if cluster.Spec.Mode != policy.Spec.AllowedMode {
+		errs = append(errs, fmt.Errorf("mode %q is not allowed by the policy %q", cluster.Spec.Mode, policy.Name))
+	}
+
+	if priorityClasses := policy.Spec.PriorityClasses; priorityClasses != nil && len(priorityClasses.AllowedHostClasses) > 0 &&
+		cluster.Spec.PriorityClass != "" && !slices.Contains(priorityClasses.AllowedHostClasses, cluster.Spec.PriorityClass) {
+		errs = append(errs, fmt.Errorf("priorityClass %q is not allowed by the policy %q", cluster.Spec.PriorityClass, policy.Name))
+	}
+
+	if policy.Spec.Constraints != nil {
+		errs = append(errs, validateConstraints(cluster, policy)...)
+	}
+
+	if clusterQuotas := policy.Spec.ClusterQuotas; clusterQuotas != nil && len(clusterQuotas.ControlPlane) > 0 {
+		if err := validateControlPlaneQuota(cluster, policy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// validateExtendedResources returns an error if two allowed extended resources are advertised on the virtual nodes
+// with the same name, or with the name of a resource always advertised.
+func validateExtendedResources(config *v1beta1.ExtendedResourcesConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	advertised := make(map[v1.ResourceName]v1.ResourceName, len(controller.CoreResources))
+	for _, resourceName := range controller.CoreResources {
+		advertised[resourceName] = resourceName
+	}
+
+	var errs []error
+
+	for _, resourceName := range config.Allowed {
+		virtualName := resourceName
+		if renamed := config.Rename[resourceName]; renamed != "" {
+			virtualName = renamed
+		}
+
+		if other, found := advertised[virtualName]; found {
+			errs = append(errs, fmt.Errorf("extended resource %q can't be advertised as %q, already used by %q", resourceName, virtualName, other))
+			continue
+		}
+
+		advertised[virtualName] = resourceName
+	}
+
+	return errors.Join(errs...)
+}
+
 // lookupServiceCIDR attempts to determine the cluster's service CIDR.
 // It first attempts to create a failing Service (with an invalid cluster IP)and extracts the expected CIDR from the resulting error.
 // If that fails, it searches the 'kube-apiserver' Pod's arguments for the --service-cluster-ip-range flag.
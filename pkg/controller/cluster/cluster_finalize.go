@@ -38,6 +38,11 @@ func (c *ClusterReconciler) finalizeCluster(ctx context.Context, cluster *v1beta
 		return reconcile.Result{}, err
 	}
 
+	// the dedicated host namespaces can't be owned by the Cluster, they are deleted with the synced objects in them
+	if err := c.deleteHostNamespaces(ctx, cluster); err != nil {
+		return reconcile.Result{}, err
+	}
+
 	// Deallocate ports for kubelet and webhook if used
 	if cluster.Spec.Mode == v1beta1.SharedClusterMode && cluster.Spec.MirrorHostNodes {
 		log.V(1).Info("dellocating ports for kubelet and webhook")
@@ -67,11 +72,9 @@ func (c *ClusterReconciler) unbindClusterRoles(ctx context.Context, cluster *v1b
 	log := ctrl.LoggerFrom(ctx)
 	log.V(1).Info("Unbinding ClusterRoles")
 
-	clusterRoles := []string{"k3k-kubelet-node", "k3k-priorityclass"}
-
 	var err error
 
-	for _, clusterRole := range clusterRoles {
+	for _, clusterRole := range agentClusterRoles {
 		var clusterRoleBinding rbacv1.ClusterRoleBinding
 		if getErr := c.Client.Get(ctx, types.NamespacedName{Name: clusterRole}, &clusterRoleBinding); getErr != nil {
 			err = errors.Join(err, fmt.Errorf("failed to get or find %s ClusterRoleBinding: %w", clusterRole, getErr))
@@ -79,6 +79,9 @@ var _ = BeforeSuite(func() {
 	err = cluster.Add(ctx, mgr, clusterConfig, 50, portAllocator, &record.FakeRecorder{})
 	Expect(err).NotTo(HaveOccurred())
 
+	err = cluster.AddIngressController(ctx, mgr, 50)
+	Expect(err).NotTo(HaveOccurred())
+
 	go func() {
 		defer GinkgoRecover()
 		err = mgr.Start(ctx)
@@ -5,16 +5,21 @@ import (
 	"fmt"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
 	k3kcontroller "github.com/rancher/k3k/pkg/controller"
+	"github.com/rancher/k3k/pkg/controller/cluster"
 	"github.com/rancher/k3k/pkg/controller/cluster/server"
+	"github.com/rancher/k3k/pkg/controller/policy"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -103,6 +108,79 @@ var _ = Describe("Cluster Controller", Label("controller"), Label("Cluster"), fu
 				Expect(spec.Ingress).To(Equal([]networkingv1.NetworkPolicyIngressRule{{}}))
 			})
 
+			It("will allow the egress out of the cluster network for each IP family", func() {
+				cluster := &v1beta1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						GenerateName: "cluster-",
+						Namespace:    namespace,
+					},
+					Spec: v1beta1.ClusterSpec{
+						ClusterCIDR: "10.42.0.0/16,fd42::/56",
+					},
+				}
+
+				Expect(k8sClient.Create(ctx, cluster)).To(Succeed())
+
+				networkPolicy := &networkingv1.NetworkPolicy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      k3kcontroller.SafeConcatNameWithPrefix(cluster.Name),
+						Namespace: cluster.Namespace,
+					},
+				}
+
+				Eventually(func() error {
+					return k8sClient.Get(ctx, client.ObjectKeyFromObject(networkPolicy), networkPolicy)
+				}).
+					WithTimeout(time.Second * 30).
+					WithPolling(time.Second).
+					Should(Succeed())
+
+				Expect(networkPolicy.Spec.Egress).To(HaveLen(1))
+				Expect(networkPolicy.Spec.Egress[0].To).To(ContainElements(
+					networkingv1.NetworkPolicyPeer{
+						IPBlock: &networkingv1.IPBlock{CIDR: "0.0.0.0/0", Except: []string{"10.42.0.0/16"}},
+					},
+					networkingv1.NetworkPolicyPeer{
+						IPBlock: &networkingv1.IPBlock{CIDR: "::/0", Except: []string{"fd42::/56"}},
+					},
+				))
+			})
+
+			It("will not be valid with duplicate names of the extended resources", func() {
+				k3kCluster := &v1beta1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						GenerateName: "cluster-",
+						Namespace:    namespace,
+					},
+					Spec: v1beta1.ClusterSpec{
+						ExtendedResources: &v1beta1.ExtendedResourcesConfig{
+							Allowed: []corev1.ResourceName{"example.com/gpu-a", "example.com/gpu-b"},
+							Rename: map[corev1.ResourceName]corev1.ResourceName{
+								"example.com/gpu-a": "tenant.io/gpu",
+								"example.com/gpu-b": "tenant.io/gpu",
+							},
+						},
+					},
+				}
+
+				err := k8sClient.Create(ctx, k3kCluster)
+				Expect(err).To(Not(HaveOccurred()))
+
+				Eventually(func(g Gomega) {
+					err := k8sClient.Get(ctx, client.ObjectKeyFromObject(k3kCluster), k3kCluster)
+					g.Expect(err).To(Not(HaveOccurred()))
+					g.Expect(k3kCluster.Status.Phase).To(Equal(v1beta1.ClusterPending))
+
+					cond := meta.FindStatusCondition(k3kCluster.Status.Conditions, cluster.ConditionReady)
+					g.Expect(cond).To(Not(BeNil()))
+					g.Expect(cond.Reason).To(Equal(cluster.ReasonValidationFailed))
+					g.Expect(cond.Message).To(ContainSubstring(`extended resource "example.com/gpu-b" can't be advertised as "tenant.io/gpu"`))
+				}).
+					WithTimeout(time.Second * 30).
+					WithPolling(time.Second).
+					Should(Succeed())
+			})
+
 			When("exposing the cluster with nodePort", func() {
 				It("will have a NodePort service", func() {
 					cluster := &v1beta1.Cluster{
@@ -296,6 +374,476 @@ var _ = Describe("Cluster Controller", Label("controller"), Label("Cluster"), fu
 					Expect(err).To(HaveOccurred())
 				})
 			})
+
+			When("setting a TTL", func() {
+				It("will report the expiration time", func() {
+					k3kCluster := &v1beta1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							GenerateName: "cluster-",
+							Namespace:    namespace,
+						},
+						Spec: v1beta1.ClusterSpec{
+							TTL: &metav1.Duration{Duration: time.Hour * 2},
+						},
+					}
+
+					err := k8sClient.Create(ctx, k3kCluster)
+					Expect(err).To(Not(HaveOccurred()))
+
+					Eventually(func(g Gomega) {
+						err := k8sClient.Get(ctx, client.ObjectKeyFromObject(k3kCluster), k3kCluster)
+						g.Expect(err).To(Not(HaveOccurred()))
+
+						g.Expect(k3kCluster.Status.ExpiresAt).To(Not(BeNil()))
+						g.Expect(k3kCluster.Status.ExpiresAt.Time).To(BeTemporally("==", k3kCluster.CreationTimestamp.Add(time.Hour*2)))
+						g.Expect(k3kCluster.Status.RemainingTime).To(Not(BeEmpty()))
+					}).
+						WithTimeout(time.Second * 30).
+						WithPolling(time.Second).
+						Should(Succeed())
+
+					// the cluster is not close to its expiration
+					Expect(meta.FindStatusCondition(k3kCluster.Status.Conditions, cluster.ConditionExpiring)).To(BeNil())
+				})
+
+				It("will be deleted when expired", func() {
+					k3kCluster := &v1beta1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							GenerateName: "cluster-",
+							Namespace:    namespace,
+						},
+						Spec: v1beta1.ClusterSpec{
+							ExpiresAt: ptr.To(metav1.NewTime(time.Now().Add(-time.Minute))),
+						},
+					}
+
+					err := k8sClient.Create(ctx, k3kCluster)
+					Expect(err).To(Not(HaveOccurred()))
+
+					// the cluster is deleted, and finalized through the usual path
+					Eventually(func() bool {
+						err := k8sClient.Get(ctx, client.ObjectKeyFromObject(k3kCluster), k3kCluster)
+						if apierrors.IsNotFound(err) {
+							return true
+						}
+
+						Expect(err).To(Not(HaveOccurred()))
+						return !k3kCluster.DeletionTimestamp.IsZero()
+					}).
+						WithTimeout(time.Second * 30).
+						WithPolling(time.Second).
+						Should(BeTrue())
+				})
+			})
+
+			When("bound to a policy with per-cluster quotas", func() {
+				var clusterPolicy *v1beta1.VirtualClusterPolicy
+
+				BeforeEach(func() {
+					clusterPolicy = &v1beta1.VirtualClusterPolicy{
+						ObjectMeta: metav1.ObjectMeta{GenerateName: "policy-"},
+						Spec: v1beta1.VirtualClusterPolicySpec{
+							ClusterQuotas: &v1beta1.ClusterQuotas{
+								ControlPlane: corev1.ResourceList{
+									corev1.ResourceLimitsCPU: resource.MustParse("1"),
+								},
+								Workloads: corev1.ResourceList{
+									corev1.ResourcePods: resource.MustParse("10"),
+								},
+							},
+						},
+					}
+
+					err := k8sClient.Create(ctx, clusterPolicy)
+					Expect(err).To(Not(HaveOccurred()))
+
+					var ns corev1.Namespace
+					err = k8sClient.Get(ctx, client.ObjectKey{Name: namespace}, &ns)
+					Expect(err).To(Not(HaveOccurred()))
+
+					ns.Labels = map[string]string{policy.PolicyNameLabelKey: clusterPolicy.Name}
+					err = k8sClient.Update(ctx, &ns)
+					Expect(err).To(Not(HaveOccurred()))
+				})
+
+				It("will report the usage of the quotas", func() {
+					cluster := &v1beta1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							GenerateName: "cluster-",
+							Namespace:    namespace,
+						},
+						Spec: v1beta1.ClusterSpec{
+							ServerLimit: corev1.ResourceList{
+								corev1.ResourceCPU: resource.MustParse("500m"),
+							},
+						},
+					}
+
+					err := k8sClient.Create(ctx, cluster)
+					Expect(err).To(Not(HaveOccurred()))
+
+					Eventually(func(g Gomega) {
+						err := k8sClient.Get(ctx, client.ObjectKeyFromObject(cluster), cluster)
+						g.Expect(err).To(Not(HaveOccurred()))
+
+						g.Expect(cluster.Status.Quota).To(Not(BeNil()))
+						g.Expect(cluster.Status.Quota.ControlPlane).To(Not(BeNil()))
+						g.Expect(cluster.Status.Quota.ControlPlane.Used.Name(corev1.ResourceLimitsCPU, resource.DecimalSI).String()).To(Equal("500m"))
+						g.Expect(cluster.Status.Quota.Workloads).To(Not(BeNil()))
+						g.Expect(cluster.Status.Quota.Workloads.Used.Pods().Value()).To(BeEquivalentTo(0))
+					}).
+						WithTimeout(time.Second * 30).
+						WithPolling(time.Second).
+						Should(Succeed())
+				})
+
+				It("will not be valid if the servers exceed the control plane quota", func() {
+					cluster := &v1beta1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							GenerateName: "cluster-",
+							Namespace:    namespace,
+						},
+						Spec: v1beta1.ClusterSpec{
+							Servers: ptr.To[int32](3),
+							ServerLimit: corev1.ResourceList{
+								corev1.ResourceCPU: resource.MustParse("500m"),
+							},
+						},
+					}
+
+					err := k8sClient.Create(ctx, cluster)
+					Expect(err).To(Not(HaveOccurred()))
+
+					Eventually(func() v1beta1.ClusterPhase {
+						err := k8sClient.Get(ctx, client.ObjectKeyFromObject(cluster), cluster)
+						Expect(err).To(Not(HaveOccurred()))
+						return cluster.Status.Phase
+					}).
+						WithTimeout(time.Second * 30).
+						WithPolling(time.Second).
+						Should(Equal(v1beta1.ClusterPending))
+				})
+
+				It("will not be valid without the limits of the servers", func() {
+					cluster := &v1beta1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							GenerateName: "cluster-",
+							Namespace:    namespace,
+						},
+					}
+
+					err := k8sClient.Create(ctx, cluster)
+					Expect(err).To(Not(HaveOccurred()))
+
+					Eventually(func() v1beta1.ClusterPhase {
+						err := k8sClient.Get(ctx, client.ObjectKeyFromObject(cluster), cluster)
+						Expect(err).To(Not(HaveOccurred()))
+						return cluster.Status.Phase
+					}).
+						WithTimeout(time.Second * 30).
+						WithPolling(time.Second).
+						Should(Equal(v1beta1.ClusterPending))
+				})
+			})
+
+			When("bound to a policy with a maxLifetime", func() {
+				BeforeEach(func() {
+					clusterPolicy := &v1beta1.VirtualClusterPolicy{
+						ObjectMeta: metav1.ObjectMeta{GenerateName: "policy-"},
+						Spec: v1beta1.VirtualClusterPolicySpec{
+							MaxLifetime: &metav1.Duration{Duration: time.Hour},
+						},
+					}
+
+					err := k8sClient.Create(ctx, clusterPolicy)
+					Expect(err).To(Not(HaveOccurred()))
+
+					var ns corev1.Namespace
+					err = k8sClient.Get(ctx, client.ObjectKey{Name: namespace}, &ns)
+					Expect(err).To(Not(HaveOccurred()))
+
+					ns.Labels = map[string]string{policy.PolicyNameLabelKey: clusterPolicy.Name}
+					err = k8sClient.Update(ctx, &ns)
+					Expect(err).To(Not(HaveOccurred()))
+				})
+
+				It("will expire before a longer TTL", func() {
+					k3kCluster := &v1beta1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							GenerateName: "cluster-",
+							Namespace:    namespace,
+						},
+						Spec: v1beta1.ClusterSpec{
+							TTL: &metav1.Duration{Duration: time.Hour * 2},
+						},
+					}
+
+					err := k8sClient.Create(ctx, k3kCluster)
+					Expect(err).To(Not(HaveOccurred()))
+
+					Eventually(func(g Gomega) {
+						err := k8sClient.Get(ctx, client.ObjectKeyFromObject(k3kCluster), k3kCluster)
+						g.Expect(err).To(Not(HaveOccurred()))
+
+						g.Expect(k3kCluster.Status.ExpiresAt).To(Not(BeNil()))
+						g.Expect(k3kCluster.Status.ExpiresAt.Time).To(BeTemporally("==", k3kCluster.CreationTimestamp.Add(time.Hour)))
+					}).
+						WithTimeout(time.Second * 30).
+						WithPolling(time.Second).
+						Should(Succeed())
+				})
+			})
+
+			When("bound to a policy with a sync configuration", func() {
+				BeforeEach(func() {
+					clusterPolicy := &v1beta1.VirtualClusterPolicy{
+						ObjectMeta: metav1.ObjectMeta{GenerateName: "policy-"},
+						Spec: v1beta1.VirtualClusterPolicySpec{
+							Sync: &v1beta1.SyncConfig{
+								Services:               v1beta1.ServiceSyncConfig{Enabled: true},
+								ConfigMaps:             v1beta1.ConfigMapSyncConfig{Enabled: true, Selector: map[string]string{"tenant": "a"}},
+								Secrets:                v1beta1.SecretSyncConfig{Enabled: false},
+								Ingresses:              v1beta1.IngressSyncConfig{Enabled: true, IngressClassMapping: map[string]string{"nginx": "host-nginx"}},
+								PersistentVolumeClaims: v1beta1.PersistentVolumeClaimSyncConfig{Enabled: true, StorageClassMapping: map[string]string{"fast": "host-fast"}},
+								GatewayRoutes: v1beta1.GatewayRouteSyncConfig{
+									Enabled:  true,
+									Gateways: []v1beta1.GatewayReference{{Namespace: "gateways", Name: "public"}},
+								},
+								GarbageCollection: v1beta1.GarbageCollectionConfig{Enabled: true, DryRun: true},
+							},
+						},
+					}
+
+					err := k8sClient.Create(ctx, clusterPolicy)
+					Expect(err).To(Not(HaveOccurred()))
+
+					var ns corev1.Namespace
+					err = k8sClient.Get(ctx, client.ObjectKey{Name: namespace}, &ns)
+					Expect(err).To(Not(HaveOccurred()))
+
+					ns.Labels = map[string]string{policy.PolicyNameLabelKey: clusterPolicy.Name}
+					err = k8sClient.Update(ctx, &ns)
+					Expect(err).To(Not(HaveOccurred()))
+				})
+
+				It("will report the sync configuration narrowed by the policy", func() {
+					k3kCluster := &v1beta1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							GenerateName: "cluster-",
+							Namespace:    namespace,
+						},
+						Spec: v1beta1.ClusterSpec{
+							Sync: &v1beta1.SyncConfig{
+								Services:               v1beta1.ServiceSyncConfig{Enabled: false},
+								ConfigMaps:             v1beta1.ConfigMapSyncConfig{Enabled: true, Selector: map[string]string{"app": "web", "tenant": "b"}},
+								Secrets:                v1beta1.SecretSyncConfig{Enabled: true},
+								Ingresses:              v1beta1.IngressSyncConfig{Enabled: true},
+								PersistentVolumeClaims: v1beta1.PersistentVolumeClaimSyncConfig{Enabled: true},
+								GarbageCollection:      v1beta1.GarbageCollectionConfig{Enabled: true},
+							},
+						},
+					}
+
+					err := k8sClient.Create(ctx, k3kCluster)
+					Expect(err).To(Not(HaveOccurred()))
+
+					Eventually(func(g Gomega) {
+						err := k8sClient.Get(ctx, client.ObjectKeyFromObject(k3kCluster), k3kCluster)
+						g.Expect(err).To(Not(HaveOccurred()))
+
+						sync := k3kCluster.Status.Sync
+						g.Expect(sync).To(Not(BeNil()))
+						g.Expect(sync.Services.Enabled).To(BeFalse())
+						g.Expect(sync.ConfigMaps.Enabled).To(BeTrue())
+						g.Expect(sync.ConfigMaps.Selector).To(Equal(map[string]string{"app": "web", "tenant": "a"}))
+						g.Expect(sync.Secrets.Enabled).To(BeFalse())
+						g.Expect(sync.Ingresses.Enabled).To(BeTrue())
+						g.Expect(sync.Ingresses.IngressClassMapping).To(Equal(map[string]string{"nginx": "host-nginx"}))
+						g.Expect(sync.PersistentVolumeClaims.Enabled).To(BeTrue())
+						g.Expect(sync.PersistentVolumeClaims.StorageClassMapping).To(Equal(map[string]string{"fast": "host-fast"}))
+						g.Expect(sync.GatewayRoutes.Enabled).To(BeFalse())
+						g.Expect(sync.GatewayRoutes.Gateways).To(Equal([]v1beta1.GatewayReference{{Namespace: "gateways", Name: "public"}}))
+						g.Expect(sync.GarbageCollection.Enabled).To(BeTrue())
+						g.Expect(sync.GarbageCollection.DryRun).To(BeTrue())
+
+						condition := meta.FindStatusCondition(k3kCluster.Status.Conditions, cluster.ConditionSyncRestricted)
+						g.Expect(condition).To(Not(BeNil()))
+						g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+					}).
+						WithTimeout(time.Second * 30).
+						WithPolling(time.Second).
+						Should(Succeed())
+				})
+
+				It("will keep only the host classes and Gateways allowed by the policy", func() {
+					k3kCluster := &v1beta1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							GenerateName: "cluster-",
+							Namespace:    namespace,
+						},
+						Spec: v1beta1.ClusterSpec{
+							Sync: &v1beta1.SyncConfig{
+								PersistentVolumeClaims: v1beta1.PersistentVolumeClaimSyncConfig{
+									Enabled:             true,
+									StorageClassMapping: map[string]string{"fast": "host-fast", "slow": "host-slow"},
+								},
+								GatewayRoutes: v1beta1.GatewayRouteSyncConfig{
+									Enabled: true,
+									Gateways: []v1beta1.GatewayReference{
+										{Namespace: "gateways", Name: "public", SectionName: "https"},
+										{Namespace: "gateways", Name: "internal"},
+									},
+								},
+							},
+						},
+					}
+
+					err := k8sClient.Create(ctx, k3kCluster)
+					Expect(err).To(Not(HaveOccurred()))
+
+					Eventually(func(g Gomega) {
+						err := k8sClient.Get(ctx, client.ObjectKeyFromObject(k3kCluster), k3kCluster)
+						g.Expect(err).To(Not(HaveOccurred()))
+
+						sync := k3kCluster.Status.Sync
+						g.Expect(sync).To(Not(BeNil()))
+						g.Expect(sync.PersistentVolumeClaims.Enabled).To(BeTrue())
+						g.Expect(sync.PersistentVolumeClaims.StorageClassMapping).To(Equal(map[string]string{"fast": "host-fast"}))
+						g.Expect(sync.GatewayRoutes.Enabled).To(BeTrue())
+						g.Expect(sync.GatewayRoutes.Gateways).To(Equal([]v1beta1.GatewayReference{{Namespace: "gateways", Name: "public", SectionName: "https"}}))
+						g.Expect(sync.GarbageCollection.Enabled).To(BeFalse())
+						g.Expect(sync.GarbageCollection.DryRun).To(BeTrue())
+
+						condition := meta.FindStatusCondition(k3kCluster.Status.Conditions, cluster.ConditionSyncRestricted)
+						g.Expect(condition).To(Not(BeNil()))
+						g.Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+						g.Expect(condition.Message).To(ContainSubstring("host-slow"))
+						g.Expect(condition.Message).To(ContainSubstring("gateways/internal"))
+					}).
+						WithTimeout(time.Second * 30).
+						WithPolling(time.Second).
+						Should(Succeed())
+				})
+
+				It("will not sync the resources if none of the host classes and Gateways is allowed", func() {
+					k3kCluster := &v1beta1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							GenerateName: "cluster-",
+							Namespace:    namespace,
+						},
+						Spec: v1beta1.ClusterSpec{
+							Sync: &v1beta1.SyncConfig{
+								Ingresses: v1beta1.IngressSyncConfig{
+									Enabled:             true,
+									IngressClassMapping: map[string]string{"nginx": "host-traefik"},
+								},
+								PersistentVolumeClaims: v1beta1.PersistentVolumeClaimSyncConfig{
+									Enabled:             true,
+									StorageClassMapping: map[string]string{"slow": "host-slow"},
+								},
+								GatewayRoutes: v1beta1.GatewayRouteSyncConfig{
+									Enabled:  true,
+									Gateways: []v1beta1.GatewayReference{{Namespace: "gateways", Name: "internal"}},
+								},
+							},
+						},
+					}
+
+					err := k8sClient.Create(ctx, k3kCluster)
+					Expect(err).To(Not(HaveOccurred()))
+
+					Eventually(func(g Gomega) {
+						err := k8sClient.Get(ctx, client.ObjectKeyFromObject(k3kCluster), k3kCluster)
+						g.Expect(err).To(Not(HaveOccurred()))
+
+						sync := k3kCluster.Status.Sync
+						g.Expect(sync).To(Not(BeNil()))
+						g.Expect(sync.Ingresses.Enabled).To(BeFalse())
+						g.Expect(sync.Ingresses.IngressClassMapping).To(BeEmpty())
+						g.Expect(sync.PersistentVolumeClaims.Enabled).To(BeFalse())
+						g.Expect(sync.PersistentVolumeClaims.StorageClassMapping).To(BeEmpty())
+						g.Expect(sync.GatewayRoutes.Enabled).To(BeFalse())
+						g.Expect(sync.GatewayRoutes.Gateways).To(BeEmpty())
+
+						condition := meta.FindStatusCondition(k3kCluster.Status.Conditions, cluster.ConditionSyncRestricted)
+						g.Expect(condition).To(Not(BeNil()))
+						g.Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+						g.Expect(condition.Reason).To(Equal(cluster.ReasonSyncRestricted))
+					}).
+						WithTimeout(time.Second * 30).
+						WithPolling(time.Second).
+						Should(Succeed())
+				})
+			})
+
+			When("bound to a policy with constraints", func() {
+				BeforeEach(func() {
+					clusterPolicy := &v1beta1.VirtualClusterPolicy{
+						ObjectMeta: metav1.ObjectMeta{GenerateName: "policy-"},
+						Spec: v1beta1.VirtualClusterPolicySpec{
+							Constraints: &v1beta1.ClusterConstraints{
+								AllowedPersistenceTypes: []v1beta1.PersistenceMode{v1beta1.EphemeralPersistenceMode},
+								MaxServers:              ptr.To[int32](1),
+								DeniedServerArgs:        []string{"--disable"},
+								DisallowMirrorHostNodes: true,
+							},
+						},
+					}
+
+					err := k8sClient.Create(ctx, clusterPolicy)
+					Expect(err).To(Not(HaveOccurred()))
+
+					var ns corev1.Namespace
+					err = k8sClient.Get(ctx, client.ObjectKey{Name: namespace}, &ns)
+					Expect(err).To(Not(HaveOccurred()))
+
+					ns.Labels = map[string]string{policy.PolicyNameLabelKey: clusterPolicy.Name}
+					err = k8sClient.Update(ctx, &ns)
+					Expect(err).To(Not(HaveOccurred()))
+				})
+
+				It("will report all the violations at once", func() {
+					k3kCluster := &v1beta1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							GenerateName: "cluster-",
+							Namespace:    namespace,
+						},
+						Spec: v1beta1.ClusterSpec{
+							Servers:         ptr.To[int32](3),
+							ServerArgs:      []string{"--disable=traefik"},
+							MirrorHostNodes: true,
+							Persistence: v1beta1.PersistenceConfig{
+								Type: v1beta1.DynamicPersistenceMode,
+							},
+							ExtendedResources: &v1beta1.ExtendedResourcesConfig{
+								Allowed: []corev1.ResourceName{"example.com/gpu"},
+								Rename:  map[corev1.ResourceName]corev1.ResourceName{"example.com/gpu": corev1.ResourceCPU},
+							},
+						},
+					}
+
+					err := k8sClient.Create(ctx, k3kCluster)
+					Expect(err).To(Not(HaveOccurred()))
+
+					Eventually(func(g Gomega) {
+						err := k8sClient.Get(ctx, client.ObjectKeyFromObject(k3kCluster), k3kCluster)
+						g.Expect(err).To(Not(HaveOccurred()))
+						g.Expect(k3kCluster.Status.Phase).To(Equal(v1beta1.ClusterPending))
+
+						cond := meta.FindStatusCondition(k3kCluster.Status.Conditions, cluster.ConditionReady)
+						g.Expect(cond).To(Not(BeNil()))
+						g.Expect(cond.Reason).To(Equal(cluster.ReasonValidationFailed))
+						g.Expect(cond.Message).To(ContainSubstring(`persistence type "dynamic" is not allowed`))
+						g.Expect(cond.Message).To(ContainSubstring(`3 servers over 1 is not allowed`))
+						g.Expect(cond.Message).To(ContainSubstring(`serverArg "disable" is not allowed`))
+						g.Expect(cond.Message).To(ContainSubstring(`mirroring the host nodes is not allowed`))
+						g.Expect(cond.Message).To(ContainSubstring(`extended resource "example.com/gpu" can't be advertised as "cpu"`))
+					}).
+						WithTimeout(time.Second * 30).
+						WithPolling(time.Second).
+						Should(Succeed())
+				})
+			})
 		})
 	})
 })
@@ -0,0 +1,175 @@
+package cluster
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/utils/ptr"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+// validateConstraints returns all the violations of the constraints of the policy by the cluster.
+func validateConstraints(cluster *v1beta1.Cluster, clusterPolicy *v1beta1.VirtualClusterPolicy) []error {
+	constraints := clusterPolicy.Spec.Constraints
+
+	var errs []error
+
+	notAllowed := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s is not allowed by the policy %q", fmt.Sprintf(format, args...), clusterPolicy.Name))
+	}
+
+	persistence := cluster.Spec.Persistence
+
+	if len(constraints.AllowedPersistenceTypes) > 0 && !slices.Contains(constraints.AllowedPersistenceTypes, persistence.Type) {
+		notAllowed("persistence type %q", persistence.Type)
+	}
+
+	if constraints.MaxStorageRequestSize != nil && persistence.Type == v1beta1.DynamicPersistenceMode && persistence.StorageRequestSize != "" {
+		size, err := resource.ParseQuantity(persistence.StorageRequestSize)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid storageRequestSize %q: %w", persistence.StorageRequestSize, err))
+		} else if size.Cmp(*constraints.MaxStorageRequestSize) > 0 {
+			notAllowed("storageRequestSize %q over %q", persistence.StorageRequestSize, constraints.MaxStorageRequestSize.String())
+		}
+	}
+
+	if len(constraints.AllowedExposeTypes) > 0 {
+		for _, exposeType := range exposeTypes(cluster.Spec.Expose) {
+			if !slices.Contains(constraints.AllowedExposeTypes, exposeType) {
+				notAllowed("expose type %q", exposeType)
+			}
+		}
+	}
+
+	if servers := ptr.Deref(cluster.Spec.Servers, 1); constraints.MaxServers != nil && servers > *constraints.MaxServers {
+		notAllowed("%d servers over %d", servers, *constraints.MaxServers)
+	}
+
+	if agents := ptr.Deref(cluster.Spec.Agents, 0); constraints.MaxAgents != nil && agents > *constraints.MaxAgents {
+		notAllowed("%d agents over %d", agents, *constraints.MaxAgents)
+	}
+
+	for _, flag := range argFlags(cluster.Spec.ServerArgs) {
+		if !flagAllowed(flag, constraints.AllowedServerArgs, constraints.DeniedServerArgs) {
+			notAllowed("serverArg %q", flag)
+		}
+	}
+
+	if constraints.MinVersion != "" || constraints.MaxVersion != "" {
+		if err := validateVersion(cluster, clusterPolicy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if constraints.DisallowMirrorHostNodes && cluster.Spec.MirrorHostNodes {
+		notAllowed("mirroring the host nodes")
+	}
+
+	return errs
+}
+
+// exposeTypes returns the ways of exposing the API server set in the expose config.
+func exposeTypes(expose *v1beta1.ExposeConfig) []v1beta1.ExposeType {
+	if expose == nil {
+		return nil
+	}
+
+	var types []v1beta1.ExposeType
+
+	if expose.Ingress != nil {
+		types = append(types, v1beta1.IngressExposeType)
+	}
+
+	if expose.LoadBalancer != nil {
+		types = append(types, v1beta1.LoadBalancerExposeType)
+	}
+
+	if expose.NodePort != nil {
+		types = append(types, v1beta1.NodePortExposeType)
+	}
+
+	return types
+}
+
+// argFlags returns the names of the flags set in the args, without the leading dashes and the values.
+func argFlags(args []string) []string {
+	var flags []string
+
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		flag, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		flags = append(flags, flag)
+	}
+
+	return flags
+}
+
+// flagAllowed returns true if the flag is not denied, and is allowed or the allow-list is empty.
+// The flags of the lists can be written with or without the leading dashes.
+func flagAllowed(flag string, allowed, denied []string) bool {
+	matches := func(policyFlag string) bool {
+		return strings.TrimLeft(policyFlag, "-") == flag
+	}
+
+	if slices.ContainsFunc(denied, matches) {
+		return false
+	}
+
+	return len(allowed) == 0 || slices.ContainsFunc(allowed, matches)
+}
+
+// validateVersion checks the version of the cluster, or the version of the host if not set, against the
+// version range of the constraints. A maximum version without patch allows all the patches of its minor version.
+func validateVersion(cluster *v1beta1.Cluster, clusterPolicy *v1beta1.VirtualClusterPolicy) error {
+	constraints := clusterPolicy.Spec.Constraints
+
+	clusterVersion := cluster.Spec.Version
+	if clusterVersion == "" {
+		clusterVersion = cluster.Status.HostVersion
+	}
+
+	// the version of the host is not known yet
+	if clusterVersion == "" {
+		return nil
+	}
+
+	current, err := version.ParseGeneric(clusterVersion)
+	if err != nil {
+		return fmt.Errorf("invalid version %q: %w", clusterVersion, err)
+	}
+
+	if constraints.MinVersion != "" {
+		minVersion, err := version.ParseGeneric(constraints.MinVersion)
+		if err != nil {
+			return fmt.Errorf("invalid minVersion %q of the policy %q: %w", constraints.MinVersion, clusterPolicy.Name, err)
+		}
+
+		if current.LessThan(minVersion) {
+			return fmt.Errorf("version %q lower than %q is not allowed by the policy %q", clusterVersion, constraints.MinVersion, clusterPolicy.Name)
+		}
+	}
+
+	if constraints.MaxVersion != "" {
+		maxVersion, err := version.ParseGeneric(constraints.MaxVersion)
+		if err != nil {
+			return fmt.Errorf("invalid maxVersion %q of the policy %q: %w", constraints.MaxVersion, clusterPolicy.Name, err)
+		}
+
+		if len(maxVersion.Components()) < 3 {
+			current = version.MajorMinor(current.Major(), current.Minor())
+		}
+
+		if maxVersion.LessThan(current) {
+			return fmt.Errorf("version %q higher than %q is not allowed by the policy %q", clusterVersion, constraints.MaxVersion, clusterPolicy.Name)
+		}
+	}
+
+	return nil
+}
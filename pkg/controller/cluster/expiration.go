@@ -0,0 +1,155 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+const (
+	// ConditionExpiring is the condition of the clusters close to their expiration.
+	ConditionExpiring = "Expiring"
+
+	// Condition Reasons
+	ReasonExpiring = "Expiring"
+	ReasonExpired  = "Expired"
+
+	// expirationWarningPeriod is the maximum time before the expiration of a cluster when it's reported as expiring,
+	// limited to a quarter of the lifetime of the short-lived clusters.
+	expirationWarningPeriod = time.Hour
+
+	// expirationRefreshPeriod is the maximum time between the updates of the remaining time of a cluster.
+	expirationRefreshPeriod = time.Minute * 10
+)
+
+// reconcileExpiration sets the expiration time and the remaining time of the cluster in its status, and warns
+// with an event when the cluster is close to its expiration.
+// The cluster is reported as expiring for the whole warning period before being deleted, so a lifetime shortened
+// after the creation of the cluster, i.e. by the maxLifetime of a policy bound later, doesn't delete it right away.
+func (c *ClusterReconciler) reconcileExpiration(cluster *v1beta1.Cluster, clusterPolicy *v1beta1.VirtualClusterPolicy) {
+	expiresAt := expirationTime(cluster, clusterPolicy)
+	if expiresAt == nil {
+		cluster.Status.ExpiresAt = nil
+		cluster.Status.RemainingTime = ""
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionExpiring)
+
+		return
+	}
+
+	warning := warningPeriod(cluster, expiresAt)
+
+	if time.Until(expiresAt.Time) > warning {
+		cluster.Status.ExpiresAt = expiresAt
+		cluster.Status.RemainingTime = duration.HumanDuration(time.Until(expiresAt.Time))
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionExpiring)
+
+		return
+	}
+
+	warnedAt := time.Now()
+	if expiring := meta.FindStatusCondition(cluster.Status.Conditions, ConditionExpiring); expiring != nil && expiring.Status == metav1.ConditionTrue {
+		warnedAt = expiring.LastTransitionTime.Time
+	}
+
+	if gracePeriodEnd := warnedAt.Add(warning).Truncate(time.Second); gracePeriodEnd.After(expiresAt.Time) {
+		expiresAt = ptr.To(metav1.NewTime(gracePeriodEnd))
+	}
+
+	cluster.Status.ExpiresAt = expiresAt
+	cluster.Status.RemainingTime = duration.HumanDuration(max(time.Until(expiresAt.Time), 0))
+
+	condition := metav1.Condition{
+		Type:    ConditionExpiring,
+		Status:  metav1.ConditionTrue,
+		Reason:  ReasonExpiring,
+		Message: fmt.Sprintf("Cluster expires at %s and will be deleted", expiresAt.UTC().Format(time.RFC3339)),
+	}
+
+	// Only emit event on transition to Expiring
+	if !meta.IsStatusConditionTrue(cluster.Status.Conditions, ConditionExpiring) {
+		c.Eventf(cluster, v1.EventTypeWarning, ReasonExpiring, condition.Message)
+	}
+
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+}
+
+// expirationTime returns the earliest expiration time of the cluster, from its TTL, its ExpiresAt and the
+// MaxLifetime of its policy, or nil if the cluster doesn't expire.
+func expirationTime(cluster *v1beta1.Cluster, clusterPolicy *v1beta1.VirtualClusterPolicy) *metav1.Time {
+	var expirations []time.Time
+
+	created := cluster.CreationTimestamp.Time
+
+	if cluster.Spec.TTL != nil {
+		expirations = append(expirations, created.Add(cluster.Spec.TTL.Duration))
+	}
+
+	if cluster.Spec.ExpiresAt != nil {
+		expirations = append(expirations, cluster.Spec.ExpiresAt.Time)
+	}
+
+	if clusterPolicy != nil && clusterPolicy.Spec.MaxLifetime != nil {
+		expirations = append(expirations, created.Add(clusterPolicy.Spec.MaxLifetime.Duration))
+	}
+
+	if len(expirations) == 0 {
+		return nil
+	}
+
+	// the times are serialized with the precision of the seconds
+	expiresAt := metav1.NewTime(slices.MinFunc(expirations, time.Time.Compare).Truncate(time.Second))
+
+	return &expiresAt
+}
+
+// warningPeriod returns the time before the expiration of the cluster when it's reported as expiring.
+// It's negative for the clusters created already expired, that are deleted right away.
+func warningPeriod(cluster *v1beta1.Cluster, expiresAt *metav1.Time) time.Duration {
+	lifetime := expiresAt.Sub(cluster.CreationTimestamp.Time)
+
+	return min(expirationWarningPeriod, lifetime/4)
+}
+
+// expired returns true if the expiration time of the cluster is passed.
+func expired(cluster *v1beta1.Cluster) bool {
+	return cluster.Status.ExpiresAt != nil && !time.Now().Before(cluster.Status.ExpiresAt.Time)
+}
+
+// expirationRequeueAfter returns the time after which the cluster has to be reconciled to refresh its remaining time,
+// to warn about its expiration or to delete it, or zero if the cluster doesn't expire.
+func expirationRequeueAfter(cluster *v1beta1.Cluster) time.Duration {
+	if cluster.Status.ExpiresAt == nil {
+		return 0
+	}
+
+	remaining := time.Until(cluster.Status.ExpiresAt.Time)
+	requeueAfter := min(remaining, expirationRefreshPeriod)
+
+	if untilWarning := remaining - warningPeriod(cluster, cluster.Status.ExpiresAt); untilWarning > 0 {
+		requeueAfter = min(requeueAfter, untilWarning)
+	}
+
+	return max(requeueAfter, time.Second)
+}
+
+// deleteExpiredCluster deletes the expired cluster, that will be finalized as any deleted cluster.
+func (c *ClusterReconciler) deleteExpiredCluster(ctx context.Context, cluster *v1beta1.Cluster) error {
+	log := ctrl.LoggerFrom(ctx)
+	log.Info("Deleting expired Cluster", "expiresAt", cluster.Status.ExpiresAt)
+
+	c.Eventf(cluster, v1.EventTypeWarning, ReasonExpired, "Cluster expired at %s, deleting it", cluster.Status.ExpiresAt.UTC().Format(time.RFC3339))
+
+	return client.IgnoreNotFound(c.Client.Delete(ctx, cluster))
+}
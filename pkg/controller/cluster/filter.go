@@ -15,6 +15,11 @@ func newClusterPredicate() predicate.Predicate {
 	return predicate.NewPredicateFuncs(func(object client.Object) bool {
 		owner := metav1.GetControllerOf(object)
 
+		// the objects in the dedicated host namespaces of a cluster can't be owned by the Cluster
+		if object.GetLabels()[translate.ClusterNamespaceLabel] != "" {
+			return object.GetLabels()[translate.ClusterNameLabel] != ""
+		}
+
 		return owner != nil &&
 			owner.Kind == "Cluster" &&
 			owner.APIVersion == v1beta1.SchemeGroupVersion.String()
@@ -31,8 +36,13 @@ func clusterNamespacedName(object client.Object) types.NamespacedName {
 		clusterName = object.GetLabels()[translate.ClusterNameLabel]
 	}
 
+	clusterNamespace := object.GetNamespace()
+	if namespace := object.GetLabels()[translate.ClusterNamespaceLabel]; namespace != "" {
+		clusterNamespace = namespace
+	}
+
 	return types.NamespacedName{
 		Name:      clusterName,
-		Namespace: object.GetNamespace(),
+		Namespace: clusterNamespace,
 	}
 }
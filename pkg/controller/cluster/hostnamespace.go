@@ -0,0 +1,294 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller"
+	"github.com/rancher/k3k/pkg/controller/cluster/agent"
+	"github.com/rancher/k3k/pkg/controller/policy"
+)
+
+const (
+	// hostNamespaceClusterRole is the ClusterRole bound to the agent of the cluster in each of its dedicated host namespaces.
+	hostNamespaceClusterRole = "k3k-kubelet-namespace"
+
+	// hostNamespacesRefreshPeriod is the period of the reconciliation of the clusters with the PerNamespace host namespace
+	// mode, to create the host namespaces of the new virtual namespaces.
+	hostNamespacesRefreshPeriod = 30 * time.Second
+
+	ReasonHostNamespaceConflict = "HostNamespaceConflict"
+)
+
+// hostNamespaces returns the dedicated host namespaces of a cluster with the PerNamespace host namespace mode,
+// created by the controller for each virtual namespace.
+func (c *ClusterReconciler) hostNamespaces(ctx context.Context, cluster *v1beta1.Cluster) ([]v1.Namespace, error) {
+	translator := translate.NewHostTranslator(cluster)
+	if !translator.NamespacePerVirtualNamespace {
+		return nil, nil
+	}
+
+	var namespaces v1.NamespaceList
+	if err := c.Client.List(ctx, &namespaces, client.MatchingLabels(translator.HostLabels())); err != nil {
+		return nil, err
+	}
+
+	return namespaces.Items, nil
+}
+
+// hostNamespaceNames returns the sorted names of the dedicated host namespaces of a cluster. The agent caches
+// the host objects only from them, so that it doesn't need the access to the other namespaces.
+func (c *ClusterReconciler) hostNamespaceNames(ctx context.Context, cluster *v1beta1.Cluster) ([]string, error) {
+	namespaces, err := c.hostNamespaces(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		names = append(names, namespace.Name)
+	}
+
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// hostNamespacesRequeueAfter limits the requeue period of a cluster with the PerNamespace host namespace mode,
+// since the virtual namespaces are not watched by the controller.
+func hostNamespacesRequeueAfter(cluster *v1beta1.Cluster, requeueAfter time.Duration) time.Duration {
+	if !translate.NewHostTranslator(cluster).NamespacePerVirtualNamespace {
+		return requeueAfter
+	}
+
+	if requeueAfter == 0 {
+		return hostNamespacesRefreshPeriod
+	}
+
+	return min(requeueAfter, hostNamespacesRefreshPeriod)
+}
+
+// syncHostNamespaces creates the missing dedicated host namespaces of the virtual namespaces of the cluster, and deletes
+// the ones of the deleted virtual namespaces, with all the objects synced in them. It returns the host namespaces in use.
+// An existing namespace not labelled for the cluster belongs to something else, and it's reported with an event.
+func (c *ClusterReconciler) syncHostNamespaces(ctx context.Context, cluster *v1beta1.Cluster, hostNamespaces []v1.Namespace) ([]v1.Namespace, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	// the host namespaces are kept while the virtual namespaces can't be listed
+	virtualClient, err := newVirtualClient(ctx, c.Client, cluster.Name, cluster.Namespace)
+	if err != nil {
+		return hostNamespaces, err
+	}
+
+	var virtNamespaces v1.NamespaceList
+	if err := virtualClient.List(ctx, &virtNamespaces); err != nil {
+		return hostNamespaces, err
+	}
+
+	translator := translate.NewHostTranslator(cluster)
+
+	var (
+		errs           []error
+		usedNamespaces []v1.Namespace
+	)
+
+	for _, hostNamespace := range hostNamespaces {
+		virtNamespace := hostNamespace.Annotations[translate.ResourceNameAnnotation]
+
+		// the host namespaces without the annotation were not created by the controller, and are never deleted
+		if virtNamespace == "" || slices.ContainsFunc(virtNamespaces.Items, func(namespace v1.Namespace) bool {
+			return namespace.Name == virtNamespace
+		}) {
+			usedNamespaces = append(usedNamespaces, hostNamespace)
+			continue
+		}
+
+		if !hostNamespace.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		log.Info("Deleting the host namespace of the deleted virtual namespace", "namespace", virtNamespace, "hostNamespace", hostNamespace.Name)
+
+		if err := c.Client.Delete(ctx, &hostNamespace); client.IgnoreNotFound(err) != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, virtNamespace := range virtNamespaces.Items {
+		if !virtNamespace.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		name := translator.HostNamespace(virtNamespace.Name)
+
+		if slices.ContainsFunc(hostNamespaces, func(namespace v1.Namespace) bool { return namespace.Name == name }) {
+			continue
+		}
+
+		hostNamespace := &v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: translator.HostLabels(),
+				Annotations: map[string]string{
+					translate.ResourceNameAnnotation: virtNamespace.Name,
+				},
+			},
+		}
+
+		if cluster.Status.PolicyName != "" {
+			hostNamespace.Labels[policy.PolicyNameLabelKey] = cluster.Status.PolicyName
+		}
+
+		log.Info("Creating host namespace", "namespace", virtNamespace.Name, "hostNamespace", name)
+
+		if err := c.Client.Create(ctx, hostNamespace); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				errs = append(errs, err)
+				continue
+			}
+
+			c.Eventf(cluster, v1.EventTypeWarning, ReasonHostNamespaceConflict,
+				"Host namespace %s of the virtual namespace %s already exists and doesn't belong to the cluster", name, virtNamespace.Name)
+		}
+	}
+
+	return usedNamespaces, errors.Join(errs...)
+}
+
+// reconcileHostNamespaces creates the dedicated host namespaces of the cluster, deletes the ones of the deleted virtual
+// namespaces, and grants its agent the access to them.
+// The VirtualClusterPolicy label of the cluster namespace is copied to them, so that the policy controller applies
+// the same limit ranges and network policies, and the network policy of the cluster is created when there is no policy.
+// The quota of the policy is not created in them, but enforced by the agent across all of them.
+func (c *ClusterReconciler) reconcileHostNamespaces(ctx context.Context, cluster *v1beta1.Cluster) error {
+	log := ctrl.LoggerFrom(ctx)
+	log.V(1).Info("Reconciling host namespaces")
+
+	if !translate.NewHostTranslator(cluster).NamespacePerVirtualNamespace {
+		return nil
+	}
+
+	namespaces, err := c.hostNamespaces(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	namespaces, err = c.syncHostNamespaces(ctx, cluster, namespaces)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, namespace := range namespaces {
+		if !namespace.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if err := c.ensureHostNamespacePolicyLabel(ctx, &namespace, cluster.Status.PolicyName); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := c.ensureHostNamespaceRoleBinding(ctx, cluster, namespace.Name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := c.ensureNetworkPolicy(ctx, cluster, namespace.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ensureHostNamespacePolicyLabel sets the VirtualClusterPolicy label of the cluster namespace on a host namespace.
+func (c *ClusterReconciler) ensureHostNamespacePolicyLabel(ctx context.Context, namespace *v1.Namespace, policyName string) error {
+	if namespace.Labels[policy.PolicyNameLabelKey] == policyName {
+		return nil
+	}
+
+	if policyName == "" {
+		delete(namespace.Labels, policy.PolicyNameLabelKey)
+	} else {
+		namespace.Labels[policy.PolicyNameLabelKey] = policyName
+	}
+
+	return c.Client.Update(ctx, namespace)
+}
+
+// ensureHostNamespaceRoleBinding binds the ClusterRole of the host namespaces to the service account of the agent.
+// The RoleBinding can't be owned by the Cluster in another namespace, and it's deleted with the host namespace.
+func (c *ClusterReconciler) ensureHostNamespaceRoleBinding(ctx context.Context, cluster *v1beta1.Cluster, namespace string) error {
+	agentName := controller.SafeConcatNameWithPrefix(cluster.Name, agent.SharedNodeAgentName)
+
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      agentName,
+			Namespace: namespace,
+		},
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, c.Client, roleBinding, func() error {
+		roleBinding.RoleRef = rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     hostNamespaceClusterRole,
+		}
+		roleBinding.Subjects = []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      agentName,
+				Namespace: cluster.Namespace,
+			},
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// deleteHostNamespaces deletes the dedicated host namespaces of a cluster, with all the objects synced in them.
+func (c *ClusterReconciler) deleteHostNamespaces(ctx context.Context, cluster *v1beta1.Cluster) error {
+	namespaces, err := c.hostNamespaces(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, namespace := range namespaces {
+		if err := c.Client.Delete(ctx, &namespace); client.IgnoreNotFound(err) != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// hostNamespaceCluster returns the key of the Cluster of a dedicated host namespace, if it is one.
+func hostNamespaceCluster(namespace client.Object) (client.ObjectKey, bool) {
+	labels := namespace.GetLabels()
+
+	key := client.ObjectKey{
+		Name:      labels[translate.ClusterNameLabel],
+		Namespace: labels[translate.ClusterNamespaceLabel],
+	}
+
+	return key, key.Name != "" && key.Namespace != ""
+}
@@ -0,0 +1,176 @@
+package cluster
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+const (
+	ingressController = "k3k-ingress-controller"
+
+	// ingressHostIndexField is the field index of the host Ingresses by the hosts of their rules.
+	ingressHostIndexField = "k3k.io/ingressHost"
+
+	// ReasonIngressHostConflict is the reason of the events recorded when a synced Ingress is deleted because its hosts
+	// are used by another Ingress of the host cluster.
+	ReasonIngressHostConflict = "IngressHostConflict"
+)
+
+// IngressReconciler deletes the Ingresses synced by the virtual kubelets using the hosts of other Ingresses of the host
+// cluster. The virtual kubelets can only check the Ingresses of the namespaces they can access.
+type IngressReconciler struct {
+	HostClient ctrlruntimeclient.Client
+
+	record.EventRecorder
+}
+
+// AddIngressController adds a new controller to the manager
+func AddIngressController(ctx context.Context, mgr manager.Manager, maxConcurrentReconciles int) error {
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &networkingv1.Ingress{}, ingressHostIndexField, func(obj ctrlruntimeclient.Object) []string {
+		return ingressHosts(obj.(*networkingv1.Ingress))
+	}); err != nil {
+		return err
+	}
+
+	reconciler := IngressReconciler{
+		HostClient:    mgr.GetClient(),
+		EventRecorder: mgr.GetEventRecorderFor(ingressController),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(ingressController).
+		For(&networkingv1.Ingress{}, builder.WithPredicates(predicate.NewPredicateFuncs(isSyncedIngress))).
+		Watches(&networkingv1.Ingress{}, handler.EnqueueRequestsFromMapFunc(reconciler.conflictingIngressRequests)).
+		WithOptions(ctrlcontroller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}).
+		Complete(&reconciler)
+}
+
+// conflictingIngressRequests maps an Ingress to the requests for the other synced Ingresses using its hosts.
+func (r *IngressReconciler) conflictingIngressRequests(ctx context.Context, obj ctrlruntimeclient.Object) []reconcile.Request {
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok {
+		return nil
+	}
+
+	var requests []reconcile.Request
+
+	for _, host := range ingressHosts(ingress) {
+		var ingresses networkingv1.IngressList
+		if err := r.HostClient.List(ctx, &ingresses, ctrlruntimeclient.MatchingFields{ingressHostIndexField: host}); err != nil {
+			ctrl.LoggerFrom(ctx).Error(err, "failed to list the ingresses using the host", "host", host)
+			continue
+		}
+
+		for _, other := range ingresses.Items {
+			if other.UID != ingress.UID && isSyncedIngress(&other) {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: other.Name, Namespace: other.Namespace}})
+			}
+		}
+	}
+
+	return requests
+}
+
+func (r *IngressReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	log.V(1).Info("Reconciling Ingress")
+
+	var ingress networkingv1.Ingress
+	if err := r.HostClient.Get(ctx, req.NamespacedName, &ingress); err != nil {
+		return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
+	}
+
+	if !ingress.DeletionTimestamp.IsZero() || !isSyncedIngress(&ingress) {
+		return reconcile.Result{}, nil
+	}
+
+	for _, host := range ingressHosts(&ingress) {
+		var ingresses networkingv1.IngressList
+		if err := r.HostClient.List(ctx, &ingresses, ctrlruntimeclient.MatchingFields{ingressHostIndexField: host}); err != nil {
+			return reconcile.Result{}, err
+		}
+
+		for _, other := range ingresses.Items {
+			if !takesHostPrecedence(&other, &ingress) {
+				continue
+			}
+
+			log.Info("deleting the synced ingress using the host of another ingress", "host", host, "ingress", other.Namespace+"/"+other.Name)
+
+			if err := r.HostClient.Delete(ctx, &ingress); err != nil {
+				return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
+			}
+
+			var cluster v1beta1.Cluster
+			if err := r.HostClient.Get(ctx, clusterNamespacedName(&ingress), &cluster); err != nil {
+				return reconcile.Result{}, ctrlruntimeclient.IgnoreNotFound(err)
+			}
+
+			r.Eventf(&cluster, v1.EventTypeWarning, ReasonIngressHostConflict, "Ingress %s/%s of the virtual cluster deleted from the host cluster: host %q is already used by the ingress %s/%s",
+				ingress.Annotations[translate.ResourceNamespaceAnnotation], ingress.Annotations[translate.ResourceNameAnnotation], host, other.Namespace, other.Name)
+
+			return reconcile.Result{}, nil
+		}
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// takesHostPrecedence returns true if the other Ingress keeps the hosts it shares with the synced Ingress.
+// The Ingresses not synced from a virtual cluster always take precedence, while between the Ingresses synced from
+// different virtual clusters the oldest one is kept.
+func takesHostPrecedence(other, synced *networkingv1.Ingress) bool {
+	if other.UID == synced.UID || !other.DeletionTimestamp.IsZero() {
+		return false
+	}
+
+	if !isSyncedIngress(other) {
+		return true
+	}
+
+	if clusterNamespacedName(other) == clusterNamespacedName(synced) {
+		return false
+	}
+
+	if !other.CreationTimestamp.Equal(&synced.CreationTimestamp) {
+		return other.CreationTimestamp.Before(&synced.CreationTimestamp)
+	}
+
+	return strings.Compare(other.Namespace+"/"+other.Name, synced.Namespace+"/"+synced.Name) < 0
+}
+
+// isSyncedIngress returns true if the Ingress was synced from a virtual cluster by its virtual kubelet.
+func isSyncedIngress(obj ctrlruntimeclient.Object) bool {
+	return obj.GetLabels()[translate.ClusterNameLabel] != "" &&
+		obj.GetAnnotations()[translate.ResourceNameAnnotation] != ""
+}
+
+// ingressHosts returns the hosts of the rules of the Ingress.
+func ingressHosts(ingress *networkingv1.Ingress) []string {
+	var hosts []string
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != "" {
+			hosts = append(hosts, rule.Host)
+		}
+	}
+
+	return hosts
+}
@@ -0,0 +1,98 @@
+package cluster_test
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ingress Controller", Label("controller"), Label("Ingress"), func() {
+	var (
+		hostNamespace    string
+		clusterNamespace string
+		ctx              context.Context
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		for _, namespace := range []*string{&hostNamespace, &clusterNamespace} {
+			createdNS := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "ns-"}}
+			err := k8sClient.Create(ctx, createdNS)
+			Expect(err).To(Not(HaveOccurred()))
+
+			*namespace = createdNS.Name
+		}
+	})
+
+	newIngress := func(namespace, host string, synced bool) *networkingv1.Ingress {
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "ingress-",
+				Namespace:    namespace,
+			},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{{Host: host}},
+			},
+		}
+
+		if synced {
+			ingress.Labels = map[string]string{translate.ClusterNameLabel: "mycluster"}
+			ingress.Annotations = map[string]string{
+				translate.ResourceNameAnnotation:      "web",
+				translate.ResourceNamespaceAnnotation: "default",
+			}
+		}
+
+		return ingress
+	}
+
+	It("will delete the synced ingresses using the host of another namespace", func() {
+		hostIngress := newIngress(hostNamespace, "app.example.com", false)
+		err := k8sClient.Create(ctx, hostIngress)
+		Expect(err).To(Not(HaveOccurred()))
+
+		syncedIngress := newIngress(clusterNamespace, "app.example.com", true)
+		err = k8sClient.Create(ctx, syncedIngress)
+		Expect(err).To(Not(HaveOccurred()))
+
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(syncedIngress), syncedIngress)
+			return apierrors.IsNotFound(err)
+		}).
+			WithTimeout(time.Second * 10).
+			WithPolling(time.Second).
+			Should(BeTrue())
+
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(hostIngress), hostIngress)
+		Expect(err).To(Not(HaveOccurred()))
+	})
+
+	It("will keep the synced ingresses using their own hosts", func() {
+		hostIngress := newIngress(hostNamespace, "app.example.com", false)
+		err := k8sClient.Create(ctx, hostIngress)
+		Expect(err).To(Not(HaveOccurred()))
+
+		syncedIngress := newIngress(clusterNamespace, "tenant.example.com", true)
+		err = k8sClient.Create(ctx, syncedIngress)
+		Expect(err).To(Not(HaveOccurred()))
+
+		Consistently(func() error {
+			return k8sClient.Get(ctx, client.ObjectKeyFromObject(syncedIngress), syncedIngress)
+		}).
+			WithTimeout(time.Second * 3).
+			WithPolling(time.Second).
+			Should(Succeed())
+	})
+})
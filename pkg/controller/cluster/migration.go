@@ -0,0 +1,64 @@
+package cluster
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apps "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rancher/k3k/k3k-kubelet/controller/migration"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller/cluster/agent"
+)
+
+// migrateHostObjects renames the host objects of a shared cluster named with the legacy name translation by a previous
+// release. The migration runs only when the image of the agents changes. The agents are stopped first, so that they
+// don't sync the objects again with the legacy names, and they are started again by ensureAgent with the new image.
+// The migration needs to update the PersistentVolumes and the VolumeSnapshotContents, that the agents can only read.
+// Each step is done once per reconciliation: migration.ErrMigrationInProgress is returned while the agents are being
+// stopped or the host objects are being renamed, and the cluster is reconciled again later to check the progress.
+func (c *ClusterReconciler) migrateHostObjects(ctx context.Context, cluster *v1beta1.Cluster, sharedAgent *agent.SharedAgent) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	daemonSet := &apps.DaemonSet{}
+	key := client.ObjectKey{Name: sharedAgent.Name(), Namespace: cluster.Namespace}
+
+	err := c.MigrationClient.Get(ctx, key, daemonSet)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	agentFound := err == nil
+
+	// the host objects were already migrated, or created with the new names, by the current agents
+	if agentFound && len(daemonSet.Spec.Template.Spec.Containers) > 0 && daemonSet.Spec.Template.Spec.Containers[0].Image == sharedAgent.Image() {
+		return nil
+	}
+
+	migrator := migration.NewMigrator(c.MigrationClient, cluster.Name, cluster.Namespace, log)
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil || !pending {
+		return err
+	}
+
+	if agentFound {
+		log.Info("Stopping the agents to migrate the names of the host objects")
+
+		if daemonSet.DeletionTimestamp.IsZero() {
+			if err := c.MigrationClient.Delete(ctx, daemonSet, client.PropagationPolicy(metav1.DeletePropagationForeground)); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+
+		// the DaemonSet is deleted in foreground, once all the agent pods are deleted
+		return migration.ErrMigrationInProgress
+	}
+
+	log.Info("Migrating the names of the host objects")
+
+	return migrator.Migrate(ctx)
+}
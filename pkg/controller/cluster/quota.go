@@ -0,0 +1,154 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	v1 "k8s.io/api/core/v1"
+	quota "k8s.io/apiserver/pkg/quota/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+	"github.com/rancher/k3k/pkg/controller/policy"
+)
+
+// reconcileQuotaStatus reports in the status of the cluster the per-cluster quotas of its policy, and their usage.
+// The workloads quota is reported only in shared mode, where the pods of the workloads run in the host cluster.
+func (c *ClusterReconciler) reconcileQuotaStatus(ctx context.Context, cluster *v1beta1.Cluster, clusterPolicy *v1beta1.VirtualClusterPolicy) error {
+	quotas := clusterPolicy.Spec.ClusterQuotas
+	if quotas == nil || (len(quotas.Workloads) == 0 && len(quotas.ControlPlane) == 0) {
+		cluster.Status.Quota = nil
+		return nil
+	}
+
+	status := &v1beta1.ClusterQuotaStatus{}
+
+	if len(quotas.ControlPlane) > 0 {
+		status.ControlPlane = policy.QuotaStatus(quotas.ControlPlane, controlPlaneUsage(cluster))
+	}
+
+	if len(quotas.Workloads) > 0 && cluster.Spec.Mode == v1beta1.SharedClusterMode {
+		var podList v1.PodList
+		if err := c.Client.List(ctx, &podList, translate.NewHostTranslator(cluster).ListOptions(nil)...); err != nil {
+			return err
+		}
+
+		pods := make([]*v1.Pod, 0, len(podList.Items))
+		for i := range podList.Items {
+			pods = append(pods, &podList.Items[i])
+		}
+
+		status.Workloads = policy.QuotaStatus(quotas.Workloads, policy.PodsQuotaUsage(pods...))
+	}
+
+	cluster.Status.Quota = status
+
+	return nil
+}
+
+// validateControlPlaneQuota checks that the resources requested by the servers of the cluster, and by its agents
+// in virtual mode, are bounded by their limits and fit in the control plane quota of the policy.
+func validateControlPlaneQuota(cluster *v1beta1.Cluster, clusterPolicy *v1beta1.VirtualClusterPolicy) error {
+	hard := clusterPolicy.Spec.ClusterQuotas.ControlPlane
+	used := controlPlaneUsage(cluster)
+
+	if unbounded := quota.Difference(quota.ResourceNames(hard), quota.ResourceNames(used)); len(unbounded) > 0 {
+		return fmt.Errorf("the serverLimit and the workerLimit must set the resources limited by the control plane quota of the policy %q: %v", clusterPolicy.Name, unbounded)
+	}
+
+	if exceeded := policy.ExceededQuota(hard, used); len(exceeded) > 0 {
+		return fmt.Errorf("the servers and agents exceed the control plane quota of the policy %q: %v", clusterPolicy.Name, exceeded)
+	}
+
+	return nil
+}
+
+// controlPlaneUsage returns the resources requested by the servers of the cluster, and by its agents in virtual mode.
+// The containers of the server and agent pods only set the limits, which are also their requests.
+func controlPlaneUsage(cluster *v1beta1.Cluster) v1.ResourceList {
+	var pods []*v1.Pod
+
+	addPods := func(replicas int32, limits v1.ResourceList) {
+		pod := &v1.Pod{
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{{
+					Resources: v1.ResourceRequirements{Requests: limits, Limits: limits},
+				}},
+			},
+		}
+
+		for range replicas {
+			pods = append(pods, pod)
+		}
+	}
+
+	addPods(ptr.Deref(cluster.Spec.Servers, 1), cluster.Spec.ServerLimit)
+
+	if cluster.Spec.Mode == v1beta1.VirtualClusterMode {
+		addPods(ptr.Deref(cluster.Spec.Agents, 0), cluster.Spec.WorkerLimit)
+	}
+
+	return policy.PodsQuotaUsage(pods...)
+}
+
+// workloadPodEventHandler enqueues the Cluster of a host pod when it's created, deleted or terminated,
+// to update the usage of its workloads quota.
+func workloadPodEventHandler(r *ClusterReconciler) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, object client.Object) []reconcile.Request {
+		key := clusterNamespacedName(object)
+
+		var cluster v1beta1.Cluster
+		if err := r.Client.Get(ctx, key, &cluster); err != nil {
+			return nil
+		}
+
+		// only the clusters with a workloads quota need to be reconciled
+		if cluster.Status.Quota == nil || cluster.Status.Quota.Workloads == nil {
+			return nil
+		}
+
+		ctrl.LoggerFrom(ctx).V(1).Info("Enqueuing Cluster for the usage of its workloads quota", "cluster", key)
+
+		return []reconcile.Request{{NamespacedName: key}}
+	})
+}
+
+// workloadPodPredicate filters the host pods of the workloads changing the usage of the quotas:
+// the created and deleted ones, and the ones reaching a terminal phase.
+var workloadPodPredicate = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		return isWorkloadPod(e.Object)
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return isWorkloadPod(e.Object)
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldPod, okOld := e.ObjectOld.(*v1.Pod)
+		newPod, okNew := e.ObjectNew.(*v1.Pod)
+
+		if !okOld || !okNew || !isWorkloadPod(newPod) {
+			return false
+		}
+
+		return isTerminated(oldPod) != isTerminated(newPod)
+	},
+	GenericFunc: func(event.GenericEvent) bool {
+		return false
+	},
+}
+
+func isWorkloadPod(object client.Object) bool {
+	return object.GetLabels()[translate.ClusterNameLabel] != ""
+}
+
+func isTerminated(pod *v1.Pod) bool {
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
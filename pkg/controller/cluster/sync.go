@@ -0,0 +1,251 @@
+package cluster
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+const (
+	// ConditionSyncRestricted is the condition of the clusters whose sync configuration maps host classes
+	// or Gateways not allowed by their policy.
+	ConditionSyncRestricted = "SyncRestricted"
+
+	// Condition Reasons
+	ReasonSyncAllowed    = "SyncConfigAllowed"
+	ReasonSyncRestricted = "SyncConfigNotAllowed"
+)
+
+// reconcileSyncConfig sets the effective sync configuration of the cluster in its status, and reports with a condition
+// and an event the host classes and Gateways of the cluster not allowed by its policy.
+func (c *ClusterReconciler) reconcileSyncConfig(cluster *v1beta1.Cluster, clusterPolicy *v1beta1.VirtualClusterPolicy) {
+	if clusterPolicy == nil {
+		cluster.Status.Sync = cluster.Spec.Sync.DeepCopy()
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, ConditionSyncRestricted)
+
+		return
+	}
+
+	sync, restrictions := effectiveSyncConfig(cluster.Spec.Sync, clusterPolicy.Spec.Sync)
+	cluster.Status.Sync = sync
+
+	condition := metav1.Condition{
+		Type:    ConditionSyncRestricted,
+		Status:  metav1.ConditionFalse,
+		Reason:  ReasonSyncAllowed,
+		Message: "The sync configuration is allowed by the policy",
+	}
+
+	if len(restrictions) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = ReasonSyncRestricted
+		condition.Message = fmt.Sprintf("Not allowed by the policy %q: %s", clusterPolicy.Name, strings.Join(restrictions, "; "))
+
+		// Only emit event when the restrictions change
+		if current := meta.FindStatusCondition(cluster.Status.Conditions, ConditionSyncRestricted); current == nil || current.Message != condition.Message {
+			c.Eventf(cluster, v1.EventTypeWarning, ReasonSyncRestricted, condition.Message)
+		}
+	}
+
+	meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+}
+
+// effectiveSyncConfig returns the sync configuration of the cluster limited by the one of its policy, and the
+// restrictions applied to the host classes and Gateways of the cluster.
+// A resource type is synced only if enabled by both, the selectors are merged with the precedence of the policy,
+// and the host classes and Gateways of the cluster must be allowed by the mappings of the policy. The Ingresses,
+// the PersistentVolumeClaims, the VolumeSnapshots and the Gateway routes are not synced if none of the host classes
+// or Gateways of the cluster is allowed, since an empty mapping allows all the classes and Gateways.
+func effectiveSyncConfig(clusterSync, policySync *v1beta1.SyncConfig) (*v1beta1.SyncConfig, []string) {
+	if policySync == nil {
+		return clusterSync.DeepCopy(), nil
+	}
+
+	if clusterSync == nil {
+		return policySync.DeepCopy(), nil
+	}
+
+	var restrictions []string
+
+	sync := clusterSync.DeepCopy()
+
+	sync.Services.Enabled = sync.Services.Enabled && policySync.Services.Enabled
+	sync.Services.Selector = mergeSelectors(sync.Services.Selector, policySync.Services.Selector)
+
+	sync.ConfigMaps.Enabled = sync.ConfigMaps.Enabled && policySync.ConfigMaps.Enabled
+	sync.ConfigMaps.Selector = mergeSelectors(sync.ConfigMaps.Selector, policySync.ConfigMaps.Selector)
+
+	sync.Secrets.Enabled = sync.Secrets.Enabled && policySync.Secrets.Enabled
+	sync.Secrets.Selector = mergeSelectors(sync.Secrets.Selector, policySync.Secrets.Selector)
+
+	sync.Ingresses.Enabled = sync.Ingresses.Enabled && policySync.Ingresses.Enabled
+	sync.Ingresses.Selector = mergeSelectors(sync.Ingresses.Selector, policySync.Ingresses.Selector)
+	ingressClassMapping, notAllowed := narrowClassMapping(sync.Ingresses.IngressClassMapping, policySync.Ingresses.IngressClassMapping)
+	sync.Ingresses.IngressClassMapping = ingressClassMapping
+
+	if len(notAllowed) > 0 {
+		restrictions = append(restrictions, "ingressClassMapping "+strings.Join(notAllowed, ", "))
+		sync.Ingresses.Enabled = sync.Ingresses.Enabled && len(ingressClassMapping) > 0
+	}
+
+	sync.GatewayRoutes.Enabled = sync.GatewayRoutes.Enabled && policySync.GatewayRoutes.Enabled
+	sync.GatewayRoutes.Selector = mergeSelectors(sync.GatewayRoutes.Selector, policySync.GatewayRoutes.Selector)
+	gateways, notAllowed := narrowGateways(sync.GatewayRoutes.Gateways, policySync.GatewayRoutes.Gateways)
+	sync.GatewayRoutes.Gateways = gateways
+
+	if len(notAllowed) > 0 {
+		restrictions = append(restrictions, "gateways "+strings.Join(notAllowed, ", "))
+		sync.GatewayRoutes.Enabled = sync.GatewayRoutes.Enabled && len(gateways) > 0
+	}
+
+	sync.PersistentVolumeClaims.Enabled = sync.PersistentVolumeClaims.Enabled && policySync.PersistentVolumeClaims.Enabled
+	sync.PersistentVolumeClaims.Selector = mergeSelectors(sync.PersistentVolumeClaims.Selector, policySync.PersistentVolumeClaims.Selector)
+	storageClassMapping, notAllowed := narrowClassMapping(sync.PersistentVolumeClaims.StorageClassMapping, policySync.PersistentVolumeClaims.StorageClassMapping)
+	sync.PersistentVolumeClaims.StorageClassMapping = storageClassMapping
+
+	if len(notAllowed) > 0 {
+		restrictions = append(restrictions, "storageClassMapping "+strings.Join(notAllowed, ", "))
+		sync.PersistentVolumeClaims.Enabled = sync.PersistentVolumeClaims.Enabled && len(storageClassMapping) > 0
+	}
+
+	sync.PersistentVolumeClaims.DefaultStorageClassName = defaultStorageClassName(sync.PersistentVolumeClaims.StorageClassMapping,
+		sync.PersistentVolumeClaims.DefaultStorageClassName, policySync.PersistentVolumeClaims.DefaultStorageClassName)
+
+	sync.PriorityClasses.Enabled = sync.PriorityClasses.Enabled && policySync.PriorityClasses.Enabled
+	sync.PriorityClasses.Selector = mergeSelectors(sync.PriorityClasses.Selector, policySync.PriorityClasses.Selector)
+
+	sync.VolumeSnapshots.Enabled = sync.VolumeSnapshots.Enabled && policySync.VolumeSnapshots.Enabled
+	sync.VolumeSnapshots.Selector = mergeSelectors(sync.VolumeSnapshots.Selector, policySync.VolumeSnapshots.Selector)
+
+	volumeSnapshotClassMapping, notAllowed := narrowClassMapping(sync.VolumeSnapshots.VolumeSnapshotClassMapping, policySync.VolumeSnapshots.VolumeSnapshotClassMapping)
+	sync.VolumeSnapshots.VolumeSnapshotClassMapping = volumeSnapshotClassMapping
+
+	if len(notAllowed) > 0 {
+		restrictions = append(restrictions, "volumeSnapshotClassMapping "+strings.Join(notAllowed, ", "))
+		sync.VolumeSnapshots.Enabled = sync.VolumeSnapshots.Enabled && len(volumeSnapshotClassMapping) > 0
+	}
+
+	sync.GarbageCollection.Enabled = sync.GarbageCollection.Enabled && policySync.GarbageCollection.Enabled
+	sync.GarbageCollection.DryRun = sync.GarbageCollection.DryRun || policySync.GarbageCollection.DryRun
+
+	// the additional kinds of the orphaned host objects are collected only if listed by both
+	sync.GarbageCollection.AdditionalKinds = slices.DeleteFunc(sync.GarbageCollection.AdditionalKinds, func(kind v1beta1.GarbageCollectedKind) bool {
+		return !slices.Contains(policySync.GarbageCollection.AdditionalKinds, kind)
+	})
+
+	return sync, restrictions
+}
+
+// mergeSelectors returns the labels required by both selectors. The policy labels take precedence,
+// so that the cluster can't select resources out of the policy selector.
+func mergeSelectors(clusterSelector, policySelector map[string]string) map[string]string {
+	if len(clusterSelector) == 0 && len(policySelector) == 0 {
+		return nil
+	}
+
+	selector := make(map[string]string, len(clusterSelector)+len(policySelector))
+	maps.Copy(selector, clusterSelector)
+	maps.Copy(selector, policySelector)
+
+	return selector
+}
+
+// narrowClassMapping returns the entries of the cluster mapping to the host classes mapped by the policy, and the
+// sorted host classes of the cluster not allowed. The policy mapping is used if the cluster doesn't map any class.
+func narrowClassMapping(clusterMapping, policyMapping map[string]string) (map[string]string, []string) {
+	if len(policyMapping) == 0 {
+		return clusterMapping, nil
+	}
+
+	if len(clusterMapping) == 0 {
+		return maps.Clone(policyMapping), nil
+	}
+
+	hostClasses := slices.Collect(maps.Values(policyMapping))
+
+	var (
+		mapping    map[string]string
+		notAllowed []string
+	)
+
+	for name, hostName := range clusterMapping {
+		if !slices.Contains(hostClasses, hostName) {
+			notAllowed = append(notAllowed, hostName)
+			continue
+		}
+
+		if mapping == nil {
+			mapping = make(map[string]string)
+		}
+
+		mapping[name] = hostName
+	}
+
+	slices.Sort(notAllowed)
+
+	return mapping, slices.Compact(notAllowed)
+}
+
+// defaultStorageClassName returns the default StorageClass of the cluster, or the one of the policy,
+// if still in the effective mapping.
+func defaultStorageClassName(mapping map[string]string, clusterDefault, policyDefault string) string {
+	for _, name := range []string{clusterDefault, policyDefault} {
+		if _, found := mapping[name]; found && name != "" {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// narrowGateways returns the Gateways of the cluster allowed by the policy, and the ones not allowed.
+// A Gateway of the policy without a sectionName allows all its listeners. The policy Gateways are used if the cluster
+// doesn't reference any Gateway, and no Gateway is used if none of the cluster is allowed.
+func narrowGateways(clusterGateways, policyGateways []v1beta1.GatewayReference) ([]v1beta1.GatewayReference, []string) {
+	if len(policyGateways) == 0 {
+		return clusterGateways, nil
+	}
+
+	if len(clusterGateways) == 0 {
+		return slices.Clone(policyGateways), nil
+	}
+
+	var (
+		gateways   []v1beta1.GatewayReference
+		notAllowed []string
+	)
+
+	for _, gateway := range clusterGateways {
+		allowed := slices.ContainsFunc(policyGateways, func(policyGateway v1beta1.GatewayReference) bool {
+			return policyGateway.Name == gateway.Name && policyGateway.Namespace == gateway.Namespace &&
+				(policyGateway.SectionName == "" || policyGateway.SectionName == gateway.SectionName)
+		})
+
+		if !allowed {
+			notAllowed = append(notAllowed, gatewayName(gateway))
+			continue
+		}
+
+		gateways = append(gateways, gateway)
+	}
+
+	return gateways, notAllowed
+}
+
+// gatewayName returns the namespaced name of the Gateway, with the name of its listener if set.
+func gatewayName(gateway v1beta1.GatewayReference) string {
+	name := gateway.Namespace + "/" + gateway.Name
+	if gateway.SectionName != "" {
+		name += "/" + gateway.SectionName
+	}
+
+	return name
+}
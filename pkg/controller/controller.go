@@ -9,6 +9,8 @@ import (
 
 	"k8s.io/apimachinery/pkg/util/wait"
 
+	v1 "k8s.io/api/core/v1"
+
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
 )
 
@@ -17,6 +19,15 @@ const (
 	AdminCommonName = "system:admin"
 )
 
+// CoreResources are the resources of the host nodes always advertised on the virtual nodes of the shared mode clusters,
+// that the extended resources can't be advertised as.
+var CoreResources = []v1.ResourceName{
+	v1.ResourceCPU,
+	v1.ResourceMemory,
+	v1.ResourcePods,
+	v1.ResourceEphemeralStorage,
+}
+
 // Backoff is the cluster creation duration backoff
 var Backoff = wait.Backoff{
 	Steps:    5,
@@ -42,6 +53,16 @@ func K3SImage(cluster *v1beta1.Cluster, k3SImage string) string {
 	return image + ":" + imageVersion
 }
 
+// SyncConfig returns the effective sync configuration of the cluster, merged with the one of its policy and
+// stored in the Status object. It falls back to the sync configuration of the cluster if not computed yet.
+func SyncConfig(cluster *v1beta1.Cluster) *v1beta1.SyncConfig {
+	if cluster.Status.Sync != nil {
+		return cluster.Status.Sync
+	}
+
+	return cluster.Spec.Sync
+}
+
 // SafeConcatNameWithPrefix runs the SafeConcatName with extra prefix.
 func SafeConcatNameWithPrefix(name ...string) string {
 	return SafeConcatName(append([]string{namePrefix}, name...)...)
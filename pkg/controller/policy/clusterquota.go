@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/quota/v1/evaluator/core"
+	"k8s.io/utils/clock"
+
+	v1 "k8s.io/api/core/v1"
+	quota "k8s.io/apiserver/pkg/quota/v1"
+)
+
+// PodsQuotaUsage returns the resources used by the pods, with the names of the ResourceQuotas
+// ("pods", "requests.cpu", "limits.memory", ...). As for the ResourceQuotas, the terminated pods are not counted.
+func PodsQuotaUsage(pods ...*v1.Pod) v1.ResourceList {
+	used := v1.ResourceList{}
+
+	for _, pod := range pods {
+		usage, err := core.PodUsageFunc(pod, clock.RealClock{})
+		if err != nil {
+			continue
+		}
+
+		used = quota.Add(used, usage)
+	}
+
+	return used
+}
+
+// PodsQuotaResources returns the resources of a quota limiting the pods, ignoring the ones limiting the other objects
+// ("services", "count/configmaps", ...).
+func PodsQuotaResources(names ...v1.ResourceName) []v1.ResourceName {
+	return core.NewPodEvaluator(nil, clock.RealClock{}).MatchingResources(names)
+}
+
+// QuotaStatus returns the hard limits of a quota, and the usage of the resources they limit.
+func QuotaStatus(hard, used v1.ResourceList) *v1.ResourceQuotaStatus {
+	status := &v1.ResourceQuotaStatus{
+		Hard: hard.DeepCopy(),
+		Used: v1.ResourceList{},
+	}
+
+	for name := range hard {
+		quantity, found := used[name]
+		if !found {
+			quantity = *resource.NewQuantity(0, resource.DecimalSI)
+		}
+
+		status.Used[name] = quantity.DeepCopy()
+	}
+
+	return status
+}
+
+// ExceededQuota returns the sorted names of the resources whose usage is over the hard limits of a quota.
+func ExceededQuota(hard, used v1.ResourceList) []v1.ResourceName {
+	_, exceeded := quota.LessThanOrEqual(quota.Mask(used, quota.ResourceNames(hard)), hard)
+	slices.Sort(exceeded)
+
+	return exceeded
+}
@@ -0,0 +1,178 @@
+package policy
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+// AppliedDefaultsAnnotationKey is the annotation of the clusters with the values of the fields defaulted by the policy,
+// to tell them apart from the values set by the clusters.
+const AppliedDefaultsAnnotationKey = "policy.k3k.io/applied-defaults"
+
+// policyDefaults returns the default values of the policy, including the deprecated default fields.
+func policyDefaults(policy *v1beta1.VirtualClusterPolicy) v1beta1.ClusterValues {
+	var defaults v1beta1.ClusterValues
+	if policy.Spec.Defaults != nil {
+		defaults = *policy.Spec.Defaults.DeepCopy()
+	}
+
+	if defaults.PriorityClass == "" {
+		defaults.PriorityClass = policy.Spec.DefaultPriorityClass
+	}
+
+	if len(defaults.NodeSelector) == 0 {
+		defaults.NodeSelector = maps.Clone(policy.Spec.DefaultNodeSelector)
+	}
+
+	return defaults
+}
+
+// applyClusterValues sets the default values of the policy in the fields left unset by the cluster, or still
+// defaulted by the policy, and then the enforced values. The fields defaulted are recorded in an annotation.
+func applyClusterValues(cluster *v1beta1.Cluster, policy *v1beta1.VirtualClusterPolicy) {
+	// an invalid annotation is ignored, and the fields set are kept
+	var applied v1beta1.ClusterValues
+	if value, found := cluster.Annotations[AppliedDefaultsAnnotationKey]; found {
+		_ = json.Unmarshal([]byte(value), &applied)
+	}
+
+	defaults := policyDefaults(policy)
+	spec := &cluster.Spec
+
+	versionDefaulted := applyDefault(&spec.Version, defaults.Version, applied.Version)
+	nodeSelectorDefaulted := applyDefault(&spec.NodeSelector, defaults.NodeSelector, applied.NodeSelector)
+	priorityClassDefaulted := applyDefault(&spec.PriorityClass, defaults.PriorityClass, applied.PriorityClass)
+	serverLimitDefaulted := applyDefault(&spec.ServerLimit, defaults.ServerLimit, applied.ServerLimit)
+	workerLimitDefaulted := applyDefault(&spec.WorkerLimit, defaults.WorkerLimit, applied.WorkerLimit)
+
+	if enforced := policy.Spec.Enforced; enforced != nil {
+		if enforced.Version != "" {
+			spec.Version = enforced.Version
+		}
+
+		if enforced.PriorityClass != "" {
+			spec.PriorityClass = enforced.PriorityClass
+		}
+
+		spec.NodeSelector = mergeNodeSelector(spec.NodeSelector, enforced.NodeSelector)
+		spec.ServerLimit = mergeResources(spec.ServerLimit, enforced.ServerLimit)
+		spec.WorkerLimit = mergeResources(spec.WorkerLimit, enforced.WorkerLimit)
+	}
+
+	// the final values are recorded, so that the defaulted fields are still recognized after the enforced values
+	applied = v1beta1.ClusterValues{}
+
+	if versionDefaulted {
+		applied.Version = spec.Version
+	}
+
+	if nodeSelectorDefaulted {
+		applied.NodeSelector = spec.NodeSelector
+	}
+
+	if priorityClassDefaulted {
+		applied.PriorityClass = spec.PriorityClass
+	}
+
+	if serverLimitDefaulted {
+		applied.ServerLimit = spec.ServerLimit
+	}
+
+	if workerLimitDefaulted {
+		applied.WorkerLimit = spec.WorkerLimit
+	}
+
+	setAppliedDefaults(cluster, applied)
+}
+
+// applyDefault sets the field to the default value if it's unset, or if it still has the value applied by the policy.
+// It returns true if the field was defaulted.
+func applyDefault[T any](field *T, defaultValue, applied T) bool {
+	if !isEmpty(*field) && (isEmpty(applied) || !equality.Semantic.DeepEqual(*field, applied)) {
+		// the value is set by the cluster
+		return false
+	}
+
+	if isEmpty(defaultValue) {
+		// the default removed from the policy is removed from the cluster
+		if !isEmpty(*field) {
+			var zero T
+			*field = zero
+		}
+
+		return false
+	}
+
+	*field = defaultValue
+
+	return true
+}
+
+// isEmpty returns true for the zero values, and the empty maps and slices.
+func isEmpty(value any) bool {
+	v := reflect.ValueOf(value)
+
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+func mergeNodeSelector(nodeSelector, enforced map[string]string) map[string]string {
+	if len(enforced) == 0 {
+		return nodeSelector
+	}
+
+	merged := maps.Clone(nodeSelector)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+
+	maps.Copy(merged, enforced)
+
+	return merged
+}
+
+func mergeResources(resources, enforced v1.ResourceList) v1.ResourceList {
+	if len(enforced) == 0 {
+		return resources
+	}
+
+	merged := resources.DeepCopy()
+	if merged == nil {
+		merged = v1.ResourceList{}
+	}
+
+	for name, quantity := range enforced {
+		merged[name] = quantity.DeepCopy()
+	}
+
+	return merged
+}
+
+func setAppliedDefaults(cluster *v1beta1.Cluster, applied v1beta1.ClusterValues) {
+	if equality.Semantic.DeepEqual(applied, v1beta1.ClusterValues{}) {
+		delete(cluster.Annotations, AppliedDefaultsAnnotationKey)
+		return
+	}
+
+	value, err := json.Marshal(applied)
+	if err != nil {
+		return
+	}
+
+	if cluster.Annotations == nil {
+		cluster.Annotations = map[string]string{}
+	}
+
+	cluster.Annotations[AppliedDefaultsAnnotationKey] = string(value)
+}
@@ -11,6 +11,7 @@ import (
 	networkingv1 "k8s.io/api/networking/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	"github.com/rancher/k3k/k3k-kubelet/translate"
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
 )
 
@@ -90,3 +91,9 @@ func (c *VirtualClusterPolicyReconciler) cleanupNamespaces(ctx context.Context)
 
 	return nil
 }
+
+// isClusterHostNamespace returns true if the namespace is a dedicated host namespace of a cluster with the PerNamespace
+// host namespace mode, bound to the policy of the namespace of the cluster.
+func isClusterHostNamespace(namespace *v1.Namespace) bool {
+	return namespace.Labels[translate.ClusterNamespaceLabel] != ""
+}
@@ -2,6 +2,11 @@ package policy
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -9,33 +14,21 @@ import (
 	networkingv1 "k8s.io/api/networking/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	netutils "k8s.io/utils/net"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	"github.com/rancher/k3k/k3k-kubelet/translate"
 	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
 	k3kcontroller "github.com/rancher/k3k/pkg/controller"
 )
 
-func (c *VirtualClusterPolicyReconciler) reconcileNetworkPolicy(ctx context.Context, namespace string, policy *v1beta1.VirtualClusterPolicy) error {
+func (c *VirtualClusterPolicyReconciler) reconcileNetworkPolicy(ctx context.Context, namespace *v1.Namespace, policy *v1beta1.VirtualClusterPolicy) error {
 	log := ctrl.LoggerFrom(ctx)
 	log.V(1).Info("Reconciling NetworkPolicy")
 
-	var cidrList []string
-
-	if c.ClusterCIDR != "" {
-		cidrList = []string{c.ClusterCIDR}
-	} else {
-		var nodeList v1.NodeList
-		if err := c.Client.List(ctx, &nodeList); err != nil {
-			return err
-		}
-
-		for _, node := range nodeList.Items {
-			if len(node.Spec.PodCIDRs) > 0 {
-				cidrList = append(cidrList, node.Spec.PodCIDRs...)
-			} else {
-				cidrList = append(cidrList, node.Spec.PodCIDR)
-			}
-		}
+	cidrList, err := c.clusterCIDRs(ctx)
+	if err != nil {
+		return err
 	}
 
 	networkPolicy := networkPolicy(namespace, policy, cidrList)
@@ -51,10 +44,14 @@ func (c *VirtualClusterPolicyReconciler) reconcileNetworkPolicy(ctx context.Cont
 		return client.IgnoreNotFound(c.Client.Delete(ctx, networkPolicy))
 	}
 
+	if err := validateNetworkPolicyConfig(policy.Spec.NetworkPolicy); err != nil {
+		return err
+	}
+
 	log.V(1).Info("Creating NetworkPolicy")
 
 	// otherwise try to create/update
-	err := c.Client.Create(ctx, networkPolicy)
+	err = c.Client.Create(ctx, networkPolicy)
 	if apierrors.IsAlreadyExists(err) {
 		log.V(1).Info("NetworkPolicy already exists, updating.")
 
@@ -64,7 +61,100 @@ func (c *VirtualClusterPolicyReconciler) reconcileNetworkPolicy(ctx context.Cont
 	return err
 }
 
-func networkPolicy(namespaceName string, policy *v1beta1.VirtualClusterPolicy, cidrList []string) *networkingv1.NetworkPolicy {
+// clusterCIDRs returns the CIDRs of the cluster network, from the configured ClusterCIDR, a comma separated list
+// for the dual-stack clusters, or from the PodCIDRs of all the nodes.
+func (c *VirtualClusterPolicyReconciler) clusterCIDRs(ctx context.Context) ([]string, error) {
+	var cidrList []string
+
+	if c.ClusterCIDR != "" {
+		for _, cidr := range strings.Split(c.ClusterCIDR, ",") {
+			cidrList = append(cidrList, strings.TrimSpace(cidr))
+		}
+
+		return cidrList, nil
+	}
+
+	var nodeList v1.NodeList
+	if err := c.Client.List(ctx, &nodeList); err != nil {
+		return nil, err
+	}
+
+	for _, node := range nodeList.Items {
+		if len(node.Spec.PodCIDRs) > 0 {
+			cidrList = append(cidrList, node.Spec.PodCIDRs...)
+		} else if node.Spec.PodCIDR != "" {
+			cidrList = append(cidrList, node.Spec.PodCIDR)
+		}
+	}
+
+	slices.Sort(cidrList)
+
+	return slices.Compact(cidrList), nil
+}
+
+// validateNetworkPolicyConfig checks the CIDRs of the network policy config of the policy.
+func validateNetworkPolicyConfig(config *v1beta1.NetworkPolicyConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	var errs []error
+
+	for _, cidr := range slices.Concat(config.AllowedEgressCIDRs, config.AllowedIngressCIDRs) {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			errs = append(errs, fmt.Errorf("invalid CIDR %q: %w", cidr, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func networkPolicy(namespace *v1.Namespace, policy *v1beta1.VirtualClusterPolicy, cidrList []string) *networkingv1.NetworkPolicy {
+	// the pods of the clusters in the namespace can reach their dedicated host namespaces, and the other way around
+	clusterNamespace := namespace.Name
+	if namespace.Labels[translate.ClusterNamespaceLabel] != "" {
+		clusterNamespace = namespace.Labels[translate.ClusterNamespaceLabel]
+	}
+
+	clusterPeers := []networkingv1.NetworkPolicyPeer{
+		namespacePeer(namespace.Name),
+		namespacePeer(clusterNamespace),
+		{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					translate.ClusterNamespaceLabel: clusterNamespace,
+				},
+			},
+		},
+	}
+
+	// the addresses out of the cluster network are reachable, for each IP family of the cluster
+	egressPeers := ExternalPeers(cidrList)
+	egressPeers = append(egressPeers, clusterPeers...)
+	egressPeers = append(egressPeers, networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"kubernetes.io/metadata.name": metav1.NamespaceSystem,
+			},
+		},
+		PodSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"k8s-app": "kube-dns",
+			},
+		},
+	})
+
+	// ingress is allowed from everywhere, unless restricted by the policy
+	ingressRule := networkingv1.NetworkPolicyIngressRule{}
+
+	if config := policy.Spec.NetworkPolicy; config != nil {
+		egressPeers = append(egressPeers, allowedPeers(config.AllowedEgressCIDRs, config.AllowedEgressNamespaces)...)
+
+		if config.RestrictIngress {
+			ingressRule.From = append(clusterPeers, allowedPeers(config.AllowedIngressCIDRs, config.AllowedIngressNamespaces)...)
+		}
+	}
+
 	return &networkingv1.NetworkPolicy{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "NetworkPolicy",
@@ -72,7 +162,7 @@ func networkPolicy(namespaceName string, policy *v1beta1.VirtualClusterPolicy, c
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      k3kcontroller.SafeConcatNameWithPrefix(policy.Name),
-			Namespace: namespaceName,
+			Namespace: namespace.Name,
 			Labels: map[string]string{
 				ManagedByLabelKey:  VirtualPolicyControllerName,
 				PolicyNameLabelKey: policy.Name,
@@ -84,39 +174,87 @@ func networkPolicy(namespaceName string, policy *v1beta1.VirtualClusterPolicy, c
 				networkingv1.PolicyTypeEgress,
 			},
 			Ingress: []networkingv1.NetworkPolicyIngressRule{
-				{},
+				ingressRule,
 			},
 			Egress: []networkingv1.NetworkPolicyEgressRule{
 				{
-					To: []networkingv1.NetworkPolicyPeer{
-						{
-							IPBlock: &networkingv1.IPBlock{
-								CIDR:   "0.0.0.0/0",
-								Except: cidrList,
-							},
-						},
-						{
-							NamespaceSelector: &metav1.LabelSelector{
-								MatchLabels: map[string]string{
-									"kubernetes.io/metadata.name": namespaceName,
-								},
-							},
-						},
-						{
-							NamespaceSelector: &metav1.LabelSelector{
-								MatchLabels: map[string]string{
-									"kubernetes.io/metadata.name": metav1.NamespaceSystem,
-								},
-							},
-							PodSelector: &metav1.LabelSelector{
-								MatchLabels: map[string]string{
-									"k8s-app": "kube-dns",
-								},
-							},
-						},
-					},
+					To: egressPeers,
 				},
 			},
 		},
 	}
 }
+
+// ExternalPeers returns the peers of the addresses out of the cluster networks, for each IP family of the CIDRs.
+// The IPv4 addresses are always included, and the IPv6 ones only if an IPv6 CIDR is in the list.
+func ExternalPeers(cidrList []string) []networkingv1.NetworkPolicyPeer {
+	ipv4CIDRs, ipv6CIDRs := splitCIDRsByFamily(cidrList)
+
+	peers := []networkingv1.NetworkPolicyPeer{
+		{
+			IPBlock: &networkingv1.IPBlock{
+				CIDR:   "0.0.0.0/0",
+				Except: ipv4CIDRs,
+			},
+		},
+	}
+
+	if len(ipv6CIDRs) > 0 {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			IPBlock: &networkingv1.IPBlock{
+				CIDR:   "::/0",
+				Except: ipv6CIDRs,
+			},
+		})
+	}
+
+	return peers
+}
+
+// splitCIDRsByFamily returns the IPv4 and the IPv6 CIDRs of the list.
+func splitCIDRsByFamily(cidrList []string) ([]string, []string) {
+	var ipv4CIDRs, ipv6CIDRs []string
+
+	for _, cidr := range cidrList {
+		cidr = strings.TrimSpace(cidr)
+
+		if cidr == "" {
+			continue
+		}
+
+		if netutils.IsIPv6CIDRString(cidr) {
+			ipv6CIDRs = append(ipv6CIDRs, cidr)
+		} else {
+			ipv4CIDRs = append(ipv4CIDRs, cidr)
+		}
+	}
+
+	return ipv4CIDRs, ipv6CIDRs
+}
+
+// allowedPeers returns the peers of the CIDRs and of the namespaces allowed by the policy.
+func allowedPeers(cidrList, namespaces []string) []networkingv1.NetworkPolicyPeer {
+	var peers []networkingv1.NetworkPolicyPeer
+
+	for _, cidr := range cidrList {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			IPBlock: &networkingv1.IPBlock{CIDR: cidr},
+		})
+	}
+
+	for _, namespace := range namespaces {
+		peers = append(peers, namespacePeer(namespace))
+	}
+
+	return peers
+}
+
+func namespacePeer(namespace string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"kubernetes.io/metadata.name": namespace,
+			},
+		},
+	}
+}
@@ -3,16 +3,23 @@ package policy
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"slices"
+	"strings"
+	"time"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	v1 "k8s.io/api/core/v1"
@@ -45,20 +52,48 @@ func Add(mgr manager.Manager, clusterCIDR string, maxConcurrentReconciles int) e
 		ClusterCIDR: clusterCIDR,
 	}
 
+	if err := addNamespaceSelectorController(mgr, maxConcurrentReconciles); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1beta1.VirtualClusterPolicy{}).
-		Watches(&v1.Namespace{}, namespaceEventHandler()).
+		Watches(&v1.Namespace{}, namespaceEventHandler(&reconciler)).
 		Watches(&v1.Node{}, nodeEventHandler(&reconciler)).
 		Watches(&v1beta1.Cluster{}, clusterEventHandler(&reconciler)).
 		Owns(&networkingv1.NetworkPolicy{}).
-		Owns(&v1.ResourceQuota{}).
+		Owns(&v1.ResourceQuota{}, builder.WithPredicates(resourceQuotaPredicate)).
 		Owns(&v1.LimitRange{}).
 		WithOptions(controller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}).
 		Complete(&reconciler)
 }
 
+// resourceQuotaPredicate filters the updates of the ResourceQuotas of the policies changing only their usage, updated
+// with every pod of the namespace. The usage reported in the status of the policy is refreshed periodically instead.
+var resourceQuotaPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldQuota, okOld := e.ObjectOld.(*v1.ResourceQuota)
+		newQuota, okNew := e.ObjectNew.(*v1.ResourceQuota)
+
+		if !okOld || !okNew {
+			return false
+		}
+
+		return !equality.Semantic.DeepEqual(oldQuota.Spec, newQuota.Spec) ||
+			!reflect.DeepEqual(oldQuota.Labels, newQuota.Labels) ||
+			!equality.Semantic.DeepEqual(oldQuota.Status.Hard, newQuota.Status.Hard)
+	},
+}
+
 // namespaceEventHandler will enqueue a reconciliation of VCP when a Namespace changes
-func namespaceEventHandler() handler.Funcs {
+func namespaceEventHandler(r *VirtualClusterPolicyReconciler) handler.Funcs {
+	// enqueue the VirtualClusterPolicies whose namespaceSelector matches the Namespace, to report the conflicts
+	enqueueSelectorVCPs := func(ctx context.Context, q workqueue.TypedRateLimitingInterface[reconcile.Request], namespaces ...*v1.Namespace) {
+		for _, name := range r.selectorPolicies(ctx, namespaces...) {
+			q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+		}
+	}
+
 	return handler.Funcs{
 		// When a Namespace is created, if it has the "policy.k3k.io/policy-name" label
 		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
@@ -67,6 +102,8 @@ func namespaceEventHandler() handler.Funcs {
 				return
 			}
 
+			enqueueSelectorVCPs(ctx, q, ns)
+
 			if ns.Labels[PolicyNameLabelKey] != "" {
 				q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: ns.Labels[PolicyNameLabelKey]}})
 			}
@@ -88,6 +125,8 @@ func namespaceEventHandler() handler.Funcs {
 				return
 			}
 
+			enqueueSelectorVCPs(ctx, q, oldNs, newNs)
+
 			// If No VCP before and after we can skip the reconciliation
 			if oldVCPName == "" && newVCPName == "" {
 				return
@@ -185,9 +224,14 @@ func nodeEventHandler(r *VirtualClusterPolicyReconciler) handler.Funcs {
 
 // clusterEventHandler will enqueue a reconciliation of the VCP associated to the Namespace when a Cluster changes.
 func clusterEventHandler(r *VirtualClusterPolicyReconciler) handler.Funcs {
+	// the fields of the cluster set by the policy, and the annotation recording the defaulted ones
 	type clusterSubSpec struct {
-		PriorityClass string
-		NodeSelector  map[string]string
+		Version         string
+		PriorityClass   string
+		NodeSelector    map[string]string
+		ServerLimit     v1.ResourceList
+		WorkerLimit     v1.ResourceList
+		AppliedDefaults string
 	}
 
 	return handler.Funcs{
@@ -227,21 +271,42 @@ func clusterEventHandler(r *VirtualClusterPolicyReconciler) handler.Funcs {
 			}
 
 			clusterSubSpecOld := clusterSubSpec{
-				PriorityClass: oldCluster.Spec.PriorityClass,
-				NodeSelector:  oldCluster.Spec.NodeSelector,
+				Version:         oldCluster.Spec.Version,
+				PriorityClass:   oldCluster.Spec.PriorityClass,
+				NodeSelector:    oldCluster.Spec.NodeSelector,
+				ServerLimit:     oldCluster.Spec.ServerLimit,
+				WorkerLimit:     oldCluster.Spec.WorkerLimit,
+				AppliedDefaults: oldCluster.Annotations[AppliedDefaultsAnnotationKey],
 			}
 
 			clusterSubSpecNew := clusterSubSpec{
-				PriorityClass: newCluster.Spec.PriorityClass,
-				NodeSelector:  newCluster.Spec.NodeSelector,
+				Version:         newCluster.Spec.Version,
+				PriorityClass:   newCluster.Spec.PriorityClass,
+				NodeSelector:    newCluster.Spec.NodeSelector,
+				ServerLimit:     newCluster.Spec.ServerLimit,
+				WorkerLimit:     newCluster.Spec.WorkerLimit,
+				AppliedDefaults: newCluster.Annotations[AppliedDefaultsAnnotationKey],
 			}
 
 			if !reflect.DeepEqual(clusterSubSpecOld, clusterSubSpecNew) {
 				q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: ns.Labels[PolicyNameLabelKey]}})
 			}
 		},
-		// When a Cluster is deleted -> nothing to do
+		// When a Cluster is deleted, if its Namespace has the "policy.k3k.io/policy-name" label, to update the status
 		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			cluster, ok := e.Object.(*v1beta1.Cluster)
+			if !ok {
+				return
+			}
+
+			var ns v1.Namespace
+			if err := r.Client.Get(ctx, types.NamespacedName{Name: cluster.Namespace}, &ns); err != nil {
+				return
+			}
+
+			if ns.Labels[PolicyNameLabelKey] != "" {
+				q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: ns.Labels[PolicyNameLabelKey]}})
+			}
 		},
 	}
 }
@@ -259,10 +324,14 @@ func (c *VirtualClusterPolicyReconciler) Reconcile(ctx context.Context, req reco
 
 	reconcilerErr := c.reconcileVirtualClusterPolicy(ctx, &policy)
 
+	policy.Status.ObservedGeneration = policy.Generation
+
 	// update Status if needed
 	if !reflect.DeepEqual(orig.Status, policy.Status) {
 		log.Info("Updating VirtualClusterPolicy Status")
 
+		policy.Status.LastUpdate = time.Now().UTC().Format(time.RFC3339)
+
 		if err := c.Client.Status().Update(ctx, &policy); err != nil {
 			return reconcile.Result{}, err
 		}
@@ -282,21 +351,30 @@ func (c *VirtualClusterPolicyReconciler) Reconcile(ctx context.Context, req reco
 		}
 	}
 
-	return reconcile.Result{}, nil
+	return reconcile.Result{RequeueAfter: quotaStatusRequeueAfter(&policy)}, nil
 }
 
 func (c *VirtualClusterPolicyReconciler) reconcileVirtualClusterPolicy(ctx context.Context, policy *v1beta1.VirtualClusterPolicy) error {
+	// the failures are collected, so that the resources of the other namespaces are still reconciled
+	var errs []error
+
 	if err := c.reconcileMatchingNamespaces(ctx, policy); err != nil {
-		return err
+		errs = append(errs, err)
 	}
 
 	if err := c.cleanupNamespaces(ctx); err != nil {
-		return err
+		errs = append(errs, err)
+	}
+
+	if err := c.reconcileNamespaceConflicts(ctx, policy); err != nil {
+		errs = append(errs, err)
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
+// reconcileMatchingNamespaces reconciles the resources of the policy in the bound namespaces, reporting the result
+// for each namespace in the status of the policy. A failing namespace doesn't block the others.
 func (c *VirtualClusterPolicyReconciler) reconcileMatchingNamespaces(ctx context.Context, policy *v1beta1.VirtualClusterPolicy) error {
 	log := ctrl.LoggerFrom(ctx)
 	log.V(1).Info("Reconciling matching Namespaces")
@@ -310,49 +388,102 @@ func (c *VirtualClusterPolicyReconciler) reconcileMatchingNamespaces(ctx context
 		return err
 	}
 
+	var (
+		errs              []error
+		namespaceStatuses []v1beta1.PolicyNamespaceStatus
+	)
+
 	for _, ns := range namespaces.Items {
-		log = log.WithValues("namespace", ns.Name)
-		ctx = ctrl.LoggerInto(ctx, log)
+		nsCtx := ctrl.LoggerInto(ctx, log.WithValues("namespace", ns.Name))
 
-		log.V(1).Info("Reconciling Namespace")
+		namespaceStatus, err := c.reconcileNamespace(nsCtx, &ns, policy)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to reconcile namespace %s: %w", ns.Name, err))
+		}
 
-		orig := ns.DeepCopy()
+		namespaceStatuses = append(namespaceStatuses, namespaceStatus)
+	}
 
-		if err := c.reconcileNetworkPolicy(ctx, ns.Name, policy); err != nil {
-			return err
-		}
+	// the namespaces are sorted, so that the status doesn't change with the order of the list
+	slices.SortFunc(namespaceStatuses, func(a, b v1beta1.PolicyNamespaceStatus) int {
+		return strings.Compare(a.Name, b.Name)
+	})
 
-		if err := c.reconcileQuota(ctx, ns.Name, policy); err != nil {
-			return err
-		}
+	policy.Status.Namespaces = namespaceStatuses
 
-		if err := c.reconcileLimit(ctx, ns.Name, policy); err != nil {
-			return err
-		}
+	if err := c.reconcileQuotaStatus(ctx, policy); err != nil {
+		errs = append(errs, err)
+	}
 
-		if err := c.reconcileClusters(ctx, &ns, policy); err != nil {
-			return err
-		}
+	updatePolicySummary(policy)
 
-		c.reconcileNamespacePodSecurityLabels(ctx, &ns, policy)
+	return errors.Join(errs...)
+}
+
+// reconcileNamespace reconciles the resources of the policy in a bound namespace, and returns the result for each of them.
+func (c *VirtualClusterPolicyReconciler) reconcileNamespace(ctx context.Context, ns *v1.Namespace, policy *v1beta1.VirtualClusterPolicy) (v1beta1.PolicyNamespaceStatus, error) {
+	log := ctrl.LoggerFrom(ctx)
+	log.V(1).Info("Reconciling Namespace")
 
-		if !reflect.DeepEqual(orig, &ns) {
-			log.Info("Updating Namespace")
+	var errs []error
 
-			if err := c.Client.Update(ctx, &ns); err != nil {
-				return err
-			}
+	result := func(err error) v1beta1.PolicyResourceStatus {
+		if err != nil {
+			errs = append(errs, err)
+			return v1beta1.PolicyResourceStatus{Error: err.Error()}
 		}
+
+		return v1beta1.PolicyResourceStatus{Synced: true}
+	}
+
+	namespaceStatus := v1beta1.PolicyNamespaceStatus{Name: ns.Name}
+
+	orig := ns.DeepCopy()
+
+	namespaceStatus.NetworkPolicy = result(c.reconcileNetworkPolicy(ctx, ns, policy))
+	namespaceStatus.Quota = result(c.reconcileQuota(ctx, ns, policy))
+	namespaceStatus.LimitRange = result(c.reconcileLimit(ctx, ns.Name, policy))
+
+	if err := c.reconcileClusters(ctx, ns, policy); err != nil {
+		errs = append(errs, err)
+	}
+
+	var clusters v1beta1.ClusterList
+	if err := c.Client.List(ctx, &clusters, client.InNamespace(ns.Name)); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, cluster := range clusters.Items {
+		namespaceStatus.Clusters = append(namespaceStatus.Clusters, cluster.Name)
 	}
 
-	return nil
+	slices.Sort(namespaceStatus.Clusters)
+
+	c.reconcileNamespacePodSecurityLabels(ctx, ns, policy)
+
+	var updateErr error
+
+	if !reflect.DeepEqual(orig, ns) {
+		log.Info("Updating Namespace")
+
+		updateErr = c.Client.Update(ctx, ns)
+	}
+
+	namespaceStatus.PodSecurityAdmission = result(updateErr)
+
+	return namespaceStatus, errors.Join(errs...)
 }
 
-func (c *VirtualClusterPolicyReconciler) reconcileQuota(ctx context.Context, namespace string, policy *v1beta1.VirtualClusterPolicy) error {
+// reconcileQuota creates the ResourceQuota of the policy in a bound namespace. The dedicated host namespaces of the
+// clusters don't have their own ResourceQuota, otherwise a cluster would get the whole quota for each virtual namespace:
+// the quota of the namespace of the cluster is enforced by its k3k-kubelet across all its host namespaces.
+func (c *VirtualClusterPolicyReconciler) reconcileQuota(ctx context.Context, ns *v1.Namespace, policy *v1beta1.VirtualClusterPolicy) error {
 	log := ctrl.LoggerFrom(ctx)
 	log.V(1).Info("Reconciling ResourceQuota")
 
-	if policy.Spec.Quota == nil {
+	namespace := ns.Name
+
+	if policy.Spec.Quota == nil || isClusterHostNamespace(ns) {
 		// check if resourceQuota object exists and deletes it.
 		var toDeleteResourceQuota v1.ResourceQuota
 
@@ -471,10 +602,9 @@ func (c *VirtualClusterPolicyReconciler) reconcileClusters(ctx context.Context,
 	for _, cluster := range clusters.Items {
 		orig := cluster.DeepCopy()
 
-		cluster.Spec.PriorityClass = policy.Spec.DefaultPriorityClass
-		cluster.Spec.NodeSelector = policy.Spec.DefaultNodeSelector
+		applyClusterValues(&cluster, policy)
 
-		if !reflect.DeepEqual(orig, cluster) {
+		if !reflect.DeepEqual(orig, &cluster) {
 			log.V(1).Info("Updating Cluster", "cluster", cluster.Name, "namespace", namespace.Name)
 
 			// continue updating also the other clusters even if an error occurred
@@ -5,8 +5,10 @@ import (
 	"reflect"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -162,6 +164,101 @@ var _ = Describe("VirtualClusterPolicy Controller", Label("controller"), Label("
 					Should(BeNil())
 			})
 
+			It("should allow the traffic configured in the policy", func() {
+				policy := newPolicy(v1beta1.VirtualClusterPolicySpec{
+					NetworkPolicy: &v1beta1.NetworkPolicyConfig{
+						AllowedEgressCIDRs:       []string{"10.0.0.0/24", "fd00::/64"},
+						AllowedEgressNamespaces:  []string{"monitoring"},
+						RestrictIngress:          true,
+						AllowedIngressNamespaces: []string{"k3k-system"},
+					},
+				})
+				bindPolicyToNamespace(namespace, policy)
+
+				networkPolicy := &networkingv1.NetworkPolicy{}
+
+				Eventually(func() error {
+					key := types.NamespacedName{
+						Name:      k3kcontroller.SafeConcatNameWithPrefix(policy.Name),
+						Namespace: namespace.Name,
+					}
+					return k8sClient.Get(ctx, key, networkPolicy)
+				}).
+					WithTimeout(time.Minute).
+					WithPolling(time.Second).
+					Should(BeNil())
+
+				namespaceRule := func(name string) networkingv1.NetworkPolicyPeer {
+					return networkingv1.NetworkPolicyPeer{
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"kubernetes.io/metadata.name": name},
+						},
+					}
+				}
+
+				spec := networkPolicy.Spec
+
+				Expect(spec.Egress).To(HaveLen(1))
+				Expect(spec.Egress[0].To).To(ContainElements(
+					networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/24"}},
+					networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: "fd00::/64"}},
+					namespaceRule("monitoring"),
+				))
+
+				// ingress is allowed only from the namespace and the allowed namespaces
+				Expect(spec.Ingress).To(HaveLen(1))
+				Expect(spec.Ingress[0].From).To(ContainElements(
+					namespaceRule(namespace.Name),
+					namespaceRule("k3k-system"),
+				))
+				Expect(spec.Ingress[0].From).To(Not(ContainElement(namespaceRule("monitoring"))))
+			})
+
+			It("should exclude the IPv4 and IPv6 PodCIDRs of the nodes", func() {
+				node := &v1.Node{
+					ObjectMeta: metav1.ObjectMeta{
+						GenerateName: "node-",
+					},
+					Spec: v1.NodeSpec{
+						PodCIDR:  "10.42.0.0/24",
+						PodCIDRs: []string{"10.42.0.0/24", "fd42::/64"},
+					},
+				}
+
+				err := k8sClient.Create(ctx, node)
+				Expect(err).To(Not(HaveOccurred()))
+
+				DeferCleanup(func() {
+					Expect(k8sClient.Delete(ctx, node)).To(Succeed())
+				})
+
+				policy := newPolicy(v1beta1.VirtualClusterPolicySpec{})
+				bindPolicyToNamespace(namespace, policy)
+
+				Eventually(func(g Gomega) {
+					var networkPolicy networkingv1.NetworkPolicy
+
+					key := types.NamespacedName{
+						Name:      k3kcontroller.SafeConcatNameWithPrefix(policy.Name),
+						Namespace: namespace.Name,
+					}
+					g.Expect(k8sClient.Get(ctx, key, &networkPolicy)).To(Succeed())
+
+					g.Expect(networkPolicy.Spec.Egress).To(HaveLen(1))
+					g.Expect(networkPolicy.Spec.Egress[0].To).To(ContainElements(
+						networkingv1.NetworkPolicyPeer{
+							IPBlock: &networkingv1.IPBlock{CIDR: "0.0.0.0/0", Except: []string{"10.42.0.0/24"}},
+						},
+						networkingv1.NetworkPolicyPeer{
+							IPBlock: &networkingv1.IPBlock{CIDR: "::/0", Except: []string{"fd42::/64"}},
+						},
+					))
+				}).
+					WithTimeout(time.Minute).
+					WithPolling(time.Second).
+					Should(Succeed())
+			})
+
 			It("should add and update the proper pod-security labels to the namespace", func() {
 				var (
 					privileged = v1beta1.PrivilegedPodSecurityAdmissionLevel
@@ -377,7 +474,7 @@ var _ = Describe("VirtualClusterPolicy Controller", Label("controller"), Label("
 					Should(BeTrue())
 			})
 
-			It("should update the nodeSelector if changed", func() {
+			It("should update the defaulted nodeSelector if changed", func() {
 				policy := newPolicy(v1beta1.VirtualClusterPolicySpec{
 					DefaultNodeSelector: map[string]string{"label-1": "value-1"},
 				})
@@ -389,23 +486,30 @@ var _ = Describe("VirtualClusterPolicy Controller", Label("controller"), Label("
 						Namespace:    namespace.Name,
 					},
 					Spec: v1beta1.ClusterSpec{
-						Mode:         v1beta1.SharedClusterMode,
-						Servers:      ptr.To[int32](1),
-						Agents:       ptr.To[int32](0),
-						NodeSelector: map[string]string{"label-1": "value-1"},
+						Mode:    v1beta1.SharedClusterMode,
+						Servers: ptr.To[int32](1),
+						Agents:  ptr.To[int32](0),
 					},
 				}
 
 				err := k8sClient.Create(ctx, cluster)
 				Expect(err).To(Not(HaveOccurred()))
 
-				Expect(cluster.Spec.NodeSelector).To(Equal(policy.Spec.DefaultNodeSelector))
+				// wait a bit
+				Eventually(func() bool {
+					key := types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}
+					err = k8sClient.Get(ctx, key, cluster)
+					Expect(err).To(Not(HaveOccurred()))
+					return reflect.DeepEqual(cluster.Spec.NodeSelector, policy.Spec.DefaultNodeSelector)
+				}).
+					WithTimeout(time.Second * 10).
+					WithPolling(time.Second).
+					Should(BeTrue())
 
 				// update the VirtualClusterPolicy
 				policy.Spec.DefaultNodeSelector["label-2"] = "value-2"
 				err = k8sClient.Update(ctx, policy)
 				Expect(err).To(Not(HaveOccurred()))
-				Expect(cluster.Spec.NodeSelector).To(Not(Equal(policy.Spec.DefaultNodeSelector)))
 
 				// wait a bit
 				Eventually(func() bool {
@@ -422,20 +526,108 @@ var _ = Describe("VirtualClusterPolicy Controller", Label("controller"), Label("
 				cluster.Spec.NodeSelector["label-3"] = "value-3"
 				err = k8sClient.Update(ctx, cluster)
 				Expect(err).To(Not(HaveOccurred()))
-				Expect(cluster.Spec.NodeSelector).To(Not(Equal(policy.Spec.DefaultNodeSelector)))
 
-				// wait a bit and check it's restored
-				Eventually(func() bool {
+				// the nodeSelector changed by the cluster is not a default anymore, and it's kept
+				Consistently(func() map[string]string {
 					var updatedCluster v1beta1.Cluster
 
 					key := types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}
 					err = k8sClient.Get(ctx, key, &updatedCluster)
 					Expect(err).To(Not(HaveOccurred()))
-					return reflect.DeepEqual(updatedCluster.Spec.NodeSelector, policy.Spec.DefaultNodeSelector)
+					return updatedCluster.Spec.NodeSelector
+				}).
+					WithTimeout(time.Second * 5).
+					WithPolling(time.Second).
+					Should(HaveKeyWithValue("label-3", "value-3"))
+			})
+
+			It("should not override the values set by the Cluster", func() {
+				policy := newPolicy(v1beta1.VirtualClusterPolicySpec{
+					Defaults: &v1beta1.ClusterValues{
+						PriorityClass: "default-priority",
+						Version:       "v1.31.4-k3s1",
+						NodeSelector:  map[string]string{"label-1": "value-1"},
+					},
+				})
+				bindPolicyToNamespace(namespace, policy)
+
+				cluster := &v1beta1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						GenerateName: "cluster-",
+						Namespace:    namespace.Name,
+					},
+					Spec: v1beta1.ClusterSpec{
+						Mode:          v1beta1.SharedClusterMode,
+						Servers:       ptr.To[int32](1),
+						Agents:        ptr.To[int32](0),
+						PriorityClass: "cluster-priority",
+					},
+				}
+
+				err := k8sClient.Create(ctx, cluster)
+				Expect(err).To(Not(HaveOccurred()))
+
+				Eventually(func(g Gomega) {
+					key := types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}
+					err := k8sClient.Get(ctx, key, cluster)
+					g.Expect(err).To(Not(HaveOccurred()))
+
+					g.Expect(cluster.Spec.Version).To(Equal("v1.31.4-k3s1"))
+					g.Expect(cluster.Spec.NodeSelector).To(Equal(map[string]string{"label-1": "value-1"}))
+					g.Expect(cluster.Annotations).To(HaveKey("policy.k3k.io/applied-defaults"))
 				}).
 					WithTimeout(time.Second * 10).
 					WithPolling(time.Second).
-					Should(BeTrue())
+					Should(Succeed())
+
+				Expect(cluster.Spec.PriorityClass).To(Equal("cluster-priority"))
+			})
+
+			It("should enforce the values over the ones of the Cluster", func() {
+				policy := newPolicy(v1beta1.VirtualClusterPolicySpec{
+					Enforced: &v1beta1.ClusterValues{
+						PriorityClass: "enforced-priority",
+						NodeSelector:  map[string]string{"label-1": "value-1"},
+						ServerLimit: v1.ResourceList{
+							v1.ResourceMemory: resource.MustParse("1Gi"),
+						},
+					},
+				})
+				bindPolicyToNamespace(namespace, policy)
+
+				cluster := &v1beta1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						GenerateName: "cluster-",
+						Namespace:    namespace.Name,
+					},
+					Spec: v1beta1.ClusterSpec{
+						Mode:          v1beta1.SharedClusterMode,
+						Servers:       ptr.To[int32](1),
+						Agents:        ptr.To[int32](0),
+						PriorityClass: "cluster-priority",
+						NodeSelector:  map[string]string{"label-1": "other", "label-2": "value-2"},
+						ServerLimit: v1.ResourceList{
+							v1.ResourceCPU: resource.MustParse("1"),
+						},
+					},
+				}
+
+				err := k8sClient.Create(ctx, cluster)
+				Expect(err).To(Not(HaveOccurred()))
+
+				Eventually(func(g Gomega) {
+					key := types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}
+					err := k8sClient.Get(ctx, key, cluster)
+					g.Expect(err).To(Not(HaveOccurred()))
+
+					g.Expect(cluster.Spec.PriorityClass).To(Equal("enforced-priority"))
+					g.Expect(cluster.Spec.NodeSelector).To(Equal(map[string]string{"label-1": "value-1", "label-2": "value-2"}))
+					g.Expect(cluster.Spec.ServerLimit.Cpu().String()).To(Equal("1"))
+					g.Expect(cluster.Spec.ServerLimit.Memory().String()).To(Equal("1Gi"))
+				}).
+					WithTimeout(time.Second * 10).
+					WithPolling(time.Second).
+					Should(Succeed())
 			})
 
 			It("should create a ResourceQuota if Quota is enabled", func() {
@@ -512,6 +704,56 @@ var _ = Describe("VirtualClusterPolicy Controller", Label("controller"), Label("
 					Should(BeTrue())
 			})
 
+			It("should report the bound namespaces and their clusters in the status", func() {
+				clusterPolicy := newPolicy(v1beta1.VirtualClusterPolicySpec{
+					Quota: &v1.ResourceQuotaSpec{
+						Hard: v1.ResourceList{
+							v1.ResourcePods: resource.MustParse("10"),
+						},
+					},
+				})
+
+				bindPolicyToNamespace(namespace, clusterPolicy)
+
+				cluster := &v1beta1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						GenerateName: "cluster-",
+						Namespace:    namespace.Name,
+					},
+					Spec: v1beta1.ClusterSpec{
+						Mode:    v1beta1.SharedClusterMode,
+						Servers: ptr.To[int32](1),
+						Agents:  ptr.To[int32](0),
+					},
+				}
+
+				err := k8sClient.Create(ctx, cluster)
+				Expect(err).To(Not(HaveOccurred()))
+
+				Eventually(func(g Gomega) {
+					err := k8sClient.Get(ctx, client.ObjectKeyFromObject(clusterPolicy), clusterPolicy)
+					g.Expect(err).To(Not(HaveOccurred()))
+
+					g.Expect(clusterPolicy.Status.Namespaces).To(HaveLen(1))
+
+					namespaceStatus := clusterPolicy.Status.Namespaces[0]
+					g.Expect(namespaceStatus.Name).To(Equal(namespace.Name))
+					g.Expect(namespaceStatus.Clusters).To(ConsistOf(cluster.Name))
+					g.Expect(namespaceStatus.NetworkPolicy.Synced).To(BeTrue())
+					g.Expect(namespaceStatus.Quota.Synced).To(BeTrue())
+					g.Expect(namespaceStatus.LimitRange.Synced).To(BeTrue())
+					g.Expect(namespaceStatus.PodSecurityAdmission.Synced).To(BeTrue())
+
+					g.Expect(clusterPolicy.Status.Quota).To(Not(BeNil()))
+					g.Expect(clusterPolicy.Status.ObservedGeneration).To(Equal(clusterPolicy.Generation))
+					g.Expect(clusterPolicy.Status.Summary).To(Equal("1 namespaces, 1 clusters, 0 failed"))
+					g.Expect(meta.IsStatusConditionTrue(clusterPolicy.Status.Conditions, policy.ConditionReady)).To(BeTrue())
+				}).
+					WithTimeout(time.Second * 10).
+					WithPolling(time.Second).
+					Should(Succeed())
+			})
+
 			It("should delete the ResourceQuota if unbound", func() {
 				clusterPolicy := newPolicy(v1beta1.VirtualClusterPolicySpec{
 					Quota: &v1.ResourceQuotaSpec{
@@ -555,6 +797,115 @@ var _ = Describe("VirtualClusterPolicy Controller", Label("controller"), Label("
 					Should(BeTrue())
 			})
 		})
+
+		When("selecting namespaces", func() {
+			var (
+				team      string
+				namespace *v1.Namespace
+			)
+
+			BeforeEach(func() {
+				team = rand.String(8)
+
+				namespace = &v1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						GenerateName: "ns-",
+						Labels:       map[string]string{"team": team},
+					},
+				}
+
+				err := k8sClient.Create(ctx, namespace)
+				Expect(err).To(Not(HaveOccurred()))
+			})
+
+			teamSelector := func() *metav1.LabelSelector {
+				return &metav1.LabelSelector{MatchLabels: map[string]string{"team": team}}
+			}
+
+			boundPolicy := func() string {
+				var ns v1.Namespace
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(namespace), &ns); err != nil {
+					return ""
+				}
+
+				return ns.Labels[policy.PolicyNameLabelKey]
+			}
+
+			It("should bind the matching namespaces", func() {
+				clusterPolicy := newPolicy(v1beta1.VirtualClusterPolicySpec{NamespaceSelector: teamSelector()})
+
+				Eventually(boundPolicy).
+					WithTimeout(time.Second * 10).
+					WithPolling(time.Second).
+					Should(Equal(clusterPolicy.Name))
+
+				// a new namespace of the team is bound too
+				otherNamespace := &v1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						GenerateName: "ns-",
+						Labels:       map[string]string{"team": team},
+					},
+				}
+
+				err := k8sClient.Create(ctx, otherNamespace)
+				Expect(err).To(Not(HaveOccurred()))
+
+				Eventually(func() string {
+					var ns v1.Namespace
+					if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(otherNamespace), &ns); err != nil {
+						return ""
+					}
+
+					return ns.Labels[policy.PolicyNameLabelKey]
+				}).
+					WithTimeout(time.Second * 10).
+					WithPolling(time.Second).
+					Should(Equal(clusterPolicy.Name))
+			})
+
+			It("should bind the policy with the highest priority and report the conflict", func() {
+				lowPolicy := newPolicy(v1beta1.VirtualClusterPolicySpec{NamespaceSelector: teamSelector()})
+				highPolicy := newPolicy(v1beta1.VirtualClusterPolicySpec{NamespaceSelector: teamSelector(), Priority: 10})
+
+				Eventually(boundPolicy).
+					WithTimeout(time.Second * 10).
+					WithPolling(time.Second).
+					Should(Equal(highPolicy.Name))
+
+				Eventually(func() bool {
+					var pol v1beta1.VirtualClusterPolicy
+					if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(lowPolicy), &pol); err != nil {
+						return false
+					}
+
+					return meta.IsStatusConditionTrue(pol.Status.Conditions, policy.ConditionNamespaceConflict)
+				}).
+					WithTimeout(time.Second * 10).
+					WithPolling(time.Second).
+					Should(BeTrue())
+
+				// the namespace is bound to the other policy when the winner is deleted
+				err := k8sClient.Delete(ctx, highPolicy)
+				Expect(err).To(Not(HaveOccurred()))
+
+				Eventually(boundPolicy).
+					WithTimeout(time.Second * 10).
+					WithPolling(time.Second).
+					Should(Equal(lowPolicy.Name))
+			})
+
+			It("should not bind the namespaces labelled by hand", func() {
+				manualPolicy := newPolicy(v1beta1.VirtualClusterPolicySpec{})
+				bindPolicyToNamespace(namespace, manualPolicy)
+
+				newPolicy(v1beta1.VirtualClusterPolicySpec{NamespaceSelector: teamSelector(), Priority: 10})
+
+				Consistently(boundPolicy).
+					WithTimeout(time.Second * 5).
+					WithPolling(time.Second).
+					Should(Equal(manualPolicy.Name))
+			})
+		})
 	})
 })
 
@@ -0,0 +1,251 @@
+package policy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+const (
+	namespaceSelectorControllerName = "k3k-policy-namespace-selector"
+
+	// PolicySelectedAnnotationKey is the annotation of the namespaces bound to a VirtualClusterPolicy by its namespaceSelector.
+	// Their "policy.k3k.io/policy-name" label is managed by the controller.
+	PolicySelectedAnnotationKey = "policy.k3k.io/selected"
+
+	// ConditionNamespaceConflict is the condition of the policies whose namespaceSelector matches namespaces bound to other policies.
+	ConditionNamespaceConflict = "NamespaceConflict"
+
+	// Condition Reasons
+	ReasonNamespacesBound    = "NamespacesBound"
+	ReasonNamespacesConflict = "NamespacesBoundToOtherPolicies"
+)
+
+// namespaceSelectorReconciler binds the namespaces selected by the namespaceSelector of the policies, setting their
+// "policy.k3k.io/policy-name" label. Each namespace is reconciled on its own, so that the binding of a namespace is
+// never updated concurrently, and only the namespaces are reconciled again when a selector changes.
+type namespaceSelectorReconciler struct {
+	Client client.Client
+}
+
+// addNamespaceSelectorController adds the controller binding the namespaces selected by the policies.
+func addNamespaceSelectorController(mgr manager.Manager, maxConcurrentReconciles int) error {
+	reconciler := namespaceSelectorReconciler{
+		Client: mgr.GetClient(),
+	}
+
+	// the namespaces are bound again when the selector or the priority of a policy changes, or when it's deleted
+	policyPredicate := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldPolicy, okOld := e.ObjectOld.(*v1beta1.VirtualClusterPolicy)
+			newPolicy, okNew := e.ObjectNew.(*v1beta1.VirtualClusterPolicy)
+
+			if !okOld || !okNew {
+				return false
+			}
+
+			return !reflect.DeepEqual(oldPolicy.Spec.NamespaceSelector, newPolicy.Spec.NamespaceSelector) ||
+				oldPolicy.Spec.Priority != newPolicy.Spec.Priority ||
+				oldPolicy.DeletionTimestamp.IsZero() != newPolicy.DeletionTimestamp.IsZero()
+		},
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(namespaceSelectorControllerName).
+		For(&v1.Namespace{}, builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Watches(&v1beta1.VirtualClusterPolicy{}, handler.EnqueueRequestsFromMapFunc(reconciler.namespaceRequests), builder.WithPredicates(policyPredicate)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}).
+		Complete(&reconciler)
+}
+
+// namespaceRequests returns the requests for all the namespaces, that could be selected by a changed policy.
+func (r *namespaceSelectorReconciler) namespaceRequests(ctx context.Context, _ client.Object) []reconcile.Request {
+	var namespaces v1.NamespaceList
+	if err := r.Client.List(ctx, &namespaces); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: ns.Name}})
+	}
+
+	return requests
+}
+
+// Reconcile binds the namespace to the policy with the highest priority selecting it. The namespaces labelled by hand,
+// and the dedicated host namespaces of the clusters, are not selected. The selected namespaces not matching any policy
+// anymore are unbound.
+func (r *namespaceSelectorReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	var ns v1.Namespace
+	if err := r.Client.Get(ctx, req.NamespacedName, &ns); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !ns.DeletionTimestamp.IsZero() || ns.Labels[translate.ClusterNamespaceLabel] != "" {
+		return reconcile.Result{}, nil
+	}
+
+	_, selected := ns.Annotations[PolicySelectedAnnotationKey]
+	if ns.Labels[PolicyNameLabelKey] != "" && !selected {
+		return reconcile.Result{}, nil
+	}
+
+	var policies v1beta1.VirtualClusterPolicyList
+	if err := r.Client.List(ctx, &policies); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	orig := ns.DeepCopy()
+
+	if matching := selectingPolicies(&ns, policies.Items); len(matching) > 0 {
+		if ns.Labels == nil {
+			ns.Labels = map[string]string{}
+		}
+
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+
+		ns.Labels[PolicyNameLabelKey] = matching[0]
+		ns.Annotations[PolicySelectedAnnotationKey] = "true"
+	} else {
+		delete(ns.Labels, PolicyNameLabelKey)
+		delete(ns.Annotations, PolicySelectedAnnotationKey)
+	}
+
+	if reflect.DeepEqual(orig, &ns) {
+		return reconcile.Result{}, nil
+	}
+
+	log.Info("Updating the policy of the Namespace", "policy", ns.Labels[PolicyNameLabelKey])
+
+	return reconcile.Result{}, r.Client.Update(ctx, &ns)
+}
+
+// reconcileNamespaceConflicts reports in the status of the policy the namespaces matching its namespaceSelector,
+// but bound to another policy.
+func (c *VirtualClusterPolicyReconciler) reconcileNamespaceConflicts(ctx context.Context, policy *v1beta1.VirtualClusterPolicy) error {
+	if policy.Spec.NamespaceSelector == nil {
+		meta.RemoveStatusCondition(&policy.Status.Conditions, ConditionNamespaceConflict)
+		return nil
+	}
+
+	var namespaces v1.NamespaceList
+	if err := c.Client.List(ctx, &namespaces); err != nil {
+		return err
+	}
+
+	var conflicts []string
+
+	for _, ns := range namespaces.Items {
+		if ns.Labels[translate.ClusterNamespaceLabel] != "" || !selectsNamespace(policy, &ns) {
+			continue
+		}
+
+		if boundPolicy := ns.Labels[PolicyNameLabelKey]; boundPolicy != policy.Name {
+			conflicts = append(conflicts, fmt.Sprintf("%s (%s)", ns.Name, boundPolicy))
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               ConditionNamespaceConflict,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonNamespacesBound,
+		Message:            "All the selected namespaces are bound to the policy",
+		ObservedGeneration: policy.Generation,
+	}
+
+	if len(conflicts) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = ReasonNamespacesConflict
+		condition.Message = "Selected namespaces bound to other policies: " + strings.Join(conflicts, ", ")
+	}
+
+	meta.SetStatusCondition(&policy.Status.Conditions, condition)
+
+	return nil
+}
+
+// selectingPolicies returns the names of the policies selecting a namespace, sorted by decreasing priority and name.
+func selectingPolicies(ns *v1.Namespace, policies []v1beta1.VirtualClusterPolicy) []string {
+	var matching []*v1beta1.VirtualClusterPolicy
+
+	for i := range policies {
+		if policies[i].DeletionTimestamp.IsZero() && selectsNamespace(&policies[i], ns) {
+			matching = append(matching, &policies[i])
+		}
+	}
+
+	slices.SortFunc(matching, func(a, b *v1beta1.VirtualClusterPolicy) int {
+		return cmp.Or(cmp.Compare(b.Spec.Priority, a.Spec.Priority), cmp.Compare(a.Name, b.Name))
+	})
+
+	names := make([]string, 0, len(matching))
+	for _, policy := range matching {
+		names = append(names, policy.Name)
+	}
+
+	return names
+}
+
+// selectsNamespace returns true if the namespaceSelector of the policy matches the labels of the namespace,
+// other than the policy label.
+func selectsNamespace(policy *v1beta1.VirtualClusterPolicy, ns *v1.Namespace) bool {
+	if policy.Spec.NamespaceSelector == nil {
+		return false
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(policy.Spec.NamespaceSelector)
+	if err != nil {
+		return false
+	}
+
+	nsLabels := maps.Clone(ns.Labels)
+	delete(nsLabels, PolicyNameLabelKey)
+
+	return selector.Matches(labels.Set(nsLabels))
+}
+
+// selectorPolicies returns the names of the policies whose namespaceSelector matches any of the namespaces.
+func (c *VirtualClusterPolicyReconciler) selectorPolicies(ctx context.Context, namespaces ...*v1.Namespace) []string {
+	var policies v1beta1.VirtualClusterPolicyList
+	if err := c.Client.List(ctx, &policies); err != nil {
+		return nil
+	}
+
+	var names []string
+
+	for _, policy := range policies.Items {
+		if slices.ContainsFunc(namespaces, func(ns *v1.Namespace) bool { return selectsNamespace(&policy, ns) }) {
+			names = append(names, policy.Name)
+		}
+	}
+
+	return names
+}
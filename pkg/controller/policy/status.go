@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	quota "k8s.io/apiserver/pkg/quota/v1"
+
+	"github.com/rancher/k3k/k3k-kubelet/translate"
+	"github.com/rancher/k3k/pkg/apis/k3k.io/v1beta1"
+)
+
+const (
+	// ConditionReady is the condition of the policies whose resources are reconciled in all the bound namespaces.
+	ConditionReady = "Ready"
+
+	// Condition Reasons
+	ReasonNamespacesSynced     = "NamespacesSynced"
+	ReasonNamespacesSyncFailed = "NamespacesSyncFailed"
+
+	// quotaStatusRefreshPeriod is the maximum time between the updates of the quota usage in the status of a policy.
+	quotaStatusRefreshPeriod = time.Minute
+)
+
+// reconcileQuotaStatus reports in the status of the policy the sum of the hard limits and of the usage
+// of its ResourceQuotas in the bound namespaces, and the usage of the pods in the dedicated host namespaces
+// of the clusters, counted in the quota of the namespace of their cluster.
+func (c *VirtualClusterPolicyReconciler) reconcileQuotaStatus(ctx context.Context, policy *v1beta1.VirtualClusterPolicy) error {
+	if policy.Spec.Quota == nil {
+		policy.Status.Quota = nil
+		return nil
+	}
+
+	var resourceQuotas v1.ResourceQuotaList
+	if err := c.Client.List(ctx, &resourceQuotas, client.MatchingLabels{
+		ManagedByLabelKey:  VirtualPolicyControllerName,
+		PolicyNameLabelKey: policy.Name,
+	}); err != nil {
+		return err
+	}
+
+	status := &v1.ResourceQuotaStatus{
+		Hard: v1.ResourceList{},
+		Used: v1.ResourceList{},
+	}
+
+	for _, resourceQuota := range resourceQuotas.Items {
+		// the ResourceQuotas of the unbound namespaces are being deleted
+		if !slices.ContainsFunc(policy.Status.Namespaces, func(ns v1beta1.PolicyNamespaceStatus) bool {
+			return ns.Name == resourceQuota.Namespace
+		}) {
+			continue
+		}
+
+		status.Hard = quota.Add(status.Hard, resourceQuota.Status.Hard)
+		status.Used = quota.Add(status.Used, resourceQuota.Status.Used)
+	}
+
+	var namespaces v1.NamespaceList
+	if err := c.Client.List(ctx, &namespaces, client.MatchingLabels{PolicyNameLabelKey: policy.Name}, client.HasLabels{translate.ClusterNamespaceLabel}); err != nil {
+		return err
+	}
+
+	for _, ns := range namespaces.Items {
+		var podList v1.PodList
+		if err := c.Client.List(ctx, &podList, client.InNamespace(ns.Name)); err != nil {
+			return err
+		}
+
+		pods := make([]*v1.Pod, 0, len(podList.Items))
+		for i := range podList.Items {
+			pods = append(pods, &podList.Items[i])
+		}
+
+		status.Used = quota.Add(status.Used, quota.Mask(PodsQuotaUsage(pods...), quota.ResourceNames(status.Hard)))
+	}
+
+	policy.Status.Quota = status
+
+	return nil
+}
+
+// quotaStatusRequeueAfter returns the time after which the policy has to be reconciled to refresh the usage of its
+// quota, or zero if the policy has no quota.
+func quotaStatusRequeueAfter(policy *v1beta1.VirtualClusterPolicy) time.Duration {
+	if policy.Spec.Quota == nil {
+		return 0
+	}
+
+	return quotaStatusRefreshPeriod
+}
+
+// updatePolicySummary sets the summary and the Ready condition of the policy from the status of its namespaces.
+func updatePolicySummary(policy *v1beta1.VirtualClusterPolicy) {
+	var (
+		clusters int
+		failed   []string
+	)
+
+	for _, ns := range policy.Status.Namespaces {
+		clusters += len(ns.Clusters)
+
+		if !ns.NetworkPolicy.Synced || !ns.Quota.Synced || !ns.LimitRange.Synced || !ns.PodSecurityAdmission.Synced {
+			failed = append(failed, ns.Name)
+		}
+	}
+
+	policy.Status.Summary = fmt.Sprintf("%d namespaces, %d clusters, %d failed", len(policy.Status.Namespaces), clusters, len(failed))
+
+	condition := metav1.Condition{
+		Type:               ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonNamespacesSynced,
+		Message:            "The policy is applied to all the bound namespaces",
+		ObservedGeneration: policy.Generation,
+	}
+
+	if len(failed) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ReasonNamespacesSyncFailed
+		condition.Message = "Failed to apply the policy to the namespaces: " + strings.Join(failed, ", ")
+	}
+
+	meta.SetStatusCondition(&policy.Status.Conditions, condition)
+}